@@ -0,0 +1,256 @@
+package commands
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/DNSControl/dnscontrol/v4/models"
+	"github.com/DNSControl/dnscontrol/v4/pkg/credsfile"
+	"github.com/DNSControl/dnscontrol/v4/pkg/dnsseccheck"
+	"github.com/DNSControl/dnscontrol/v4/pkg/nameservers"
+	"github.com/DNSControl/dnscontrol/v4/pkg/normalize"
+	"github.com/DNSControl/dnscontrol/v4/pkg/printer"
+	"github.com/nozzle/throttler"
+	"github.com/urfave/cli/v3"
+)
+
+// Exit codes of the check-dnssec command.
+const (
+	checkDNSSECExitOK       = 0 // No errors (there may be warnings).
+	checkDNSSECExitFound    = 2 // At least one zone has an error.
+	checkDNSSECExitErrored  = 3 // At least one zone could not be checked.
+	checkDNSSECExitWarnings = 4 // At least one zone has a warning (with --strict).
+)
+
+var _ = cmd(catUtils, func() *cli.Command {
+	var args CheckDNSSECArgs
+	return &cli.Command{
+		Name:  "check-dnssec",
+		Usage: "check that the DS records at the parent match the keys of each zone",
+		Action: func(ctx context.Context, c *cli.Command) error {
+			code, err := CheckDNSSEC(args)
+			if err != nil {
+				return exit(err)
+			}
+			if code != checkDNSSECExitOK {
+				return cli.Exit("", code)
+			}
+			return nil
+		},
+		Flags: args.flags(),
+		Description: `For each zone, compare the DS records at the parent (usually set at the
+registrar) to the DNSKEY records served by the nameservers of each DNS
+provider. Report DS records that match no key, keys or digests that use
+deprecated algorithms, and signatures that are invalid, expired or about to
+expire. Nothing is changed.
+
+EXIT CODES:
+   0   No errors.
+   1   dnscontrol could not run (bad configuration, credentials, etc.).
+   2   At least one zone has an error: validating resolvers can't resolve it.
+   3   At least one zone could not be checked.
+   4   At least one zone has a warning (only with --strict).
+
+EXAMPLES:
+   dnscontrol check-dnssec
+   dnscontrol check-dnssec --domains example.com --warn-days 14
+
+Documentation: https://docs.dnscontrol.org/commands/check-dnssec`,
+	}
+}())
+
+// CheckDNSSECArgs contains all data/flags needed to run check-dnssec, independently of CLI.
+type CheckDNSSECArgs struct {
+	GetDNSConfigArgs
+	GetCredentialsArgs
+	FilterArgs
+	Resolver  string
+	WarnDays  int
+	Strict    bool
+	ConcurMax int
+}
+
+func (args *CheckDNSSECArgs) flags() []cli.Flag {
+	flags := args.GetDNSConfigArgs.flags()
+	flags = append(flags, args.GetCredentialsArgs.flags()...)
+	flags = append(flags, args.FilterArgs.flags()...)
+	flags = append(flags, &cli.StringFlag{
+		Name:        "resolver",
+		Destination: &args.Resolver,
+		Usage:       `Recursive resolver to query for the DS records (host or host:port). Default: the first nameserver in /etc/resolv.conf`,
+	})
+	flags = append(flags, &cli.IntFlag{
+		Name:        "warn-days",
+		Destination: &args.WarnDays,
+		Value:       7,
+		Usage:       `Warn about signatures that expire within this many days`,
+		Action: func(ctx context.Context, c *cli.Command, v int) error {
+			if v < 0 {
+				fmt.Printf("%d is not a valid value for --warn-days.  Values must be 0 or greater\n", v)
+				os.Exit(1)
+			}
+			return nil
+		},
+	})
+	flags = append(flags, &cli.BoolFlag{
+		Name:        "strict",
+		Destination: &args.Strict,
+		Usage:       `Exit with code 4 if there are warnings`,
+	})
+	flags = append(flags, &cli.IntFlag{
+		Name:        "cmax",
+		Destination: &args.ConcurMax,
+		Value:       10,
+		Usage:       `Maximum number of zones checked at once`,
+		Action: func(ctx context.Context, c *cli.Command, v int) error {
+			if v < 1 {
+				fmt.Printf("%d is not a valid value for --cmax.  Values must be 1 or greater\n", v)
+				os.Exit(1)
+			}
+			return nil
+		},
+	})
+	return flags
+}
+
+// dnssecResult is the result of checking a zone at a DNS provider.
+type dnssecResult struct {
+	zone       *models.DomainConfig
+	provider   string
+	nameserver string
+	findings   []dnsseccheck.Finding
+	err        error
+}
+
+// CheckDNSSEC implements the check-dnssec subcommand. It returns the exit
+// code, or an error if nothing could be checked at all.
+func CheckDNSSEC(args CheckDNSSECArgs) (int, error) {
+	resolver := args.Resolver
+	if resolver == "" {
+		var err error
+		if resolver, err = dnsseccheck.DefaultResolver(); err != nil {
+			return 0, err
+		}
+	} else if _, _, err := net.SplitHostPort(resolver); err != nil {
+		resolver = net.JoinHostPort(resolver, "53")
+	}
+
+	cfg, err := GetDNSConfig(args.GetDNSConfigArgs)
+	if err != nil {
+		return 0, err
+	}
+	providerConfigs, err := credsfile.LoadProviderConfigs(args.CredsFile)
+	if err != nil {
+		return 0, err
+	}
+	if _, err := PInitializeProviders(cfg, providerConfigs, false); err != nil {
+		return 0, err
+	}
+	errs := normalize.ValidateAndNormalizeConfig(cfg)
+	if PrintValidationErrors(errs) {
+		return 0, errors.New("exiting due to validation errors")
+	}
+
+	zones := whichZonesToProcess(cfg.Domains, args.Domains)
+	zonesSerial, zonesConcurrent := splitConcurrent(zones, "concurrent")
+	results := make([][]*dnssecResult, len(zones))
+	index := map[*models.DomainConfig]int{}
+	for i, zone := range zones {
+		index[zone] = i
+	}
+	warnBefore := time.Duration(args.WarnDays) * 24 * time.Hour
+	t := throttler.New(args.ConcurMax, len(zonesConcurrent))
+	for i, zone := range zonesConcurrent {
+		go func(zone *models.DomainConfig) {
+			results[index[zone]] = checkZoneDNSSEC(zone, args.Providers, resolver, warnBefore)
+			t.Done(nil)
+		}(zone)
+		// Delay the last call to t.Throttle() until the serial processing is done.
+		if i != ultimate(zonesConcurrent) {
+			t.Throttle()
+		}
+	}
+	for _, zone := range zonesSerial {
+		results[index[zone]] = checkZoneDNSSEC(zone, args.Providers, resolver, warnBefore)
+	}
+	if len(zonesConcurrent) > 0 {
+		t.Throttle()
+	}
+
+	code := checkDNSSECExitOK
+	for _, zrs := range results {
+		for _, r := range zrs {
+			if r.err != nil {
+				printer.Printf("%s (%s): ERROR: could not check: %s\n", r.zone.DisplayName, r.provider, r.err)
+				code = checkDNSSECExitErrored
+				continue
+			}
+			printer.Printf("%s (%s, %s):\n", r.zone.DisplayName, r.provider, strings.TrimSuffix(r.nameserver, ":53"))
+			for _, f := range r.findings {
+				printer.Printf("    %s\n", f)
+			}
+			switch worst := dnsseccheck.Worst(r.findings); {
+			case code == checkDNSSECExitErrored:
+			case worst == dnsseccheck.Error:
+				code = checkDNSSECExitFound
+			case worst == dnsseccheck.Warning && args.Strict && code == checkDNSSECExitOK:
+				code = checkDNSSECExitWarnings
+			}
+		}
+	}
+	return code, nil
+}
+
+// checkZoneDNSSEC checks the zone at each of its (selected) providers.
+func checkZoneDNSSEC(zone *models.DomainConfig, providerFilter, resolver string, warnBefore time.Duration) []*dnssecResult {
+	var results []*dnssecResult
+	for _, provider := range whichProvidersToProcess(zone.DNSProviderInstances, providerFilter) {
+		r := &dnssecResult{zone: zone, provider: provider.Name}
+		results = append(results, r)
+
+		// The provider's nameservers, or the zone's if the provider has none.
+		var addrs []string
+		nss, err := nameservers.DetermineNameserversForProviders(zone, []*models.DNSProviderInstance{provider}, true)
+		if err != nil {
+			r.err = err
+			continue
+		}
+		for _, ns := range nss {
+			addrs = append(addrs, net.JoinHostPort(ns.Name, "53"))
+		}
+		if len(addrs) == 0 {
+			if addrs, r.err = dnsseccheck.LookupNS(zone.Name, resolver); r.err != nil {
+				continue
+			}
+		}
+		if len(addrs) == 0 {
+			r.err = errors.New("no nameservers")
+			continue
+		}
+
+		// The first nameserver that answers.
+		var z *dnsseccheck.Zone
+		for _, addr := range addrs {
+			if z, r.err = dnsseccheck.Fetch(zone.Name, resolver, addr); r.err == nil {
+				r.nameserver = addr
+				break
+			}
+		}
+		if r.err != nil {
+			continue
+		}
+		r.findings = dnsseccheck.Check(z, time.Now(), warnBefore)
+		if zone.AutoDNSSEC == "on" && len(z.DNSKEY) == 0 {
+			r.findings = append(r.findings, dnsseccheck.Finding{
+				Severity: dnsseccheck.Warning,
+				Msg:      "AUTODNSSEC_ON is set, but the zone has no DNSKEY",
+			})
+		}
+	}
+	return results
+}
@@ -0,0 +1,116 @@
+package commands
+
+import (
+	"context"
+	"errors"
+
+	"github.com/DNSControl/dnscontrol/v4/pkg/emailcheck"
+	"github.com/DNSControl/dnscontrol/v4/pkg/normalize"
+	"github.com/DNSControl/dnscontrol/v4/pkg/printer"
+	"github.com/DNSControl/dnscontrol/v4/pkg/spflib"
+	"github.com/urfave/cli/v3"
+)
+
+// Exit codes of the check-email command. They mean the same as those of
+// check-dnssec.
+const (
+	checkEmailExitOK       = 0 // No errors (there may be warnings).
+	checkEmailExitFound    = 2 // At least one zone has an error.
+	checkEmailExitWarnings = 4 // At least one zone has a warning (with --strict).
+)
+
+var _ = cmd(catUtils, func() *cli.Command {
+	var args CheckEmailArgs
+	return &cli.Command{
+		Name:  "check-email",
+		Usage: "audit the SPF, DMARC and DKIM records of each zone",
+		Action: func(ctx context.Context, c *cli.Command) error {
+			code, err := CheckEmail(args)
+			if err != nil {
+				return exit(err)
+			}
+			if code != checkEmailExitOK {
+				return cli.Exit("", code)
+			}
+			return nil
+		},
+		Flags: args.flags(),
+		Description: `For each zone of dnsconfig.js, check the email-authentication records:
+SPF records that are missing (next to MX records), duplicated, invalid or
+that need more than 10 DNS lookups; DMARC records that are missing or
+malformed, or that send reports to other domains that don't accept them;
+DKIM keys that are malformed or shorter than 1024 bits; null MX records
+without "v=spf1 -all". The records of the zones of dnsconfig.js are taken
+from dnsconfig.js; the other names (the includes of third-party senders,
+etc.) are looked up in DNS. Nothing is changed.
+
+EXIT CODES:
+   0   No errors.
+   1   dnscontrol could not run (bad configuration, etc.).
+   2   At least one zone has an error: receivers ignore a record, or reject mail.
+   4   At least one zone has a warning (only with --strict).
+
+EXAMPLES:
+   dnscontrol check-email
+   dnscontrol check-email --domains example.com --strict
+
+Documentation: https://docs.dnscontrol.org/commands/check-email`,
+	}
+}())
+
+// CheckEmailArgs contains all data/flags needed to run check-email, independently of CLI.
+type CheckEmailArgs struct {
+	GetDNSConfigArgs
+	Domains string
+	Strict  bool
+}
+
+func (args *CheckEmailArgs) flags() []cli.Flag {
+	flags := args.GetDNSConfigArgs.flags()
+	flags = append(flags, &cli.StringFlag{
+		Name:        "domains",
+		Destination: &args.Domains,
+		Usage:       `Comma separated list of domain names to include`,
+	})
+	flags = append(flags, &cli.BoolFlag{
+		Name:        "strict",
+		Destination: &args.Strict,
+		Usage:       `Exit with code 4 if there are warnings`,
+	})
+	return flags
+}
+
+// CheckEmail implements the check-email subcommand. It returns the exit
+// code, or an error if nothing could be checked.
+func CheckEmail(args CheckEmailArgs) (int, error) {
+	cfg, err := GetDNSConfig(args.GetDNSConfigArgs)
+	if err != nil {
+		return 0, err
+	}
+	errs := normalize.ValidateAndNormalizeConfig(cfg)
+	if PrintValidationErrors(errs) {
+		return 0, errors.New("exiting due to validation errors")
+	}
+
+	resolvers := map[string]*configResolver{} // By split horizon tag.
+	code := checkEmailExitOK
+	for _, zone := range whichZonesToProcess(cfg.Domains, args.Domains) {
+		resolver, ok := resolvers[zone.Tag]
+		if !ok {
+			resolver = newConfigResolver(cfg, zone.Tag, spflib.LiveResolver{})
+			resolvers[zone.Tag] = resolver
+		}
+		findings := emailcheck.Check(zone, resolver)
+		printer.Printf("%s:\n", zone.DisplayName)
+		for _, f := range findings {
+			printer.Printf("    %s\n", f)
+		}
+		switch worst := emailcheck.Worst(findings); {
+		case worst == emailcheck.Error:
+			code = checkEmailExitFound
+		case worst == emailcheck.Warning && args.Strict && code == checkEmailExitOK:
+			code = checkEmailExitWarnings
+		}
+	}
+	return code, nil
+}
@@ -0,0 +1,289 @@
+package commands
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/netip"
+	"slices"
+	"strings"
+
+	"github.com/DNSControl/dnscontrol/v4/models"
+	"github.com/DNSControl/dnscontrol/v4/pkg/normalize"
+	"github.com/DNSControl/dnscontrol/v4/pkg/printer"
+	"github.com/DNSControl/dnscontrol/v4/pkg/spflib"
+	dnsv1 "github.com/miekg/dns"
+	"github.com/urfave/cli/v3"
+)
+
+// Exit codes of the check-spf command.
+const (
+	checkSPFExitPass    = 0 // The result is pass.
+	checkSPFExitNotPass = 2 // The result is none, neutral, fail or softfail.
+	checkSPFExitError   = 3 // The result is temperror or permerror.
+)
+
+// maxCNAMEChain is how many CNAMEs the config resolver follows.
+const maxCNAMEChain = 8
+
+var _ = cmd(catUtils, func() *cli.Command {
+	var args CheckSPFArgs
+	return &cli.Command{
+		Name:  "check-spf",
+		Usage: "check whether an IP address may send mail for a domain, according to the SPF records of dnsconfig.js",
+		Action: func(ctx context.Context, c *cli.Command) error {
+			code, err := CheckSPF(args)
+			if err != nil {
+				return exit(err)
+			}
+			if code != checkSPFExitPass {
+				return cli.Exit("", code)
+			}
+			return nil
+		},
+		Flags: args.flags(),
+		Description: `Evaluate the SPF record of the domain as a receiving mail server would
+(check_host() of RFC 7208), before the changes are pushed. The records of
+the zones of dnsconfig.js are taken from dnsconfig.js; the other names
+(the includes of third-party senders, etc.) are looked up in DNS. Nothing
+is changed.
+
+EXIT CODES:
+   0   pass.
+   1   dnscontrol could not run (bad configuration, etc.).
+   2   none, neutral, fail or softfail.
+   3   temperror or permerror.
+
+EXAMPLES:
+   dnscontrol check-spf --ip 192.0.2.1 --domain example.com
+   dnscontrol check-spf --ip 2001:db8::1 --domain example.com --sender bounces@example.com
+
+Documentation: https://docs.dnscontrol.org/commands/check-spf`,
+	}
+}())
+
+// CheckSPFArgs contains all data/flags needed to run check-spf, independently of CLI.
+type CheckSPFArgs struct {
+	GetDNSConfigArgs
+	IP     string
+	Domain string
+	Sender string
+	HELO   string
+	Tag    string
+}
+
+func (args *CheckSPFArgs) flags() []cli.Flag {
+	flags := args.GetDNSConfigArgs.flags()
+	flags = append(flags, &cli.StringFlag{
+		Name:        "ip",
+		Destination: &args.IP,
+		Required:    true,
+		Usage:       `IP address of the sending mail server`,
+	})
+	flags = append(flags, &cli.StringFlag{
+		Name:        "domain",
+		Destination: &args.Domain,
+		Required:    true,
+		Usage:       `Domain of the MAIL FROM (or HELO) identity`,
+	})
+	flags = append(flags, &cli.StringFlag{
+		Name:        "sender",
+		Destination: &args.Sender,
+		Usage:       `MAIL FROM address, for the macros. Default: postmaster@ the domain`,
+	})
+	flags = append(flags, &cli.StringFlag{
+		Name:        "helo",
+		Destination: &args.HELO,
+		Usage:       `HELO/EHLO domain, for the %{h} macro. Default: the domain of the sender`,
+	})
+	flags = append(flags, &cli.StringFlag{
+		Name:        "tag",
+		Destination: &args.Tag,
+		Usage:       `Use the split horizon view with this tag (D("example.com!tag", ...)). Default: the zones without a tag`,
+	})
+	return flags
+}
+
+// CheckSPF implements the check-spf subcommand. It returns the exit code, or
+// an error if nothing could be checked.
+func CheckSPF(args CheckSPFArgs) (int, error) {
+	ip, err := netip.ParseAddr(args.IP)
+	if err != nil {
+		return 0, fmt.Errorf("--ip: %w", err)
+	}
+
+	cfg, err := GetDNSConfig(args.GetDNSConfigArgs)
+	if err != nil {
+		return 0, err
+	}
+	errs := normalize.ValidateAndNormalizeConfig(cfg)
+	if PrintValidationErrors(errs) {
+		return 0, errors.New("exiting due to validation errors")
+	}
+
+	checker := &spflib.Checker{Resolver: newConfigResolver(cfg, args.Tag, spflib.LiveResolver{}), HELO: args.HELO}
+	res, err := checker.CheckHost(ip, args.Domain, args.Sender)
+
+	printer.Printf("%s: %s\n", res.Result, describeSPFResult(ip, args.Domain, res.Result))
+	if res.Mechanism != "" {
+		printer.Printf("    mechanism: %s (in the SPF record of %s)\n", res.Mechanism, res.Domain)
+	}
+	if err != nil {
+		printer.Printf("    error: %s\n", err)
+	}
+	printer.Printf("    DNS lookups: %d (of %d)\n", res.Lookups, spflib.MaxLookups)
+
+	switch res.Result {
+	case spflib.ResultPass:
+		return checkSPFExitPass, nil
+	case spflib.ResultTempError, spflib.ResultPermError:
+		return checkSPFExitError, nil
+	default:
+		return checkSPFExitNotPass, nil
+	}
+}
+
+// describeSPFResult explains an SPF result.
+func describeSPFResult(ip netip.Addr, domain string, result spflib.Result) string {
+	switch result {
+	case spflib.ResultPass:
+		return fmt.Sprintf("%s may send mail for %s", ip, domain)
+	case spflib.ResultFail:
+		return fmt.Sprintf("%s may not send mail for %s", ip, domain)
+	case spflib.ResultSoftFail:
+		return fmt.Sprintf("%s is probably not allowed to send mail for %s", ip, domain)
+	case spflib.ResultNeutral:
+		return fmt.Sprintf("%s makes no assertion about %s", domain, ip)
+	case spflib.ResultNone:
+		return fmt.Sprintf("%s has no SPF record", domain)
+	case spflib.ResultTempError:
+		return "a DNS lookup failed"
+	default:
+		return "the SPF records are invalid"
+	}
+}
+
+// configResolver answers from the records of the zones of dnsconfig.js, and
+// looks the other names up with live.
+type configResolver struct {
+	zones   []string                  // The names of the zones, in lower case.
+	records map[string]models.Records // By FQDN, in lower case.
+	live    spflib.HostResolver
+}
+
+// newConfigResolver returns a configResolver for the split horizon view tag:
+// the zones with that tag, and the zones without a tag that the view
+// doesn't have. The views of a zone are never merged.
+func newConfigResolver(cfg *models.DNSConfig, tag string, live spflib.HostResolver) *configResolver {
+	r := &configResolver{records: map[string]models.Records{}, live: live}
+	inView := map[string]bool{}
+	for _, dc := range cfg.Domains {
+		if dc.Tag == tag {
+			inView[dc.Name] = true
+		}
+	}
+	for _, dc := range cfg.Domains {
+		if dc.Tag != tag && (dc.Tag != "" || inView[dc.Name]) {
+			continue
+		}
+		r.zones = append(r.zones, strings.ToLower(dc.Name))
+		for _, rc := range dc.Records {
+			name := strings.ToLower(rc.GetLabelFQDN())
+			r.records[name] = append(r.records[name], rc)
+		}
+	}
+	return r
+}
+
+// inConfig returns true if name is in one of the zones of dnsconfig.js.
+func (r *configResolver) inConfig(name string) bool {
+	return slices.ContainsFunc(r.zones, func(zone string) bool {
+		return name == zone || strings.HasSuffix(name, "."+zone)
+	})
+}
+
+// lookup returns the records of type rtype named name, following CNAMEs.
+// ok is false if name isn't in dnsconfig.js; the name to look up in DNS is
+// returned instead (the target of a CNAME).
+func (r *configResolver) lookup(name, rtype string) (recs models.Records, live string, ok bool) {
+	name = strings.ToLower(strings.TrimSuffix(name, "."))
+	for range maxCNAMEChain {
+		if !r.inConfig(name) {
+			return nil, name, false
+		}
+		var cname string
+		for _, rc := range r.records[name] {
+			switch rc.Type {
+			case rtype:
+				recs = append(recs, rc)
+			case "CNAME":
+				cname = strings.ToLower(strings.TrimSuffix(rc.GetTargetField(), "."))
+			}
+		}
+		if len(recs) > 0 || cname == "" {
+			return recs, "", true
+		}
+		name = cname
+	}
+	return nil, "", true
+}
+
+func (r *configResolver) LookupTXT(name string) ([]string, error) {
+	recs, live, ok := r.lookup(name, "TXT")
+	if !ok {
+		return r.live.LookupTXT(live)
+	}
+	var txts []string
+	for _, rc := range recs {
+		txts = append(txts, rc.GetTargetTXTJoined())
+	}
+	return txts, nil
+}
+
+func (r *configResolver) LookupIP(network, name string) ([]netip.Addr, error) {
+	rtype := "AAAA"
+	if network == "ip4" {
+		rtype = "A"
+	}
+	recs, live, ok := r.lookup(name, rtype)
+	if !ok {
+		return r.live.LookupIP(network, live)
+	}
+	var addrs []netip.Addr
+	for _, rc := range recs {
+		addrs = append(addrs, rc.GetTargetIP())
+	}
+	return addrs, nil
+}
+
+func (r *configResolver) LookupMX(name string) ([]string, error) {
+	recs, live, ok := r.lookup(name, "MX")
+	if !ok {
+		return r.live.LookupMX(live)
+	}
+	recs = slices.Clone(recs)
+	slices.SortStableFunc(recs, func(a, b *models.RecordConfig) int {
+		return int(a.MxPreference) - int(b.MxPreference)
+	})
+	var hosts []string
+	for _, rc := range recs {
+		hosts = append(hosts, strings.TrimSuffix(rc.GetTargetField(), "."))
+	}
+	return hosts, nil
+}
+
+func (r *configResolver) LookupAddr(addr netip.Addr) ([]string, error) {
+	reverse, err := dnsv1.ReverseAddr(addr.String())
+	if err != nil {
+		return nil, err
+	}
+	recs, _, ok := r.lookup(reverse, "PTR")
+	if !ok {
+		return r.live.LookupAddr(addr)
+	}
+	var names []string
+	for _, rc := range recs {
+		names = append(names, rc.GetTargetField())
+	}
+	return names, nil
+}
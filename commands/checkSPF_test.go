@@ -0,0 +1,99 @@
+package commands
+
+import (
+	"net/netip"
+	"testing"
+
+	"github.com/DNSControl/dnscontrol/v4/models"
+	"github.com/DNSControl/dnscontrol/v4/pkg/spflib"
+)
+
+// liveSPFResolver is the DNS outside of dnsconfig.js.
+type liveSPFResolver map[string][]string
+
+func (r liveSPFResolver) LookupTXT(name string) ([]string, error) { return r[name], nil }
+func (r liveSPFResolver) LookupMX(name string) ([]string, error)  { return nil, nil }
+func (r liveSPFResolver) LookupAddr(netip.Addr) ([]string, error) { return nil, nil }
+func (r liveSPFResolver) LookupIP(network, name string) ([]netip.Addr, error) {
+	return nil, nil
+}
+
+func Test_CheckSPFConfigResolver(t *testing.T) {
+	dc := models.MustNewDomainConfig("example.com")
+	dc.Records = models.Records{
+		makePlanRec("@", "TXT", "v=spf1 mx include:_spf.example.net -all"),
+		makePlanRec("@", "MX", "20 backup.example.com."),
+		makePlanRec("@", "MX", "10 mail.example.com."),
+		makePlanRec("mail", "CNAME", "host.example.com."),
+		makePlanRec("host", "A", "192.0.2.10"),
+		makePlanRec("backup", "A", "192.0.2.20"),
+		// Not in DNS yet: the config wins.
+		makePlanRec("new", "TXT", "v=spf1 ip4:198.51.100.0/24 -all"),
+	}
+	live := liveSPFResolver{
+		"_spf.example.net": {"v=spf1 ip4:203.0.113.0/24 -all"},
+		"new.example.com":  {"v=spf1 -all"},
+	}
+	r := newConfigResolver(&models.DNSConfig{Domains: []*models.DomainConfig{dc}}, "", live)
+
+	if hosts, _ := r.LookupMX("example.com."); len(hosts) != 2 || hosts[0] != "mail.example.com" {
+		t.Errorf("LookupMX() = %v, want mail.example.com first", hosts)
+	}
+	if addrs, _ := r.LookupIP("ip4", "mail.example.com"); len(addrs) != 1 || addrs[0] != netip.MustParseAddr("192.0.2.10") {
+		t.Errorf("LookupIP() = %v, want the address of the CNAME target", addrs)
+	}
+	if txts, _ := r.LookupTXT("nothing.example.com"); len(txts) != 0 {
+		t.Errorf("LookupTXT() = %v, want nothing", txts)
+	}
+
+	for _, tt := range []struct {
+		ip, domain string
+		want       spflib.Result
+	}{
+		{"192.0.2.10", "example.com", spflib.ResultPass},
+		{"192.0.2.20", "example.com", spflib.ResultPass},
+		{"203.0.113.5", "example.com", spflib.ResultPass},
+		{"198.51.100.5", "example.com", spflib.ResultFail},
+		{"198.51.100.5", "new.example.com", spflib.ResultPass},
+	} {
+		c := &spflib.Checker{Resolver: r}
+		if got, err := c.CheckHost(netip.MustParseAddr(tt.ip), tt.domain, ""); got.Result != tt.want {
+			t.Errorf("CheckHost(%s, %s) = %s, %v, want %s", tt.ip, tt.domain, got.Result, err, tt.want)
+		}
+	}
+}
+
+func Test_CheckSPFConfigResolver_splitHorizon(t *testing.T) {
+	view := func(name, spf string) *models.DomainConfig {
+		dc := &models.DomainConfig{}
+		dc.PopulateNamesFromRaw(name)
+		r := &models.RecordConfig{TTL: 300}
+		r.SetLabel("@", dc.Name)
+		if err := r.PopulateFromString("TXT", spf, dc.Name); err != nil {
+			t.Fatal(err)
+		}
+		dc.Records = models.Records{r}
+		return dc
+	}
+	cfg := &models.DNSConfig{Domains: []*models.DomainConfig{
+		view("example.com!inside", "v=spf1 ip4:10.0.0.0/8 -all"),
+		view("example.com!outside", "v=spf1 ip4:192.0.2.0/24 -all"),
+		view("example.net", "v=spf1 include:example.com -all"),
+	}}
+
+	for _, tt := range []struct {
+		tag, ip, domain string
+		want            spflib.Result
+	}{
+		{"inside", "10.1.2.3", "example.com", spflib.ResultPass},
+		{"inside", "192.0.2.1", "example.com", spflib.ResultFail},
+		{"outside", "192.0.2.1", "example.com", spflib.ResultPass},
+		{"outside", "192.0.2.1", "example.net", spflib.ResultPass}, // The zones without a tag are in every view.
+		{"", "192.0.2.1", "example.com", spflib.ResultNone},        // Not in the view: looked up in DNS.
+	} {
+		c := &spflib.Checker{Resolver: newConfigResolver(cfg, tt.tag, liveSPFResolver{})}
+		if got, err := c.CheckHost(netip.MustParseAddr(tt.ip), tt.domain, ""); got.Result != tt.want {
+			t.Errorf("tag %q: CheckHost(%s, %s) = %s, %v, want %s", tt.tag, tt.ip, tt.domain, got.Result, err, tt.want)
+		}
+	}
+}
@@ -12,6 +12,7 @@ import (
 	"github.com/DNSControl/dnscontrol/v4/pkg/diff2"
 	"github.com/DNSControl/dnscontrol/v4/pkg/js"
 	"github.com/DNSControl/dnscontrol/v4/pkg/printer"
+	"github.com/DNSControl/dnscontrol/v4/pkg/spflib"
 	"github.com/DNSControl/dnscontrol/v4/pkg/version"
 	"github.com/fatih/color"
 	"github.com/urfave/cli/v3"
@@ -65,6 +66,23 @@ func Run(v string) int {
 			Usage:       "Enable JS fetch(), dangerous on untrusted code!",
 			Destination: &js.EnableFetch,
 		},
+		&cli.StringFlag{
+			Name:        "js-engine",
+			Usage:       fmt.Sprintf("JavaScript engine for dnsconfig.js: %s (ES5) or %s (ES2020+)", js.EngineOtto, js.EngineGoja),
+			Value:       js.EngineOtto,
+			Destination: &js.Engine,
+		},
+		&cli.StringFlag{
+			Name:        "spf-refresh",
+			Usage:       fmt.Sprintf("When the SPF records cached in spfcache.json are looked up again: %s, %s (TTL) or %s", spflib.RefreshNever, spflib.RefreshExpired, spflib.RefreshAlways),
+			Value:       spflib.RefreshAlways,
+			Destination: &spflib.Refresh,
+		},
+		&cli.BoolFlag{
+			Name:        "spf-offline",
+			Usage:       "Never look up SPF records; fail if one is not in spfcache.json",
+			Destination: &spflib.Offline,
+		},
 		&cli.BoolFlag{
 			Name:   "diff2",
 			Usage:  "Obsolete flag. Will be removed in v5 or later",
@@ -0,0 +1,608 @@
+package commands
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/DNSControl/dnscontrol/v4/models"
+	"github.com/DNSControl/dnscontrol/v4/pkg/prettyzone"
+	"github.com/DNSControl/dnscontrol/v4/pkg/zonefile"
+	dnsv1 "github.com/miekg/dns"
+	"github.com/urfave/cli/v3"
+)
+
+var _ = cmd(catUtils, func() *cli.Command {
+	var args ConvertZonefileArgs
+	return &cli.Command{
+		Name:  "convert-zonefile",
+		Usage: "converts BIND zone files to dnsconfig.js, keeping comments, $INCLUDE and $GENERATE (stand-alone)",
+		Action: func(ctx context.Context, c *cli.Command) error {
+			if c.NArg() < 1 {
+				return cli.Exit("Arguments should be: zonefile [...] (Ex: example.com.zone)", 1)
+			}
+			args.ZoneFiles = c.Args().Slice()
+			if args.Zone != "" && len(args.ZoneFiles) > 1 {
+				return cli.Exit("--zone can only be used with one zone file", 1)
+			}
+			return exit(ConvertZonefile(args))
+		},
+		Flags:     args.flags(),
+		UsageText: "dnscontrol convert-zonefile [command options] zonefile [...]",
+		Description: `Convert zone files (RFC 1035, as used by BIND) to a dnsconfig.js draft.
+Unlike get-zones, the structure of the files is kept:
+
+   Comments become JavaScript comments, blank lines are kept.
+   $GENERATE becomes GENERATE().
+   $INCLUDE becomes require() of a .js file, written next to the output,
+   that defines the records of the included file.
+
+ARGUMENTS:
+   zonefile: One or more zone files. The zone is the first $ORIGIN of the
+             file, or the file name without ".zone" (see --zone).
+
+EXAMPLES:
+   dnscontrol convert-zonefile example.com.zone
+   dnscontrol convert-zonefile --out=dnsconfig.js zones/*.zone
+   dnscontrol convert-zonefile --zone=example.com --out=draft.js db.example
+
+Documentation: https://docs.dnscontrol.org/commands/convert-zonefile`,
+	}
+}())
+
+// ConvertZonefileArgs contains all data/flags needed to run convert-zonefile, independently of CLI.
+type ConvertZonefileArgs struct {
+	ZoneFiles  []string // The zone files to convert
+	Zone       string   // The zone of the (only) file
+	OutputFile string   // Filename to send output ("" means stdout)
+}
+
+func (args *ConvertZonefileArgs) flags() []cli.Flag {
+	var flags []cli.Flag
+	flags = append(flags, &cli.StringFlag{
+		Name:        "zone",
+		Destination: &args.Zone,
+		Usage:       `The zone of the file (default: its first $ORIGIN, or its name without ".zone")`,
+	})
+	flags = append(flags, &cli.StringFlag{
+		Name:        "out",
+		Destination: &args.OutputFile,
+		Usage:       `Instead of stdout, write to this file. The files of $INCLUDE are written to its directory`,
+	})
+	return flags
+}
+
+// ConvertZonefile implements the convert-zonefile subcommand.
+func ConvertZonefile(args ConvertZonefileArgs) error {
+	outDir := "."
+	if args.OutputFile != "" {
+		outDir = filepath.Dir(args.OutputFile)
+	}
+
+	var domains []string
+	zc := &zoneConverter{outDir: outDir}
+	for _, filename := range args.ZoneFiles {
+		d, err := zc.convertZone(filename, args.Zone)
+		if err != nil {
+			return err
+		}
+		domains = append(domains, d)
+	}
+
+	// The files of $INCLUDE.
+	for _, inc := range zc.includes {
+		var b strings.Builder
+		fmt.Fprintf(&b, "// generated by convert-zonefile from %s ($ORIGIN %s.). This is 'a decent first draft' and requires editing.\n\n", inc.file, inc.origin)
+		fmt.Fprintf(&b, "var %s = [\n%s];\n", inc.varName, indentItems(inc.items))
+		if err := os.MkdirAll(filepath.Dir(inc.jsFile), 0o755); err != nil {
+			return err
+		}
+		if err := os.WriteFile(inc.jsFile, []byte(b.String()), 0o644); err != nil {
+			return err
+		}
+	}
+
+	w := os.Stdout
+	if args.OutputFile != "" {
+		var err error
+		if w, err = os.Create(args.OutputFile); err != nil {
+			return fmt.Errorf("failed ConvertZonefile Create(%q): %w", args.OutputFile, err)
+		}
+		defer w.Close()
+	}
+	fmt.Fprintf(w, "// generated by convert-zonefile. This is 'a decent first draft' and requires editing.\n")
+	fmt.Fprintf(w, "\n")
+	fmt.Fprintf(w, `var DSP_CHANGEME = NewDnsProvider("CHANGEME");`+"\n")
+	fmt.Fprintf(w, `var REG_CHANGEME = NewRegistrar("none");`+"\n\n")
+	if len(zc.includes) != 0 {
+		for _, inc := range zc.includes {
+			rel, err := filepath.Rel(outDir, inc.jsFile)
+			if err != nil {
+				return err
+			}
+			fmt.Fprintf(w, "require(%s); // $INCLUDE %s ($ORIGIN %s.)\n", jsonQuoted("./"+filepath.ToSlash(rel)), inc.file, inc.origin)
+		}
+		fmt.Fprintln(w)
+	}
+	for _, d := range domains {
+		fmt.Fprint(w, d)
+	}
+	return nil
+}
+
+// zoneConverter converts zone files to JavaScript.
+type zoneConverter struct {
+	outDir     string         // Where the files of $INCLUDE are written.
+	zone       string         // The zone being converted (no trailing dot).
+	defaultTTL uint32         // The DefaultTTL() of the zone.
+	includes   []*zoneInclude // The files of $INCLUDE, in the order they must be required.
+}
+
+// zoneInclude is a file of $INCLUDE, converted.
+type zoneInclude struct {
+	file    string   // The zone file.
+	origin  string   // The origin it was included with (no trailing dot).
+	varName string   // The variable that holds its records.
+	jsFile  string   // The file that defines varName.
+	items   []string // The records.
+}
+
+// zoneState is the state of the parser of a zone file.
+type zoneState struct {
+	filename  string
+	origin    string // No trailing dot.
+	ttl       uint32 // The TTL of records that have none.
+	dollarTTL bool   // ttl is from $TTL, not from the last record.
+	lastOwner string // FQDN, for records that start with a blank.
+}
+
+// convertZone converts the zone file, and returns the D() of the zone.
+func (zc *zoneConverter) convertZone(filename, zone string) (string, error) {
+	content, err := os.ReadFile(filename)
+	if err != nil {
+		return "", err
+	}
+	entries, err := splitZoneEntries(string(content))
+	if err != nil {
+		return "", fmt.Errorf("%s: %w", filename, err)
+	}
+
+	// The zone and its default TTL are those in effect before the first record.
+	zc.defaultTTL = models.DefaultTTL
+	dollarTTL := false
+	for _, e := range entries {
+		fields := strings.Fields(e.text)
+		if len(fields) == 0 {
+			continue
+		}
+		if len(fields) < 2 || !strings.HasPrefix(fields[0], "$") {
+			break
+		}
+		switch strings.ToUpper(fields[0]) {
+		case "$ORIGIN":
+			if zone == "" {
+				zone = fields[1]
+			}
+		case "$TTL":
+			if ttl, err := parseZoneTTL(fields[1]); err == nil {
+				zc.defaultTTL, dollarTTL = ttl, true
+			}
+		}
+	}
+	if zone == "" {
+		zone = strings.TrimSuffix(filepath.Base(filename), ".zone")
+		if zone == filepath.Base(filename) {
+			return "", fmt.Errorf("%s: can't tell the zone (no $ORIGIN): use --zone", filename)
+		}
+	}
+	zc.zone = strings.ToLower(strings.TrimSuffix(zone, "."))
+
+	st := &zoneState{filename: filename, origin: zc.zone, ttl: zc.defaultTTL, dollarTTL: dollarTTL}
+	items, err := zc.convertEntries(entries, st)
+	if err != nil {
+		return "", err
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "// Converted from %s\n", filename)
+	fmt.Fprintf(&b, "D(%s, REG_CHANGEME,\n", jsonQuoted(zc.zone))
+	header := []string{"DnsProvider(DSP_CHANGEME),"}
+	if zc.defaultTTL != models.DefaultTTL {
+		header = append(header, fmt.Sprintf("DefaultTTL(%d),", zc.defaultTTL))
+	}
+	b.WriteString(indentItems(append(header, items...)))
+	b.WriteString(");\n\n")
+	return b.String(), nil
+}
+
+// convertEntries returns the items (arguments of D()) of the entries.
+func (zc *zoneConverter) convertEntries(entries []zoneEntry, st *zoneState) ([]string, error) {
+	var items []string
+	for _, e := range entries {
+		comment := ""
+		if len(e.comments) != 0 {
+			comment = " //" + strings.Join(e.comments, ";")
+		}
+		fields := strings.Fields(e.text)
+		switch {
+		case len(fields) == 0 && len(e.comments) == 0:
+			items = append(items, "")
+			continue
+		case len(fields) == 0:
+			for _, c := range e.comments {
+				items = append(items, "//"+c)
+			}
+			continue
+		}
+
+		var item string
+		var err error
+		switch strings.ToUpper(fields[0]) {
+		case "$ORIGIN":
+			if len(fields) != 2 {
+				return nil, fmt.Errorf("%s:%d: $ORIGIN needs one name", st.filename, e.line)
+			}
+			st.origin = strings.ToLower(strings.TrimSuffix(zc.absName(fields[1], st.origin), "."))
+			item = "// " + e.text
+		case "$TTL":
+			if len(fields) != 2 {
+				return nil, fmt.Errorf("%s:%d: $TTL needs one TTL", st.filename, e.line)
+			}
+			if st.ttl, err = parseZoneTTL(fields[1]); err != nil {
+				return nil, fmt.Errorf("%s:%d: %w", st.filename, e.line, err)
+			}
+			st.dollarTTL = true
+			item = "// " + e.text
+		case "$INCLUDE":
+			if item, err = zc.convertInclude(fields[1:], st); comment == "" {
+				comment = " // " + e.text
+			}
+		case "$GENERATE":
+			var genItems []string
+			genItems, err = zc.convertGenerate(e.text, fields[1:], st)
+			if err == nil {
+				items = append(items, "// "+e.text+comment)
+				items = append(items, genItems...)
+				continue
+			}
+		default:
+			if strings.HasPrefix(fields[0], "$") {
+				return nil, fmt.Errorf("%s:%d: unknown directive %s", st.filename, e.line, fields[0])
+			}
+			item, err = zc.convertRecord(e.text, e.indented, st)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%s:%d: %w", st.filename, e.line, err)
+		}
+		items = append(items, item+comment)
+	}
+	return items, nil
+}
+
+// convertRecord returns the DSL of the record, with a comma.
+func (zc *zoneConverter) convertRecord(text string, indented bool, st *zoneState) (string, error) {
+	owner := ""
+	if indented {
+		if st.lastOwner == "" {
+			return "", errors.New("the first record has no name")
+		}
+		owner = st.lastOwner + " "
+	}
+	snippet := fmt.Sprintf("$ORIGIN %s.\n$TTL %d\n%s%s\n", st.origin, st.ttl, owner, text)
+	recs, err := zonefile.Parse(snippet, zc.zone, st.filename)
+	if err != nil {
+		return "", err
+	}
+	if len(recs) == 0 {
+		// A DNSSEC record (RRSIG, NSEC, ...) that is generated when the
+		// zone is signed.
+		return "// " + text, nil
+	}
+	rec := recs[0]
+	if rec.NameFQDN != zc.zone && !strings.HasSuffix(rec.NameFQDN, "."+zc.zone) {
+		return "", fmt.Errorf("%s is not in the zone %s", rec.NameFQDN, zc.zone)
+	}
+	st.lastOwner = rec.NameFQDN + "."
+	if !st.dollarTTL {
+		st.ttl = rec.TTL // RFC 1035: the TTL of the last record.
+	}
+	return formatDsl(rec, zc.defaultTTL) + ",", nil
+}
+
+// absName returns the name, relative to origin, as a FQDN.
+func (zc *zoneConverter) absName(name, origin string) string {
+	switch {
+	case name == "@":
+		return origin + "."
+	case strings.HasSuffix(name, "."):
+		return name
+	default:
+		return name + "." + origin + "."
+	}
+}
+
+var jsVarInvalid = regexp.MustCompile(`[^A-Z0-9_]+`)
+
+// convertInclude converts the file of $INCLUDE file [origin], and returns
+// the variable that holds its records.
+func (zc *zoneConverter) convertInclude(args []string, st *zoneState) (string, error) {
+	if len(args) < 1 || len(args) > 2 {
+		return "", errors.New("$INCLUDE needs a file name and an optional origin")
+	}
+	file := args[0]
+	path := file
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(filepath.Dir(st.filename), file)
+	}
+	origin := st.origin
+	if len(args) == 2 {
+		origin = strings.ToLower(strings.TrimSuffix(zc.absName(args[1], st.origin), "."))
+	}
+
+	// A file included again with the same origin is converted once.
+	if i := slices.IndexFunc(zc.includes, func(inc *zoneInclude) bool { return inc.file == file && inc.origin == origin }); i >= 0 {
+		return zc.includes[i].varName + ",", nil
+	}
+
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	entries, err := splitZoneEntries(string(content))
+	if err != nil {
+		return "", fmt.Errorf("%s: %w", path, err)
+	}
+	// $ORIGIN and $TTL in the included file don't change the including file.
+	items, err := zc.convertEntries(entries, &zoneState{filename: path, origin: origin, ttl: st.ttl, dollarTTL: st.dollarTTL, lastOwner: st.lastOwner})
+	if err != nil {
+		return "", err
+	}
+
+	// A file included with another origin gets another variable and file,
+	// named after the origin.
+	base := strings.TrimSuffix(file, filepath.Ext(file))
+	if slices.ContainsFunc(zc.includes, func(inc *zoneInclude) bool { return inc.file == file }) {
+		base += "." + origin
+	}
+	varName := "INCLUDE_" + strings.Trim(jsVarInvalid.ReplaceAllString(strings.ToUpper(filepath.Base(base)), "_"), "_")
+	for n := 2; slices.ContainsFunc(zc.includes, func(inc *zoneInclude) bool { return inc.varName == varName }); n++ {
+		varName = fmt.Sprintf("%s_%d", strings.TrimRight(varName, "_0123456789"), n)
+	}
+	zc.includes = append(zc.includes, &zoneInclude{
+		file:    file,
+		origin:  origin,
+		varName: varName,
+		jsFile:  filepath.Join(zc.outDir, filepath.FromSlash(strings.TrimPrefix(filepath.ToSlash(base), "/"))+".js"),
+		items:   items,
+	})
+	return varName + ",", nil
+}
+
+// generateTypes are the types whose $GENERATE becomes GENERATE(): they have
+// a name or an address as their only field.
+var generateTypes = map[string]bool{"A": true, "AAAA": true, "CNAME": true, "DNAME": true, "NS": true, "PTR": true}
+
+// convertGenerate converts $GENERATE range lhs [ttl] [class] type rhs to
+// GENERATE(). Types other than generateTypes are expanded to the records
+// instead.
+func (zc *zoneConverter) convertGenerate(text string, args []string, st *zoneState) ([]string, error) {
+	if len(args) < 4 {
+		return nil, errors.New("$GENERATE needs a range, a name, a type and a value")
+	}
+	start, stop, step, err := prettyzone.ParseGenerateRange(args[0])
+	if err != nil {
+		return nil, err
+	}
+	lhs := args[1]
+	rest := args[2:]
+	ttl := st.ttl
+	var rtype string
+	for len(rest) > 0 && rtype == "" {
+		switch tok := strings.ToUpper(rest[0]); {
+		case tok == "IN":
+		case dnsv1.StringToType[tok] != 0:
+			rtype = tok
+		default:
+			t, err := parseZoneTTL(tok)
+			if err != nil {
+				return nil, fmt.Errorf("$GENERATE: unknown type %q", rest[0])
+			}
+			ttl = t
+		}
+		rest = rest[1:]
+	}
+	if rtype == "" || len(rest) == 0 {
+		return nil, errors.New("$GENERATE needs a type and a value")
+	}
+	rhs := strings.Join(rest, " ")
+
+	// Check every record, and expand if GENERATE() can't express them.
+	var items []string
+	for i := start; i <= stop; i += step {
+		name, err := prettyzone.GenerateSubst(lhs, i)
+		if err != nil {
+			return nil, fmt.Errorf("$GENERATE: %w", err)
+		}
+		value, err := prettyzone.GenerateSubst(rhs, i)
+		if err != nil {
+			return nil, fmt.Errorf("$GENERATE: %w", err)
+		}
+		item, err := zc.convertRecord(fmt.Sprintf("%s %d %s %s", name, ttl, rtype, value), false, st)
+		if err != nil {
+			return nil, fmt.Errorf("$GENERATE: %w", err)
+		}
+		items = append(items, item)
+	}
+	if !generateTypes[rtype] {
+		return items, nil
+	}
+
+	// The name, relative to the zone.
+	switch {
+	case lhs == "@":
+		lhs = zc.relName(st.origin + ".")
+	case strings.HasSuffix(lhs, "."):
+	case st.origin != zc.zone:
+		lhs += strings.TrimSuffix("."+st.origin+".", "."+zc.zone+".")
+	}
+	// Names in the value are relative to the zone in dnsconfig.js.
+	if rtype != "A" && rtype != "AAAA" && !strings.HasSuffix(rhs, ".") && st.origin != zc.zone {
+		rhs += "." + st.origin + "."
+	}
+	stepArg := ""
+	if step != 1 {
+		stepArg = fmt.Sprintf("%d, ", step)
+	}
+	ttlop := ""
+	if ttl != zc.defaultTTL {
+		ttlop = fmt.Sprintf(", TTL(%d)", ttl)
+	}
+	return []string{fmt.Sprintf("GENERATE(%s, %d, %d, %s%s, %s%s),", jsonQuoted(lhs), start, stop, stepArg, rtype, jsonQuoted(rhs), ttlop)}, nil
+}
+
+// relName returns the label of the FQDN in the zone.
+func (zc *zoneConverter) relName(fqdn string) string {
+	name := strings.TrimSuffix(fqdn, ".")
+	if name == zc.zone {
+		return "@"
+	}
+	return strings.TrimSuffix(name, "."+zc.zone)
+}
+
+// parseZoneTTL parses a TTL of a zone file: seconds, or BIND's 1w2d3h4m5s.
+func parseZoneTTL(s string) (uint32, error) {
+	units := map[byte]uint64{'s': 1, 'm': 60, 'h': 3600, 'd': 86400, 'w': 604800}
+	var ttl, n uint64
+	digits := false
+	for i := 0; i < len(s); i++ {
+		c := s[i] | 0x20 // Lower case.
+		switch {
+		case s[i] >= '0' && s[i] <= '9':
+			n = n*10 + uint64(s[i]-'0')
+			digits = true
+		case units[c] != 0 && digits:
+			ttl += n * units[c]
+			n, digits = 0, false
+		default:
+			return 0, fmt.Errorf("invalid TTL %q", s)
+		}
+		if ttl+n > 1<<32-1 {
+			return 0, fmt.Errorf("invalid TTL %q", s)
+		}
+	}
+	if s == "" || (!digits && units[s[len(s)-1]|0x20] == 0) {
+		return 0, fmt.Errorf("invalid TTL %q", s)
+	}
+	return uint32(ttl + n), nil
+}
+
+// indentItems returns the items, one per line, indented with a tab.
+func indentItems(items []string) string {
+	var b strings.Builder
+	for _, item := range items {
+		if item == "" {
+			b.WriteString("\n")
+			continue
+		}
+		for line := range strings.SplitSeq(item, "\n") {
+			b.WriteString("\t" + line + "\n")
+		}
+	}
+	return b.String()
+}
+
+// zoneEntry is a directive, a record, or comments and blank lines of a zone file.
+type zoneEntry struct {
+	line     int      // The first line.
+	text     string   // Without the parentheses and comments, on one line.
+	indented bool     // The record has no name (it's the name of the previous one).
+	comments []string // Without the ";".
+}
+
+// splitZoneEntries splits a zone file into entries: one per line, except
+// that a record in parentheses is one entry.
+func splitZoneEntries(content string) ([]zoneEntry, error) {
+	var entries []zoneEntry
+	var e zoneEntry
+	var text strings.Builder
+	space := false // Whitespace (outside of strings) before the next character.
+	put := func(c byte) {
+		if space && text.Len() != 0 {
+			text.WriteByte(' ')
+		}
+		space = false
+		text.WriteByte(c)
+	}
+	depth := 0
+	quoted := false
+	line := 1
+	startOfEntry := true
+	for i := 0; i < len(content); i++ {
+		c := content[i]
+		if startOfEntry {
+			e = zoneEntry{line: line, indented: c == ' ' || c == '\t'}
+			text.Reset()
+			space = false
+			startOfEntry = false
+		}
+		switch {
+		case quoted && c == '\\' && i+1 < len(content):
+			put(c)
+			text.WriteByte(content[i+1])
+			i++
+		case c == '"':
+			put(c)
+			quoted = !quoted
+		case quoted && c != '\n':
+			put(c)
+		case c == ';':
+			end := strings.IndexByte(content[i:], '\n')
+			if end < 0 {
+				end = len(content) - i
+			}
+			e.comments = append(e.comments, strings.TrimRight(content[i+1:i+end], " \t\r"))
+			i += end - 1
+		case c == '(':
+			depth++
+			space = true
+		case c == ')':
+			if depth == 0 {
+				return nil, fmt.Errorf("line %d: unbalanced )", line)
+			}
+			depth--
+			space = true
+		case c == '\n':
+			if quoted {
+				return nil, fmt.Errorf("line %d: unterminated string", line)
+			}
+			line++
+			if depth > 0 {
+				space = true
+				continue
+			}
+			e.text = text.String()
+			if e.text == "" {
+				e.indented = false
+			}
+			entries = append(entries, e)
+			startOfEntry = true
+		case c == ' ' || c == '\t' || c == '\r':
+			space = true
+		default:
+			put(c)
+		}
+	}
+	if depth > 0 || quoted {
+		return nil, fmt.Errorf("line %d: unexpected end of file", e.line)
+	}
+	if !startOfEntry && (text.Len() != 0 || len(e.comments) != 0) {
+		e.text = text.String()
+		entries = append(entries, e)
+	}
+	return entries, nil
+}
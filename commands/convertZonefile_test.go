@@ -0,0 +1,111 @@
+package commands
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/andreyvit/diff"
+)
+
+func TestConvertZonefile(t *testing.T) {
+	/*
+	  Input:                                Should match contents of:
+	  test_data/convert/example.com.zone   test_data/convert/want/dnsconfig.js
+	  (and its $INCLUDE files)             test_data/convert/want/*.js
+	*/
+	dir := t.TempDir()
+	err := ConvertZonefile(ConvertZonefileArgs{
+		ZoneFiles:  []string{"test_data/convert/example.com.zone"},
+		OutputFile: filepath.Join(dir, "dnsconfig.js"),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, name := range []string{"dnsconfig.js", "mail.js", "mail.lab.example.com.js"} {
+		expectedFilename := filepath.Join("test_data/convert/want", name)
+		got, err := os.ReadFile(filepath.Join(dir, name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		want, err := os.ReadFile(expectedFilename)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if w, g := string(want), string(got); w != g {
+			// If the test fails, output a file showing "got"
+			if err := os.WriteFile(expectedFilename+".ACTUAL", got, 0o644); err != nil {
+				t.Fatal(err)
+			}
+			t.Errorf("%s mismatch (-got +want):\n%s", name, diff.LineDiff(g, w))
+		}
+	}
+}
+
+func TestConvertZonefile_outsideZone(t *testing.T) {
+	zonefile := filepath.Join(t.TempDir(), "example.com.zone")
+	if err := os.WriteFile(zonefile, []byte("www.example.net. IN A 192.0.2.1\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	err := ConvertZonefile(ConvertZonefileArgs{
+		ZoneFiles:  []string{zonefile},
+		OutputFile: filepath.Join(t.TempDir(), "dnsconfig.js"),
+	})
+	if err == nil {
+		t.Error("a record outside of the zone was accepted")
+	}
+}
+
+func TestSplitZoneEntries(t *testing.T) {
+	entries, err := splitZoneEntries(`; header
+@ IN SOA ns1 hostmaster (
+	1 ; serial
+	2 3 4 5 )
+	TXT "a  ; (b)"
+
+www   A 192.0.2.1 ; web
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []zoneEntry{
+		{line: 1, comments: []string{" header"}},
+		{line: 2, text: "@ IN SOA ns1 hostmaster 1 2 3 4 5", comments: []string{" serial"}},
+		{line: 5, text: `TXT "a  ; (b)"`, indented: true},
+		{line: 6},
+		{line: 7, text: "www A 192.0.2.1", comments: []string{" web"}},
+	}
+	if len(entries) != len(want) {
+		t.Fatalf("got %d entries %+v, want %d", len(entries), entries, len(want))
+	}
+	for i, e := range entries {
+		w := want[i]
+		if e.line != w.line || e.text != w.text || e.indented != w.indented || len(e.comments) != len(w.comments) {
+			t.Errorf("entry %d = %+v, want %+v", i, e, w)
+			continue
+		}
+		for j := range e.comments {
+			if e.comments[j] != w.comments[j] {
+				t.Errorf("entry %d = %+v, want %+v", i, e, w)
+			}
+		}
+	}
+
+	if _, err := splitZoneEntries("@ IN SOA ( 1 2\n"); err == nil {
+		t.Error("unbalanced parentheses were accepted")
+	}
+}
+
+func TestParseZoneTTL(t *testing.T) {
+	for s, want := range map[string]uint32{"300": 300, "1h": 3600, "1W2d": 777600, "1h30m5s": 5405, "0": 0} {
+		if got, err := parseZoneTTL(s); err != nil || got != want {
+			t.Errorf("parseZoneTTL(%q) = %d, %v, want %d", s, got, err, want)
+		}
+	}
+	for _, s := range []string{"", "h", "1y", "-1", "4294967296"} {
+		if _, err := parseZoneTTL(s); err == nil {
+			t.Errorf("parseZoneTTL(%q) was accepted", s)
+		}
+	}
+}
@@ -0,0 +1,450 @@
+package commands
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/DNSControl/dnscontrol/v4/models"
+	"github.com/DNSControl/dnscontrol/v4/pkg/credsfile"
+	"github.com/DNSControl/dnscontrol/v4/pkg/diff2"
+	"github.com/DNSControl/dnscontrol/v4/pkg/nameservers"
+	"github.com/DNSControl/dnscontrol/v4/pkg/normalize"
+	"github.com/DNSControl/dnscontrol/v4/pkg/zonerecs"
+	"github.com/nozzle/throttler"
+	"github.com/urfave/cli/v3"
+)
+
+// Exit codes of the drift command.
+const (
+	driftExitNone    = 0 // Every zone matches dnsconfig.js.
+	driftExitFound   = 2 // At least one zone drifted.
+	driftExitErrored = 3 // At least one zone could not be checked.
+)
+
+var _ = cmd(catMain, func() *cli.Command {
+	var args DriftArgs
+	return &cli.Command{
+		Name:  "drift",
+		Usage: "report records that were added, modified or removed at the providers outside of dnscontrol",
+		Action: func(ctx context.Context, c *cli.Command) error {
+			code, err := Drift(args)
+			if err != nil {
+				return exit(err)
+			}
+			if code != driftExitNone {
+				return cli.Exit("", code)
+			}
+			return nil
+		},
+		Flags: args.flags(),
+		Description: `Compare the records at the DNS providers to dnsconfig.js and report each
+difference: records added out-of-band, records modified out-of-band and
+records that are missing. Nothing is changed.
+
+EXIT CODES:
+   0   No drift.
+   1   dnscontrol could not run (bad configuration, credentials, etc.).
+   2   At least one zone drifted.
+   3   At least one zone could not be checked (the report lists the errors).
+
+EXAMPLES:
+   dnscontrol drift
+   dnscontrol drift --format markdown --output drift.md
+
+Documentation: https://docs.dnscontrol.org/commands/drift`,
+	}
+}())
+
+// DriftArgs contains all data/flags needed to run drift, independently of CLI.
+type DriftArgs struct {
+	GetDNSConfigArgs
+	GetCredentialsArgs
+	FilterArgs
+	Format    string
+	Output    string
+	ConcurMax int
+}
+
+func (args *DriftArgs) flags() []cli.Flag {
+	flags := args.GetDNSConfigArgs.flags()
+	flags = append(flags, args.GetCredentialsArgs.flags()...)
+	flags = append(flags, args.FilterArgs.flags()...)
+	flags = append(flags, &cli.StringFlag{
+		Name:        "format",
+		Destination: &args.Format,
+		Value:       "json",
+		Usage:       `Format of the report: json, markdown`,
+		Action: func(ctx context.Context, c *cli.Command, s string) error {
+			if !slices.Contains([]string{"json", "markdown"}, s) {
+				fmt.Printf("%q is not a valid option for --format.  Values are: json, markdown\n", s)
+				os.Exit(1)
+			}
+			return nil
+		},
+	})
+	flags = append(flags, &cli.StringFlag{
+		Name:        "output",
+		Destination: &args.Output,
+		Value:       "-",
+		Usage:       `Write the report to this file ("-" for stdout)`,
+	})
+	flags = append(flags, &cli.IntFlag{
+		Name:        "cmax",
+		Destination: &args.ConcurMax,
+		Value:       100,
+		Usage:       `Maximum number of concurrent connections`,
+		Action: func(ctx context.Context, c *cli.Command, v int) error {
+			if v < 1 {
+				fmt.Printf("%d is not a valid value for --cmax.  Values must be 1 or greater\n", v)
+				os.Exit(1)
+			}
+			return nil
+		},
+	})
+	return flags
+}
+
+// DriftReport lists the differences between the records at the providers
+// and dnsconfig.js.
+type DriftReport struct {
+	Zones []*DriftZone `json:"zones"`
+}
+
+// DriftZone is the drift of a particular zone at a particular DNS provider.
+// The records are as seen from the provider: Added are at the provider but
+// not in dnsconfig.js, Missing are in dnsconfig.js but not at the provider.
+type DriftZone struct {
+	Domain   string           `json:"domain"`
+	Tag      string           `json:"tag,omitempty"`
+	Provider string           `json:"provider"`
+	Error    string           `json:"error,omitempty"` // The zone could not be checked.
+	Added    []*PlanRecord    `json:"added"`
+	Modified []*DriftModified `json:"modified"`
+	Missing  []*PlanRecord    `json:"missing"`
+}
+
+// DriftModified is a record that was modified at the provider.
+type DriftModified struct {
+	Actual  *PlanRecord `json:"actual"`  // At the provider.
+	Desired *PlanRecord `json:"desired"` // In dnsconfig.js.
+}
+
+// Drifted returns true if the zone's records differ from dnsconfig.js.
+func (dz *DriftZone) Drifted() bool {
+	return len(dz.Added)+len(dz.Modified)+len(dz.Missing) != 0
+}
+
+// String returns the name of the zone/provider in a human-friendly format.
+func (dz *DriftZone) String() string {
+	if dz.Tag == "" {
+		return fmt.Sprintf("%s (%s)", dz.Domain, dz.Provider)
+	}
+	return fmt.Sprintf("%s!%s (%s)", dz.Domain, dz.Tag, dz.Provider)
+}
+
+// Drift implements the drift subcommand. It returns the exit code, or an
+// error if the report could not be generated at all.
+func Drift(args DriftArgs) (int, error) {
+	cfg, err := GetDNSConfig(args.GetDNSConfigArgs)
+	if err != nil {
+		return 0, err
+	}
+	providerConfigs, err := credsfile.LoadProviderConfigs(args.CredsFile)
+	if err != nil {
+		return 0, err
+	}
+	if _, err := PInitializeProviders(cfg, providerConfigs, false); err != nil {
+		return 0, err
+	}
+	errs := normalize.ValidateAndNormalizeConfig(cfg)
+	if PrintValidationErrors(errs) {
+		return 0, errors.New("exiting due to validation errors")
+	}
+
+	zones := whichZonesToProcess(cfg.Domains, args.Domains)
+	zonesSerial, zonesConcurrent := splitConcurrent(zones, "concurrent")
+	results := make([][]*DriftZone, len(zones))
+	index := map[*models.DomainConfig]int{}
+	for i, zone := range zones {
+		index[zone] = i
+	}
+
+	// Gather. Nothing is shared between zones, so only the number of
+	// zones being gathered at once needs to be limited.
+	t := throttler.New(args.ConcurMax, len(zonesConcurrent))
+	for i, zone := range zonesConcurrent {
+		go func(zone *models.DomainConfig) {
+			results[index[zone]] = driftZone(zone, args.Providers)
+			t.Done(nil)
+		}(zone)
+		// Delay the last call to t.Throttle() until the serial processing is done.
+		if i != ultimate(zonesConcurrent) {
+			t.Throttle()
+		}
+	}
+	for _, zone := range zonesSerial {
+		results[index[zone]] = driftZone(zone, args.Providers)
+	}
+	if len(zonesConcurrent) > 0 {
+		t.Throttle()
+	}
+
+	report := &DriftReport{Zones: []*DriftZone{}}
+	for _, dzs := range results {
+		report.Zones = append(report.Zones, dzs...)
+	}
+	slices.SortStableFunc(report.Zones, func(a, b *DriftZone) int {
+		return cmp.Or(
+			cmp.Compare(a.Domain, b.Domain),
+			cmp.Compare(a.Tag, b.Tag),
+			cmp.Compare(a.Provider, b.Provider),
+		)
+	})
+	if err := writeDriftReport(args.Output, args.Format, report); err != nil {
+		return 0, fmt.Errorf("could not write report: %w", err)
+	}
+	return report.exitCode(), nil
+}
+
+// exitCode returns the exit code the drift command should return for this report.
+func (r *DriftReport) exitCode() int {
+	code := driftExitNone
+	for _, dz := range r.Zones {
+		if dz.Error != "" {
+			return driftExitErrored
+		}
+		if dz.Drifted() {
+			code = driftExitFound
+		}
+	}
+	return code
+}
+
+// driftZone compares the zone's records at each of its (selected) providers
+// to the desired records.
+func driftZone(zone *models.DomainConfig, providerFilter string) []*DriftZone {
+	// Add the NS records, as push would. Otherwise they would all appear to
+	// have been added out-of-band.
+	nsList, nsErr := nameservers.DetermineNameserversForProviders(zone, zone.DNSProviderInstances, true)
+	if nsErr == nil {
+		zone.Nameservers = nsList
+		nameservers.AddNSRecords(zone)
+	}
+
+	var dzs []*DriftZone
+	for _, provider := range whichProvidersToProcess(zone.DNSProviderInstances, providerFilter) {
+		dz := &DriftZone{
+			Domain:   zone.Name,
+			Tag:      zone.Tag,
+			Provider: provider.Name,
+			Added:    []*PlanRecord{},
+			Modified: []*DriftModified{},
+			Missing:  []*PlanRecord{},
+		}
+		dzs = append(dzs, dz)
+		if nsErr != nil {
+			dz.Error = nsErr.Error()
+			continue
+		}
+
+		// The corrections are generated but never run.
+		zr, err := zonerecs.GetZoneResult(provider.Driver, zone)
+		if err != nil {
+			dz.Error = err.Error()
+			continue
+		}
+		if len(zr.Changes) == 0 && zr.ActualChangeCount != 0 && zr.ChangesRecords() {
+			dz.Error = "the provider did not report which records differ"
+			continue
+		}
+		dz.Added, dz.Modified, dz.Missing = classifyDrift(zr.Changes)
+	}
+	return dzs
+}
+
+// classifyDrift sorts the records in the diff2 instructions into those that
+// were added, modified and removed at the provider. The instructions are
+// regrouped by name and rtype, since a CHANGE generated by ByLabel() or
+// ByRecordSet() may include records that are unchanged, or that were added
+// or removed rather than modified.
+func classifyDrift(changes diff2.ChangeList) (added []*PlanRecord, modified []*DriftModified, missing []*PlanRecord) {
+	type key struct{ name, rtype string }
+	var keys []key
+	actual := map[key]models.Records{}
+	desired := map[key]models.Records{}
+	for _, c := range changes {
+		if c.Type == diff2.REPORT {
+			continue
+		}
+		for _, r := range c.Old {
+			k := key{r.GetLabelFQDN(), r.Type}
+			if actual[k] == nil && desired[k] == nil {
+				keys = append(keys, k)
+			}
+			actual[k] = append(actual[k], r)
+		}
+		for _, r := range c.New {
+			k := key{r.GetLabelFQDN(), r.Type}
+			if actual[k] == nil && desired[k] == nil {
+				keys = append(keys, k)
+			}
+			desired[k] = append(desired[k], r)
+		}
+	}
+
+	added, modified, missing = []*PlanRecord{}, []*DriftModified{}, []*PlanRecord{}
+	for _, k := range keys {
+		act, des := actual[k], desired[k]
+
+		// Records that are the same on both sides did not drift.
+		act = slices.DeleteFunc(act, func(a *models.RecordConfig) bool {
+			i := slices.IndexFunc(des, func(d *models.RecordConfig) bool {
+				return d.TTL == a.TTL && d.ToComparableNoTTL() == a.ToComparableNoTTL()
+			})
+			if i == -1 {
+				return false
+			}
+			des = slices.Delete(des, i, i+1)
+			return true
+		})
+
+		// Records that differ only by TTL were modified...
+		act = slices.DeleteFunc(act, func(a *models.RecordConfig) bool {
+			i := slices.IndexFunc(des, func(d *models.RecordConfig) bool {
+				return d.ToComparableNoTTL() == a.ToComparableNoTTL()
+			})
+			if i == -1 {
+				return false
+			}
+			modified = append(modified, &DriftModified{Actual: genPlanRecord(a), Desired: genPlanRecord(des[i])})
+			des = slices.Delete(des, i, i+1)
+			return true
+		})
+
+		// ...as were the remaining records, as far as they pair up. The rest
+		// were added or removed.
+		n := min(len(act), len(des))
+		for i := range n {
+			modified = append(modified, &DriftModified{Actual: genPlanRecord(act[i]), Desired: genPlanRecord(des[i])})
+		}
+		for _, r := range act[n:] {
+			added = append(added, genPlanRecord(r))
+		}
+		for _, r := range des[n:] {
+			missing = append(missing, genPlanRecord(r))
+		}
+	}
+
+	slices.SortFunc(added, comparePlanRecords)
+	slices.SortFunc(missing, comparePlanRecords)
+	slices.SortFunc(modified, func(a, b *DriftModified) int {
+		return cmp.Or(comparePlanRecords(a.Desired, b.Desired), comparePlanRecords(a.Actual, b.Actual))
+	})
+	return added, modified, missing
+}
+
+func genPlanRecord(r *models.RecordConfig) *PlanRecord {
+	return genPlanRecords(models.Records{r})[0]
+}
+
+func comparePlanRecords(a, b *PlanRecord) int {
+	return cmp.Or(
+		strings.Compare(a.Label, b.Label),
+		strings.Compare(a.Type, b.Type),
+		strings.Compare(a.Value, b.Value),
+		cmp.Compare(a.TTL, b.TTL),
+	)
+}
+
+// writeDriftReport writes the report to filename ("-" for stdout) in the
+// given format.
+func writeDriftReport(filename, format string, report *DriftReport) error {
+	w := io.Writer(os.Stdout)
+	if filename != "-" {
+		f, err := os.OpenFile(filename, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o644)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		w = f
+	}
+
+	if format == "markdown" {
+		_, err := io.WriteString(w, driftMarkdown(report))
+		return err
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	enc.SetEscapeHTML(false)
+	return enc.Encode(report)
+}
+
+// driftMarkdown formats the report as Markdown: a summary table followed by
+// a section for each zone that drifted or could not be checked.
+func driftMarkdown(report *DriftReport) string {
+	var sb strings.Builder
+	sb.WriteString("# DNS drift report\n\n")
+	if len(report.Zones) == 0 {
+		sb.WriteString("No zones were checked.\n")
+		return sb.String()
+	}
+
+	sb.WriteString("| Zone | Added | Modified | Missing |\n")
+	sb.WriteString("|------|------:|---------:|--------:|\n")
+	for _, dz := range report.Zones {
+		if dz.Error != "" {
+			fmt.Fprintf(&sb, "| %s | error | error | error |\n", mdEscape(dz.String()))
+			continue
+		}
+		fmt.Fprintf(&sb, "| %s | %d | %d | %d |\n", mdEscape(dz.String()), len(dz.Added), len(dz.Modified), len(dz.Missing))
+	}
+
+	for _, dz := range report.Zones {
+		if dz.Error == "" && !dz.Drifted() {
+			continue
+		}
+		fmt.Fprintf(&sb, "\n## %s\n", dz)
+		if dz.Error != "" {
+			fmt.Fprintf(&sb, "\nCould not be checked: %s\n", mdEscape(dz.Error))
+			continue
+		}
+		if len(dz.Added) != 0 {
+			sb.WriteString("\n### Added out-of-band\n\n")
+			sb.WriteString("| Label | Type | TTL | Value |\n")
+			sb.WriteString("|-------|------|----:|-------|\n")
+			for _, r := range dz.Added {
+				fmt.Fprintf(&sb, "| %s | %s | %d | %s |\n", mdEscape(r.Label), r.Type, r.TTL, mdEscape(r.Value))
+			}
+		}
+		if len(dz.Modified) != 0 {
+			sb.WriteString("\n### Modified out-of-band\n\n")
+			sb.WriteString("| Label | Type | Actual | Desired |\n")
+			sb.WriteString("|-------|------|--------|---------|\n")
+			for _, m := range dz.Modified {
+				fmt.Fprintf(&sb, "| %s | %s | %d %s | %d %s |\n", mdEscape(m.Desired.Label), m.Desired.Type,
+					m.Actual.TTL, mdEscape(m.Actual.Value), m.Desired.TTL, mdEscape(m.Desired.Value))
+			}
+		}
+		if len(dz.Missing) != 0 {
+			sb.WriteString("\n### Missing\n\n")
+			sb.WriteString("| Label | Type | TTL | Value |\n")
+			sb.WriteString("|-------|------|----:|-------|\n")
+			for _, r := range dz.Missing {
+				fmt.Fprintf(&sb, "| %s | %s | %d | %s |\n", mdEscape(r.Label), r.Type, r.TTL, mdEscape(r.Value))
+			}
+		}
+	}
+	return sb.String()
+}
+
+// mdEscape escapes s for use in a Markdown table cell.
+func mdEscape(s string) string {
+	return strings.NewReplacer("|", `\|`, "\n", " ").Replace(s)
+}
@@ -0,0 +1,117 @@
+package commands
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/DNSControl/dnscontrol/v4/models"
+	"github.com/DNSControl/dnscontrol/v4/pkg/diff2"
+)
+
+func Test_classifyDrift(t *testing.T) {
+	withTTL := func(r *models.RecordConfig, ttl uint32) *models.RecordConfig {
+		r.TTL = ttl
+		return r
+	}
+	// The records at the provider.
+	existing := models.Records{
+		makePlanRec("www", "A", "1.1.1.1"),
+		makePlanRec("www", "A", "9.9.9.9"), // modified
+		makePlanRec("mail", "A", "5.5.5.5"),
+		withTTL(makePlanRec("mail", "MX", "10 mail.example.com."), 60), // TTL modified
+		makePlanRec("rogue", "TXT", "added"),                           // added
+	}
+
+	for _, fn := range []struct {
+		name string
+		by   func(models.Records, *models.DomainConfig, diff2.ComparableFunc) (diff2.ChangeList, int, error)
+	}{
+		{"ByRecord", diff2.ByRecord},
+		{"ByRecordSet", diff2.ByRecordSet},
+		{"ByLabel", diff2.ByLabel},
+	} {
+		t.Run(fn.name, func(t *testing.T) {
+			dc := models.MustNewDomainConfig("example.com")
+			dc.Records = models.Records{
+				makePlanRec("www", "A", "1.1.1.1"),
+				makePlanRec("www", "A", "3.3.3.3"),
+				makePlanRec("mail", "A", "5.5.5.5"),
+				makePlanRec("mail", "MX", "10 mail.example.com."),
+				makePlanRec("mail", "AAAA", "2001:db8::1"), // missing
+			}
+			diff2.StartRecording(dc)
+			if _, _, err := fn.by(existing, dc, nil); err != nil {
+				t.Fatal(err)
+			}
+			added, modified, missing := classifyDrift(diff2.StopRecording(dc))
+
+			var got []string
+			for _, r := range added {
+				got = append(got, "added "+r.Label+" "+r.Type+" "+r.Value)
+			}
+			for _, m := range modified {
+				got = append(got, "modified "+m.Desired.Label+" "+m.Desired.Type+" "+m.Actual.Value+" -> "+m.Desired.Value)
+			}
+			for _, r := range missing {
+				got = append(got, "missing "+r.Label+" "+r.Type+" "+r.Value)
+			}
+			want := []string{
+				`added rogue TXT "added"`,
+				"modified mail MX 10 mail.example.com. -> 10 mail.example.com.",
+				"modified www A 9.9.9.9 -> 3.3.3.3",
+				"missing mail AAAA 2001:db8::1",
+			}
+			if strings.Join(got, "\n") != strings.Join(want, "\n") {
+				t.Errorf("classifyDrift() =\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+			}
+		})
+	}
+}
+
+func TestDriftReport_exitCode(t *testing.T) {
+	clean := &DriftZone{Domain: "example.com", Provider: "bind"}
+	drifted := &DriftZone{Domain: "example.net", Provider: "bind", Missing: []*PlanRecord{{Label: "www", Type: "A"}}}
+	failed := &DriftZone{Domain: "example.org", Provider: "bind", Error: "no such zone"}
+
+	tests := []struct {
+		name  string
+		zones []*DriftZone
+		want  int
+	}{
+		{"none", nil, driftExitNone},
+		{"clean", []*DriftZone{clean}, driftExitNone},
+		{"drifted", []*DriftZone{clean, drifted}, driftExitFound},
+		{"errored", []*DriftZone{drifted, failed}, driftExitErrored},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := (&DriftReport{Zones: tt.zones}).exitCode(); got != tt.want {
+				t.Errorf("exitCode() = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_driftMarkdown(t *testing.T) {
+	report := &DriftReport{Zones: []*DriftZone{
+		{Domain: "example.com", Provider: "bind"},
+		{
+			Domain: "example.net", Tag: "inside", Provider: "bind",
+			Added: []*PlanRecord{{Label: "@", Type: "TXT", TTL: 300, Value: "a|b"}},
+		},
+	}}
+	got := driftMarkdown(report)
+	for _, want := range []string{
+		"| example.com (bind) | 0 | 0 | 0 |\n",
+		"| example.net!inside (bind) | 1 | 0 | 0 |\n",
+		"## example.net!inside (bind)\n",
+		"| @ | TXT | 300 | a\\|b |\n",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("driftMarkdown() is missing %q:\n%s", want, got)
+		}
+	}
+	if strings.Contains(got, "## example.com") {
+		t.Errorf("driftMarkdown() has a section for a zone that did not drift:\n%s", got)
+	}
+}
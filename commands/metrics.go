@@ -0,0 +1,328 @@
+package commands
+
+import (
+	"cmp"
+	"errors"
+	"fmt"
+	"maps"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/DNSControl/dnscontrol/v4/models"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/push"
+	"github.com/prometheus/common/expfmt"
+	"github.com/prometheus/common/model"
+)
+
+// The names of the metrics written by --metrics-file and --metrics-pushgateway.
+const (
+	metricCorrections = "dnscontrol_corrections"
+	metricExecuted    = "dnscontrol_corrections_executed"
+	metricFailures    = "dnscontrol_failures"
+	metricAPISeconds  = "dnscontrol_api_duration_seconds"
+	metricLastSuccess = "dnscontrol_last_success_timestamp_seconds"
+	metricRunSuccess  = "dnscontrol_run_success"
+	metricRunTime     = "dnscontrol_run_timestamp_seconds"
+	metricRunSeconds  = "dnscontrol_run_duration_seconds"
+)
+
+// runMetrics collects the metrics of a preview/push run, per zone and
+// provider (or registrar). It is safe for concurrent use. A nil *runMetrics
+// discards everything, so callers do not need to check if metrics are wanted.
+type runMetrics struct {
+	command     string // "preview" or "push"
+	file        string // --metrics-file
+	pushgateway string // --metrics-pushgateway
+	job         string // --metrics-job
+	start       time.Time
+
+	sync.Mutex
+	zones map[zoneResultKey]*zoneMetrics
+}
+
+// zoneMetrics are the metrics of one zone at one provider.
+type zoneMetrics struct {
+	corrections   int     // Corrections found.
+	executed      int     // Corrections run successfully.
+	failures      int     // Failed gathers and corrections.
+	gatherSeconds float64 // Time spent getting the records and generating the corrections.
+	pushSeconds   float64 // Time spent running the corrections.
+}
+
+// newRunMetrics returns a runMetrics, or nil if no metrics were requested.
+func newRunMetrics(args PPreviewArgs, command string) *runMetrics {
+	if args.MetricsFile == "" && args.MetricsPushgateway == "" {
+		return nil
+	}
+	return &runMetrics{
+		command:     command,
+		file:        args.MetricsFile,
+		pushgateway: args.MetricsPushgateway,
+		job:         args.MetricsJob,
+		start:       time.Now(),
+		zones:       map[zoneResultKey]*zoneMetrics{},
+	}
+}
+
+// zone returns the metrics of a zone/provider. The caller must hold the lock.
+func (m *runMetrics) zone(zone *models.DomainConfig, providerName string) *zoneMetrics {
+	k := zoneResultKey{zone.UniqueName, providerName}
+	zm, ok := m.zones[k]
+	if !ok {
+		zm = &zoneMetrics{}
+		m.zones[k] = zm
+	}
+	return zm
+}
+
+// gathered records that the zone's records at a provider were gathered
+// (successfully if err is nil).
+func (m *runMetrics) gathered(zone *models.DomainConfig, providerName string, d time.Duration, err error) {
+	if m == nil {
+		return
+	}
+	m.Lock()
+	defer m.Unlock()
+	zm := m.zone(zone, providerName)
+	zm.gatherSeconds += d.Seconds()
+	if err != nil {
+		zm.failures++
+	}
+}
+
+// counted records the number of corrections found for the zone at a provider.
+func (m *runMetrics) counted(zone *models.DomainConfig, providerName string, n int) {
+	if m == nil {
+		return
+	}
+	m.Lock()
+	defer m.Unlock()
+	m.zone(zone, providerName).corrections += n
+}
+
+// instrument returns a copy of corrections whose functions record how long
+// they ran and whether they failed.
+func (m *runMetrics) instrument(zone *models.DomainConfig, providerName string, corrections []*models.Correction) []*models.Correction {
+	if m == nil {
+		return corrections
+	}
+	m.Lock()
+	m.zone(zone, providerName) // Record the zone even if nothing runs.
+	m.Unlock()
+
+	wrapped := make([]*models.Correction, len(corrections))
+	for i, c := range corrections {
+		wrapped[i] = c
+		if c.F == nil {
+			continue
+		}
+		f := c.F
+		wrapped[i] = &models.Correction{Msg: c.Msg, NonRecord: c.NonRecord, F: func() error {
+			start := time.Now()
+			err := f()
+			m.Lock()
+			defer m.Unlock()
+			zm := m.zone(zone, providerName)
+			zm.pushSeconds += time.Since(start).Seconds()
+			if err != nil {
+				zm.failures++
+			} else {
+				zm.executed++
+			}
+			return err
+		}}
+	}
+	return wrapped
+}
+
+// write writes the metrics to the file and/or pushgateway. runErr is the
+// error that the run ended with (if any).
+func (m *runMetrics) write(runErr error) error {
+	if m == nil {
+		return nil
+	}
+	m.Lock()
+	defer m.Unlock()
+
+	now := time.Now()
+	var errs []error
+	if m.file != "" {
+		if err := m.writeFile(now, runErr); err != nil {
+			errs = append(errs, fmt.Errorf("could not write metrics file: %w", err))
+		}
+	}
+	if m.pushgateway != "" {
+		if err := m.push(now, runErr); err != nil {
+			errs = append(errs, fmt.Errorf("could not push metrics: %w", err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// writeFile writes the metrics in the format of the node_exporter textfile
+// collector. The last success timestamps of the zones that failed this time,
+// and those of the other commands, are copied from the previous version of
+// the file.
+func (m *runMetrics) writeFile(now time.Time, runErr error) error {
+	lastSuccess, err := readLastSuccess(m.file)
+	if err != nil {
+		return err
+	}
+
+	labels := []string{"command", "zone", "provider"}
+	reg := prometheus.NewRegistry()
+	zg := newZoneGauges(reg, labels)
+	for _, k := range slices.SortedFunc(maps.Keys(m.zones), compareZoneResultKeys) {
+		zm := m.zones[k]
+		values := []string{m.command, k.zone, k.provider}
+		zg.set(values, zm)
+		if zm.failures == 0 {
+			lastSuccess[lastSuccessKey{m.command, k}] = float64(now.Unix())
+		}
+	}
+	for k, t := range lastSuccess {
+		zg.lastSuccess.WithLabelValues(k.command, k.zone, k.provider).Set(t)
+	}
+	newRunGauges(reg, []string{"command"}).set([]string{m.command}, m.start, now, runErr)
+
+	return prometheus.WriteToTextfile(m.file, reg)
+}
+
+// lastSuccessKey identifies a last success timestamp in the metrics file.
+type lastSuccessKey struct {
+	command string
+	zoneResultKey
+}
+
+// readLastSuccess returns the last success timestamps in a metrics file
+// written by writeFile, or nothing if the file does not exist.
+func readLastSuccess(filename string) (map[lastSuccessKey]float64, error) {
+	last := map[lastSuccessKey]float64{}
+	f, err := os.Open(filename)
+	if errors.Is(err, os.ErrNotExist) {
+		return last, nil
+	} else if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	parser := expfmt.NewTextParser(model.UTF8Validation)
+	families, err := parser.TextToMetricFamilies(f)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", filename, err)
+	}
+	if mf, ok := families[metricLastSuccess]; ok {
+		for _, metric := range mf.GetMetric() {
+			var k lastSuccessKey
+			for _, lp := range metric.GetLabel() {
+				switch lp.GetName() {
+				case "command":
+					k.command = lp.GetValue()
+				case "zone":
+					k.zone = lp.GetValue()
+				case "provider":
+					k.provider = lp.GetValue()
+				}
+			}
+			last[k] = metric.GetGauge().GetValue()
+		}
+	}
+	return last, nil
+}
+
+// push sends the metrics to a Prometheus pushgateway. Each zone/provider is
+// pushed as its own group (job, command, zone, provider) so that the last
+// success timestamp of a zone that failed is left as it was.
+func (m *runMetrics) push(now time.Time, runErr error) error {
+	var errs []error
+	for _, k := range slices.SortedFunc(maps.Keys(m.zones), compareZoneResultKeys) {
+		zm := m.zones[k]
+		reg := prometheus.NewRegistry()
+		zg := newZoneGauges(reg, nil)
+		zg.set(nil, zm)
+		if zm.failures == 0 {
+			zg.lastSuccess.WithLabelValues().Set(float64(now.Unix()))
+		}
+		err := push.New(m.pushgateway, m.job).
+			Grouping("command", m.command).
+			Grouping("zone", k.zone).
+			Grouping("provider", k.provider).
+			Gatherer(reg).
+			Add()
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s (%s): %w", k.zone, k.provider, err))
+		}
+	}
+
+	reg := prometheus.NewRegistry()
+	newRunGauges(reg, nil).set(nil, m.start, now, runErr)
+	if err := push.New(m.pushgateway, m.job).Grouping("command", m.command).Gatherer(reg).Add(); err != nil {
+		errs = append(errs, err)
+	}
+	return errors.Join(errs...)
+}
+
+func compareZoneResultKeys(a, b zoneResultKey) int {
+	return cmp.Or(strings.Compare(a.zone, b.zone), strings.Compare(a.provider, b.provider))
+}
+
+// zoneGauges are the per zone/provider metrics.
+type zoneGauges struct {
+	corrections, executed, failures, lastSuccess *prometheus.GaugeVec
+	apiSeconds                                   *prometheus.GaugeVec // With an additional "phase" label.
+}
+
+func newZoneGauges(reg *prometheus.Registry, labels []string) *zoneGauges {
+	gauge := func(name, help string, labels []string) *prometheus.GaugeVec {
+		g := prometheus.NewGaugeVec(prometheus.GaugeOpts{Name: name, Help: help}, labels)
+		reg.MustRegister(g)
+		return g
+	}
+	return &zoneGauges{
+		corrections: gauge(metricCorrections, "Number of corrections found for the zone at the provider.", labels),
+		executed:    gauge(metricExecuted, "Number of corrections that were run successfully.", labels),
+		failures:    gauge(metricFailures, "Number of failures getting the zone's records or running its corrections.", labels),
+		lastSuccess: gauge(metricLastSuccess, "When the zone was last processed without failures (Unix time).", labels),
+		apiSeconds:  gauge(metricAPISeconds, "Time spent calling the provider. phase is gather (getting records, generating corrections) or push (running corrections).", append(slices.Clone(labels), "phase")),
+	}
+}
+
+func (zg *zoneGauges) set(values []string, zm *zoneMetrics) {
+	zg.corrections.WithLabelValues(values...).Set(float64(zm.corrections))
+	zg.executed.WithLabelValues(values...).Set(float64(zm.executed))
+	zg.failures.WithLabelValues(values...).Set(float64(zm.failures))
+	zg.apiSeconds.WithLabelValues(append(slices.Clone(values), "gather")...).Set(zm.gatherSeconds)
+	zg.apiSeconds.WithLabelValues(append(slices.Clone(values), "push")...).Set(zm.pushSeconds)
+}
+
+// runGauges are the metrics of the run as a whole.
+type runGauges struct {
+	success, timestamp, seconds *prometheus.GaugeVec
+}
+
+func newRunGauges(reg *prometheus.Registry, labels []string) *runGauges {
+	gauge := func(name, help string) *prometheus.GaugeVec {
+		g := prometheus.NewGaugeVec(prometheus.GaugeOpts{Name: name, Help: help}, labels)
+		reg.MustRegister(g)
+		return g
+	}
+	return &runGauges{
+		success:   gauge(metricRunSuccess, "1 if the run completed without errors, otherwise 0."),
+		timestamp: gauge(metricRunTime, "When the run ended (Unix time)."),
+		seconds:   gauge(metricRunSeconds, "How long the run took."),
+	}
+}
+
+func (rg *runGauges) set(values []string, start, end time.Time, runErr error) {
+	success := 1.0
+	if runErr != nil {
+		success = 0
+	}
+	rg.success.WithLabelValues(values...).Set(success)
+	rg.timestamp.WithLabelValues(values...).Set(float64(end.Unix()))
+	rg.seconds.WithLabelValues(values...).Set(end.Sub(start).Seconds())
+}
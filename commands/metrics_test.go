@@ -0,0 +1,153 @@
+package commands
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/DNSControl/dnscontrol/v4/models"
+)
+
+// runTestMetrics records a run with one zone that succeeded and one that failed.
+func runTestMetrics(m *runMetrics) {
+	good := &models.DomainConfig{UniqueName: "example.com"}
+	bad := &models.DomainConfig{UniqueName: "example.net"}
+
+	m.gathered(good, "bind", time.Second, nil)
+	m.counted(good, "bind", 2)
+	for _, c := range m.instrument(good, "bind", []*models.Correction{
+		{Msg: "report"},
+		{Msg: "ok", F: func() error { return nil }},
+		{Msg: "ok", F: func() error { return nil }},
+	}) {
+		if c.F != nil {
+			_ = c.F()
+		}
+	}
+
+	m.gathered(bad, "bind", time.Second, nil)
+	m.counted(bad, "bind", 1)
+	for _, c := range m.instrument(bad, "bind", []*models.Correction{
+		{Msg: "fail", F: func() error { return errors.New("boom") }},
+	}) {
+		_ = c.F()
+	}
+}
+
+func TestRunMetrics_nil(t *testing.T) {
+	m := newRunMetrics(PPreviewArgs{}, "push")
+	if m != nil {
+		t.Fatalf("newRunMetrics() = %v, want nil", m)
+	}
+	runTestMetrics(m) // Must not panic.
+	if err := m.write(nil); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestRunMetrics_writeFile(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "dnscontrol.prom")
+
+	// The previous run succeeded for both zones.
+	previous := `# HELP dnscontrol_last_success_timestamp_seconds When the zone was last processed without failures (Unix time).
+# TYPE dnscontrol_last_success_timestamp_seconds gauge
+dnscontrol_last_success_timestamp_seconds{command="push",provider="bind",zone="example.com"} 1000
+dnscontrol_last_success_timestamp_seconds{command="push",provider="bind",zone="example.net"} 1000
+dnscontrol_last_success_timestamp_seconds{command="preview",provider="bind",zone="example.org"} 1000
+`
+	if err := os.WriteFile(file, []byte(previous), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	m := newRunMetrics(PPreviewArgs{MetricsFile: file}, "push")
+	runTestMetrics(m)
+	if err := m.write(errors.New("completed with errors")); err != nil {
+		t.Fatal(err)
+	}
+
+	b, err := os.ReadFile(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := string(b)
+	for _, want := range []string{
+		`dnscontrol_corrections{command="push",provider="bind",zone="example.com"} 2`,
+		`dnscontrol_corrections_executed{command="push",provider="bind",zone="example.com"} 2`,
+		`dnscontrol_failures{command="push",provider="bind",zone="example.com"} 0`,
+		`dnscontrol_corrections_executed{command="push",provider="bind",zone="example.net"} 0`,
+		`dnscontrol_failures{command="push",provider="bind",zone="example.net"} 1`,
+		`dnscontrol_api_duration_seconds{command="push",phase="gather",provider="bind",zone="example.net"} 1`,
+		`dnscontrol_last_success_timestamp_seconds{command="push",provider="bind",zone="example.net"} 1000`,    // Carried forward.
+		`dnscontrol_last_success_timestamp_seconds{command="preview",provider="bind",zone="example.org"} 1000`, // Another command's.
+		`dnscontrol_run_success{command="push"} 0`,
+	} {
+		if !strings.Contains(got, want+"\n") {
+			t.Errorf("metrics file is missing %q:\n%s", want, got)
+		}
+	}
+	for _, notWant := range []string{
+		`dnscontrol_last_success_timestamp_seconds{command="push",provider="bind",zone="example.com"} 1000`, // Updated.
+	} {
+		if strings.Contains(got, notWant) {
+			t.Errorf("metrics file has %q:\n%s", notWant, got)
+		}
+	}
+}
+
+func TestRunMetrics_push(t *testing.T) {
+	var mu sync.Mutex
+	pushed := map[string]string{} // grouping labels -> body
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			t.Errorf("got %s, want POST so that other metrics in the group are kept", r.Method)
+		}
+		b, _ := io.ReadAll(r.Body)
+		// The grouping labels may be in any order.
+		parts := strings.Split(strings.TrimPrefix(r.URL.Path, "/metrics/"), "/")
+		var pairs []string
+		for i := 0; i+1 < len(parts); i += 2 {
+			pairs = append(pairs, parts[i]+"="+parts[i+1])
+		}
+		slices.Sort(pairs)
+		mu.Lock()
+		pushed[strings.Join(pairs, ",")] = string(b)
+		mu.Unlock()
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer srv.Close()
+
+	m := newRunMetrics(PPreviewArgs{MetricsPushgateway: srv.URL, MetricsJob: "dns"}, "push")
+	runTestMetrics(m)
+	if err := m.write(nil); err != nil {
+		t.Fatal(err)
+	}
+
+	var paths []string
+	for p := range pushed {
+		paths = append(paths, p)
+	}
+	slices.Sort(paths)
+	want := []string{
+		"command=push,job=dns",
+		"command=push,job=dns,provider=bind,zone=example.com",
+		"command=push,job=dns,provider=bind,zone=example.net",
+	}
+	if !slices.Equal(paths, want) {
+		t.Fatalf("pushed to %v, want %v", paths, want)
+	}
+	// The failed zone must not overwrite its last success.
+	if strings.Contains(pushed[want[2]], "dnscontrol_last_success_timestamp_seconds") {
+		t.Errorf("failed zone pushed a last success timestamp")
+	}
+	if !strings.Contains(pushed[want[1]], "dnscontrol_last_success_timestamp_seconds") {
+		t.Errorf("successful zone did not push a last success timestamp")
+	}
+}
@@ -0,0 +1,44 @@
+package commands
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+
+	"github.com/DNSControl/dnscontrol/v4/pkg/printer"
+)
+
+// writeMTASTSPolicies writes the policy files of MTASTS_BUILDER() whose
+// content changed. A relative file name is relative to the directory of
+// jsFile (dnsconfig.js). If write is false, the files that would be written
+// are only listed.
+func writeMTASTSPolicies(policies map[string]string, jsFile string, write bool, out printer.Printer) error {
+	var errs []error
+	for _, name := range slices.Sorted(maps.Keys(policies)) {
+		file := name
+		if !filepath.IsAbs(file) {
+			file = filepath.Join(filepath.Dir(jsFile), file)
+		}
+		policy := []byte(policies[name])
+		if old, err := os.ReadFile(file); err == nil && bytes.Equal(old, policy) {
+			continue
+		}
+		if !write {
+			out.Printf("MTA-STS policy %q will be written by push\n", file)
+			continue
+		}
+		out.Printf("Writing MTA-STS policy %q\n", file)
+		if err := os.MkdirAll(filepath.Dir(file), 0o755); err != nil {
+			errs = append(errs, fmt.Errorf("could not write MTA-STS policy: %w", err))
+			continue
+		}
+		if err := os.WriteFile(file, policy, 0o644); err != nil {
+			errs = append(errs, fmt.Errorf("could not write MTA-STS policy: %w", err))
+		}
+	}
+	return errors.Join(errs...)
+}
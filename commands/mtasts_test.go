@@ -0,0 +1,40 @@
+package commands
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/DNSControl/dnscontrol/v4/pkg/printer"
+)
+
+func Test_writeMTASTSPolicies(t *testing.T) {
+	dir := t.TempDir()
+	jsFile := filepath.Join(dir, "dnsconfig.js")
+	if err := os.WriteFile(jsFile, nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	policy := "version: STSv1\r\nmode: testing\r\nmx: mx.example.com\r\nmax_age: 604800\r\n"
+	policies := map[string]string{"www/mta-sts/.well-known/mta-sts.txt": policy}
+	file := filepath.Join(dir, "www", "mta-sts", ".well-known", "mta-sts.txt")
+
+	// preview does not write the file.
+	if err := writeMTASTSPolicies(policies, jsFile, false, printer.DefaultPrinter); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(file); !os.IsNotExist(err) {
+		t.Errorf("preview wrote the policy file: %v", err)
+	}
+
+	// push writes it next to dnsconfig.js, creating the directories.
+	if err := writeMTASTSPolicies(policies, jsFile, true, printer.DefaultPrinter); err != nil {
+		t.Fatal(err)
+	}
+	if dat, err := os.ReadFile(file); err != nil || string(dat) != policy {
+		t.Errorf("the policy file is %q, %v, want %q", dat, err, policy)
+	}
+
+	if err := writeMTASTSPolicies(map[string]string{"dnsconfig.js/mta-sts.txt": policy}, jsFile, true, printer.DefaultPrinter); err == nil {
+		t.Error("writing below a file succeeded")
+	}
+}
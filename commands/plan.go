@@ -0,0 +1,406 @@
+package commands
+
+import (
+	"cmp"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"os"
+	"reflect"
+	"slices"
+	"sync"
+
+	"github.com/DNSControl/dnscontrol/v4/models"
+	"github.com/DNSControl/dnscontrol/v4/pkg/diff2"
+	"github.com/DNSControl/dnscontrol/v4/pkg/zonerecs"
+)
+
+// PlanVersion is the version of the plan file format. It is incremented
+// whenever a change is made that would break a program that reads the file.
+const PlanVersion = 1
+
+// Plan is a machine-readable list of the changes that preview found (or that
+// push attempted).  It is generated from the same diff2 instructions the
+// providers use to generate their corrections.
+//
+// Corrections that are not changes to a zone's records, such as a provider's
+// config file, its catalog zone, or the delegation and DS records at the
+// registrar, are listed in PlanZone.Other by their messages.
+//
+// A plan also records a fingerprint of the existing records of each zone.
+// `push --plan` uses it to refuse to push if the zone changed since the plan
+// was made.
+type Plan struct {
+	Version int         `json:"version"`
+	Zones   []*PlanZone `json:"zones"`
+}
+
+// PlanZone is the list of changes for a particular zone at a particular DNS
+// provider (or at its registrar, if Registrar is true).
+type PlanZone struct {
+	Domain        string        `json:"domain"`
+	Tag           string        `json:"tag,omitempty"`
+	Provider      string        `json:"provider"`
+	Registrar     bool          `json:"registrar,omitempty"`
+	ExistingCount int           `json:"existing_count"`
+	Fingerprint   string        `json:"existing_fingerprint"`
+	Changes       []*PlanChange `json:"changes"`
+	Other         []string      `json:"other,omitempty"` // Messages of the corrections that are not record changes.
+}
+
+// PlanChange is the JSON representation of a diff2.Change.
+type PlanChange struct {
+	Verb  string        `json:"verb"`            // CREATE, CHANGE, DELETE, REPORT
+	Label string        `json:"label,omitempty"` // Shortname ("@" for the apex)
+	Name  string        `json:"name,omitempty"`  // FQDN
+	Type  string        `json:"rtype,omitempty"`
+	Old   []*PlanRecord `json:"old,omitempty"`
+	New   []*PlanRecord `json:"new,omitempty"`
+	Msgs  []string      `json:"msgs,omitempty"`
+}
+
+// PlanRecord is the JSON representation of a models.RecordConfig.
+type PlanRecord struct {
+	Label string `json:"label"`
+	Type  string `json:"rtype"`
+	TTL   uint32 `json:"ttl"`
+	Value string `json:"value"`
+}
+
+// zoneResultKey identifies a zone/provider pair.
+type zoneResultKey struct {
+	zone     string // DomainConfig.UniqueName
+	provider string
+}
+
+// zoneResultCache collects the zonerecs.ZoneResult of each zone/provider
+// pair processed during the gathering phase.  It is safe for concurrent use.
+type zoneResultCache struct {
+	results map[zoneResultKey]zonerecs.ZoneResult
+	sync.Mutex
+}
+
+// newZoneResultCache creates a zoneResultCache.
+func newZoneResultCache() *zoneResultCache {
+	return &zoneResultCache{results: map[zoneResultKey]zonerecs.ZoneResult{}}
+}
+
+func (zrc *zoneResultCache) store(zone *models.DomainConfig, providerName string, zr zonerecs.ZoneResult) {
+	zrc.Lock()
+	defer zrc.Unlock()
+	zrc.results[zoneResultKey{zone.UniqueName, providerName}] = zr
+}
+
+func (zrc *zoneResultCache) get(zone *models.DomainConfig, providerName string) (zonerecs.ZoneResult, bool) {
+	zrc.Lock()
+	defer zrc.Unlock()
+	zr, ok := zrc.results[zoneResultKey{zone.UniqueName, providerName}]
+	return zr, ok
+}
+
+// genPlan generates a Plan from the results gathered for the zones and
+// providers that were processed, and for their catalog zones. The output is
+// sorted so that it is stable from run to run, no matter the order the zones
+// were gathered in.
+func genPlan(zones []*models.DomainConfig, providerFilter string, zrc *zoneResultCache, catalogs []catalogZone) *Plan {
+	plan := &Plan{Version: PlanVersion, Zones: []*PlanZone{}}
+	for _, zone := range zones {
+		providersToProcess := whichProvidersToProcess(zone.DNSProviderInstances, providerFilter)
+		for _, provider := range providersToProcess {
+			zr, ok := zrc.get(zone, provider.Name)
+			if !ok {
+				continue // Gathering failed or was skipped.
+			}
+			plan.Zones = append(plan.Zones, &PlanZone{
+				Domain:        zone.Name,
+				Tag:           zone.Tag,
+				Provider:      provider.Name,
+				ExistingCount: len(zr.Existing),
+				Fingerprint:   fingerprintRecords(zr.Existing),
+				Changes:       genPlanChanges(zr.Changes),
+				Other: genPlanOther(slices.DeleteFunc(slices.Clone(zr.Corrections), func(c *models.Correction) bool {
+					return !c.NonRecord
+				})),
+			})
+		}
+
+		// The registrar is processed under the same condition as in PHASE 3.
+		if skipProvider(zone.RegistrarInstance.Name, providersToProcess) {
+			if other := genPlanOther(zone.GetCorrections(zone.RegistrarInstance.Name)); len(other) != 0 {
+				plan.Zones = append(plan.Zones, &PlanZone{
+					Domain:    zone.Name,
+					Tag:       zone.Tag,
+					Provider:  zone.RegistrarInstance.Name,
+					Registrar: true,
+					Changes:   []*PlanChange{},
+					Other:     other,
+				})
+			}
+		}
+	}
+	for _, cz := range catalogs {
+		if other := genPlanOther(cz.corrections); cz.err == nil && len(other) != 0 {
+			plan.Zones = append(plan.Zones, &PlanZone{
+				Domain:   cz.zone.Name,
+				Provider: cz.provider.Name,
+				Changes:  []*PlanChange{},
+				Other:    other,
+			})
+		}
+	}
+	slices.SortStableFunc(plan.Zones, func(a, b *PlanZone) int {
+		return cmp.Or(
+			cmp.Compare(a.Domain, b.Domain),
+			cmp.Compare(a.Tag, b.Tag),
+			cmp.Compare(a.Provider, b.Provider),
+			compareBool(a.Registrar, b.Registrar),
+		)
+	})
+	return plan
+}
+
+func genPlanChanges(changes diff2.ChangeList) []*PlanChange {
+	pcs := make([]*PlanChange, 0, len(changes))
+	for _, c := range changes {
+		pc := &PlanChange{
+			Verb: c.Type.String(),
+			Name: c.Key.NameFQDN,
+			Type: c.Key.Type,
+			Old:  genPlanRecords(c.Old),
+			New:  genPlanRecords(c.New),
+			Msgs: parseCorrectionMsg(c.MsgsJoined),
+		}
+		// ByRecord() and ByLabel() leave .Key.Type blank. Fill in what we can from the records.
+		if r := cmp.Or(first(c.New), first(c.Old)); r != nil {
+			pc.Label = r.GetLabel()
+			pc.Name = cmp.Or(pc.Name, r.GetLabelFQDN())
+			if pc.Type == "" && allSameType(c.Old, c.New) {
+				pc.Type = r.Type
+			}
+		}
+		pcs = append(pcs, pc)
+	}
+	return pcs
+}
+
+// genPlanOther returns the messages of the corrections that would be
+// executed. Informational corrections (without .F) are skipped.
+func genPlanOther(corrections []*models.Correction) []string {
+	var msgs []string
+	for _, c := range corrections {
+		if c.F != nil {
+			msgs = append(msgs, parseCorrectionMsg(c.Msg)...)
+		}
+	}
+	return msgs
+}
+
+func compareBool(a, b bool) int {
+	switch {
+	case a == b:
+		return 0
+	case a:
+		return 1
+	}
+	return -1
+}
+
+func genPlanRecords(recs models.Records) []*PlanRecord {
+	if len(recs) == 0 {
+		return nil
+	}
+	prs := make([]*PlanRecord, len(recs))
+	for i, r := range recs {
+		prs[i] = &PlanRecord{
+			Label: r.GetLabel(),
+			Type:  r.Type,
+			TTL:   r.TTL,
+			Value: r.GetTargetCombined(),
+		}
+	}
+	return prs
+}
+
+func first(recs models.Records) *models.RecordConfig {
+	if len(recs) == 0 {
+		return nil
+	}
+	return recs[0]
+}
+
+// allSameType returns true if all the records have the same rtype.
+func allSameType(lists ...models.Records) bool {
+	t := ""
+	for _, recs := range lists {
+		for _, r := range recs {
+			if t == "" {
+				t = r.Type
+			} else if r.Type != t {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// fingerprintRecords returns a hash of a zone's records. The order of the
+// records does not matter. SOA serial numbers are not included since
+// ToComparableNoTTL() omits them.
+func fingerprintRecords(recs models.Records) string {
+	lines := make([]string, len(recs))
+	for i, r := range recs {
+		lines[i] = fmt.Sprintf("%s %d %s %s", r.GetLabelFQDN(), r.TTL, r.Type, r.ToComparableNoTTL())
+	}
+	slices.Sort(lines)
+
+	h := sha256.New()
+	for _, l := range lines {
+		h.Write([]byte(l))
+		h.Write([]byte{'\n'})
+	}
+	return "sha256:" + hex.EncodeToString(h.Sum(nil))
+}
+
+// String returns the name of the zone/provider in a human-friendly format.
+func (pz *PlanZone) String() string {
+	if pz.Tag == "" {
+		return fmt.Sprintf("%s (%s)", pz.Domain, pz.providerName())
+	}
+	return fmt.Sprintf("%s!%s (%s)", pz.Domain, pz.Tag, pz.providerName())
+}
+
+func (pz *PlanZone) providerName() string {
+	if pz.Registrar {
+		return "registrar " + pz.Provider
+	}
+	return pz.Provider
+}
+
+// verifyPlan compares a previously saved plan to the plan generated by this
+// run. It returns one message for each zone that does not match. A zone
+// matches if the existing records have the same fingerprint and the same
+// changes would be made. A zone that is not in the plan only matches if no
+// changes would be made.
+//
+// The other corrections are compared for each provider as a whole, since a
+// provider may attach its config file or catalog zone updates to whichever
+// zone it processes first.
+func verifyPlan(saved, current *Plan) []string {
+	type key struct {
+		domain, tag, provider string
+		registrar             bool
+	}
+	savedZones := map[key]*PlanZone{}
+	for _, pz := range saved.Zones {
+		savedZones[key{pz.Domain, pz.Tag, pz.Provider, pz.Registrar}] = pz
+	}
+
+	var problems []string
+	for _, cz := range current.Zones {
+		k := key{cz.Domain, cz.Tag, cz.Provider, cz.Registrar}
+		sz, ok := savedZones[k]
+		delete(savedZones, k)
+		switch {
+		case !ok && len(cz.Changes) == 0:
+			// Nothing to do, so nothing to plan.
+		case !ok:
+			problems = append(problems, fmt.Sprintf("%s: %d changes are not in the plan", cz, len(cz.Changes)))
+		case sz.Fingerprint != cz.Fingerprint:
+			problems = append(problems, fmt.Sprintf("%s: the records at the provider changed since the plan was made (%d records then, %d now)",
+				cz, sz.ExistingCount, cz.ExistingCount))
+		case !planChangesEqual(sz.Changes, cz.Changes):
+			problems = append(problems, fmt.Sprintf("%s: the changes to be made differ from the plan (%d changes planned, %d now)",
+				cz, len(sz.Changes), len(cz.Changes)))
+		}
+	}
+
+	// Anything left over was planned but not gathered this time. That is
+	// only a problem if changes were planned for it.
+	for _, sz := range saved.Zones {
+		if _, ok := savedZones[key{sz.Domain, sz.Tag, sz.Provider, sz.Registrar}]; ok && len(sz.Changes) != 0 {
+			problems = append(problems, fmt.Sprintf("%s: changes are planned but the zone was not processed", sz))
+		}
+	}
+
+	savedOther, currentOther := planOther(saved), planOther(current)
+	all := maps.Clone(savedOther)
+	maps.Copy(all, currentOther)
+	for _, p := range slices.Sorted(maps.Keys(all)) {
+		if !slices.Equal(savedOther[p], currentOther[p]) {
+			problems = append(problems, fmt.Sprintf("%s: the changes to its config, catalog zone or delegations differ from the plan (%d planned, %d now)",
+				p, len(savedOther[p]), len(currentOther[p])))
+		}
+	}
+	return problems
+}
+
+// planOther returns the sorted messages of the other corrections of each
+// provider (or registrar) in plan.
+func planOther(plan *Plan) map[string][]string {
+	other := map[string][]string{}
+	for _, pz := range plan.Zones {
+		if len(pz.Other) == 0 {
+			continue
+		}
+		other[pz.providerName()] = append(other[pz.providerName()], pz.Other...)
+	}
+	for _, msgs := range other {
+		slices.Sort(msgs)
+	}
+	return other
+}
+
+// planChangesEqual returns true if a and b describe the same changes. The
+// messages are not compared since they are for humans and may include
+// formatting that varies.
+func planChangesEqual(a, b []*PlanChange) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		x, y := *a[i], *b[i]
+		x.Msgs, y.Msgs = nil, nil
+		if !reflect.DeepEqual(x, y) {
+			return false
+		}
+	}
+	return true
+}
+
+// readPlan reads a plan file written by writePlan.
+func readPlan(filename string) (*Plan, error) {
+	b, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	plan := &Plan{}
+	if err := json.Unmarshal(b, plan); err != nil {
+		return nil, fmt.Errorf("%s: %w", filename, err)
+	}
+	if plan.Version != PlanVersion {
+		return nil, fmt.Errorf("%s: plan version %d is not supported (expected %d)", filename, plan.Version, PlanVersion)
+	}
+	return plan, nil
+}
+
+func writePlan(filename string, plan *Plan) error {
+	// No filename? No plan.
+	if filename == "" {
+		return nil
+	}
+
+	f, err := os.OpenFile(filename, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o644)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	// Disabling HTML encoding
+	enc := json.NewEncoder(f)
+	enc.SetIndent("", "  ")
+	enc.SetEscapeHTML(false)
+
+	return enc.Encode(plan)
+}
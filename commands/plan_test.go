@@ -0,0 +1,183 @@
+package commands
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/DNSControl/dnscontrol/v4/models"
+	"github.com/DNSControl/dnscontrol/v4/pkg/diff2"
+)
+
+func makePlanRec(label, rtype, content string) *models.RecordConfig {
+	r := &models.RecordConfig{TTL: 300}
+	r.SetLabel(label, "example.com")
+	if err := r.PopulateFromString(rtype, content, "example.com"); err != nil {
+		panic(err)
+	}
+	return r
+}
+
+func Test_genPlanChanges(t *testing.T) {
+	dc := models.MustNewDomainConfig("example.com")
+	dc.Records = models.Records{
+		makePlanRec("www", "A", "1.2.3.4"),
+		makePlanRec("mail", "A", "5.6.7.8"),
+	}
+	existing := models.Records{
+		makePlanRec("www", "A", "1.2.3.4"),
+		makePlanRec("mail", "A", "9.9.9.9"),
+		makePlanRec("old", "TXT", "goodbye"),
+	}
+
+	diff2.StartRecording(dc)
+	_, _, err := diff2.ByRecordSet(existing, dc, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	changes := diff2.StopRecording(dc)
+
+	got := genPlanChanges(changes)
+	want := []*PlanChange{
+		{
+			Verb:  "CHANGE",
+			Label: "mail",
+			Name:  "mail.example.com",
+			Type:  "A",
+			Old:   []*PlanRecord{{Label: "mail", Type: "A", TTL: 300, Value: "9.9.9.9"}},
+			New:   []*PlanRecord{{Label: "mail", Type: "A", TTL: 300, Value: "5.6.7.8"}},
+		},
+		{
+			Verb:  "DELETE",
+			Label: "old",
+			Name:  "old.example.com",
+			Type:  "TXT",
+			Old:   []*PlanRecord{{Label: "old", Type: "TXT", TTL: 300, Value: `"goodbye"`}},
+		},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("genPlanChanges() returned %d changes, want %d", len(got), len(want))
+	}
+	for i := range want {
+		got[i].Msgs = nil // The message format is tested elsewhere.
+		if !reflect.DeepEqual(got[i], want[i]) {
+			t.Errorf("genPlanChanges()[%d] = %+v, want %+v", i, got[i], want[i])
+		}
+	}
+
+	// Recording must stop once the results are collected.
+	if cl := diff2.StopRecording(dc); cl != nil {
+		t.Errorf("StopRecording() after stop = %v, want nil", cl)
+	}
+}
+
+func Test_verifyPlan(t *testing.T) {
+	change := &PlanChange{Verb: "CREATE", Label: "www", Name: "www.example.com", Type: "A",
+		New: []*PlanRecord{{Label: "www", Type: "A", TTL: 300, Value: "1.2.3.4"}}}
+	zone := func(domain, fp string, changes ...*PlanChange) *PlanZone {
+		return &PlanZone{Domain: domain, Provider: "bind", Fingerprint: fp, Changes: changes}
+	}
+	saved := &Plan{Version: PlanVersion, Zones: []*PlanZone{
+		zone("a.com", "sha256:aaa", change),
+		zone("b.com", "sha256:bbb", change),
+		zone("c.com", "sha256:ccc"),
+	}}
+
+	tests := []struct {
+		name    string
+		current []*PlanZone
+		want    int
+	}{
+		{
+			name:    "match",
+			current: []*PlanZone{zone("a.com", "sha256:aaa", change), zone("b.com", "sha256:bbb", change), zone("c.com", "sha256:ccc")},
+			want:    0,
+		},
+		{
+			name:    "unchangedZoneNotProcessed",
+			current: []*PlanZone{zone("a.com", "sha256:aaa", change), zone("b.com", "sha256:bbb", change)},
+			want:    0,
+		},
+		{
+			name:    "drift",
+			current: []*PlanZone{zone("a.com", "sha256:xxx", change), zone("b.com", "sha256:bbb", change), zone("c.com", "sha256:ccc")},
+			want:    1,
+		},
+		{
+			name:    "changesDiffer",
+			current: []*PlanZone{zone("a.com", "sha256:aaa"), zone("b.com", "sha256:bbb", change), zone("c.com", "sha256:ccc", change)},
+			want:    2,
+		},
+		{
+			name:    "unchangedZoneNotInPlan",
+			current: []*PlanZone{zone("a.com", "sha256:aaa", change), zone("b.com", "sha256:bbb", change), zone("d.com", "sha256:ddd")},
+			want:    0,
+		},
+		{
+			name:    "notInPlanAndNotProcessed",
+			current: []*PlanZone{zone("a.com", "sha256:aaa", change), zone("d.com", "sha256:ddd", change)},
+			want:    2,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := verifyPlan(saved, &Plan{Version: PlanVersion, Zones: tt.current})
+			if len(got) != tt.want {
+				t.Errorf("verifyPlan() = %q, want %d problems", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_verifyPlan_other(t *testing.T) {
+	zone := func(domain string, other ...string) *PlanZone {
+		return &PlanZone{Domain: domain, Provider: "bind", Fingerprint: "sha256:" + domain, Other: other}
+	}
+	registrar := func(domain string, other ...string) *PlanZone {
+		return &PlanZone{Domain: domain, Provider: "bind", Registrar: true, Other: other}
+	}
+	saved := &Plan{Version: PlanVersion, Zones: []*PlanZone{
+		zone("a.com", "WRITE config"),
+		zone("b.com"),
+		registrar("b.com", "DS b.com"),
+	}}
+
+	tests := []struct {
+		name    string
+		current []*PlanZone
+		want    int
+	}{
+		{
+			name:    "match",
+			current: []*PlanZone{zone("a.com", "WRITE config"), zone("b.com"), registrar("b.com", "DS b.com")},
+			want:    0,
+		},
+		{
+			name:    "claimedByAnotherZone",
+			current: []*PlanZone{zone("a.com"), zone("b.com", "WRITE config"), registrar("b.com", "DS b.com")},
+			want:    0,
+		},
+		{
+			name:    "configDiffers",
+			current: []*PlanZone{zone("a.com", "WRITE config", "+ ADD ZONE c.com"), zone("b.com"), registrar("b.com", "DS b.com")},
+			want:    1,
+		},
+		{
+			name:    "registrarNotInPlan",
+			current: []*PlanZone{zone("a.com", "WRITE config"), zone("b.com"), registrar("b.com", "DS b.com"), registrar("a.com", "DS a.com")},
+			want:    1,
+		},
+		{
+			name:    "registrarNotProcessed",
+			current: []*PlanZone{zone("a.com", "WRITE config"), zone("b.com")},
+			want:    1,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := verifyPlan(saved, &Plan{Version: PlanVersion, Zones: tt.current})
+			if len(got) != tt.want {
+				t.Errorf("verifyPlan() = %q, want %d problems", got, tt.want)
+			}
+		})
+	}
+}
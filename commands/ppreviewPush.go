@@ -20,7 +20,9 @@ import (
 	"github.com/DNSControl/dnscontrol/v4/models"
 	"github.com/DNSControl/dnscontrol/v4/pkg/bindserial"
 	"github.com/DNSControl/dnscontrol/v4/pkg/credsfile"
+	"github.com/DNSControl/dnscontrol/v4/pkg/diff2"
 	"github.com/DNSControl/dnscontrol/v4/pkg/domaintags"
+	"github.com/DNSControl/dnscontrol/v4/pkg/dssync"
 	"github.com/DNSControl/dnscontrol/v4/pkg/nameservers"
 	"github.com/DNSControl/dnscontrol/v4/pkg/normalize"
 	"github.com/DNSControl/dnscontrol/v4/pkg/notifications"
@@ -59,14 +61,21 @@ type PPreviewArgs struct {
 	GetDNSConfigArgs
 	GetCredentialsArgs
 	FilterArgs
-	Notify            bool
-	WarnChanges       bool
-	ConcurMode        string
-	ConcurMax         int // Maximum number of concurrent connections
-	NoPopulate        bool
-	PopulateOnPreview bool
-	Report            string
-	Full              bool
+	Notify             bool
+	WarnChanges        bool
+	ConcurMode         string
+	ConcurMax          int // Maximum number of concurrent connections
+	NoPopulate         bool
+	PopulateOnPreview  bool
+	Report             string
+	SavePlan           string
+	MaxDeletes         int // Refuse to delete more records than this per zone (-1 = no limit)
+	MaxChangePercent   int // Refuse to delete/modify more than this % of a zone (-1 = no limit)
+	MetricsFile        string
+	MetricsPushgateway string
+	MetricsJob         string
+	Output             string // "text" or "json"
+	Full               bool
 }
 
 // ReportItem is a record of corrections for a particular domain/provider/registrar.
@@ -152,6 +161,66 @@ func (args *PPreviewArgs) flags() []cli.Flag {
 		Destination: &args.Report,
 		Usage:       `Generate a machine-parseable report of corrections.`,
 	})
+	flags = append(flags, &cli.StringFlag{
+		Name:        "save-plan",
+		Destination: &args.SavePlan,
+		Usage:       `Write a machine-readable (JSON) plan of every change to this file`,
+	})
+	flags = append(flags, &cli.IntFlag{
+		Name:        "max-deletes",
+		Destination: &args.MaxDeletes,
+		Value:       -1,
+		Usage:       `Refuse to change a zone if more than this many records would be deleted (-1 = no limit)`,
+		Action: func(ctx context.Context, c *cli.Command, v int) error {
+			if v < -1 {
+				fmt.Printf("%d is not a valid value for --max-deletes.  Values must be -1 or greater\n", v)
+				os.Exit(1)
+			}
+			return nil
+		},
+	})
+	flags = append(flags, &cli.IntFlag{
+		Name:        "max-change-percent",
+		Destination: &args.MaxChangePercent,
+		Value:       -1,
+		Usage:       `Refuse to change a zone if more than this percent of its records would be deleted or modified (-1 = no limit)`,
+		Action: func(ctx context.Context, c *cli.Command, v int) error {
+			if v < -1 {
+				fmt.Printf("%d is not a valid value for --max-change-percent.  Values must be -1 or greater\n", v)
+				os.Exit(1)
+			}
+			return nil
+		},
+	})
+	flags = append(flags, &cli.StringFlag{
+		Name:        "metrics-file",
+		Destination: &args.MetricsFile,
+		Usage:       `Write Prometheus metrics about the run to this file (node_exporter textfile collector format)`,
+	})
+	flags = append(flags, &cli.StringFlag{
+		Name:        "metrics-pushgateway",
+		Destination: &args.MetricsPushgateway,
+		Usage:       `Push Prometheus metrics about the run to the pushgateway at this URL`,
+	})
+	flags = append(flags, &cli.StringFlag{
+		Name:        "metrics-job",
+		Destination: &args.MetricsJob,
+		Value:       "dnscontrol",
+		Usage:       `Job name to use with --metrics-pushgateway`,
+	})
+	flags = append(flags, &cli.StringFlag{
+		Name:        "output",
+		Destination: &args.Output,
+		Value:       "text",
+		Usage:       `Output format: text or json (one JSON object per line)`,
+		Action: func(ctx context.Context, c *cli.Command, s string) error {
+			if !slices.Contains([]string{"text", "json"}, s) {
+				fmt.Printf("%q is not a valid option for --output.  Values are: text, json\n", s)
+				os.Exit(1)
+			}
+			return nil
+		},
+	})
 	return flags
 }
 
@@ -170,7 +239,11 @@ var _ = cmd(catMain, func() *cli.Command {
 // PPushArgs contains all data/flags needed to run push, independently of CLI.
 type PPushArgs struct {
 	PPreviewArgs
-	Interactive bool
+	Interactive   bool
+	PlanFile      string
+	SnapshotDir   string
+	Verify        bool
+	VerifyTimeout time.Duration
 }
 
 func (args *PPushArgs) flags() []cli.Flag {
@@ -180,23 +253,74 @@ func (args *PPushArgs) flags() []cli.Flag {
 		Destination: &args.Interactive,
 		Usage:       "Interactive. Confirm or Exclude each correction before they run",
 	})
+	flags = append(flags, &cli.StringFlag{
+		Name:        "plan",
+		Destination: &args.PlanFile,
+		Usage:       `Only push if the live zones and the changes still match this plan (from preview --save-plan)`,
+	})
+	flags = append(flags, &cli.StringFlag{
+		Name:        "snapshot-dir",
+		Destination: &args.SnapshotDir,
+		Usage:       `Before making changes, save the existing records of each zone to a new snapshot in this directory (see rollback)`,
+	})
+	flags = append(flags, &cli.BoolFlag{
+		Name:        "verify",
+		Destination: &args.Verify,
+		Usage:       `After pushing, wait until every nameserver of each changed zone serves the new records`,
+	})
+	flags = append(flags, &cli.DurationFlag{
+		Name:        "verify-timeout",
+		Destination: &args.VerifyTimeout,
+		Value:       5 * time.Minute,
+		Usage:       `How long --verify waits for the nameservers to serve the new records`,
+	})
 	return flags
 }
 
 // PPreview implements the preview subcommand.
 func PPreview(args PPreviewArgs) error {
-	return prun(args, false, false, printer.DefaultPrinter, args.Report)
+	return prun(PPushArgs{PPreviewArgs: args}, false, cliPrinter(args), args.Report)
 }
 
 // PPush implements the push subcommand.
 func PPush(args PPushArgs) error {
-	return prun(args.PPreviewArgs, true, args.Interactive, printer.DefaultPrinter, args.Report)
+	return prun(args, true, cliPrinter(args.PPreviewArgs), args.Report)
+}
+
+// cliPrinter returns the printer selected by --output.
+func cliPrinter(args PPreviewArgs) printer.CLI {
+	if args.Output == "json" {
+		jp := printer.NewJSONPrinter(os.Stdout, printer.DefaultPrinter.Verbose)
+		// Messages printed by the providers become events too.
+		printer.DefaultPrinter.Writer = jp
+		return jp
+	}
+	return printer.DefaultPrinter
 }
 
 var pobsoleteDiff2FlagUsed = false
 
 // run is the main routine common to preview/push.
-func prun(args PPreviewArgs, push bool, interactive bool, out printer.CLI, report string) error {
+func prun(pargs PPushArgs, push bool, out printer.CLI, report string) (err error) {
+	args := pargs.PPreviewArgs
+	interactive := pargs.Interactive
+	if push && pargs.Verify && interactive {
+		return errors.New("--verify can not be used with -i")
+	}
+	if push && args.Output == "json" && interactive {
+		return errors.New("--output=json can not be used with -i")
+	}
+
+	// Record metrics about the run, however it ends.
+	command := "preview"
+	if push {
+		command = "push"
+	}
+	metrics := newRunMetrics(args, command)
+	defer func() {
+		err = errors.Join(err, metrics.write(err))
+	}()
+
 	// This is a hack until we have the new printer replacement.
 	printer.SkinnyReport = !args.Full
 	fullMode := args.Full
@@ -211,6 +335,15 @@ func prun(args PPreviewArgs, push bool, interactive bool, out printer.CLI, repor
 		return err
 	}
 
+	var savedPlan *Plan
+	if push && pargs.PlanFile != "" {
+		out.PrintfIf(fullMode, "Reading plan: %q\n", pargs.PlanFile)
+		savedPlan, err = readPlan(pargs.PlanFile)
+		if err != nil {
+			return fmt.Errorf("could not read plan: %w", err)
+		}
+	}
+
 	out.PrintfIf(fullMode, "Reading creds: %q\n", args.CredsFile)
 	providerConfigs, err := credsfile.LoadProviderConfigs(args.CredsFile)
 	if err != nil {
@@ -252,6 +385,7 @@ func prun(args PPreviewArgs, push bool, interactive bool, out printer.CLI, repor
 	}
 
 	zcache := NewCmdZoneCache()
+	zresults := newZoneResultCache()
 
 	// Loop over all (or some) zones:
 	zonesToProcess := whichZonesToProcess(cfg.Domains, args.Domains)
@@ -264,7 +398,9 @@ func prun(args PPreviewArgs, push bool, interactive bool, out printer.CLI, repor
 	var concurrentErrors atomic.Bool
 
 	// Populate the zones (if desired/needed/able):
-	if !args.NoPopulate {
+	// When pushing a plan, zones are not created because creating them is not
+	// part of the plan.
+	if !args.NoPopulate && savedPlan == nil {
 		out.PrintfIf(fullMode, "PHASE 1: CHECKING for missing zones\n")
 		t := throttler.New(args.ConcurMax, len(zonesConcurrent))
 		out.Printf("CONCURRENTLY checking for %d zone(s)\n", len(zonesConcurrent))
@@ -276,7 +412,7 @@ func prun(args PPreviewArgs, push bool, interactive bool, out printer.CLI, repor
 				if err != nil {
 					concurrentErrors.Store(true)
 				}
-				out.Debugf("...DONE: %q (%.1fs)\n", zone.Name, time.Since(start).Seconds())
+				out.ForZone(zone.GetUniqueName(), "").Debugf("...DONE: %q (%.1fs)\n", zone.Name, time.Since(start).Seconds())
 				t.Done(err)
 			}(zone)
 			// Delay the last call to t.Throttle() until the serial processing is done.
@@ -330,6 +466,9 @@ func prun(args PPreviewArgs, push bool, interactive bool, out printer.CLI, repor
 					anyErrors = cmp.Or(anyErrors, pprintOrRunCorrections(zone.Name, provider.Name, corrections, out, push || args.PopulateOnPreview, interactive, notifier, report))
 				}
 			}
+			if started {
+				out.EndDomain()
+			}
 		}
 	}
 
@@ -340,11 +479,11 @@ func prun(args PPreviewArgs, push bool, interactive bool, out printer.CLI, repor
 		out.PrintfIf(fullMode, "Concurrently gathering: %q\n", zone.UniqueName)
 		go func(zone *models.DomainConfig, args PPreviewArgs, zcache *CmdZoneCache) {
 			start := time.Now()
-			err := oneZone(zone, args, push)
+			err := oneZone(zone, args, push, zresults, metrics)
 			if err != nil {
 				concurrentErrors.Store(true)
 			}
-			out.Debugf("...DONE: %q (%.1fs)\n", zone.Name, time.Since(start).Seconds())
+			out.ForZone(zone.GetUniqueName(), "").Debugf("...DONE: %q (%.1fs)\n", zone.Name, time.Since(start).Seconds())
 			t.Done(err)
 		}(zone, args, zcache)
 		// Delay the last call to t.Throttle() until the serial processing is done.
@@ -358,7 +497,7 @@ func prun(args PPreviewArgs, push bool, interactive bool, out printer.CLI, repor
 	out.Printf("SERIALLY gathering records of %d zone(s)\n", len(zonesSerial))
 	for _, zone := range zonesSerial {
 		out.Printf("Serially Gathering: %q\n", zone.UniqueName)
-		if err := oneZone(zone, args, push); err != nil {
+		if err := oneZone(zone, args, push, zresults, metrics); err != nil {
 			anyErrors = true
 		}
 	}
@@ -368,7 +507,7 @@ func prun(args PPreviewArgs, push bool, interactive bool, out printer.CLI, repor
 		if printer.DefaultPrinter.Verbose {
 			msg = "Waiting for concurrent gathering(s) to complete...\n"
 		}
-		out.PrintfIf(true, msg)
+		out.PrintfIf(true, "%s", msg)
 		errorCount := t.Throttle()
 		if errorCount > 0 {
 			anyErrors = true
@@ -378,7 +517,38 @@ func prun(args PPreviewArgs, push bool, interactive bool, out printer.CLI, repor
 
 	anyErrors = cmp.Or(anyErrors, concurrentErrors.Load())
 
+	// The catalog zones list the zones, so they are gathered after them.
+	catalogs := gatherCatalogZones(zonesToProcess, args.Providers)
+
+	// Refuse to push if anything changed since the plan was made.
+	if savedPlan != nil {
+		problems := verifyPlan(savedPlan, genPlan(zonesToProcess, args.Providers, zresults, catalogs))
+		for _, p := range problems {
+			out.Errorf("%s\n", p)
+		}
+		if len(problems) != 0 {
+			return fmt.Errorf("refusing to push: %d zone(s) no longer match the plan %q", len(problems), pargs.PlanFile)
+		}
+	}
+
+	// Save the zones that are about to be changed, so they can be rolled back.
+	if push && pargs.SnapshotDir != "" {
+		dir, n, err := saveSnapshot(pargs.SnapshotDir, zonesToProcess, args.Providers, zresults)
+		if err != nil {
+			return fmt.Errorf("refusing to push: could not save snapshot: %w", err)
+		}
+		if n != 0 {
+			out.Printf("Saved a snapshot of %d zone(s) to %q\n", n, dir)
+		}
+	}
+
+	// Publish the MTA-STS policies before the records that announce them.
+	if err := writeMTASTSPolicies(cfg.MTASTSPolicies, args.JSFile, push, out); err != nil {
+		return err
+	}
+
 	// Now we know what to do, print or do the tasks.
+	var pushed []pushedZone // Zones to verify
 	out.PrintfIf(fullMode, "PHASE 3: CORRECTIONS\n")
 	for _, zone := range zonesToProcess {
 		out.StartDomain(zone)
@@ -389,12 +559,17 @@ func prun(args PPreviewArgs, push bool, interactive bool, out printer.CLI, repor
 			skip := skipProvider(provider.Name, providersToProcess)
 			out.StartDNSProvider(provider.Name, skip)
 			if !skip {
-				corrections := zone.GetCorrections(provider.Name)
+				corrections := metrics.instrument(zone, provider.Name, zone.GetCorrections(provider.Name))
 				numActions := zone.GetChangeCount(provider.Name)
+				metrics.counted(zone, provider.Name, numActions)
 				totalCorrections += numActions
 				out.EndProvider2(provider.Name, numActions)
 				reportItems = append(reportItems, genReportItem(zone.Name, corrections, provider.Name, ""))
-				anyErrors = cmp.Or(anyErrors, pprintOrRunCorrections(zone.Name, provider.Name, corrections, out, push, interactive, notifier, report))
+				failed := pprintOrRunCorrections(zone.Name, provider.Name, corrections, out, push, interactive, notifier, report)
+				anyErrors = cmp.Or(anyErrors, failed)
+				if push && pargs.Verify && !failed {
+					pushed = append(pushed, pushedZone{zone, provider})
+				}
 			}
 		}
 
@@ -402,13 +577,36 @@ func prun(args PPreviewArgs, push bool, interactive bool, out printer.CLI, repor
 		skip := skipProvider(zone.RegistrarInstance.Name, providersToProcess)
 		out.StartRegistrar(zone.RegistrarName, !skip)
 		if skip {
-			corrections := zone.GetCorrections(zone.RegistrarInstance.Name)
+			corrections := metrics.instrument(zone, zone.RegistrarInstance.Name, zone.GetCorrections(zone.RegistrarInstance.Name))
 			numActions := zone.GetChangeCount(zone.RegistrarInstance.Name)
+			metrics.counted(zone, zone.RegistrarInstance.Name, numActions)
 			out.EndProvider2(zone.RegistrarName, numActions)
 			totalCorrections += numActions
 			reportItems = append(reportItems, genReportItem(zone.Name, corrections, "", zone.RegistrarName))
 			anyErrors = cmp.Or(anyErrors, pprintOrRunCorrections(zone.Name, zone.RegistrarInstance.Name, corrections, out, push, interactive, notifier, report))
 		}
+		out.EndDomain()
+	}
+
+	// Update the catalog zones after the zones they list:
+	for _, cz := range catalogs {
+		if cz.err != nil {
+			out.ForZone("", cz.provider.Name).Errorf("Provider %s: catalog zone: %s\n", cz.provider.Name, cz.err)
+			anyErrors = true
+			continue
+		}
+		out.StartDomain(cz.zone)
+		out.StartDNSProvider(cz.provider.Name, false)
+		totalCorrections += cz.count
+		out.EndProvider2(cz.provider.Name, cz.count)
+		reportItems = append(reportItems, genReportItem(cz.zone.Name, cz.corrections, cz.provider.Name, ""))
+		anyErrors = cmp.Or(anyErrors, pprintOrRunCorrections(cz.zone.Name, cz.provider.Name, cz.corrections, out, push, interactive, notifier, report))
+		out.EndDomain()
+	}
+
+	// Check that the nameservers serve what was pushed.
+	if len(pushed) != 0 {
+		anyErrors = cmp.Or(verifyPush(pushed, zresults, pargs.VerifyTimeout, out), anyErrors)
 	}
 
 	if os.Getenv("TEAMCITY_VERSION") != "" {
@@ -423,6 +621,10 @@ func prun(args PPreviewArgs, push bool, interactive bool, out printer.CLI, repor
 	if err != nil {
 		return errors.New("could not write report")
 	}
+	err = writePlan(args.SavePlan, genPlan(zonesToProcess, args.Providers, zresults, catalogs))
+	if err != nil {
+		return fmt.Errorf("could not write plan: %w", err)
+	}
 	if anyErrors {
 		return errors.New("completed with errors")
 	}
@@ -567,17 +769,36 @@ func oneZonePopulate(zone *models.DomainConfig, zc *CmdZoneCache) error {
 	return errors.Join(errs...)
 }
 
-func oneZone(zone *models.DomainConfig, args PPreviewArgs, push bool) error {
+func oneZone(zone *models.DomainConfig, args PPreviewArgs, push bool, zresults *zoneResultCache, metrics *runMetrics) error {
 	var errs []error
 	// Fix the parent zone's delegation: (if able/needed)
+	start := time.Now()
 	delegationCorrections, dcCount, err := generateDelegationCorrections(zone, zone.DNSProviderInstances, zone.RegistrarInstance)
+	metrics.gathered(zone, zone.RegistrarInstance.Name, time.Since(start), err)
+	if err != nil {
+		errs = append(errs, err)
+	}
+
+	// With AUTODNSSEC_OFF, the providers keep signing the zone until the DS
+	// records at the registrar are gone from the resolvers' caches:
+	holdUntil, err := dssync.HoldUnsigning(zone, zone.RegistrarInstance.Driver, time.Now())
 	if err != nil {
 		errs = append(errs, err)
 	}
+	if !holdUntil.IsZero() {
+		zone.AutoDNSSEC = "" // Leave DNSSEC as it is.
+		defer func() { zone.AutoDNSSEC = "off" }()
+	}
 
 	// Loop over the (selected) providers configured for that zone:
 	providersToProcess := whichProvidersToProcess(zone.DNSProviderInstances, args.Providers)
 	for _, provider := range providersToProcess {
+		if !holdUntil.IsZero() {
+			zone.StoreCorrections(provider.Name, []*models.Correction{{
+				Msg: fmt.Sprintf("DNSSEC stays on until the DS records at the registrar are gone from the resolvers' caches. A push after %s turns it off", holdUntil.Local().Format(time.DateTime)),
+			}})
+		}
+
 		// If Phase 1 found the zone missing and queued a creation correction
 		// that this run will not execute, fetching records for the not-yet-
 		// created zone would fail with a raw provider error and a non-zero
@@ -590,13 +811,23 @@ func oneZone(zone *models.DomainConfig, args PPreviewArgs, push bool) error {
 		}
 
 		// Update the zone's records at the provider:
-		zoneCor, rep, actualChangeCount, err := generateZoneCorrections(zone, provider)
-		zone.StoreCorrections(provider.Name, rep)
-		zone.StoreCorrections(provider.Name, zoneCor)
-		zone.IncrementChangeCount(provider.Name, actualChangeCount)
+		start := time.Now()
+		zr, err := generateZoneCorrections(zone, provider)
+		if err == nil {
+			// Abort the zone if too much would change:
+			if err = checkChangeLimits(zone, zr, args); err != nil {
+				zr = refuseZoneChanges(zone, provider.Name, zr, err)
+			}
+		}
+		metrics.gathered(zone, provider.Name, time.Since(start), err)
+		zone.StoreCorrections(provider.Name, zr.Reports)
+		zone.StoreCorrections(provider.Name, zr.Corrections)
+		zone.IncrementChangeCount(provider.Name, zr.ActualChangeCount)
 		if err != nil {
 			errs = append(errs, err)
+			continue
 		}
+		zresults.store(zone, provider.Name, zr)
 	}
 
 	// Do the delegation corrections after the zones are updated.
@@ -605,6 +836,38 @@ func oneZone(zone *models.DomainConfig, args PPreviewArgs, push bool) error {
 	return errors.Join(errs...)
 }
 
+// catalogZone is the catalog zone of a provider and its corrections.
+type catalogZone struct {
+	zone        *models.DomainConfig
+	provider    *models.DNSProviderInstance
+	corrections []*models.Correction
+	count       int
+	err         error // The corrections could not be determined.
+}
+
+// gatherCatalogZones returns the catalog zones of the (selected) providers
+// of zones, with the corrections that update them. Each provider's catalog
+// zone is a zone of its own, so that it is updated even if the changes of
+// one of its members are refused.
+func gatherCatalogZones(zones []*models.DomainConfig, filter string) []catalogZone {
+	var catalogs []catalogZone
+	seen := map[string]bool{}
+	for _, zone := range zones {
+		for _, provider := range whichProvidersToProcess(zone.DNSProviderInstances, filter) {
+			cc, ok := provider.Driver.(providers.CatalogZoneCorrector)
+			if !ok || seen[provider.Name] {
+				continue
+			}
+			seen[provider.Name] = true
+			dc, corrections, count, err := cc.CatalogZoneCorrections()
+			if dc != nil || err != nil {
+				catalogs = append(catalogs, catalogZone{zone: dc, provider: provider, corrections: corrections, count: count, err: err})
+			}
+		}
+	}
+	return catalogs
+}
+
 func whichProvidersToProcess(providers []*models.DNSProviderInstance, filter string) []*models.DNSProviderInstance {
 	if filter == "all" { // all
 		return providers
@@ -811,12 +1074,61 @@ func generatePopulateCorrections(provider *models.DNSProviderInstance, dc *model
 	}}, nil
 }
 
-func generateZoneCorrections(zone *models.DomainConfig, provider *models.DNSProviderInstance) ([]*models.Correction, []*models.Correction, int, error) {
-	reports, zoneCorrections, actualChangeCount, err := zonerecs.CorrectZoneRecords(provider.Driver, zone)
+func generateZoneCorrections(zone *models.DomainConfig, provider *models.DNSProviderInstance) (zonerecs.ZoneResult, error) {
+	zr, err := zonerecs.GetZoneResult(provider.Driver, zone)
 	if err != nil {
-		return []*models.Correction{{Msg: fmt.Sprintf("Domain %q provider %s Error: %s", zone.Name, provider.Name, err)}}, nil, 0, err
+		return zonerecs.ZoneResult{
+			Existing:    zr.Existing,
+			Corrections: []*models.Correction{{Msg: fmt.Sprintf("Domain %q provider %s Error: %s", zone.Name, provider.Name, err)}},
+		}, err
+	}
+	return zr, nil
+}
+
+// checkChangeLimits returns an error if the changes to be made to a zone
+// exceed the limits set by CHANGE_LIMIT() or --max-deletes/--max-change-percent.
+func checkChangeLimits(zone *models.DomainConfig, zr zonerecs.ZoneResult, args PPreviewArgs) error {
+	if zr.ActualChangeCount == 0 || !zr.ChangesRecords() {
+		return nil
+	}
+
+	type sourcedLimit struct {
+		source string
+		limit  models.ChangeLimit
+	}
+	limits := []sourcedLimit{{"--max-deletes/--max-change-percent", models.ChangeLimit{MaxDeletes: args.MaxDeletes, MaxChangePercent: args.MaxChangePercent}}}
+	if zone.ChangeLimit != nil {
+		limits = append(limits, sourcedLimit{"CHANGE_LIMIT()", *zone.ChangeLimit})
+	}
+
+	for _, l := range limits {
+		if !l.limit.IsLimited() {
+			continue
+		}
+		if zr.Changes.Tally() == (diff2.Tally{}) {
+			return fmt.Errorf("refusing to change zone: can not enforce %s because the provider did not report which records would change", l.source)
+		}
+		if err := diff2.CheckChangeLimit(zr.Changes, len(zr.Existing), l.limit); err != nil {
+			return fmt.Errorf("refusing to change zone: %w. Raise the limit set by %s if this is intended", err, l.source)
+		}
+	}
+	return nil
+}
+
+// refuseZoneChanges replaces the corrections of zr that change the zone's
+// records with a message that says why they were refused. The corrections
+// that update something else, such as the server config file of BIND, are
+// kept: the provider gives them to only one zone, so they would be lost.
+func refuseZoneChanges(zone *models.DomainConfig, providerName string, zr zonerecs.ZoneResult, err error) zonerecs.ZoneResult {
+	corrections := []*models.Correction{{Msg: fmt.Sprintf("Domain %q provider %s Error: %s", zone.Name, providerName, err)}}
+	for _, c := range zr.Corrections {
+		if c.NonRecord {
+			corrections = append(corrections, c)
+		}
 	}
-	return zoneCorrections, reports, actualChangeCount, nil
+	zr.Corrections = corrections
+	zr.ActualChangeCount = len(corrections) - 1
+	return zr
 }
 
 func generateDelegationCorrections(zone *models.DomainConfig, providers []*models.DNSProviderInstance, _ *models.RegistrarInstance) ([]*models.Correction, int, error) {
@@ -839,7 +1151,13 @@ func generateDelegationCorrections(zone *models.DomainConfig, providers []*model
 	if err != nil {
 		return msg(fmt.Sprintf("zone %q; Rprovider %q; Error: %s", zone.Name, zone.RegistrarInstance.Name, err)), 0, err
 	}
-	return corrections, len(corrections), nil
+
+	// Publish the DS records of the DNS providers' keys (AUTODNSSEC_ON):
+	dsCorrections, dsCount, err := dssync.Corrections(zone, providers, zone.RegistrarInstance.Driver)
+	if err != nil {
+		return msg(fmt.Sprintf("zone %q; Rprovider %q; DS Error: %s", zone.Name, zone.RegistrarInstance.Name, err)), 0, err
+	}
+	return append(corrections, dsCorrections...), len(corrections) + dsCount, nil
 }
 
 func msg(s string) []*models.Correction {
@@ -899,6 +1217,19 @@ func PInitializeProviders(cfg *models.DNSConfig, providerConfigs map[string]map[
 			pInst.IsDefault = !isNonDefault[pInst.Name]
 		}
 	}
+
+	// Tell the providers that want to know which zones they serve:
+	for name, prov := range dnsProviders {
+		if setter, ok := prov.(providers.ConfiguredZonesSetter); ok {
+			var zones []*models.DomainConfig
+			for _, d := range cfg.Domains {
+				if slices.ContainsFunc(d.DNSProviderInstances, func(p *models.DNSProviderInstance) bool { return p.Name == name }) {
+					zones = append(zones, d)
+				}
+			}
+			setter.SetConfiguredZones(zones)
+		}
+	}
 	return notify, err
 }
 
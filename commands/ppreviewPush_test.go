@@ -1,10 +1,13 @@
 package commands
 
 import (
+	"path/filepath"
 	"testing"
 
 	"github.com/DNSControl/dnscontrol/v4/models"
+	"github.com/DNSControl/dnscontrol/v4/pkg/providers"
 	"github.com/DNSControl/dnscontrol/v4/pkg/rtypecontrol"
+	"github.com/DNSControl/dnscontrol/v4/pkg/zonerecs"
 )
 
 func Test_whichZonesToProcess(t *testing.T) {
@@ -250,3 +253,128 @@ func Test_reportZonePendingCreation(t *testing.T) {
 		})
 	}
 }
+
+// A zone that is added to dnsconfig.js changes the BIND server config, but
+// the records of the zone whose corrections update it do not change. Its
+// CHANGE_LIMIT() must not refuse the push.
+func Test_checkChangeLimits_newZone(t *testing.T) {
+	dir := t.TempDir()
+	creds := map[string]string{"directory": dir, "configfile": filepath.Join(dir, "named.conf.zones")}
+	newZone := func(name string) *models.DomainConfig {
+		dc := models.MustNewDomainConfig(name)
+		dc.PostProcess()
+		dc.Records = models.Records{makePlanRec("www", "A", "1.2.3.4")}
+		dc.ChangeLimit = &models.ChangeLimit{MaxDeletes: 0, MaxChangePercent: -1}
+		return dc
+	}
+	gather := func(zones ...*models.DomainConfig) zonerecs.ZoneResult {
+		t.Helper()
+		p, err := providers.CreateDNSProvider("BIND", creds, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		p.(providers.ConfiguredZonesSetter).SetConfiguredZones(zones)
+		zr, err := zonerecs.GetZoneResult(p, zones[0])
+		if err != nil {
+			t.Fatal(err)
+		}
+		return zr
+	}
+
+	// The first push creates the zone file and the config.
+	zr := gather(newZone("example.com"))
+	if err := checkChangeLimits(newZone("example.com"), zr, PPreviewArgs{}); err != nil {
+		t.Fatalf("first push: %v", err)
+	}
+	for _, c := range zr.Corrections {
+		if err := c.F(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Another zone is added. Only the config changes.
+	zone := newZone("example.com")
+	zr = gather(zone, newZone("example.org"))
+	if zr.ActualChangeCount == 0 || zr.ChangesRecords() {
+		t.Fatalf("got %d changes (changes records: %v), want only the config to change", zr.ActualChangeCount, zr.ChangesRecords())
+	}
+	if err := checkChangeLimits(zone, zr, PPreviewArgs{MaxDeletes: 0, MaxChangePercent: -1}); err != nil {
+		t.Errorf("checkChangeLimits() = %v, want nil", err)
+	}
+}
+
+// A zone whose changes are refused by CHANGE_LIMIT() must still update the
+// BIND server config, if its corrections are the ones that update it.
+func Test_refuseZoneChanges(t *testing.T) {
+	dir := t.TempDir()
+	creds := map[string]string{"directory": dir, "configfile": filepath.Join(dir, "named.conf.zones")}
+	newZone := func(name string, recs ...*models.RecordConfig) *models.DomainConfig {
+		dc := models.MustNewDomainConfig(name)
+		dc.PostProcess()
+		dc.Records = recs
+		dc.ChangeLimit = &models.ChangeLimit{MaxDeletes: 0, MaxChangePercent: -1}
+		return dc
+	}
+	gather := func(zones ...*models.DomainConfig) zonerecs.ZoneResult {
+		t.Helper()
+		p, err := providers.CreateDNSProvider("BIND", creds, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		p.(providers.ConfiguredZonesSetter).SetConfiguredZones(zones)
+		zr, err := zonerecs.GetZoneResult(p, zones[0])
+		if err != nil {
+			t.Fatal(err)
+		}
+		return zr
+	}
+
+	// The first push creates the zone file and the config.
+	zr := gather(newZone("example.com", makePlanRec("www", "A", "1.2.3.4"), makePlanRec("mail", "A", "1.2.3.5")))
+	for _, c := range zr.Corrections {
+		if err := c.F(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// A record is deleted, which the limit refuses, and a zone is added.
+	zone := newZone("example.com", makePlanRec("www", "A", "1.2.3.4"))
+	zr = gather(zone, newZone("example.org"))
+	err := checkChangeLimits(zone, zr, PPreviewArgs{MaxDeletes: -1, MaxChangePercent: -1})
+	if err == nil {
+		t.Fatal("checkChangeLimits() = nil, want the deletion to be refused")
+	}
+	zr = refuseZoneChanges(zone, "bind", zr, err)
+	if len(zr.Corrections) != 2 || zr.Corrections[0].F != nil || !zr.Corrections[1].NonRecord {
+		t.Fatalf("got %d corrections, want the refusal and the config update", len(zr.Corrections))
+	}
+	if zr.ActualChangeCount != 1 {
+		t.Errorf("got %d changes, want 1", zr.ActualChangeCount)
+	}
+}
+
+// The catalog zone of a provider is gathered once, as a zone of its own.
+func Test_gatherCatalogZones(t *testing.T) {
+	dir := t.TempDir()
+	p, err := providers.CreateDNSProvider("BIND", map[string]string{"directory": dir, "catalog": "catalog.example"}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	instance := &models.DNSProviderInstance{ProviderBase: models.ProviderBase{Name: "bind", IsDefault: true}, Driver: p}
+	var zones []*models.DomainConfig
+	for _, name := range []string{"example.com", "example.org"} {
+		dc := models.MustNewDomainConfig(name)
+		dc.PostProcess()
+		dc.DNSProviderInstances = []*models.DNSProviderInstance{instance}
+		zones = append(zones, dc)
+	}
+	p.(providers.ConfiguredZonesSetter).SetConfiguredZones(zones)
+
+	catalogs := gatherCatalogZones(zones, "")
+	if len(catalogs) != 1 {
+		t.Fatalf("got %d catalog zones, want 1", len(catalogs))
+	}
+	if cz := catalogs[0]; cz.err != nil || cz.zone.Name != "catalog.example" || len(cz.corrections) != 1 || cz.count == 0 {
+		t.Errorf("got zone %v, %d corrections, %d changes, error %v; want catalog.example with 1 correction", cz.zone, len(cz.corrections), cz.count, cz.err)
+	}
+}
@@ -0,0 +1,178 @@
+package commands
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/DNSControl/dnscontrol/v4/models"
+	"github.com/DNSControl/dnscontrol/v4/pkg/credsfile"
+	"github.com/DNSControl/dnscontrol/v4/pkg/domaintags"
+	"github.com/DNSControl/dnscontrol/v4/pkg/notifications"
+	"github.com/DNSControl/dnscontrol/v4/pkg/printer"
+	"github.com/DNSControl/dnscontrol/v4/pkg/providers"
+	"github.com/DNSControl/dnscontrol/v4/pkg/snapshot"
+	"github.com/DNSControl/dnscontrol/v4/pkg/zonerecs"
+	"github.com/urfave/cli/v3"
+)
+
+var _ = cmd(catMain, func() *cli.Command {
+	var args RollbackArgs
+	return &cli.Command{
+		Name:  "rollback",
+		Usage: "restore zones to the state saved in a snapshot (see push --snapshot-dir)",
+		Action: func(ctx context.Context, c *cli.Command) error {
+			if c.NArg() != 1 {
+				return cli.Exit("Arguments should be: snapshotdir (Ex: snapshots/20240102T150405Z)", 1)
+			}
+			args.SnapshotDir = c.Args().First()
+			return exit(Rollback(args))
+		},
+		Flags:     args.flags(),
+		UsageText: "dnscontrol rollback [command options] snapshotdir",
+		Description: `Restore the zones in a snapshot made by "push --snapshot-dir".
+
+The records in the snapshot are the desired state. The corrections needed to
+restore them are computed and executed the same way "push" does. dnsconfig.js
+is not read; the providers are configured from creds.json.
+
+EXAMPLES:
+   dnscontrol rollback --preview snapshots/20240102T150405Z
+   dnscontrol rollback snapshots/20240102T150405Z
+   dnscontrol rollback --domains example.com snapshots/20240102T150405Z
+
+Documentation: https://docs.dnscontrol.org/commands/rollback`,
+	}
+}())
+
+// RollbackArgs contains all data/flags needed to run rollback, independently of CLI.
+type RollbackArgs struct {
+	GetCredentialsArgs
+	FilterArgs
+	SnapshotDir string
+	Preview     bool
+	Interactive bool
+}
+
+func (args *RollbackArgs) flags() []cli.Flag {
+	flags := args.GetCredentialsArgs.flags()
+	flags = append(flags, args.FilterArgs.flags()...)
+	flags = append(flags, &cli.BoolFlag{
+		Name:        "preview",
+		Destination: &args.Preview,
+		Usage:       "Show the corrections that would restore the snapshot, but do not execute them",
+	})
+	flags = append(flags, &cli.BoolFlag{
+		Name:        "i",
+		Destination: &args.Interactive,
+		Usage:       "Interactive. Confirm or Exclude each correction before they run",
+	})
+	return flags
+}
+
+// saveSnapshot writes the existing records of every zone/provider that has
+// corrections to a new snapshot directory in base. It returns the directory
+// and the number of zones saved. Nothing is written if there is nothing to
+// save.
+func saveSnapshot(base string, zones []*models.DomainConfig, providerFilter string, zresults *zoneResultCache) (string, int, error) {
+	dir := snapshot.NewDir(base, time.Now())
+	n := 0
+	for _, zone := range zones {
+		for _, provider := range whichProvidersToProcess(zone.DNSProviderInstances, providerFilter) {
+			zr, ok := zresults.get(zone, provider.Name)
+			if !ok || len(zr.Corrections) == 0 {
+				continue // Nothing will change.
+			}
+			if err := snapshot.Write(dir, provider.Name, zone, zr.Existing); err != nil {
+				return dir, n, err
+			}
+			n++
+		}
+	}
+	return dir, n, nil
+}
+
+// Rollback implements the rollback subcommand.
+func Rollback(args RollbackArgs) error {
+	out := printer.DefaultPrinter
+
+	entries, err := snapshot.List(args.SnapshotDir)
+	if err != nil {
+		return err
+	}
+
+	providerConfigs, err := credsfile.LoadProviderConfigs(args.CredsFile)
+	if err != nil {
+		return err
+	}
+
+	zoneFilter := domaintags.CompilePermitList(args.Domains)
+	notifier := notifications.Init(nil)
+	dsps := map[string]providers.DNSServiceProvider{}
+
+	var totalCorrections int
+	var anyErrors bool
+	for _, e := range entries {
+		if !zoneFilter.Permitted(e.UniqueName) || skipProviderName(e.Provider, args.Providers) {
+			continue
+		}
+
+		// Create the provider (once) from creds.json.
+		dsp, ok := dsps[e.Provider]
+		if !ok {
+			creds, ok := providerConfigs[e.Provider]
+			if !ok {
+				return fmt.Errorf("snapshot %q: provider %q is not in %q", e.Filename, e.Provider, args.CredsFile)
+			}
+			dsp, err = providers.CreateDNSProvider(creds[pproviderTypeFieldName], creds, nil)
+			if err != nil {
+				return fmt.Errorf("snapshot %q: %w", e.Filename, err)
+			}
+			dsps[e.Provider] = dsp
+		}
+
+		recs, err := e.Read()
+		if err != nil {
+			return err
+		}
+
+		// The snapshot is the desired state of the entire zone. There is
+		// nothing to ignore or preserve.
+		dc := &models.DomainConfig{Name: e.UniqueName, Records: recs}
+		dc.PostProcess() // Populate the name varieties, as if read from dnsconfig.js.
+
+		out.StartDomain(dc)
+		out.StartDNSProvider(e.Provider, false)
+		zr, err := zonerecs.GetZoneResult(dsp, dc)
+		if err != nil {
+			out.Errorf("Domain %q provider %s Error: %s\n", dc.Name, e.Provider, err)
+			out.EndDomain()
+			anyErrors = true
+			continue
+		}
+		totalCorrections += zr.ActualChangeCount
+		out.EndProvider2(e.Provider, zr.ActualChangeCount)
+		corrections := append(zr.Reports, zr.Corrections...)
+		anyErrors = pprintOrRunCorrections(dc.Name, e.Provider, corrections, out, !args.Preview, args.Interactive, notifier, "") || anyErrors
+		out.EndDomain()
+	}
+
+	notifier.Done()
+	out.Printf("Done. %d corrections.\n", totalCorrections)
+	if anyErrors {
+		return errors.New("completed with errors")
+	}
+	return nil
+}
+
+// skipProviderName returns true if name is not in the comma-separated filter.
+// An empty filter or "all" permits all names.
+func skipProviderName(name string, filter string) bool {
+	if filter == "" || filter == "all" {
+		return false
+	}
+	return !slices.Contains(strings.Split(filter, ","), name)
+}
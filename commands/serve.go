@@ -0,0 +1,146 @@
+package commands
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/DNSControl/dnscontrol/v4/models"
+	"github.com/DNSControl/dnscontrol/v4/pkg/domaintags"
+	"github.com/DNSControl/dnscontrol/v4/pkg/normalize"
+	"github.com/DNSControl/dnscontrol/v4/pkg/printer"
+	"github.com/DNSControl/dnscontrol/v4/pkg/zoneserver"
+	dnsv1 "github.com/miekg/dns"
+	"github.com/urfave/cli/v3"
+)
+
+var _ = cmd(catUtils, func() *cli.Command {
+	var args ServeArgs
+	return &cli.Command{
+		Name:  "serve",
+		Usage: "answer DNS queries from the zones in dnsconfig.js (the desired state)",
+		Action: func(ctx context.Context, c *cli.Command) error {
+			return exit(Serve(ctx, args))
+		},
+		Flags: args.flags(),
+		Description: `Run a local DNS server that answers queries from the records in
+dnsconfig.js, without accessing any providers. This lets you test resolvers,
+mail setups, service discovery, etc. against the desired state before running
+"push".
+
+EXAMPLES:
+   dnscontrol serve
+   dnscontrol serve --listen 127.0.0.1:5353 --tag inside
+   dig @127.0.0.1 -p 5353 www.example.com
+
+Documentation: https://docs.dnscontrol.org/commands/serve`,
+	}
+}())
+
+// ServeArgs contains all data/flags needed to run serve, independently of CLI.
+type ServeArgs struct {
+	GetDNSConfigArgs
+	Domains string
+	Listen  string
+	Tag     string
+}
+
+func (args *ServeArgs) flags() []cli.Flag {
+	flags := args.GetDNSConfigArgs.flags()
+	flags = append(flags, &cli.StringFlag{
+		Name:        "domains",
+		Destination: &args.Domains,
+		Usage:       `Comma separated list of domain names to include`,
+	})
+	flags = append(flags, &cli.StringFlag{
+		Name:        "listen",
+		Destination: &args.Listen,
+		Value:       "127.0.0.1:5353",
+		Usage:       `Address and port to listen on (UDP and TCP)`,
+	})
+	flags = append(flags, &cli.StringFlag{
+		Name:        "tag",
+		Destination: &args.Tag,
+		Usage:       `Serve the split horizon view with this tag. Domains without this tag are served untagged`,
+	})
+	return flags
+}
+
+// Serve implements the serve subcommand. It runs until ctx is canceled or
+// the process is interrupted.
+func Serve(ctx context.Context, args ServeArgs) error {
+	cfg, err := GetDNSConfig(args.GetDNSConfigArgs)
+	if err != nil {
+		return err
+	}
+	errs := normalize.ValidateAndNormalizeConfig(cfg)
+	if PrintValidationErrors(errs) {
+		return errors.New("exiting due to validation errors")
+	}
+
+	zones := whichZonesToServe(cfg.Domains, args.Domains, args.Tag)
+	if len(zones) == 0 {
+		return fmt.Errorf("no domains to serve (tag %q)", args.Tag)
+	}
+	handler, skipped := zoneserver.New(zones, uint32(time.Now().Unix()))
+	for _, s := range skipped {
+		printer.Warnf("%s\n", s)
+	}
+
+	ctx, stop := signal.NotifyContext(ctx, syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	servers := []*dnsv1.Server{
+		{Addr: args.Listen, Net: "udp", Handler: handler},
+		{Addr: args.Listen, Net: "tcp", Handler: handler},
+	}
+	failed := make(chan error, len(servers))
+	for _, srv := range servers {
+		go func() { failed <- srv.ListenAndServe() }()
+	}
+
+	for _, dc := range zones {
+		printer.Printf("Serving %s\n", dc.DisplayName)
+	}
+	printer.Printf("Listening on %s (udp, tcp). Press Ctrl-C to stop.\n", args.Listen)
+
+	select {
+	case <-ctx.Done():
+		err = nil
+	case err = <-failed:
+	}
+	for _, srv := range servers {
+		_ = srv.Shutdown()
+	}
+	return err
+}
+
+// whichZonesToServe returns the zones that match filter (see
+// whichZonesToProcess) as seen from the split horizon view tag. A domain's
+// zone with that tag is preferred; otherwise the untagged zone is used.
+func whichZonesToServe(domains []*models.DomainConfig, filter string, tag string) []*models.DomainConfig {
+	fh := domaintags.CompilePermitList(filter)
+
+	var picked []*models.DomainConfig
+	byName := map[string]int{} // Index into picked.
+	for _, dc := range domains {
+		if !fh.Permitted(dc.Name) && !fh.Permitted(dc.GetUniqueName()) {
+			continue
+		}
+		if dc.Tag != tag && dc.Tag != "" {
+			continue
+		}
+		i, ok := byName[dc.Name]
+		switch {
+		case !ok:
+			byName[dc.Name] = len(picked)
+			picked = append(picked, dc)
+		case dc.Tag == tag:
+			picked[i] = dc // The tagged zone wins over the untagged one.
+		}
+	}
+	return picked
+}
@@ -0,0 +1,45 @@
+package commands
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/DNSControl/dnscontrol/v4/models"
+)
+
+func Test_whichZonesToServe(t *testing.T) {
+	dcCom := &models.DomainConfig{Name: "example.com"}
+	dcComInside := &models.DomainConfig{Name: "example.com!inside"}
+	dcComOutside := &models.DomainConfig{Name: "example.com!outside"}
+	dcNet := &models.DomainConfig{Name: "example.net"}
+	dcOrgInside := &models.DomainConfig{Name: "example.org!inside"}
+	allDC := []*models.DomainConfig{dcCom, dcComInside, dcComOutside, dcNet, dcOrgInside}
+	for _, dc := range allDC {
+		dc.PostProcess()
+	}
+
+	tests := []struct {
+		name   string
+		filter string
+		tag    string
+		want   []*models.DomainConfig
+	}{
+		{"untagged", "", "", []*models.DomainConfig{dcCom, dcNet}},
+		{"inside", "", "inside", []*models.DomainConfig{dcComInside, dcNet, dcOrgInside}},
+		{"outside", "", "outside", []*models.DomainConfig{dcComOutside, dcNet}},
+		{"unknownTag", "", "nope", []*models.DomainConfig{dcCom, dcNet}},
+		{"filtered", "example.com", "inside", []*models.DomainConfig{dcComInside}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := whichZonesToServe(allDC, tt.filter, tt.tag)
+			if !slices.Equal(got, tt.want) {
+				var names []string
+				for _, dc := range got {
+					names = append(names, dc.UniqueName)
+				}
+				t.Errorf("whichZonesToServe() = %v", names)
+			}
+		})
+	}
+}
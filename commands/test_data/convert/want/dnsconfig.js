@@ -0,0 +1,46 @@
+// generated by convert-zonefile. This is 'a decent first draft' and requires editing.
+
+var DSP_CHANGEME = NewDnsProvider("CHANGEME");
+var REG_CHANGEME = NewRegistrar("none");
+
+require("./mail.js"); // $INCLUDE mail.zone ($ORIGIN example.com.)
+require("./mail.lab.example.com.js"); // $INCLUDE mail.zone ($ORIGIN lab.example.com.)
+
+// Converted from test_data/convert/example.com.zone
+D("example.com", REG_CHANGEME,
+	DnsProvider(DSP_CHANGEME),
+	DefaultTTL(3600),
+	// The zone of example.com.
+	// Maintained by hand since 2009.
+	// $ORIGIN example.com.
+	// $TTL 3600
+
+	//SOA("@", "ns1.example.com.", "hostmaster.example.com.", 3600, 600, 604800, 300), // serial; refresh; retry; expire; minimum
+	//NAMESERVER("ns1.example.com."),
+	//NAMESERVER("ns2.example.net."),
+	MX("@", 10, "mail.example.com."), // the primary
+	MX("@", 20, "backup.example.net."),
+
+	// Web servers
+	A("www", "192.0.2.10", TTL(300)),
+	AAAA("www", "2001:db8::10"), // same name as www
+	CNAME("ftp", "www.example.com."),
+	TXT("txt", "v=spf1  -allsecond ; string"),
+
+	// DHCP pool
+	// $GENERATE 10-20 dhcp-$ A 192.0.2.$
+	GENERATE("dhcp-$", 10, 20, A, "192.0.2.$"),
+	// $GENERATE 1-4/2 host${5,3,x} 60 CNAME dhcp-$
+	GENERATE("host${5,3,x}", 1, 4, 2, CNAME, "dhcp-$", TTL(60)),
+	// $GENERATE 1-2 _srv$._tcp SRV 0 0 80 www
+	SRV("_srv1._tcp", 0, 0, 80, "www.example.com."),
+	SRV("_srv2._tcp", 0, 0, 80, "www.example.com."),
+
+	INCLUDE_MAIL, // $INCLUDE mail.zone
+	// $ORIGIN lab.example.com.
+	INCLUDE_MAIL_LAB_EXAMPLE_COM, // $INCLUDE mail.zone lab.example.com.
+	// $GENERATE 1-3 node$ CNAME dhcp-$.example.com.
+	GENERATE("node$.lab", 1, 3, CNAME, "dhcp-$.example.com."),
+	A("test.lab", "192.0.2.99"),
+);
+
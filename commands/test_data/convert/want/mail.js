@@ -0,0 +1,7 @@
+// generated by convert-zonefile from mail.zone ($ORIGIN example.com.). This is 'a decent first draft' and requires editing.
+
+var INCLUDE_MAIL = [
+	// Mail
+	A("smtp", "192.0.2.25"),
+	CNAME("imap", "smtp.example.com."),
+];
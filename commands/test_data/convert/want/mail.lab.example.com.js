@@ -0,0 +1,7 @@
+// generated by convert-zonefile from mail.zone ($ORIGIN lab.example.com.). This is 'a decent first draft' and requires editing.
+
+var INCLUDE_MAIL_LAB_EXAMPLE_COM = [
+	// Mail
+	A("smtp.lab", "192.0.2.25"),
+	CNAME("imap.lab", "smtp.lab.example.com."),
+];
@@ -366,6 +366,26 @@ declare const AUTODNSSEC_OFF: DomainModifier;
  *
  * If neither `AUTODNSSEC_ON` or `AUTODNSSEC_OFF` is specified for a domain no changes will be requested.
  *
+ * ## DS records at the registrar
+ *
+ * A signed zone is only trusted if the registrar publishes the DS records of its key-signing keys in the parent zone. If the registrar can manage DS records (DNSimple) and every DNS provider of the domain can list its keys (PowerDNS, deSEC, Google Cloud DNS), `preview` and `push` keep the DS records at the registrar in sync with the keys:
+ *
+ * * The DS record (SHA-256) of each key-signing key that a DNS provider publishes is added, including keys that are published but not active yet. A DS record with another digest type that matches the key is left alone.
+ * * A DS record that matches no key is removed, but only once the DS records of the current keys have been at the registrar for 24 hours, so that resolvers that cached the old DS records have expired them. Until then, `preview` lists the DS records that a later `push` will remove. Set the `ds_rollover_wait` metadata (in seconds) to change the wait, for example to the TTL of the DS records of your TLD.
+ * * The DS records to add are determined again when the registrar's corrections run, after the DNS providers' corrections. If a DNS provider has no key yet because `AUTODNSSEC_ON` was just added, the DS records of the keys it publishes in that `push` are added too. A DNS provider that publishes its keys later needs another `push`.
+ *
+ * This supports the "double-DS" key rollover: publish the new key at the DNS provider, run `push` to add its DS record, wait for the TTL of the DS records, then retire the old key at the DNS provider and run `push` to remove its DS record. `dnscontrol check-dnssec` verifies the result.
+ *
+ * ```javascript
+ * D("example.com", REG_DNSIMPLE, DnsProvider(DSP_POWERDNS),
+ *   {ds_rollover_wait: "172800"},  // Wait 2 days before removing old DS records.
+ *   AUTODNSSEC_ON,
+ *   A("@", "10.1.1.1"),
+ * );
+ * ```
+ *
+ * With `AUTODNSSEC_OFF`, the DS records at the registrar are removed first. The DNS providers keep signing the zone until the DS records have been gone for the wait (`ds_rollover_wait`, 24 hours by default), so that validating resolvers that cached them can still resolve the zone. `preview` and `push` report when a later `push` turns DNSSEC off. Registrars don't report when the DS records were removed, so DNSControl keeps that in `dnscontrol/dssync` in the user's cache directory (for example `~/.cache` on Linux). A `push` from another machine can not know it, and turns DNSSEC off as soon as the DS records are gone.
+ *
  * @see https://docs.dnscontrol.org/language-reference/domain-modifiers/autodnssec_on
  */
 declare const AUTODNSSEC_ON: DomainModifier;
@@ -417,6 +437,53 @@ declare const AUTODNSSEC_ON: DomainModifier;
  */
 declare function AZURE_ALIAS(name: string, type: "A" | "AAAA" | "CNAME", target: string, ...modifiers: RecordModifier[]): DomainModifier;
 
+/**
+ * DNSControl contains a `BIMI_BUILDER` which can be used to create
+ * [BIMI](https://bimigroup.org/) (Brand Indicators for Message Identification)
+ * records, which tell the mailbox providers which logo to display next to the
+ * mail of the domain.
+ *
+ * ## Example
+ *
+ * ```javascript
+ * D("example.com", REG_MY_PROVIDER, DnsProvider(DSP_MY_PROVIDER),
+ *   BIMI_BUILDER({
+ *     location: "https://example.com/logo.svg",
+ *     authority: "https://example.com/vmc.pem",
+ *   }),
+ *   BIMI_BUILDER({
+ *     label: "marketing",
+ *     selector: "brand",
+ *     location: "",
+ *   }),
+ * );
+ * ```
+ *
+ * This yields the following records:
+ *
+ * ```text
+ * default._bimi             IN  TXT "v=BIMI1; l=https://example.com/logo.svg; a=https://example.com/vmc.pem"
+ * brand._bimi.marketing     IN  TXT "v=BIMI1; l=; a="
+ * ```
+ *
+ * The second record declines to publish a logo for `marketing.example.com`.
+ *
+ * ### Parameters
+ *
+ * * `label:` The DNS label for the BIMI record (`<selector>._bimi` prefix is added, default: `"@"`)
+ * * `selector:` The BIMI selector (default: `"default"`)
+ * * `location:` The `https://` URL of the SVG Tiny PS logo (`l=`), or `""` to decline to publish a logo (required)
+ * * `authority:` The `https://` URL of the Verified Mark Certificate, in PEM (`a=`, optional)
+ * * `ttl:` Input for `TTL` method (optional)
+ *
+ * ### Caveats
+ *
+ * * The mailbox providers only display the logo of domains with a DMARC policy of `quarantine` or `reject` (see [`DMARC_BUILDER`](DMARC_BUILDER.md)).
+ *
+ * @see https://docs.dnscontrol.org/language-reference/domain-modifiers/bimi_builder
+ */
+declare function BIMI_BUILDER(opts: { label?: string; selector?: string; location: string; authority?: string; ttl?: Duration }): DomainModifier;
+
 /**
  * `CAA` adds a [Certification Authority Authorization record](https://www.rfc-editor.org/rfc/rfc8659) to a domain. The name should be the relative label for the record. Use `@` for the domain apex.
  *
@@ -673,6 +740,45 @@ declare function CF_TEMP_REDIRECT(source: string, destination: string, ...modifi
  */
 declare function CF_WORKER_ROUTE(pattern: string, script: string): DomainModifier;
 
+/**
+ * `CHANGE_LIMIT` is a safety check that refuses to change the domain if too many records would be deleted or modified in one `push`. This protects against mistakes in `dnsconfig.js` (for example, a missing `INCLUDE` or an empty variable) that would otherwise delete most of a zone.
+ *
+ * * `max_deletes`: The maximum number of records that may be deleted.
+ * * `max_change_percent`: The maximum percentage of the existing records that may be deleted or modified. Adding records is not counted.
+ *
+ * Both limits are whole numbers. Either limit may be `-1` (or omitted) to disable it.
+ *
+ * ```javascript
+ * D("example.com", REG_MY_PROVIDER, DnsProvider(DSP_MY_PROVIDER),
+ *     CHANGE_LIMIT(10),       // At most 10 records may be deleted.
+ *     A("@", "1.2.3.4"),
+ * );
+ *
+ * D("example.org", REG_MY_PROVIDER, DnsProvider(DSP_MY_PROVIDER),
+ *     CHANGE_LIMIT(-1, 25),   // At most 25% of the records may be deleted or modified.
+ *     A("@", "1.2.3.4"),
+ * );
+ * ```
+ *
+ * The limits are checked after the existing records are downloaded and compared to `dnsconfig.js`, but before any changes are made. A domain that exceeds a limit is not changed and an error is reported. Updates that are not records of the domain, such as the server config file of the [BIND](../../provider/bind.md) provider, are still made. Other domains are processed as usual. `preview` reports the same error, so the problem can be found before running `push`.
+ *
+ * ```text
+ * ******************** Domain: example.com
+ * INFO#1: Domain "example.com" provider bind Error: refusing to change zone: 120 records would be deleted (the limit is 10). Raise the limit set by CHANGE_LIMIT() if this is intended
+ * ```
+ *
+ * If the change was intended, raise or remove the limit for that one `push`.
+ *
+ * The `--max-deletes` and `--max-change-percent` flags of [`preview` and `push`](../../commands/preview-push.md) set the same limits for every domain. If both the flags and `CHANGE_LIMIT` are used, both limits must be satisfied.
+ *
+ * NOTE: Records are counted the way the provider sends changes. Replacing
+ * the 3 A records of a label with 1 A record counts as 1 modification and 2
+ * deletions.
+ *
+ * @see https://docs.dnscontrol.org/language-reference/domain-modifiers/change_limit
+ */
+declare function CHANGE_LIMIT(max_deletes?: number, max_change_percent?: number): DomainModifier;
+
 /**
  * Documentation needed.
  *
@@ -1275,6 +1381,43 @@ declare function DnsProvider(name: string, nsCount?: number): DomainModifier;
  */
 declare function FRAME(name: string, target: string, ...modifiers: RecordModifier[]): DomainModifier;
 
+/**
+ * `GENERATE` creates a range of records, like `$GENERATE` in BIND zone files. For each `i` from `start` to `stop`, it adds the record `type(name, args...)`, with each `$` in `name` and in the string arguments replaced by `i`. The other arguments (numbers, [record modifiers](https://docs.dnscontrol.org/language-reference/record-modifiers), metadata) are passed as is.
+ *
+ * `type` is a record type, like `A` or `PTR` (without quotes). To skip numbers, put a step before the type: `GENERATE("host-$", 0, 254, 2, A, "10.0.0.$")` uses 0, 2, 4, ... 254.
+ *
+ * As in BIND, `${offset,width,base}` adds `offset` to `i` and writes it with at least `width` digits, in `base`:
+ *
+ * | base | |
+ * |------|-|
+ * | `d`  | Decimal (the default). |
+ * | `o`  | Octal. |
+ * | `x`, `X` | Hexadecimal, in lower or upper case. |
+ * | `n`, `N` | Nibbles: hexadecimal digits in reverse order, separated by dots, for `ip6.arpa`. The width counts the dots. |
+ *
+ * `\$` (written `"\\$"` in JavaScript) and `$$` are a literal `$`. `start` and `stop` must be 0 or greater, and a `GENERATE` creates at most 65536 records.
+ *
+ * ```javascript
+ * D("example.com", REG_MY_PROVIDER, DnsProvider(DSP_MY_PROVIDER),
+ *   // host-1 to host-254: 10.0.0.1 to 10.0.0.254
+ *   GENERATE("host-$", 1, 254, A, "10.0.0.$"),
+ *   // dhcp-010 to dhcp-020, with a TTL of 60
+ *   GENERATE("dhcp-${0,3}", 10, 20, CNAME, "host-$", TTL(60)),
+ *   // Other record types work too.
+ *   GENERATE("mx$", 1, 2, MX, 10, "mail$.example.net."),
+ * );
+ *
+ * D(REV("10.0.0.0/24"), REG_MY_PROVIDER, DnsProvider(DSP_MY_PROVIDER),
+ *   GENERATE("$", 1, 254, PTR, "host-$.example.com."),
+ * );
+ * ```
+ *
+ * The [BIND provider](../../provider/bind.md#generate) can write the records of `GENERATE` as `$GENERATE` lines (option `generate`). [`convert-zonefile`](../../commands/convert-zonefile.md) converts `$GENERATE` lines to `GENERATE`.
+ *
+ * @see https://docs.dnscontrol.org/language-reference/domain-modifiers/generate
+ */
+declare function GENERATE(name: string, start: number, stop: number, type: number | ((name: string, ...args: any[]) => DomainModifier), ...args: any[]): DomainModifier;
+
 /**
  * `HASH` hashes `value` using the hashing algorithm given in `algorithm`
  * (accepted values `SHA1`, `SHA256`, and `SHA512`) and returns the hex encoded
@@ -1833,6 +1976,7 @@ declare function IGNORE(labelSpec: string, typeSpec?: string, targetSpec?: strin
  *
  * * [`IGNORE`](IGNORE.md) for manually ignoring specific records with glob patterns
  * * [`NO_PURGE`](NO_PURGE.md) for preventing deletion of all unmanaged records
+ * * [`OWNERSHIP_REGISTRY`](OWNERSHIP_REGISTRY.md) for sharing a zone between several `dnsconfig.js` files
  * * [External-dns documentation](https://github.com/kubernetes-sigs/external-dns)
  *
  * @see https://docs.dnscontrol.org/language-reference/domain-modifiers/ignore_external_dns
@@ -2420,6 +2564,70 @@ declare function MIKROTIK_FWD(name: string, target: string, ...modifiers: Record
  */
 declare function MIKROTIK_NXDOMAIN(name: string, ...modifiers: RecordModifier[]): DomainModifier;
 
+/**
+ * DNSControl contains a `MTASTS_BUILDER` which can be used to create the
+ * `_mta-sts` TXT record of [MTA-STS](https://datatracker.ietf.org/doc/html/rfc8461)
+ * (SMTP MTA Strict Transport Security).
+ *
+ * MTA-STS has two parts: the TXT record, and a policy file that must be
+ * published at `https://mta-sts.<domain>/.well-known/mta-sts.txt`. The `id` of
+ * the TXT record tells the senders when the policy changed. `MTASTS_BUILDER`
+ * derives the `id` from a hash of the policy, so it changes whenever the policy
+ * does, and it can write the policy file so that it is published with the
+ * record.
+ *
+ * ## Example
+ *
+ * ```javascript
+ * D("example.com", REG_MY_PROVIDER, DnsProvider(DSP_MY_PROVIDER),
+ *   MX("@", 10, "mx1.example.com."),
+ *   MX("@", 20, "mx2.example.com."),
+ *   MTASTS_BUILDER({
+ *     mode: "enforce",
+ *     mx: ["mx1.example.com", "mx2.example.com"],
+ *     maxAge: "1d",
+ *     policyFile: "www/mta-sts/.well-known/mta-sts.txt",
+ *   }),
+ *   CNAME("mta-sts", "www.example.com."),
+ * );
+ * ```
+ *
+ * This yields the following record:
+ *
+ * ```text
+ * _mta-sts    IN  TXT "v=STSv1; id=dd640a87380e9e16b013ba30e3abcd5b"
+ * ```
+ *
+ * and writes the policy to `www/mta-sts/.well-known/mta-sts.txt`:
+ *
+ * ```text
+ * version: STSv1
+ * mode: enforce
+ * mx: mx1.example.com
+ * mx: mx2.example.com
+ * max_age: 86400
+ * ```
+ *
+ * ### Parameters
+ *
+ * * `label:` The DNS label for the MTA-STS record (`_mta-sts` prefix is added, default: `"@"`)
+ * * `mode:` The policy mode, must be one of `"enforce"`, `"testing"`, `"none"` (default: `"testing"`)
+ * * `mx:` Array of the MX hosts that may receive mail for the domain. `*.` matches exactly one label, as in `"*.mail.example.com"` (required unless `mode` is `"none"`)
+ * * `maxAge:` How long the senders may cache the policy, in seconds or as a duration such as `"1w"`; at most a year (`max_age:`, default: `"1w"`)
+ * * `id:` The policy id, 1 to 32 letters and digits (`id=`, default: the first 32 hex digits of the SHA-256 of the policy)
+ * * `policyFile:` File to write the policy to, relative to the directory of `dnsconfig.js` (optional). The file is only rewritten when the policy changes.
+ * * `ttl:` Input for `TTL` method (optional)
+ *
+ * ### Caveats
+ *
+ * * The policy is written by `push`, before the records are changed. `preview` lists the policy files that would be written. Publish the file before the record changes: the senders fetch the policy when they see a new `id`.
+ * * Two `MTASTS_BUILDER`s can share a `policyFile` only if their policies are the same.
+ * * To retire a policy, publish `mode: "none"` first, and wait `maxAge` before you remove the record.
+ *
+ * @see https://docs.dnscontrol.org/language-reference/domain-modifiers/mtasts_builder
+ */
+declare function MTASTS_BUILDER(opts: { label?: string; mode?: 'enforce' | 'testing' | 'none'; mx?: string[]; maxAge?: Duration; id?: string; policyFile?: string; ttl?: Duration }): DomainModifier;
+
 /**
  * `MX` adds a [Mail exchange record](https://www.rfc-editor.org/rfc/rfc1035) to the domain.
  *
@@ -2937,6 +3145,75 @@ declare function NewRegistrar(name: string, type?: string, meta?: object): strin
  */
 declare function OPENPGPKEY(name: string, target: string, ...modifiers: RecordModifier[]): DomainModifier;
 
+/**
+ * `OWNERSHIP_REGISTRY` makes DNSControl mark the records it manages with TXT records that name an owner, and only modify or delete records that are marked with that owner. Several teams (each with their own `dnsconfig.js` and owner name) can then manage the same zone without long lists of [`IGNORE`](IGNORE.md) statements.
+ *
+ * This is similar to the [TXT registry](https://github.com/kubernetes-sigs/external-dns/blob/master/docs/registry/txt.md) of Kubernetes external-dns.
+ *
+ * ```javascript
+ * // In team A's dnsconfig.js:
+ * D("example.com", REG_MY_PROVIDER, DnsProvider(DSP_MY_PROVIDER),
+ *   OWNERSHIP_REGISTRY("team-a"),
+ *   A("www", "1.2.3.4"),
+ *   MX("@", 10, "mail"),
+ * );
+ *
+ * // In team B's dnsconfig.js:
+ * D("example.com", REG_MY_PROVIDER, DnsProvider(DSP_MY_PROVIDER),
+ *   OWNERSHIP_REGISTRY("team-b"),
+ *   A("api", "1.2.3.5"),
+ * );
+ * ```
+ *
+ * The owner may contain letters, digits, `.`, `_` and `-`.
+ *
+ * ## Markers
+ *
+ * For each label and record type in `dnsconfig.js`, DNSControl creates one TXT record (a "marker") named `PREFIX` + the record type + `.` + the label. The default prefix is `_dnscontrol-`. For the example above, team A's push creates:
+ *
+ * ```text
+ * _dnscontrol-a.www.example.com.  TXT  "heritage=dnscontrol,dnscontrol/owner=team-a"
+ * _dnscontrol-mx.example.com.     TXT  "heritage=dnscontrol,dnscontrol/owner=team-a"
+ * ```
+ *
+ * A wildcard label `*` is written as `_wildcard` in the marker's name. The markers are managed automatically: they are created along with the records and deleted when the records are removed from `dnsconfig.js`. They do not need to be (and should not be) listed in `dnsconfig.js`.
+ *
+ * Everyone that shares a zone must use the same prefix. To use a different prefix, pass it as the second parameter:
+ *
+ * ```javascript
+ * OWNERSHIP_REGISTRY("team-a", "_owner-"),  // Markers like "_owner-a.www"
+ * ```
+ *
+ * ## Which records are touched?
+ *
+ * Each existing record is looked up by its label and type:
+ *
+ * | The marker... | Then the record is... |
+ * |---|---|
+ * | names this owner | managed as usual: modified or deleted to match `dnsconfig.js`. |
+ * | names a different owner | never touched. Listing it in `dnsconfig.js` is an error. |
+ * | does not exist, but `dnsconfig.js` lists the label and type | adopted: it is changed to match `dnsconfig.js` and a marker is created. |
+ * | does not exist, and `dnsconfig.js` does not list the label and type | left alone, as if it were [`IGNORE`](IGNORE.md)'d. |
+ *
+ * Adoption makes it easy to start using `OWNERSHIP_REGISTRY` in an existing zone: the first `push` creates markers for the records in `dnsconfig.js` and leaves everything else as is. `preview` lists the records that are adopted and left alone.
+ *
+ * ## Caveats
+ *
+ * * Ownership is per label and type. Two owners can not share, for example, the A records at `www`.
+ * * Records protected by [`IGNORE`](IGNORE.md), [`NO_PURGE`](NO_PURGE.md) or [`IGNORE_EXTERNAL_DNS`](IGNORE_EXTERNAL_DNS.md) are handled by those features as usual and do not get markers unless they are listed in `dnsconfig.js`.
+ * * The SOA and apex NS records do not get markers and are managed as usual, since they belong to the zone rather than to an owner. Everyone that shares the zone should use the same DNS providers and [`NAMESERVER`](NAMESERVER.md)s.
+ * * Markers are ordinary TXT records, so anyone with access to the provider can change them.
+ *
+ * ## See also
+ *
+ * * [`IGNORE`](IGNORE.md) for manually ignoring specific records with glob patterns
+ * * [`IGNORE_EXTERNAL_DNS`](IGNORE_EXTERNAL_DNS.md) for ignoring records managed by Kubernetes external-dns
+ * * [`NO_PURGE`](NO_PURGE.md) for preventing deletion of all unmanaged records
+ *
+ * @see https://docs.dnscontrol.org/language-reference/domain-modifiers/ownership_registry
+ */
+declare function OWNERSHIP_REGISTRY(owner: string, prefix?: string): DomainModifier;
+
 /**
  * `PANIC` terminates the script and therefore DNSControl with an exit code of 1. This should be used if your script cannot gather enough information to generate records, for example when a HTTP request failed.
  *
@@ -3462,6 +3739,8 @@ declare function SOA(name: string, ns: string, mbox: string, refresh: number, re
  * * `txtMaxSize` The maximum size for each TXT record. Values over 255 will result in [multiple strings][multi-string]. General recommendation is to [not go higher than 450][record-size] so that DNS responses will still fit in a UDP packet. (Optional. Default: `"255"`)
  * * `parts:` The individual parts of the SPF settings.
  * * `flatten:` Which includes should be inlined. For safety purposes the flattening is done on an opt-in basis. If `"*"` is listed, all includes will be flattened... this might create more problems than is solves due to length limitations.
+ * * `aggregate:` If `true`, the `ip4:` and `ip6:` ranges (including the ones of the flattened includes) are merged: duplicates are removed, and overlapping and adjacent ranges are replaced by the fewest CIDRs. (Optional. Default: `false`)
+ * * `optimize:` If `true`, DNSControl picks which includes to flatten (in addition to the ones of `flatten:`) to stay within 10 lookups with the fewest TXT records, and aggregates the ranges. See [The optimizer](#the-optimizer). (Optional. Default: `false`)
  *
  * [multi-string]: https://tools.ietf.org/html/rfc4408#section-3.1.3 [record-size]: https://tools.ietf.org/html/rfc4408#section-3.1.4
  *
@@ -3472,12 +3751,43 @@ declare function SOA(name: string, ns: string, mbox: string, refresh: number, re
  *   * `TXT("_spf1", "...")`
  *     * If the optimizer needs to split a long string across multiple TXT records, the additional TXT records will have labels `_spf1`, `_spf2`, `_spf3`, etc.
  *   * `TXT("_rawspf", "v=spf1 .... ~all")`
- *     * This is the unaltered SPF configuration. This is purely for debugging purposes and is not used by any email or anti-spam system.  It is only generated if flattening is requested.
+ *     * This is the unaltered SPF configuration. This is purely for debugging purposes and is not used by any email or anti-spam system.  It is only generated if flattening, aggregation or optimization is requested.
  *
  * We recommend first using this without any flattening. Make sure `dnscontrol preview` works as expected. Once that is done, add the flattening required to reduce the number of lookups to 10 or less.
  *
  * To count the number of lookups, you can use our interactive SPF debugger at [https://dnscontrol.github.io/dnscontrol/flattener/index.html](https://dnscontrol.github.io/dnscontrol/flattener/index.html)
  *
+ * ## The optimizer
+ *
+ * Rather than listing the includes to flatten, set `optimize: true`:
+ *
+ * ```javascript
+ * SPF_BUILDER({
+ *   label: "@",
+ *   overflow: "_spf%d",
+ *   parts: [
+ *     "v=spf1",
+ *     "ip4:198.252.206.0/24",
+ *     "include:_spf.google.com",
+ *     "include:mailgun.org",
+ *     "include:sendgrid.net",
+ *     "~all"
+ *   ],
+ *   flatten: [
+ *     "sendgrid.net" // Always flattened.
+ *   ],
+ *   optimize: true
+ * }),
+ * ```
+ *
+ * The optimizer tries the combinations of includes to flatten and keeps the one that:
+ *
+ * 1. requires at most 10 lookups (the includes that chain the split records count);
+ * 2. with the fewest TXT records;
+ * 3. flattening the fewest includes.
+ *
+ * The `ip4:` and `ip6:` ranges are merged as with `aggregate: true`. The number of lookups before and after the optimization is reported, and a warning is emitted if the record can't be brought within 10 lookups. With more than 12 includes to pick from, all of them are flattened.
+ *
  * # The first in a chain is special
  *
  * When generating the chain of SPF records, each one is max length 255.  For the first item in the chain, the max is 255 - "overhead1".  Setting this to 255 or higher has undefined behavior.
@@ -3512,7 +3822,31 @@ declare function SOA(name: string, ns: string, mbox: string, refresh: number, re
  *
  * DNSControl will optionally keep a cache of the DNS lookups performed during optimization.  In the event that a DNS server is down, the cache will be used. This makes it possible to do `dnscontrol push` even if your or third-party DNS servers are down.
  *
- * To enable this feature, create an (empty) file called `spfcache.json` in the current directory.  To disable this feature, delete the file. There are no command-line flags related to this feature.
+ * To enable this feature, create an (empty) file called `spfcache.json` in the current directory.  To disable this feature, delete the file.
+ *
+ * Each entry of `spfcache.json` records when the SPF record was looked up (`Fetched`) and its TTL in seconds (`TTL`):
+ *
+ * ```json
+ * {
+ *   "_spf.google.com": {
+ *     "SPF": "v=spf1 include:_netblocks.google.com include:_netblocks2.google.com include:_netblocks3.google.com ~all",
+ *     "Fetched": "2026-10-17T03:00:00Z",
+ *     "TTL": 300
+ *   }
+ * }
+ * ```
+ *
+ * The global flag `--spf-refresh` sets when the cached records are looked up again:
+ *
+ * * `always` (the default): every record is looked up again and compared to the cache.
+ * * `expired`: only the records whose TTL expired are looked up again. Entries without `Fetched` (written by older versions) are expired. A scheduled job can use this to refresh the cache on a cadence.
+ * * `never`: only the records that aren't in the cache are looked up.
+ *
+ * With the global flag `--spf-offline`, no record is looked up: the cache is used as is, and a record that isn't in it is an error. This makes CI runs reproducible without network access:
+ *
+ * ```shell
+ * dnscontrol --spf-offline preview
+ * ```
  *
  * The `spfcache.json` stored the cached DNS lookups. If it needs to be updated, the new file contents will be written to a file called `spfcache.updated.json` and instructions such as the ones below will be output telling you exactly what to do:
  *
@@ -3525,7 +3859,7 @@ declare function SOA(name: string, ns: string, mbox: string, refresh: number, re
  *     $ git commit spfcache.json
  * ```
  *
- * In this case, you are being asked to replace `spfcache.json` with the newly generated data in `spfcache.updated.json`.
+ * In this case, you are being asked to replace `spfcache.json` with the newly generated data in `spfcache.updated.json`. With `--spf-refresh expired`, the records that were refreshed because their TTL expired are listed too, so that their new fetch time is recorded.
  *
  * The instructions are hardcoded strings. The filenames will not change. The instructions assume you use git. If you use something else, please do the appropriate equivalent command.
  *
@@ -3535,7 +3869,7 @@ declare function SOA(name: string, ns: string, mbox: string, refresh: number, re
  *
  * 2. The TXT record that is generated may exceed DNS limits.  dnscontrol will not generate a single TXT record that exceeds DNS limits, but it ignores the fact that there may be other TXT records on the same label.  For example, suppose it generates a TXT record on the bare domain (stackoverflow.com) that is 250 bytes long. That's fine and doesn't require a continuation record.  However if there is another TXT record (not an SPF record, perhaps a TXT record used to verify domain ownership), the total packet size of all the TXT records could exceed 512 bytes, and will require EDNS or a TCP request.
  *
- * 3. DNSControl does not warn if the number of lookups exceeds 10. We hope to implement this some day.
+ * 3. DNSControl only warns if the number of lookups exceeds 10 when `optimize: true` is set.
  *
  * 4. The `redirect=` directive is only partially implemented.  We only handle the case where redirect is the last item in the SPF record. In which case, it is equivalent to `include:`.
  *
@@ -3587,7 +3921,7 @@ declare function SOA(name: string, ns: string, mbox: string, refresh: number, re
  *
  * @see https://docs.dnscontrol.org/language-reference/domain-modifiers/spf_builder
  */
-declare function SPF_BUILDER(opts: { label?: string; overflow?: string; overhead1?: string; raw?: string; ttl?: Duration; txtMaxSize?: number; parts: string[]; flatten?: string[] }): DomainModifier;
+declare function SPF_BUILDER(opts: { label?: string; overflow?: string; overhead1?: string; raw?: string; ttl?: Duration; txtMaxSize?: number; parts: string[]; flatten?: string[]; aggregate?: boolean; optimize?: boolean }): DomainModifier;
 
 /**
  * `SRV` adds a [Service locator record](https://www.rfc-editor.org/rfc/rfc2782) to a domain. The name should be the relative label for the record.
@@ -3677,6 +4011,45 @@ declare function SVCB(name: string, priority: number, target: string, params: st
  */
 declare function TLSA(name: string, usage: number, selector: number, type: number, certificate: string, ...modifiers: RecordModifier[]): DomainModifier;
 
+/**
+ * DNSControl contains a `TLSRPT_BUILDER` which can be used to create the
+ * `_smtp._tls` TXT record of [SMTP TLS Reporting](https://datatracker.ietf.org/doc/html/rfc8460).
+ * The senders send reports of the failures to negotiate TLS (with MTA-STS or
+ * DANE) to its `rua` targets.
+ *
+ * ## Example
+ *
+ * ```javascript
+ * D("example.com", REG_MY_PROVIDER, DnsProvider(DSP_MY_PROVIDER),
+ *   TLSRPT_BUILDER({
+ *     rua: [
+ *       "mailto:tlsrpt@example.com",
+ *       "https://reports.example.com/tlsrpt",
+ *     ],
+ *   }),
+ * );
+ * ```
+ *
+ * This yields the following record:
+ *
+ * ```text
+ * _smtp._tls  IN  TXT "v=TLSRPTv1; rua=mailto:tlsrpt@example.com,https://reports.example.com/tlsrpt"
+ * ```
+ *
+ * ### Parameters
+ *
+ * * `label:` The DNS label for the TLS-RPT record (`_smtp._tls` prefix is added, default: `"@"`)
+ * * `rua:` Array of report targets, `mailto:` or `https://` URIs (required)
+ * * `ttl:` Input for `TTL` method (optional)
+ *
+ * ### Caveats
+ *
+ * * Commas, semicolons and exclamation points must be percent-encoded in the URIs.
+ *
+ * @see https://docs.dnscontrol.org/language-reference/domain-modifiers/tlsrpt_builder
+ */
+declare function TLSRPT_BUILDER(opts: { label?: string; rua: string[]; ttl?: Duration }): DomainModifier;
+
 /**
  * TTL sets the TTL for a single record only. This will take precedence
  * over the domain's [DefaultTTL](../domain-modifiers/DefaultTTL.md) if supplied.
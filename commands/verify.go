@@ -0,0 +1,146 @@
+package commands
+
+import (
+	"cmp"
+	"context"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/DNSControl/dnscontrol/v4/models"
+	"github.com/DNSControl/dnscontrol/v4/pkg/diff2"
+	"github.com/DNSControl/dnscontrol/v4/pkg/nameservers"
+	"github.com/DNSControl/dnscontrol/v4/pkg/nsverify"
+	"github.com/DNSControl/dnscontrol/v4/pkg/printer"
+	"github.com/DNSControl/dnscontrol/v4/pkg/zonerecs"
+)
+
+// verifyInterval is how long to wait between attempts to verify a push.
+var verifyInterval = 10 * time.Second
+
+// pushedZone is a zone/provider pair whose corrections were run successfully.
+type pushedZone struct {
+	zone     *models.DomainConfig
+	provider *models.DNSProviderInstance
+}
+
+// verifyPush waits until the nameservers of each zone/provider serve the
+// records that were pushed, or timeout expires. The mismatches are reported
+// per zone/nameserver. It returns true if any zone could not be verified.
+func verifyPush(pushed []pushedZone, zresults *zoneResultCache, timeout time.Duration, out printer.CLI) bool {
+	type job struct {
+		pushedZone
+		nameservers []string
+		rrsets      []nsverify.RRset
+		err         error // The nameservers could not be determined.
+		mismatches  []nsverify.Mismatch
+	}
+
+	var jobs []*job
+	for _, p := range pushed {
+		zr, ok := zresults.get(p.zone, p.provider.Name)
+		if !ok || len(zr.Corrections) == 0 {
+			continue // Nothing was changed.
+		}
+		j := &job{pushedZone: p, rrsets: expectedRRsets(zr)}
+		if len(j.rrsets) == 0 {
+			continue
+		}
+		nss, err := nameservers.DetermineNameserversForProviders(p.zone, []*models.DNSProviderInstance{p.provider}, true)
+		j.err = err
+		for _, ns := range nss {
+			j.nameservers = append(j.nameservers, ns.Name)
+		}
+		if err == nil && len(nss) == 0 {
+			out.ForZone(p.zone.GetUniqueName(), p.provider.Name).Warnf("%s (%s): not verified: no nameservers\n", p.zone.DisplayName, p.provider.Name)
+			continue
+		}
+		jobs = append(jobs, j)
+	}
+	if len(jobs) == 0 {
+		return false
+	}
+
+	out.Printf("Verifying %d zone(s) at their nameservers (timeout %s)...\n", len(jobs), timeout)
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	var wg sync.WaitGroup
+	for _, j := range jobs {
+		if j.err != nil {
+			continue
+		}
+		wg.Go(func() {
+			j.mismatches = nsverify.Wait(ctx, j.nameservers, j.rrsets, verifyInterval)
+		})
+	}
+	wg.Wait()
+
+	var anyErrors bool
+	for _, j := range jobs {
+		zout := out.ForZone(j.zone.GetUniqueName(), j.provider.Name)
+		switch {
+		case j.err != nil:
+			anyErrors = true
+			zout.Errorf("%s (%s): verification failed: %s\n", j.zone.DisplayName, j.provider.Name, j.err)
+		case len(j.mismatches) != 0:
+			anyErrors = true
+			for _, mm := range j.mismatches {
+				zout.Errorf("%s (%s): verification failed: %s\n", j.zone.DisplayName, j.provider.Name, mm)
+			}
+		default:
+			zout.Printf("%s (%s): verified %d RRset(s) at %s\n", j.zone.DisplayName, j.provider.Name, len(j.rrsets), strings.Join(j.nameservers, ", "))
+		}
+	}
+	return anyErrors
+}
+
+// expectedRRsets returns the RRsets that a push changed and the records
+// they should now have.  The records are what existed, minus what diff2 said
+// to remove, plus what diff2 said to add. That way records preserved by
+// NO_PURGE, IGNORE(), etc. are expected to remain.
+func expectedRRsets(zr zonerecs.ZoneResult) []nsverify.RRset {
+	type key struct{ name, rtype string }
+	want := map[key]models.Records{}
+	for _, r := range zr.Existing {
+		k := key{r.GetLabelFQDN(), r.Type}
+		want[k] = append(want[k], r)
+	}
+
+	// Removals first, so that a record that is removed by one instruction
+	// and added by another is expected.
+	changed := map[key]bool{}
+	for _, c := range zr.Changes {
+		if c.Type == diff2.REPORT {
+			continue
+		}
+		for _, r := range c.Old {
+			k := key{r.GetLabelFQDN(), r.Type}
+			changed[k] = true
+			want[k] = slices.DeleteFunc(want[k], func(x *models.RecordConfig) bool {
+				return x.ToComparableNoTTL() == r.ToComparableNoTTL()
+			})
+		}
+	}
+	for _, c := range zr.Changes {
+		if c.Type == diff2.REPORT {
+			continue
+		}
+		for _, r := range c.New {
+			k := key{r.GetLabelFQDN(), r.Type}
+			changed[k] = true
+			want[k] = append(want[k], r)
+		}
+	}
+
+	var rrsets []nsverify.RRset
+	for k := range changed {
+		if nsverify.Verifiable(k.rtype) {
+			rrsets = append(rrsets, nsverify.RRset{Name: k.name, Type: k.rtype, Want: want[k]})
+		}
+	}
+	slices.SortFunc(rrsets, func(a, b nsverify.RRset) int {
+		return cmp.Or(strings.Compare(a.Name, b.Name), strings.Compare(a.Type, b.Type))
+	})
+	return rrsets
+}
@@ -0,0 +1,66 @@
+package commands
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/DNSControl/dnscontrol/v4/models"
+	"github.com/DNSControl/dnscontrol/v4/pkg/diff2"
+	"github.com/DNSControl/dnscontrol/v4/pkg/zonerecs"
+)
+
+func Test_expectedRRsets(t *testing.T) {
+	existing := models.Records{
+		makePlanRec("www", "A", "1.1.1.1"),
+		makePlanRec("www", "A", "2.2.2.2"),
+		makePlanRec("mail", "A", "5.5.5.5"),
+		makePlanRec("old", "TXT", "goodbye"),
+	}
+
+	for _, fn := range []struct {
+		name string
+		by   func(models.Records, *models.DomainConfig, diff2.ComparableFunc) (diff2.ChangeList, int, error)
+	}{
+		{"ByRecord", diff2.ByRecord},
+		{"ByRecordSet", diff2.ByRecordSet},
+		{"ByLabel", diff2.ByLabel},
+	} {
+		t.Run(fn.name, func(t *testing.T) {
+			dc := models.MustNewDomainConfig("example.com")
+			dc.Records = models.Records{
+				makePlanRec("www", "A", "1.1.1.1"),
+				makePlanRec("www", "A", "3.3.3.3"),
+				makePlanRec("mail", "A", "5.5.5.5"),
+				makePlanRec("new", "AAAA", "2001:db8::1"),
+			}
+			diff2.StartRecording(dc)
+			if _, _, err := fn.by(existing, dc, nil); err != nil {
+				t.Fatal(err)
+			}
+			zr := zonerecs.ZoneResult{Existing: existing, Changes: diff2.StopRecording(dc)}
+
+			got := map[string][]string{}
+			for _, rs := range expectedRRsets(zr) {
+				k := rs.Name + " " + rs.Type
+				got[k] = []string{}
+				for _, r := range rs.Want {
+					got[k] = append(got[k], r.GetTargetCombined())
+				}
+				slices.Sort(got[k])
+			}
+			want := map[string][]string{
+				"new.example.com AAAA": {"2001:db8::1"},
+				"old.example.com TXT":  {},
+				"www.example.com A":    {"1.1.1.1", "3.3.3.3"},
+			}
+			if len(got) != len(want) {
+				t.Fatalf("expectedRRsets() = %v, want %v", got, want)
+			}
+			for k, w := range want {
+				if !slices.Equal(got[k], w) {
+					t.Errorf("expectedRRsets()[%q] = %v, want %v", k, got[k], w)
+				}
+			}
+		})
+	}
+}
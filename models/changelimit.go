@@ -0,0 +1,19 @@
+package models
+
+// ChangeLimit describes a CHANGE_LIMIT() rule: the most a single push may
+// change a zone. A negative value means there is no limit.
+type ChangeLimit struct {
+	// Maximum number of records that may be deleted.
+	MaxDeletes int `json:"max_deletes"`
+
+	// Maximum percentage of the existing records that may be deleted or modified.
+	MaxChangePercent int `json:"max_change_percent"`
+}
+
+// NoChangeLimit is a ChangeLimit that permits any amount of change.
+var NoChangeLimit = ChangeLimit{MaxDeletes: -1, MaxChangePercent: -1}
+
+// IsLimited returns true if cl limits anything.
+func (cl ChangeLimit) IsLimited() bool {
+	return cl.MaxDeletes >= 0 || cl.MaxChangePercent >= 0
+}
@@ -18,6 +18,10 @@ type DNSConfig struct {
 	RegistrarsByName   map[string]*RegistrarConfig   `json:"-"`
 	DNSProvidersByName map[string]*DNSProviderConfig `json:"-"`
 	SkipRecordAudit    bool                          `json:"skiprecordaudit,omitempty"`
+	// MTASTSPolicies are the policy files of MTASTS_BUILDER(), by file name
+	// (relative to dnsconfig.js). They are collected by normalization and
+	// written by push.
+	MTASTSPolicies map[string]string `json:"-"`
 }
 
 // FindDomain returns the *DomainConfig for domain query in config.
@@ -103,6 +107,10 @@ func NameserversToStrings(nss []*Nameserver) (s []string) {
 type Correction struct {
 	F   func() error `json:"-"`
 	Msg string
+	// NonRecord is true if the correction changes something other than the
+	// zone's records, such as a server config file or the catalog zone.
+	// Change limits and drift detection ignore such corrections.
+	NonRecord bool `json:"-"`
 }
 
 // PostProcess performs and post-processing required after running dnsconfig.js and loading the result.
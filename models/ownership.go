@@ -0,0 +1,12 @@
+package models
+
+// OwnershipRegistry describes an OWNERSHIP_REGISTRY() rule: DNSControl marks
+// the records it manages with TXT records naming Owner, and only modifies or
+// deletes records that are marked as its own.
+type OwnershipRegistry struct {
+	// The owner ID written to (and looked for in) the ownership TXT records.
+	Owner string `json:"owner"`
+
+	// Prefix of the label of the ownership TXT records. Empty means the default.
+	Prefix string `json:"prefix,omitempty"`
+}
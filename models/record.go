@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"log"
+	"regexp"
 	"strings"
 
 	"github.com/DNSControl/dnscontrol/v4/pkg/txtutil"
@@ -236,6 +237,8 @@ func (rc *RecordConfig) UnmarshalJSON(b []byte) error {
 	return nil
 }
 
+var programCounter = regexp.MustCompile(`\(\d+\)(\)?)$`)
+
 // FixPosition takes the string representation of a position in a file that
 // comes from dnsconfig.js's initial execution, and reduces it down to just the
 // line/position we display to the user. The input is not well-defined, thus if
@@ -248,7 +251,10 @@ func FixPosition(str string) string {
 	str = strings.TrimSpace(str)
 	str = strings.ReplaceAll(str, "\n", " ")
 	str = strings.ReplaceAll(str, "<anonymous>", "line")
+	str = strings.ReplaceAll(str, "<eval>", "line") // goja
 	str = strings.TrimPrefix(str, "at ")
+	// goja appends the program counter: "<eval>:3:5(12)".
+	str = programCounter.ReplaceAllString(str, "$1")
 	return fmt.Sprintf("[%s]", str)
 }
 
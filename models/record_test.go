@@ -245,6 +245,16 @@ func TestFixPosition(t *testing.T) {
 			pos:  "at <anonymous>:2904:5",
 			want: "[line:2904:5]",
 		},
+		{
+			name: "goja position",
+			pos:  "\tat <eval>:12:6(42)",
+			want: "[line:12:6]",
+		},
+		{
+			name: "goja position in a function",
+			pos:  "at macro (<eval>:3:10(7))",
+			want: "[macro (line:3:10)]",
+		},
 		{
 			name: "random string",
 			pos:  "alsdjfsljd",
@@ -0,0 +1,97 @@
+// Package catalogzone generates catalog zones (RFC 9432), which list the
+// zones that a primary serves so that its secondaries can provision them.
+package catalogzone
+
+import (
+	"crypto/sha1" //#nosec G505 -- The ID only has to be unique, as in RFC 9432 section 4.1.
+	"encoding/hex"
+	"fmt"
+
+	"github.com/DNSControl/dnscontrol/v4/models"
+	dnsv1 "github.com/miekg/dns"
+)
+
+// Domain metadata that sets the properties of a member zone.
+const (
+	MetaGroup = "catalog_group" // The "group" property (RFC 9432 section 4.4.2).
+	MetaCoo   = "catalog_coo"   // The "coo" property (RFC 9432 section 4.4.1), a catalog zone name.
+)
+
+// schemaVersion is the version of RFC 9432 catalog zones.
+const schemaVersion = "2"
+
+// MemberID returns the unique ID of a member zone: the SHA-1 hash of its
+// name in wire format, as BIND and Knot generate it.
+func MemberID(zone string) (string, error) {
+	buf := make([]byte, 255)
+	n, err := dnsv1.PackDomainName(dnsv1.CanonicalName(zone), buf, 0, nil, false)
+	if err != nil {
+		return "", fmt.Errorf("invalid zone name %q: %w", zone, err)
+	}
+	sum := sha1.Sum(buf[:n]) //#nosec G401
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// Zone returns the catalog zone named catalog that lists the members.
+// The SOA is left to the provider. A zone that is listed more than once
+// (in split horizon views) is a single member, with the properties of the
+// first.
+func Zone(catalog string, members []*models.DomainConfig) (*models.DomainConfig, error) {
+	dc, err := models.NewDomainConfig(catalog)
+	if err != nil {
+		return nil, err
+	}
+	dc.PostProcess()
+
+	add := func(label, rtype, target string) error {
+		rc := &models.RecordConfig{Type: rtype, TTL: models.DefaultTTL, Metadata: map[string]string{}}
+		rc.SetLabel(label, dc.Name)
+		var err error
+		if rtype == "TXT" {
+			err = rc.SetTargetTXT(target)
+		} else {
+			err = rc.SetTarget(target)
+		}
+		dc.Records = append(dc.Records, rc)
+		return err
+	}
+
+	// RFC 9432 section 4.1 and 4.2:
+	if err := add("@", "NS", "invalid."); err != nil {
+		return nil, err
+	}
+	if err := add("version", "TXT", schemaVersion); err != nil {
+		return nil, err
+	}
+
+	seen := map[string]bool{}
+	for _, m := range members {
+		if m.Name == dc.Name {
+			return nil, fmt.Errorf("catalog zone %s can not be a member of itself. Remove it from dnsconfig.js", dc.Name)
+		}
+		if seen[m.Name] {
+			continue
+		}
+		seen[m.Name] = true
+
+		id, err := MemberID(m.Name)
+		if err != nil {
+			return nil, err
+		}
+		label := id + ".zones"
+		if err := add(label, "PTR", m.Name+"."); err != nil {
+			return nil, err
+		}
+		if group := m.Metadata[MetaGroup]; group != "" {
+			if err := add("group."+label, "TXT", group); err != nil {
+				return nil, err
+			}
+		}
+		if coo := m.Metadata[MetaCoo]; coo != "" {
+			if err := add("coo."+label, "PTR", dnsv1.Fqdn(coo)); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return dc, nil
+}
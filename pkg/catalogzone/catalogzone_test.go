@@ -0,0 +1,67 @@
+package catalogzone
+
+import (
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/DNSControl/dnscontrol/v4/models"
+)
+
+func TestMemberID(t *testing.T) {
+	// The example in RFC 9432 section 4.1.
+	got, err := MemberID("domain.example")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "5960775ba382e7a4e09263fc06e7c00569b6a05c"; got != want {
+		t.Errorf("MemberID() = %s, want %s", got, want)
+	}
+	if again, _ := MemberID("Domain.Example."); again != got {
+		t.Errorf("MemberID() depends on the case or the trailing dot: %s", again)
+	}
+}
+
+func member(name string, metadata map[string]string) *models.DomainConfig {
+	dc := models.MustNewDomainConfig(name)
+	for k, v := range metadata {
+		dc.Metadata[k] = v
+	}
+	return dc
+}
+
+func TestZone(t *testing.T) {
+	dc, err := Zone("catalog.example", []*models.DomainConfig{
+		member("example.com", nil),
+		member("example.org", map[string]string{MetaGroup: "signed", MetaCoo: "new-catalog.example"}),
+		member("example.com!inside", map[string]string{MetaGroup: "ignored"}),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if dc.Name != "catalog.example" {
+		t.Errorf("Name = %q", dc.Name)
+	}
+	var got []string
+	for _, rc := range dc.Records {
+		got = append(got, rc.Name+" "+rc.Type+" "+rc.GetTargetCombined())
+	}
+	want := []string{
+		"@ NS invalid.",
+		`version TXT "2"`,
+		"c5e4b4da1e5a620ddaa3635e55c3732a5b49c7f4.zones PTR example.com.",
+		"47ac1a4d93b61fffdb4762c18c9e7d1a6b046d33.zones PTR example.org.",
+		`group.47ac1a4d93b61fffdb4762c18c9e7d1a6b046d33.zones TXT "signed"`,
+		"coo.47ac1a4d93b61fffdb4762c18c9e7d1a6b046d33.zones PTR new-catalog.example.",
+	}
+	if !slices.Equal(got, want) {
+		t.Errorf("records =\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+}
+
+func TestZone_self(t *testing.T) {
+	_, err := Zone("catalog.example", []*models.DomainConfig{member("catalog.example", nil)})
+	if err == nil || !strings.Contains(err.Error(), "member of itself") {
+		t.Errorf("Zone() error = %v, want a member of itself error", err)
+	}
+}
@@ -0,0 +1,68 @@
+package diff2
+
+// This file implements the CHANGE_LIMIT() safety check.  A typo in
+// dnsconfig.js (for example, a missing INCLUDE or a bad variable) can
+// delete most of a zone.  The check compares the instructions generated
+// for a zone to a ChangeLimit so that such a push can be refused before
+// any correction is run.
+
+import (
+	"fmt"
+
+	"github.com/DNSControl/dnscontrol/v4/models"
+)
+
+// Tally is the number of records affected by a ChangeList.
+type Tally struct {
+	Creates  int // Records added.
+	Modifies int // Records replaced by a different record.
+	Deletes  int // Records removed.
+}
+
+// Tally counts the records affected by the instructions. REPORTs are not
+// counted.
+//
+// A CHANGE generated by ByRecordSet() or ByLabel() may replace many
+// records with a different number of records. The records are paired off
+// as modifications; any extra old records are counted as deletes and any
+// extra new records as creates.
+func (cl ChangeList) Tally() Tally {
+	var t Tally
+	for _, c := range cl {
+		switch c.Type {
+		case CREATE:
+			t.Creates += len(c.New)
+		case DELETE:
+			t.Deletes += len(c.Old)
+		case CHANGE:
+			o, n := len(c.Old), len(c.New)
+			t.Modifies += min(o, n)
+			t.Deletes += max(0, o-n)
+			t.Creates += max(0, n-o)
+		}
+	}
+	return t
+}
+
+// CheckChangeLimit returns an error if the instructions exceed limit.
+// existingCount is the number of records in the zone before any change is
+// made.  The change percentage is the number of records deleted or modified
+// as a percentage of existingCount. Adding records to a zone is always
+// permitted.
+func CheckChangeLimit(cl ChangeList, existingCount int, limit models.ChangeLimit) error {
+	t := cl.Tally()
+
+	if limit.MaxDeletes >= 0 && t.Deletes > limit.MaxDeletes {
+		return fmt.Errorf("%d records would be deleted (the limit is %d)", t.Deletes, limit.MaxDeletes)
+	}
+
+	if limit.MaxChangePercent >= 0 && existingCount > 0 {
+		percent := float64(t.Deletes+t.Modifies) * 100 / float64(existingCount)
+		if percent > float64(limit.MaxChangePercent) {
+			return fmt.Errorf("%d of %d records (%.1f%%) would be deleted or modified (the limit is %d%%)",
+				t.Deletes+t.Modifies, existingCount, percent, limit.MaxChangePercent)
+		}
+	}
+
+	return nil
+}
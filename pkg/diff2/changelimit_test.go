@@ -0,0 +1,61 @@
+package diff2
+
+import (
+	"testing"
+
+	"github.com/DNSControl/dnscontrol/v4/models"
+)
+
+func TestChangeListTally(t *testing.T) {
+	a1 := makeRec("www", "A", "1.1.1.1")
+	a2 := makeRec("www", "A", "2.2.2.2")
+	a3 := makeRec("www", "A", "3.3.3.3")
+	mx := makeRec("@", "MX", "10 mx.f.com.")
+
+	cl := ChangeList{
+		{Type: REPORT, Msgs: []string{"ignored"}},
+		{Type: CREATE, New: models.Records{mx}},
+		{Type: DELETE, Old: models.Records{a1, a2}},
+		{Type: CHANGE, Old: models.Records{a1, a2, a3}, New: models.Records{a1}}, // 1 modified, 2 deleted
+		{Type: CHANGE, Old: models.Records{a1}, New: models.Records{a2, a3}},     // 1 modified, 1 created
+	}
+	got := cl.Tally()
+	want := Tally{Creates: 2, Modifies: 2, Deletes: 4}
+	if got != want {
+		t.Errorf("Tally() = %+v, want %+v", got, want)
+	}
+}
+
+func TestCheckChangeLimit(t *testing.T) {
+	a1 := makeRec("www", "A", "1.1.1.1")
+	a2 := makeRec("www", "A", "2.2.2.2")
+	// 3 deletes, 1 modify, 1 create.
+	cl := ChangeList{
+		{Type: DELETE, Old: models.Records{a1, a2, a1}},
+		{Type: CHANGE, Old: models.Records{a1}, New: models.Records{a2}},
+		{Type: CREATE, New: models.Records{a2}},
+	}
+
+	tests := []struct {
+		name     string
+		existing int
+		limit    models.ChangeLimit
+		wantErr  bool
+	}{
+		{"none", 10, models.NoChangeLimit, false},
+		{"deletesUnder", 10, models.ChangeLimit{MaxDeletes: 3, MaxChangePercent: -1}, false},
+		{"deletesOver", 10, models.ChangeLimit{MaxDeletes: 2, MaxChangePercent: -1}, true},
+		{"deletesZero", 10, models.ChangeLimit{MaxDeletes: 0, MaxChangePercent: -1}, true},
+		{"percentUnder", 10, models.ChangeLimit{MaxDeletes: -1, MaxChangePercent: 40}, false},
+		{"percentOver", 10, models.ChangeLimit{MaxDeletes: -1, MaxChangePercent: 39}, true},
+		{"percentNewZone", 0, models.ChangeLimit{MaxDeletes: -1, MaxChangePercent: 0}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := CheckChangeLimit(cl, tt.existing, tt.limit)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("CheckChangeLimit() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
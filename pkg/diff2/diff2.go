@@ -230,7 +230,7 @@ func byHelper(fn func(cc *CompareConfig) (ChangeList, int), existing models.Reco
 
 // byHelperStruct does 90% of the work for the By*() calls.
 func byHelperStruct(fn func(cc *CompareConfig) (ChangeList, int), existing models.Records, dc *models.DomainConfig, compFunc ComparableFunc) (ByResults, error) {
-	// Process NO_PURGE/ENSURE_ABSENT and IGNORE*().
+	// Process NO_PURGE/ENSURE_ABSENT, IGNORE*() and OWNERSHIP_REGISTRY().
 	desiredPlus, msgs, err := handsoff(
 		dc.Name,
 		existing, dc.Records, dc.EnsureAbsent,
@@ -239,6 +239,7 @@ func byHelperStruct(fn func(cc *CompareConfig) (ChangeList, int), existing model
 		dc.KeepUnknown,
 		dc.IgnoreExternalDNS,
 		dc.ExternalDNSPrefix,
+		dc.OwnershipRegistry,
 	)
 	if err != nil {
 		return ByResults{}, err
@@ -261,6 +262,8 @@ func byHelperStruct(fn func(cc *CompareConfig) (ChangeList, int), existing model
 		instructions = append([]Change{chg}, instructions...)
 	}
 
+	record(dc, instructions)
+
 	return ByResults{
 		Instructions:      instructions,
 		ActualChangeCount: actualChangeCount,
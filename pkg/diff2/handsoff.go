@@ -99,7 +99,7 @@ The actual implementation combines this all into one loop:
     Append "foreign list" to "desired".
 */
 
-// handsoff processes the IGNORE*()//NO_PURGE/ENSURE_ABSENT features.
+// handsoff processes the IGNORE*()//NO_PURGE/ENSURE_ABSENT/OWNERSHIP_REGISTRY features.
 func handsoff(
 	domain string,
 	existing, desired, absences models.Records,
@@ -108,6 +108,7 @@ func handsoff(
 	noPurge bool,
 	ignoreExternalDNS bool,
 	externalDNSPrefix string,
+	ownership *models.OwnershipRegistry,
 ) (models.Records, []string, error) {
 	var msgs []string
 
@@ -174,10 +175,42 @@ func handsoff(
 		externalDNSIgnored = filterOutConflicts(externalDNSIgnored, externalDNSConflicts)
 	}
 
+	// Process OWNERSHIP_REGISTRY feature. This is done last so that records
+	// that are already protected are not reported twice.
+	var owned ownershipResult
+	if ownership != nil {
+		skip := map[*models.RecordConfig]bool{}
+		for _, recs := range []models.Records{ignorable, foreign, externalDNSIgnored} {
+			for _, rec := range recs {
+				skip[rec] = true
+			}
+		}
+		owned = processOwnership(domain, existing, desired, ownership, skip)
+		if len(owned.conflict) != 0 {
+			return nil, nil, fmt.Errorf("OWNERSHIP_REGISTRY(%q): these records are managed by another owner. Remove them from dnsconfig.js:\n    %s",
+				ownership.Owner, strings.Join(owned.conflict, "\n    "))
+		}
+		if len(owned.foreign) != 0 {
+			msgs = append(msgs, fmt.Sprintf("%d records not being touched because they are owned by others (OWNERSHIP_REGISTRY)%s", len(owned.foreign), punct))
+			msgs = append(msgs, reportSkips(owned.foreign, !printer.SkinnyReport)...)
+		}
+		if len(owned.unowned) != 0 {
+			msgs = append(msgs, fmt.Sprintf("%d records not being deleted because they are not owned by %q (OWNERSHIP_REGISTRY)%s", len(owned.unowned), ownership.Owner, punct))
+			msgs = append(msgs, reportSkips(owned.unowned, !printer.SkinnyReport)...)
+		}
+		if len(owned.adopted) != 0 {
+			msgs = append(msgs, fmt.Sprintf("%d records being adopted by %q (OWNERSHIP_REGISTRY)%s", len(owned.adopted), ownership.Owner, punct))
+			msgs = append(msgs, reportSkips(owned.adopted, !printer.SkinnyReport)...)
+		}
+	}
+
 	// Add the ignored/foreign items to the desired list so they are not deleted:
 	desired = append(desired, ignorable...)
 	desired = append(desired, foreign...)
 	desired = append(desired, externalDNSIgnored...)
+	desired = append(desired, owned.markers...)
+	desired = append(desired, owned.foreign...)
+	desired = append(desired, owned.unowned...)
 	return desired, msgs, nil
 }
 
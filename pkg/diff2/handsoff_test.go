@@ -275,6 +275,7 @@ func Test_ignore_external_dns(t *testing.T) {
 		false, // noPurge
 		true,  // ignoreExternalDNS
 		"",    // externalDNSPrefix (empty = default)
+		nil,   // ownership
 	)
 	if err != nil {
 		t.Fatal(err)
@@ -361,6 +362,7 @@ func Test_ignore_external_dns_custom_prefix(t *testing.T) {
 		false,     // noPurge
 		true,      // ignoreExternalDNS
 		"extdns-", // externalDNSPrefix
+		nil,       // ownership
 	)
 	if err != nil {
 		t.Fatal(err)
@@ -413,6 +415,7 @@ func Test_ignore_external_dns_conflict(t *testing.T) {
 		false, // noPurge
 		true,  // ignoreExternalDNS
 		"",    // externalDNSPrefix
+		nil,   // ownership
 	)
 	if err != nil {
 		t.Fatal(err)
@@ -0,0 +1,165 @@
+package diff2
+
+// This file implements the OWNERSHIP_REGISTRY feature: DNSControl marks the
+// records it manages with TXT records (much like external-dns' TXT
+// registry) and only modifies or deletes records that are marked as its own.
+// This lets several dnsconfig.js files (or other tools) manage the same zone.
+//
+// For each label:rtype that DNSControl manages there is one marker:
+//   <prefix><rtype>.<label> TXT "heritage=dnscontrol,dnscontrol/owner=<owner>"
+// For example, with the default prefix, "_dnscontrol-a.www" marks the A
+// records at "www", and "_dnscontrol-mx" marks the MX records at the apex.
+//
+// An existing record is:
+// - owned by us if its marker names our owner. It is managed as usual.
+// - owned by someone else if its marker names a different owner. It is never
+//   touched. Defining it in dnsconfig.js is an error.
+// - unowned if it has no marker. It is adopted (marked as ours) if
+//   dnsconfig.js defines its label:rtype. Otherwise it is left alone.
+// The SOA and apex NS records are exempt. They are managed as usual.
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/DNSControl/dnscontrol/v4/models"
+)
+
+const (
+	// DefaultOwnershipPrefix is the prefix of the ownership TXT records' labels
+	// if OWNERSHIP_REGISTRY() is not given one.
+	DefaultOwnershipPrefix = "_dnscontrol-"
+
+	ownershipHeritage = "heritage=dnscontrol"
+	ownershipOwnerKey = "dnscontrol/owner="
+)
+
+// ownershipMarkerLabel returns the label of the TXT record that marks who
+// owns the label:rtype records.
+func ownershipMarkerLabel(prefix, label, rtype string) string {
+	marker := prefix + strings.ToLower(rtype)
+	if label == "@" {
+		return marker
+	}
+	// A "*" that is not the leftmost label is not a wildcard. Most providers
+	// reject it.
+	if rest, ok := strings.CutPrefix(label, "*"); ok {
+		label = "_wildcard" + rest
+	}
+	return marker + "." + label
+}
+
+// ownershipMarkerText returns the contents of a TXT record that marks owner's records.
+func ownershipMarkerText(owner string) string {
+	return ownershipHeritage + "," + ownershipOwnerKey + owner
+}
+
+// parseOwnershipMarker returns the owner named in rec, or false if rec is
+// not an ownership marker.
+func parseOwnershipMarker(rec *models.RecordConfig) (string, bool) {
+	if rec.Type != "TXT" {
+		return "", false
+	}
+	fields := strings.Split(rec.GetTargetTXTJoined(), ",")
+	if fields[0] != ownershipHeritage {
+		return "", false
+	}
+	for _, f := range fields[1:] {
+		if owner, ok := strings.CutPrefix(f, ownershipOwnerKey); ok {
+			return owner, true
+		}
+	}
+	return "", true // A marker without an owner is owned by nobody we know.
+}
+
+// ownershipExempt returns true if rec is managed as usual, without a marker.
+// The SOA and apex NS records belong to the zone rather than to an owner.
+// Everyone that shares the zone generates the same NS records from the
+// providers' nameservers.
+func ownershipExempt(rec *models.RecordConfig) bool {
+	return rec.Type == "SOA" || (rec.Type == "NS" && rec.GetLabel() == "@")
+}
+
+// ownershipResult is what processOwnership learned about a zone.
+type ownershipResult struct {
+	markers  models.Records // Markers to add to desired.
+	foreign  models.Records // Existing records owned by someone else (and their markers).
+	unowned  models.Records // Existing records without a marker that are not in desired.
+	adopted  models.Records // Existing records without a marker that are in desired.
+	conflict []string       // Desired label:rtypes that are owned by someone else.
+}
+
+// processOwnership implements OWNERSHIP_REGISTRY. skip lists the existing
+// records that IGNORE*(), NO_PURGE, etc. already protect.
+func processOwnership(domain string, existing, desired models.Records, reg *models.OwnershipRegistry, skip map[*models.RecordConfig]bool) ownershipResult {
+	prefix := reg.Prefix
+	if prefix == "" {
+		prefix = DefaultOwnershipPrefix
+	}
+
+	type key struct{ label, rtype string }
+	var res ownershipResult
+
+	// Who owns what, according to the existing markers:
+	owners := map[string]string{} // marker label -> owner
+	for _, rec := range existing {
+		if owner, ok := parseOwnershipMarker(rec); ok {
+			if prev, dup := owners[rec.GetLabel()]; dup && prev != owner {
+				owner = "" // Conflicting markers. Trust neither.
+			}
+			owners[rec.GetLabel()] = owner
+		}
+	}
+
+	// The markers for the records we want:
+	wanted := map[key]bool{}
+	for _, rec := range desired {
+		k := key{rec.GetLabel(), rec.Type}
+		if wanted[k] || ownershipExempt(rec) {
+			continue
+		}
+		if _, ok := parseOwnershipMarker(rec); ok {
+			continue
+		}
+		wanted[k] = true
+
+		marker := &models.RecordConfig{Type: "TXT", TTL: rec.TTL, Metadata: map[string]string{}}
+		marker.SetLabel(ownershipMarkerLabel(prefix, k.label, k.rtype), domain)
+		if err := marker.SetTargetTXT(ownershipMarkerText(reg.Owner)); err != nil {
+			panic(err) // Can not happen. The type is TXT.
+		}
+		res.markers = append(res.markers, marker)
+
+		if owner, ok := owners[marker.GetLabel()]; ok && owner != reg.Owner {
+			if owner == "" {
+				res.conflict = append(res.conflict, fmt.Sprintf("%s %s has a marker with no owner (or conflicting owners)", rec.GetLabelFQDN(), rec.Type))
+			} else {
+				res.conflict = append(res.conflict, fmt.Sprintf("%s %s is owned by %q", rec.GetLabelFQDN(), rec.Type, owner))
+			}
+		}
+	}
+
+	for _, rec := range existing {
+		if skip[rec] || ownershipExempt(rec) {
+			continue
+		}
+		if owner, ok := parseOwnershipMarker(rec); ok {
+			if owner != reg.Owner {
+				res.foreign = append(res.foreign, rec)
+			}
+			continue // Our markers are regenerated from desired (or deleted).
+		}
+		owner, marked := owners[ownershipMarkerLabel(prefix, rec.GetLabel(), rec.Type)]
+		switch {
+		case marked && owner == reg.Owner:
+			// Ours.
+		case marked:
+			res.foreign = append(res.foreign, rec)
+		case wanted[key{rec.GetLabel(), rec.Type}]:
+			res.adopted = append(res.adopted, rec)
+		default:
+			res.unowned = append(res.unowned, rec)
+		}
+	}
+	return res
+}
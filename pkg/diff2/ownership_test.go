@@ -0,0 +1,166 @@
+package diff2
+
+import (
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/DNSControl/dnscontrol/v4/models"
+)
+
+func TestOwnershipMarkerLabel(t *testing.T) {
+	tests := []struct {
+		prefix, label, rtype string
+		want                 string
+	}{
+		{"_dnscontrol-", "www", "A", "_dnscontrol-a.www"},
+		{"_dnscontrol-", "@", "MX", "_dnscontrol-mx"},
+		{"_dnscontrol-", "*", "CNAME", "_dnscontrol-cname._wildcard"},
+		{"_dnscontrol-", "*.dev", "A", "_dnscontrol-a._wildcard.dev"},
+		{"own-", "a.b", "AAAA", "own-aaaa.a.b"},
+	}
+	for _, tt := range tests {
+		if got := ownershipMarkerLabel(tt.prefix, tt.label, tt.rtype); got != tt.want {
+			t.Errorf("ownershipMarkerLabel(%q, %q, %q) = %q, want %q", tt.prefix, tt.label, tt.rtype, got, tt.want)
+		}
+	}
+}
+
+func TestParseOwnershipMarker(t *testing.T) {
+	tests := []struct {
+		rtype, target string
+		wantOwner     string
+		wantOK        bool
+	}{
+		{"TXT", "heritage=dnscontrol,dnscontrol/owner=team-a", "team-a", true},
+		{"TXT", "heritage=dnscontrol", "", true},
+		{"TXT", "heritage=external-dns,external-dns/owner=k8s", "", false},
+		{"TXT", "v=spf1 -all", "", false},
+		{"A", "1.2.3.4", "", false},
+	}
+	for _, tt := range tests {
+		owner, ok := parseOwnershipMarker(makeTestRecord("x", tt.rtype, tt.target, "f.com"))
+		if owner != tt.wantOwner || ok != tt.wantOK {
+			t.Errorf("parseOwnershipMarker(%q) = %q, %v, want %q, %v", tt.target, owner, ok, tt.wantOwner, tt.wantOK)
+		}
+	}
+}
+
+// ownershipZone returns a zone that two owners share.
+func ownershipZone() models.Records {
+	const domain = "f.com"
+	return models.Records{
+		// Ours:
+		makeTestRecord("_dnscontrol-a.www", "TXT", "heritage=dnscontrol,dnscontrol/owner=team-a", domain),
+		makeTestRecord("www", "A", "1.1.1.1", domain),
+		makeTestRecord("_dnscontrol-a.old", "TXT", "heritage=dnscontrol,dnscontrol/owner=team-a", domain),
+		makeTestRecord("old", "A", "5.5.5.5", domain),
+		// Someone else's:
+		makeTestRecord("_dnscontrol-a.api", "TXT", "heritage=dnscontrol,dnscontrol/owner=team-b", domain),
+		makeTestRecord("api", "A", "2.2.2.2", domain),
+		// Nobody's:
+		makeTestRecord("manual", "A", "3.3.3.3", domain),
+		makeTestRecord("mail", "A", "4.4.4.4", domain),
+	}
+}
+
+func TestOwnershipRegistry(t *testing.T) {
+	const domain = "f.com"
+	existing := ownershipZone()
+
+	dc := models.MustNewDomainConfig(domain)
+	dc.OwnershipRegistry = &models.OwnershipRegistry{Owner: "team-a"}
+	dc.Records = models.Records{
+		makeTestRecord("www", "A", "1.1.1.9", domain),  // Modify ours.
+		makeTestRecord("mail", "A", "4.4.4.4", domain), // Adopt.
+	}
+
+	cl, _, err := ByRecord(existing, dc, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, c := range cl {
+		switch c.Type {
+		case REPORT:
+			if !strings.Contains(c.MsgsJoined, "1 records being adopted") || !strings.Contains(c.MsgsJoined, "owned by others") || !strings.Contains(c.MsgsJoined, "not owned by \"team-a\"") {
+				t.Errorf("unexpected report: %s", c.MsgsJoined)
+			}
+		case CREATE:
+			got = append(got, "CREATE "+c.New[0].GetLabel()+" "+c.New[0].Type+" "+c.New[0].GetTargetCombined())
+		case CHANGE:
+			got = append(got, "CHANGE "+c.New[0].GetLabel()+" "+c.New[0].Type+" "+c.New[0].GetTargetCombined())
+		case DELETE:
+			got = append(got, "DELETE "+c.Old[0].GetLabel()+" "+c.Old[0].Type)
+		}
+	}
+	slices.Sort(got)
+	want := []string{
+		`CHANGE www A 1.1.1.9`,
+		`CREATE _dnscontrol-a.mail TXT "heritage=dnscontrol,dnscontrol/owner=team-a"`,
+		`DELETE _dnscontrol-a.old TXT`,
+		`DELETE old A`,
+	}
+	if !slices.Equal(got, want) {
+		t.Errorf("ByRecord() =\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+}
+
+func TestOwnershipRegistry_stable(t *testing.T) {
+	const domain = "f.com"
+	dc := models.MustNewDomainConfig(domain)
+	dc.OwnershipRegistry = &models.OwnershipRegistry{Owner: "team-a"}
+	dc.Records = models.Records{
+		makeTestRecord("www", "A", "1.1.1.9", domain),
+		makeTestRecord("mail", "A", "4.4.4.4", domain),
+	}
+
+	// Once the zone is updated, nothing more should change.
+	result, err := ByZone(ownershipZone(), dc, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	again, err := ByZone(result.DesiredPlus, dc, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if again.HasChanges {
+		t.Errorf("second run has changes: %v", again.Msgs)
+	}
+}
+
+func TestOwnershipRegistry_conflict(t *testing.T) {
+	const domain = "f.com"
+	dc := models.MustNewDomainConfig(domain)
+	dc.OwnershipRegistry = &models.OwnershipRegistry{Owner: "team-a"}
+	dc.Records = models.Records{
+		makeTestRecord("api", "A", "9.9.9.9", domain),
+	}
+
+	_, _, err := ByRecord(ownershipZone(), dc, nil)
+	if err == nil || !strings.Contains(err.Error(), `api.f.com A is owned by "team-b"`) {
+		t.Errorf("ByRecord() error = %v, want a conflict", err)
+	}
+}
+
+func TestOwnershipRegistry_apexNS(t *testing.T) {
+	const domain = "f.com"
+	existing := models.Records{
+		makeTestRecord("@", "NS", "ns1.example.net.", domain),
+		makeTestRecord("@", "NS", "ns2.example.net.", domain),
+	}
+	dc := models.MustNewDomainConfig(domain)
+	dc.OwnershipRegistry = &models.OwnershipRegistry{Owner: "team-a"}
+	dc.Records = models.Records{
+		makeTestRecord("@", "NS", "ns1.example.net.", domain),
+	}
+
+	// The apex NS records are managed as usual, without a marker.
+	cl, _, err := ByRecord(existing, dc, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cl) != 1 || cl[0].Type != DELETE || cl[0].Old[0].GetTargetField() != "ns2.example.net." {
+		t.Errorf("ByRecord() = %v, want ns2 deleted", cl)
+	}
+}
@@ -0,0 +1,53 @@
+package diff2
+
+// This file lets a caller capture the instructions that diff2 generated
+// for a zone. Providers call the By*() functions deep inside
+// GetZoneRecordsCorrections(), so the caller (pkg/zonerecs) never sees the
+// ChangeList.  By recording it here, tools like the plan file written by
+// `preview` can report exactly what the provider decided to do.
+
+import (
+	"sync"
+
+	"github.com/DNSControl/dnscontrol/v4/models"
+)
+
+var recorder = struct {
+	sync.Mutex
+	recordings map[*models.DomainConfig]*ChangeList
+}{
+	recordings: map[*models.DomainConfig]*ChangeList{},
+}
+
+// StartRecording asks diff2 to remember the instructions generated for dc.
+// dc must be the same pointer that will be passed to the By*() functions.
+// Call StopRecording to retrieve the instructions and release the memory.
+func StartRecording(dc *models.DomainConfig) {
+	recorder.Lock()
+	defer recorder.Unlock()
+	recorder.recordings[dc] = &ChangeList{}
+}
+
+// StopRecording returns all the instructions generated for dc since
+// StartRecording was called, and stops recording.  If a provider calls the
+// By*() functions more than once per zone, the instructions are concatenated
+// in the order they were generated.
+func StopRecording(dc *models.DomainConfig) ChangeList {
+	recorder.Lock()
+	defer recorder.Unlock()
+	cl, ok := recorder.recordings[dc]
+	if !ok {
+		return nil
+	}
+	delete(recorder.recordings, dc)
+	return *cl
+}
+
+// record appends instructions to dc's recording, if one was started.
+func record(dc *models.DomainConfig, instructions ChangeList) {
+	recorder.Lock()
+	defer recorder.Unlock()
+	if cl, ok := recorder.recordings[dc]; ok {
+		*cl = append(*cl, instructions...)
+	}
+}
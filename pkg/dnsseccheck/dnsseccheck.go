@@ -0,0 +1,247 @@
+// Package dnsseccheck checks the DNSSEC chain of trust between a parent
+// zone and a zone: that the DS records at the parent (which are usually
+// set at the registrar) match a key that signs the zone's DNSKEY records,
+// and that the signatures are valid and not about to expire.
+//
+// A DS record that no longer matches any of the zone's keys makes the zone
+// unresolvable by validating resolvers, which is why these checks matter.
+package dnsseccheck
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	dnsv1 "github.com/miekg/dns"
+)
+
+// Severity is how bad a Finding is.
+type Severity int
+
+// The severities, from least to most severe.
+const (
+	Info    Severity = iota // Not a problem.
+	Warning                 // Works now, but should be fixed.
+	Error                   // Validating resolvers can't resolve the zone (or soon won't).
+)
+
+func (s Severity) String() string {
+	switch s {
+	case Info:
+		return "INFO"
+	case Warning:
+		return "WARNING"
+	default:
+		return "ERROR"
+	}
+}
+
+// Finding is the result of one check.
+type Finding struct {
+	Severity Severity
+	Msg      string
+}
+
+func (f Finding) String() string {
+	return f.Severity.String() + ": " + f.Msg
+}
+
+// Zone is the DNSSEC data of a zone.
+type Zone struct {
+	Name   string          // FQDN, without the trailing dot.
+	DS     []*dnsv1.DS     // At the parent.
+	DNSKEY []*dnsv1.DNSKEY // At the zone's nameserver.
+	SOA    []dnsv1.RR      // At the zone's nameserver, to check the zone-signing keys.
+	RRSIG  []*dnsv1.RRSIG  // Over the DNSKEY and SOA RRsets.
+}
+
+// Algorithms that must not be used for signing (RFC 8624 section 3.1).
+var deprecatedAlgorithms = map[uint8]bool{
+	dnsv1.RSAMD5:           true,
+	dnsv1.DSA:              true,
+	dnsv1.RSASHA1:          true,
+	dnsv1.DSANSEC3SHA1:     true,
+	dnsv1.RSASHA1NSEC3SHA1: true,
+	dnsv1.ECCGOST:          true,
+}
+
+// Algorithms that validating resolvers must support (RFC 8624 section 3.1).
+var supportedAlgorithms = map[uint8]bool{
+	dnsv1.RSASHA256:       true,
+	dnsv1.RSASHA512:       true,
+	dnsv1.ECDSAP256SHA256: true,
+	dnsv1.ECDSAP384SHA384: true,
+	dnsv1.ED25519:         true,
+	dnsv1.ED448:           true,
+}
+
+// Digest types of DS records (RFC 8624 section 3.3).
+var digestTypes = map[uint8]Severity{
+	dnsv1.SHA1:   Warning, // Must not be used.
+	dnsv1.SHA256: Info,
+	dnsv1.GOST94: Warning, // Must not be used.
+	dnsv1.SHA384: Info,
+}
+
+func algorithmName(alg uint8) string {
+	if name, ok := dnsv1.AlgorithmToString[alg]; ok {
+		return name
+	}
+	return fmt.Sprintf("algorithm %d", alg)
+}
+
+func digestName(digest uint8) string {
+	if name, ok := dnsv1.HashToString[digest]; ok {
+		return name
+	}
+	return fmt.Sprintf("digest type %d", digest)
+}
+
+func dsString(ds *dnsv1.DS) string {
+	return fmt.Sprintf("DS %d (%s, %s)", ds.KeyTag, algorithmName(ds.Algorithm), digestName(ds.DigestType))
+}
+
+func keyString(k *dnsv1.DNSKEY) string {
+	kind := "ZSK"
+	if k.Flags&dnsv1.SEP != 0 {
+		kind = "KSK"
+	}
+	return fmt.Sprintf("%s %d (%s)", kind, k.KeyTag(), algorithmName(k.Algorithm))
+}
+
+// Check returns the findings for the zone at time now. Signatures that
+// expire within warnBefore are reported.
+func Check(z *Zone, now time.Time, warnBefore time.Duration) []Finding {
+	var findings []Finding
+	add := func(s Severity, format string, args ...any) {
+		findings = append(findings, Finding{Severity: s, Msg: fmt.Sprintf(format, args...)})
+	}
+
+	switch {
+	case len(z.DS) == 0 && len(z.DNSKEY) == 0:
+		add(Info, "not signed (no DS at the parent, no DNSKEY)")
+		return findings
+	case len(z.DS) == 0:
+		add(Warning, "signed, but the parent has no DS: validating resolvers treat the zone as unsigned")
+	case len(z.DNSKEY) == 0:
+		add(Error, "the parent has %d DS record(s), but the zone has no DNSKEY: validating resolvers can't resolve the zone", len(z.DS))
+		return findings
+	}
+
+	for _, k := range z.DNSKEY {
+		switch {
+		case deprecatedAlgorithms[k.Algorithm]:
+			add(Warning, "%s uses %s, which must not be used for signing (RFC 8624)", keyString(k), algorithmName(k.Algorithm))
+		case !supportedAlgorithms[k.Algorithm]:
+			add(Warning, "%s uses %s, which validating resolvers may not support", keyString(k), algorithmName(k.Algorithm))
+		}
+	}
+
+	// The DS records must each match a key that signs the DNSKEY RRset.
+	dnskeys := make([]dnsv1.RR, len(z.DNSKEY))
+	for i, k := range z.DNSKEY {
+		dnskeys[i] = k
+	}
+	var trusted []*dnsv1.DNSKEY // The keys that a DS matches.
+	var orphans []*dnsv1.DS
+	for _, ds := range z.DS {
+		if s, ok := digestTypes[ds.DigestType]; !ok {
+			add(Warning, "%s uses %s, which validating resolvers may not support", dsString(ds), digestName(ds.DigestType))
+		} else if s != Info {
+			add(s, "%s uses %s, which must not be used (RFC 8624)", dsString(ds), digestName(ds.DigestType))
+		}
+		i := slices.IndexFunc(z.DNSKEY, func(k *dnsv1.DNSKEY) bool {
+			if k.KeyTag() != ds.KeyTag || k.Algorithm != ds.Algorithm {
+				return false
+			}
+			kds := k.ToDS(ds.DigestType)
+			return kds != nil && strings.EqualFold(kds.Digest, ds.Digest)
+		})
+		if i < 0 {
+			orphans = append(orphans, ds)
+			continue
+		}
+		if z.DNSKEY[i].Flags&dnsv1.SEP == 0 {
+			add(Info, "%s matches %s, which does not have the SEP flag", dsString(ds), keyString(z.DNSKEY[i]))
+		}
+		trusted = append(trusted, z.DNSKEY[i])
+	}
+
+	validDNSKEY := false
+	for _, k := range trusted {
+		if signs(z.RRSIG, k, dnsv1.TypeDNSKEY, dnskeys, now) {
+			validDNSKEY = true
+		} else {
+			add(Warning, "%s is in the parent's DS, but does not sign the DNSKEY RRset", keyString(k))
+		}
+	}
+	switch {
+	case len(z.DS) == 0:
+	case len(trusted) == 0:
+		add(Error, "no DS record at the parent matches a DNSKEY: validating resolvers can't resolve the zone")
+	case !validDNSKEY:
+		add(Error, "no key in the parent's DS signs the DNSKEY RRset: validating resolvers can't resolve the zone")
+	default:
+		for _, ds := range orphans {
+			add(Warning, "%s at the parent matches no DNSKEY (orphaned): remove it at the registrar", dsString(ds))
+		}
+	}
+	if len(trusted) == 0 || !validDNSKEY {
+		for _, ds := range orphans {
+			add(Info, "%s at the parent matches no DNSKEY", dsString(ds))
+		}
+	}
+
+	// The zone's data must be signed by one of the keys.
+	if len(z.SOA) != 0 {
+		if !slices.ContainsFunc(z.DNSKEY, func(k *dnsv1.DNSKEY) bool { return signs(z.RRSIG, k, dnsv1.TypeSOA, z.SOA, now) }) {
+			add(Error, "the SOA has no valid signature by a DNSKEY of the zone")
+		}
+	}
+
+	// Signatures that expired or will soon.
+	for _, sig := range z.RRSIG {
+		covered := dnsv1.TypeToString[sig.TypeCovered]
+		expiration := time.Unix(int64(sig.Expiration), 0).UTC()
+		inception := time.Unix(int64(sig.Inception), 0).UTC()
+		switch {
+		case !sig.ValidityPeriod(now) && now.After(expiration):
+			add(Error, "the RRSIG over %s by key %d expired on %s", covered, sig.KeyTag, expiration.Format(time.DateTime))
+		case !sig.ValidityPeriod(now):
+			add(Error, "the RRSIG over %s by key %d is not valid until %s", covered, sig.KeyTag, inception.Format(time.DateTime))
+		case expiration.Sub(now) < warnBefore:
+			add(Warning, "the RRSIG over %s by key %d expires on %s", covered, sig.KeyTag, expiration.Format(time.DateTime))
+		}
+	}
+
+	if !slices.ContainsFunc(findings, func(f Finding) bool { return f.Severity != Info }) {
+		var names []string
+		for _, k := range trusted {
+			names = append(names, keyString(k))
+		}
+		add(Info, "the chain of trust is valid: DS -> %s", strings.Join(names, ", "))
+	}
+	return findings
+}
+
+// signs returns true if one of the signatures over the RRset of type rtype
+// is a valid signature by key at time now.
+func signs(sigs []*dnsv1.RRSIG, key *dnsv1.DNSKEY, rtype uint16, rrset []dnsv1.RR, now time.Time) bool {
+	return slices.ContainsFunc(sigs, func(sig *dnsv1.RRSIG) bool {
+		return sig.TypeCovered == rtype &&
+			sig.KeyTag == key.KeyTag() &&
+			sig.Algorithm == key.Algorithm &&
+			sig.ValidityPeriod(now) &&
+			sig.Verify(key, rrset) == nil
+	})
+}
+
+// Worst returns the most severe of the findings.
+func Worst(findings []Finding) Severity {
+	worst := Info
+	for _, f := range findings {
+		worst = max(worst, f.Severity)
+	}
+	return worst
+}
@@ -0,0 +1,124 @@
+package dnsseccheck
+
+import (
+	"crypto"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	dnsv1 "github.com/miekg/dns"
+)
+
+var testNow = time.Date(2026, 10, 17, 0, 0, 0, 0, time.UTC)
+
+// testKey makes a new key for example.com.
+func testKey(t *testing.T, flags uint16) (*dnsv1.DNSKEY, crypto.Signer) {
+	t.Helper()
+	k := &dnsv1.DNSKEY{
+		Hdr:       dnsv1.RR_Header{Name: "example.com.", Rrtype: dnsv1.TypeDNSKEY, Class: dnsv1.ClassINET, Ttl: 3600},
+		Flags:     flags,
+		Protocol:  3,
+		Algorithm: dnsv1.ECDSAP256SHA256,
+	}
+	priv, err := k.Generate(256)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return k, priv.(crypto.Signer)
+}
+
+// testSign returns the signature of rrset by key, valid from inception to expiration.
+func testSign(t *testing.T, key *dnsv1.DNSKEY, priv crypto.Signer, rrset []dnsv1.RR, inception, expiration time.Time) *dnsv1.RRSIG {
+	t.Helper()
+	sig := &dnsv1.RRSIG{
+		Hdr:        dnsv1.RR_Header{Name: "example.com.", Rrtype: dnsv1.TypeRRSIG, Class: dnsv1.ClassINET, Ttl: 3600},
+		KeyTag:     key.KeyTag(),
+		SignerName: "example.com.",
+		Algorithm:  key.Algorithm,
+		Inception:  uint32(inception.Unix()),
+		Expiration: uint32(expiration.Unix()),
+	}
+	if err := sig.Sign(priv, rrset); err != nil {
+		t.Fatal(err)
+	}
+	return sig
+}
+
+// testZone returns a zone signed with a KSK and a ZSK, with the KSK's DS.
+func testZone(t *testing.T, expiration time.Time) (*Zone, *dnsv1.DNSKEY) {
+	t.Helper()
+	ksk, kskPriv := testKey(t, dnsv1.ZONE|dnsv1.SEP)
+	zsk, zskPriv := testKey(t, dnsv1.ZONE)
+	soa, err := dnsv1.NewRR("example.com. 300 IN SOA ns1.example.com. hostmaster.example.com. 1 3600 600 604800 300")
+	if err != nil {
+		t.Fatal(err)
+	}
+	inception := testNow.Add(-time.Hour)
+	return &Zone{
+		Name:   "example.com",
+		DS:     []*dnsv1.DS{ksk.ToDS(dnsv1.SHA256)},
+		DNSKEY: []*dnsv1.DNSKEY{ksk, zsk},
+		SOA:    []dnsv1.RR{soa},
+		RRSIG: []*dnsv1.RRSIG{
+			testSign(t, ksk, kskPriv, []dnsv1.RR{ksk, zsk}, inception, expiration),
+			testSign(t, zsk, zskPriv, []dnsv1.RR{soa}, inception, expiration),
+		},
+	}, ksk
+}
+
+func findingStrings(findings []Finding) string {
+	var s []string
+	for _, f := range findings {
+		s = append(s, f.String())
+	}
+	return strings.Join(s, "\n")
+}
+
+func TestCheck(t *testing.T) {
+	month := testNow.Add(30 * 24 * time.Hour)
+	otherKSK, _ := testKey(t, dnsv1.ZONE|dnsv1.SEP)
+
+	tests := []struct {
+		name   string
+		modify func(z *Zone)
+		worst  Severity
+		want   string // A substring of one of the findings.
+	}{
+		{"valid", func(z *Zone) {}, Info, "INFO: the chain of trust is valid: DS -> KSK"},
+		{"unsigned", func(z *Zone) { z.DS, z.DNSKEY, z.RRSIG = nil, nil, nil }, Info, "not signed"},
+		{"no DS", func(z *Zone) { z.DS = nil }, Warning, "the parent has no DS"},
+		{"no DNSKEY", func(z *Zone) { z.DNSKEY, z.RRSIG = nil, nil }, Error, "has no DNSKEY"},
+		{"DS of another key", func(z *Zone) { z.DS = []*dnsv1.DS{otherKSK.ToDS(dnsv1.SHA256)} }, Error, "no DS record at the parent matches a DNSKEY"},
+		{"orphaned DS", func(z *Zone) { z.DS = append(z.DS, otherKSK.ToDS(dnsv1.SHA256)) }, Warning, "matches no DNSKEY (orphaned)"},
+		{"SHA-1 digest", func(z *Zone) { z.DS = []*dnsv1.DS{z.DNSKEY[0].ToDS(dnsv1.SHA1)} }, Warning, "uses SHA1, which must not be used"},
+		{"DNSKEY not signed by the KSK", func(z *Zone) { z.RRSIG = z.RRSIG[1:] }, Error, "no key in the parent's DS signs the DNSKEY RRset"},
+		{"SOA not signed", func(z *Zone) { z.RRSIG = z.RRSIG[:1] }, Error, "the SOA has no valid signature"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			z, _ := testZone(t, month)
+			tt.modify(z)
+			findings := Check(z, testNow, 7*24*time.Hour)
+			if got := Worst(findings); got != tt.worst {
+				t.Errorf("Worst() = %s, want %s. Findings:\n%s", got, tt.worst, findingStrings(findings))
+			}
+			if !slices.ContainsFunc(findings, func(f Finding) bool { return strings.Contains(f.String(), tt.want) }) {
+				t.Errorf("no finding contains %q:\n%s", tt.want, findingStrings(findings))
+			}
+		})
+	}
+}
+
+func TestCheck_expiration(t *testing.T) {
+	z, _ := testZone(t, testNow.Add(3*24*time.Hour))
+	findings := Check(z, testNow, 7*24*time.Hour)
+	if Worst(findings) != Warning || !strings.Contains(findingStrings(findings), "expires on 2026-10-20 00:00:00") {
+		t.Errorf("expiring signatures:\n%s", findingStrings(findings))
+	}
+
+	findings = Check(z, testNow.Add(4*24*time.Hour), 7*24*time.Hour)
+	if Worst(findings) != Error || !strings.Contains(findingStrings(findings), "expired on 2026-10-20 00:00:00") {
+		t.Errorf("expired signatures:\n%s", findingStrings(findings))
+	}
+}
@@ -0,0 +1,110 @@
+package dnsseccheck
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"time"
+
+	dnsv1 "github.com/miekg/dns"
+)
+
+// QueryTimeout is how long to wait for a server to answer a query.
+var QueryTimeout = 5 * time.Second
+
+// DefaultResolver returns the first nameserver in /etc/resolv.conf, as
+// host:port.
+func DefaultResolver() (string, error) {
+	conf, err := dnsv1.ClientConfigFromFile("/etc/resolv.conf")
+	if err != nil {
+		return "", fmt.Errorf("no resolver (use --resolver): %w", err)
+	}
+	if len(conf.Servers) == 0 {
+		return "", errors.New("no nameserver in /etc/resolv.conf (use --resolver)")
+	}
+	return net.JoinHostPort(conf.Servers[0], conf.Port), nil
+}
+
+// Fetch returns the DNSSEC data of zone: the DS records from resolver,
+// which is a recursive resolver, and the DNSKEY and SOA records and their
+// signatures from nameserver, which is authoritative for the zone. Both
+// are host:port.
+func Fetch(zone, resolver, nameserver string) (*Zone, error) {
+	z := &Zone{Name: zone}
+	fqdn := dnsv1.Fqdn(zone)
+
+	// Checking is disabled so that the resolver returns the DS records
+	// even if they don't validate.
+	rrs, err := query(resolver, fqdn, dnsv1.TypeDS)
+	if err != nil {
+		return nil, fmt.Errorf("DS query to %s: %w", resolver, err)
+	}
+	for _, rr := range rrs {
+		if ds, ok := rr.(*dnsv1.DS); ok && dnsv1.CanonicalName(ds.Hdr.Name) == dnsv1.CanonicalName(fqdn) {
+			z.DS = append(z.DS, ds)
+		}
+	}
+
+	for _, qtype := range []uint16{dnsv1.TypeDNSKEY, dnsv1.TypeSOA} {
+		rrs, err := query(nameserver, fqdn, qtype)
+		if err != nil {
+			return nil, fmt.Errorf("%s query to %s: %w", dnsv1.TypeToString[qtype], nameserver, err)
+		}
+		for _, rr := range rrs {
+			if dnsv1.CanonicalName(rr.Header().Name) != dnsv1.CanonicalName(fqdn) {
+				continue
+			}
+			switch rr := rr.(type) {
+			case *dnsv1.DNSKEY:
+				z.DNSKEY = append(z.DNSKEY, rr)
+			case *dnsv1.SOA:
+				z.SOA = append(z.SOA, rr)
+			case *dnsv1.RRSIG:
+				if rr.TypeCovered == qtype {
+					z.RRSIG = append(z.RRSIG, rr)
+				}
+			}
+		}
+	}
+	return z, nil
+}
+
+// query returns the answer to the question name/qtype from server, with
+// the DNSSEC records. It retries over TCP if the answer is truncated.
+func query(server, name string, qtype uint16) ([]dnsv1.RR, error) {
+	m := new(dnsv1.Msg)
+	m.SetQuestion(name, qtype)
+	m.SetEdns0(4096, true)
+	m.CheckingDisabled = true
+
+	client := &dnsv1.Client{Timeout: QueryTimeout}
+	r, _, err := client.Exchange(m, server)
+	if err == nil && r.Truncated {
+		client.Net = "tcp"
+		r, _, err = client.Exchange(m, server)
+	}
+	if err != nil {
+		return nil, err
+	}
+	switch r.Rcode {
+	case dnsv1.RcodeSuccess, dnsv1.RcodeNameError:
+		return r.Answer, nil
+	default:
+		return nil, errors.New(dnsv1.RcodeToString[r.Rcode])
+	}
+}
+
+// LookupNS returns the nameservers of zone, as host:port, from resolver.
+func LookupNS(zone, resolver string) ([]string, error) {
+	rrs, err := query(resolver, dnsv1.Fqdn(zone), dnsv1.TypeNS)
+	if err != nil {
+		return nil, fmt.Errorf("NS query to %s: %w", resolver, err)
+	}
+	var nss []string
+	for _, rr := range rrs {
+		if ns, ok := rr.(*dnsv1.NS); ok {
+			nss = append(nss, net.JoinHostPort(ns.Ns, "53"))
+		}
+	}
+	return nss, nil
+}
@@ -0,0 +1,362 @@
+// Package dssync keeps the DS records of a domain at its registrar in sync
+// with the key-signing keys of its DNS providers (AUTODNSSEC_ON).
+//
+// Keys are rolled over with the "double-DS" method (RFC 6781 section
+// 4.1.2): the DS of a new key is added as soon as the DNS provider publishes
+// the key, and the DS of an old key is removed once the key is gone from the
+// zone and the new DS has been at the registry long enough for resolvers to
+// have seen it (the wait, usually the TTL of the DS records at the parent).
+//
+// With AUTODNSSEC_OFF, the DS records are removed first, and the DNS
+// providers keep signing the zone until the wait has passed.
+package dssync
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/DNSControl/dnscontrol/v4/models"
+	"github.com/DNSControl/dnscontrol/v4/pkg/printer"
+	"github.com/DNSControl/dnscontrol/v4/pkg/providers"
+	dnsv1 "github.com/miekg/dns"
+)
+
+// MetaCreated is the RecordConfig metadata that registrars set on the DS
+// records returned by GetRegistrarDS to the time (RFC 3339) the record was
+// added at the registry, if they know it.
+const MetaCreated = "ds_created"
+
+// MetaWait is the domain metadata that sets the wait, in seconds.
+const MetaWait = "ds_rollover_wait"
+
+// DefaultWait is the wait if the domain doesn't set one. It is the TTL of
+// the DS records in most TLDs.
+const DefaultWait = 24 * time.Hour
+
+// Plan is what to change at the registrar.
+type Plan struct {
+	Add       []*models.RecordConfig // The DS records of new keys.
+	Remove    []*models.RecordConfig // The DS records that match no key.
+	Keep      []*models.RecordConfig // The DS records that match no key, but must wait.
+	WaitUntil time.Time              // When Keep can be removed (zero if unknown).
+}
+
+// keyToDNSKEY returns the DNSKEY record of the zone for rc.
+func keyToDNSKEY(zone string, rc *models.RecordConfig) *dnsv1.DNSKEY {
+	return &dnsv1.DNSKEY{
+		Hdr:       dnsv1.RR_Header{Name: dnsv1.Fqdn(zone), Rrtype: dnsv1.TypeDNSKEY, Class: dnsv1.ClassINET},
+		Flags:     rc.DnskeyFlags,
+		Protocol:  rc.DnskeyProtocol,
+		Algorithm: rc.DnskeyAlgorithm,
+		PublicKey: rc.DnskeyPublicKey,
+	}
+}
+
+// matches returns true if ds is the DS record of key, with any digest type.
+func matches(ds *models.RecordConfig, key *dnsv1.DNSKEY) bool {
+	if ds.DsKeyTag != key.KeyTag() || ds.DsAlgorithm != key.Algorithm {
+		return false
+	}
+	kds := key.ToDS(ds.DsDigestType)
+	return kds != nil && strings.EqualFold(kds.Digest, ds.DsDigest)
+}
+
+// created returns the time ds was added at the registry, or zero if unknown.
+func created(ds *models.RecordConfig) time.Time {
+	t, err := time.Parse(time.RFC3339, ds.Metadata[MetaCreated])
+	if err != nil {
+		return time.Time{}
+	}
+	return t
+}
+
+// MakePlan returns the changes that make the DS records current of zone
+// match keys, which are the DNSKEY records of the zone. Only keys with the
+// SEP flag (key-signing keys) get a DS record. The DS records that match no
+// key are removed once the DS records of the keys were added at least wait
+// before now.
+func MakePlan(zone string, keys, current []*models.RecordConfig, now time.Time, wait time.Duration) (*Plan, error) {
+	plan := &Plan{}
+	var ksks []*dnsv1.DNSKEY
+	for _, rc := range keys {
+		if rc.DnskeyFlags&dnsv1.SEP == 0 {
+			continue
+		}
+		k := keyToDNSKEY(zone, rc)
+		if !slices.ContainsFunc(ksks, func(o *dnsv1.DNSKEY) bool { return o.KeyTag() == k.KeyTag() && o.PublicKey == k.PublicKey }) {
+			ksks = append(ksks, k)
+		}
+	}
+
+	// The keys that have no DS yet.
+	var newest time.Time // When the last DS of a key was added.
+	trusted := false     // A DS matches a key.
+	for _, k := range ksks {
+		i := slices.IndexFunc(current, func(ds *models.RecordConfig) bool { return matches(ds, k) })
+		if i >= 0 {
+			trusted = true
+			if t := created(current[i]); t.After(newest) {
+				newest = t
+			}
+			continue
+		}
+		kds := k.ToDS(dnsv1.SHA256)
+		if kds == nil {
+			return nil, fmt.Errorf("can't compute the DS of key %d (algorithm %d)", k.KeyTag(), k.Algorithm)
+		}
+		ds := &models.RecordConfig{Type: "DS", Metadata: map[string]string{}}
+		ds.SetLabel("@", zone)
+		if err := ds.SetTargetDS(kds.KeyTag, kds.Algorithm, kds.DigestType, strings.ToUpper(kds.Digest)); err != nil {
+			return nil, err
+		}
+		plan.Add = append(plan.Add, ds)
+	}
+
+	// The DS records that match no key.
+	var stale []*models.RecordConfig
+	for _, ds := range current {
+		if !slices.ContainsFunc(ksks, func(k *dnsv1.DNSKEY) bool { return matches(ds, k) }) {
+			stale = append(stale, ds)
+		}
+	}
+	switch {
+	case len(stale) == 0:
+	case !trusted:
+		// No DS matches a key: validating resolvers already can't resolve
+		// the zone, so keeping the old DS records doesn't help.
+		plan.Remove = stale
+	case len(plan.Add) != 0:
+		// Wait for the new DS records to be added first.
+		plan.Keep = stale
+	case !newest.IsZero() && now.Before(newest.Add(wait)):
+		plan.Keep = stale
+		plan.WaitUntil = newest.Add(wait)
+	default:
+		plan.Remove = stale
+	}
+	return plan, nil
+}
+
+// Wait returns the wait of the domain (see MetaWait).
+func Wait(dc *models.DomainConfig) (time.Duration, error) {
+	s, ok := dc.Metadata[MetaWait]
+	if !ok {
+		return DefaultWait, nil
+	}
+	secs, err := strconv.ParseUint(s, 10, 32)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %q is not a number of seconds", MetaWait, s)
+	}
+	return time.Duration(secs) * time.Second, nil
+}
+
+func dsString(ds *models.RecordConfig) string {
+	return fmt.Sprintf("DS %d %d %d %s", ds.DsKeyTag, ds.DsAlgorithm, ds.DsDigestType, ds.DsDigest)
+}
+
+// listKeys returns the key-signing keys of dc at dnsProviders. If a provider
+// has no key yet, its name is returned instead. ok is false if a provider
+// can't list its keys.
+func listKeys(dc *models.DomainConfig, dnsProviders []*models.DNSProviderInstance) (keys []*models.RecordConfig, keyless string, ok bool, err error) {
+	for _, p := range dnsProviders {
+		lister, ok := p.Driver.(providers.KeySigningKeysLister)
+		if !ok {
+			printer.Debugf("dssync: %s: provider %s can't list its keys, the DS records at the registrar are not updated\n", dc.Name, p.Name)
+			return nil, "", false, nil
+		}
+		pkeys, err := lister.ListKeySigningKeys(dc)
+		if err != nil {
+			return nil, "", true, fmt.Errorf("listing the keys of %s at %s: %w", dc.Name, p.Name, err)
+		}
+		if len(pkeys) == 0 {
+			return nil, p.Name, true, nil
+		}
+		keys = append(keys, pkeys...)
+	}
+	return keys, "", true, nil
+}
+
+// Corrections returns the corrections that update the DS records of dc at
+// registrar to match the key-signing keys of dnsProviders. It returns no
+// corrections if the registrar can't manage DS records, if AUTODNSSEC is not
+// set, or if one of the DNS providers can't list its keys. The count is the
+// number of corrections that change something.
+//
+// The registrar's corrections run after the DNS providers' corrections, which
+// may publish new keys (or sign the zone for the first time). The DS records
+// to add are therefore determined again when the correction runs.
+func Corrections(dc *models.DomainConfig, dnsProviders []*models.DNSProviderInstance, registrar models.Registrar) ([]*models.Correction, int, error) {
+	reg, ok := registrar.(providers.DSRegistrar)
+	if !ok || dc.AutoDNSSEC == "" || len(dnsProviders) == 0 {
+		return nil, 0, nil
+	}
+
+	var keys []*models.RecordConfig
+	var keyless string
+	if dc.AutoDNSSEC == "on" {
+		var ok bool
+		var err error
+		keys, keyless, ok, err = listKeys(dc, dnsProviders)
+		if err != nil || !ok {
+			return nil, 0, err
+		}
+	}
+
+	current, err := reg.GetRegistrarDS(dc)
+	if err != nil {
+		return nil, 0, err
+	}
+	wait, err := Wait(dc)
+	if err != nil {
+		return nil, 0, err
+	}
+	plan, err := MakePlan(dc.Name, keys, current, time.Now(), wait)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	var corrections []*models.Correction
+	count := 0
+	switch {
+	case keyless != "":
+		// DNSSEC is not enabled at the provider yet (or AUTODNSSEC_ON was
+		// just added and the zone is signed by this push). The DS records
+		// that match no key stay until the keys are known.
+		corrections = append(corrections, addCorrection(dc, dnsProviders, reg,
+			fmt.Sprintf("DS: add the DS records of the keys that %s publishes", keyless)))
+		return corrections, 1, nil
+	case len(plan.Add) != 0:
+		var msgs []string
+		for _, ds := range plan.Add {
+			msgs = append(msgs, fmt.Sprintf("DS: add %s", dsString(ds)))
+		}
+		corrections = append(corrections, addCorrection(dc, dnsProviders, reg, strings.Join(msgs, "\n")))
+		count += len(plan.Add)
+	}
+	for _, ds := range plan.Remove {
+		corrections = append(corrections, &models.Correction{
+			Msg: fmt.Sprintf("DS: remove %s (matches no key of the DNS providers)", dsString(ds)),
+			F: func() error {
+				if err := reg.RemoveRegistrarDS(dc, ds); err != nil {
+					return err
+				}
+				if dc.AutoDNSSEC == "off" {
+					return recordRemoval(dc.Name, time.Now())
+				}
+				return nil
+			},
+		})
+		count++
+	}
+	for _, ds := range plan.Keep {
+		until := "the new DS records are added"
+		if !plan.WaitUntil.IsZero() {
+			until = plan.WaitUntil.Local().Format(time.DateTime)
+		}
+		corrections = append(corrections, &models.Correction{
+			Msg: fmt.Sprintf("DS: %s matches no key of the DNS providers, it will be removed by a push after %s", dsString(ds), until),
+		})
+	}
+	return corrections, count, nil
+}
+
+// addCorrection returns the correction that adds the DS records of the keys
+// that dnsProviders publish when it runs.
+func addCorrection(dc *models.DomainConfig, dnsProviders []*models.DNSProviderInstance, reg providers.DSRegistrar, msg string) *models.Correction {
+	return &models.Correction{
+		Msg: msg,
+		F: func() error {
+			keys, keyless, _, err := listKeys(dc, dnsProviders)
+			if err != nil {
+				return err
+			}
+			if keyless != "" {
+				printer.Warnf("DS: %s has no key yet, run push again to add the DS records at the registrar\n", keyless)
+				return nil
+			}
+			current, err := reg.GetRegistrarDS(dc)
+			if err != nil {
+				return err
+			}
+			plan, err := MakePlan(dc.Name, keys, current, time.Now(), 0)
+			if err != nil {
+				return err
+			}
+			for _, ds := range plan.Add {
+				if err := reg.AddRegistrarDS(dc, ds); err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+	}
+}
+
+// HoldUnsigning returns the time until which the DNS providers of dc must
+// keep signing the zone (AUTODNSSEC_OFF), or zero if they may stop now. The
+// DS records at the registrar are removed first, and validating resolvers
+// that cached them can't resolve the zone once it is unsigned until the wait
+// has passed.
+func HoldUnsigning(dc *models.DomainConfig, registrar models.Registrar, now time.Time) (time.Time, error) {
+	reg, ok := registrar.(providers.DSRegistrar)
+	if !ok || dc.AutoDNSSEC != "off" {
+		return time.Time{}, nil
+	}
+	wait, err := Wait(dc)
+	if err != nil {
+		return time.Time{}, err
+	}
+	current, err := reg.GetRegistrarDS(dc)
+	if err != nil {
+		return time.Time{}, err
+	}
+	if len(current) != 0 {
+		return now.Add(wait), nil // Removed by this push.
+	}
+	if t := removedAt(dc.Name); !t.IsZero() && now.Before(t.Add(wait)) {
+		return t.Add(wait), nil
+	}
+	return time.Time{}, nil
+}
+
+// StateDir returns the directory that keeps when the DS records of each zone
+// were removed (AUTODNSSEC_OFF). Registrars don't report it.
+var StateDir = func() (string, error) {
+	dir, err := os.UserCacheDir()
+	return filepath.Join(dir, "dnscontrol", "dssync"), err
+}
+
+// recordRemoval records that the DS records of zone were removed at t.
+func recordRemoval(zone string, t time.Time) error {
+	dir, err := StateDir()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(dir, zone+".removed"), []byte(t.UTC().Format(time.RFC3339)+"\n"), 0o644)
+}
+
+// removedAt returns when the DS records of zone were removed, or zero if
+// unknown.
+func removedAt(zone string) time.Time {
+	dir, err := StateDir()
+	if err != nil {
+		return time.Time{}
+	}
+	b, err := os.ReadFile(filepath.Join(dir, zone+".removed"))
+	if err != nil {
+		return time.Time{}
+	}
+	t, err := time.Parse(time.RFC3339, strings.TrimSpace(string(b)))
+	if err != nil {
+		return time.Time{}
+	}
+	return t
+}
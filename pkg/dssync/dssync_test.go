@@ -0,0 +1,299 @@
+package dssync
+
+import (
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DNSControl/dnscontrol/v4/models"
+	dnsv1 "github.com/miekg/dns"
+)
+
+var testNow = time.Date(2026, 10, 17, 12, 0, 0, 0, time.UTC)
+
+// testKey returns a new DNSKEY record of example.com.
+func testKey(t *testing.T, flags uint16) *models.RecordConfig {
+	t.Helper()
+	k := &dnsv1.DNSKEY{
+		Hdr:       dnsv1.RR_Header{Name: "example.com.", Rrtype: dnsv1.TypeDNSKEY, Class: dnsv1.ClassINET},
+		Flags:     flags,
+		Protocol:  3,
+		Algorithm: dnsv1.ECDSAP256SHA256,
+	}
+	if _, err := k.Generate(256); err != nil {
+		t.Fatal(err)
+	}
+	rc := &models.RecordConfig{Type: "DNSKEY", Metadata: map[string]string{}}
+	rc.SetLabel("@", "example.com")
+	if err := rc.SetTargetDNSKEY(k.Flags, k.Protocol, k.Algorithm, k.PublicKey); err != nil {
+		t.Fatal(err)
+	}
+	return rc
+}
+
+// testDS returns the DS record of key, added at the registry at created.
+func testDS(key *models.RecordConfig, digestType uint8, created time.Time) *models.RecordConfig {
+	kds := keyToDNSKEY("example.com", key).ToDS(digestType)
+	ds := &models.RecordConfig{Type: "DS", Metadata: map[string]string{}}
+	ds.SetLabel("@", "example.com")
+	_ = ds.SetTargetDS(kds.KeyTag, kds.Algorithm, kds.DigestType, strings.ToLower(kds.Digest))
+	if !created.IsZero() {
+		ds.Metadata[MetaCreated] = created.Format(time.RFC3339)
+	}
+	return ds
+}
+
+func dsStrings(records []*models.RecordConfig) []string {
+	var s []string
+	for _, ds := range records {
+		s = append(s, dsString(ds))
+	}
+	return s
+}
+
+func TestMakePlan(t *testing.T) {
+	oldKSK := testKey(t, dnsv1.ZONE|dnsv1.SEP)
+	newKSK := testKey(t, dnsv1.ZONE|dnsv1.SEP)
+	thirdKSK := testKey(t, dnsv1.ZONE|dnsv1.SEP)
+	zsk := testKey(t, dnsv1.ZONE)
+	oldDS := testDS(oldKSK, dnsv1.SHA256, testNow.Add(-30*24*time.Hour))
+	newDS := testDS(newKSK, dnsv1.SHA256, time.Time{})
+	hour := testNow.Add(-time.Hour)
+	twoDays := testNow.Add(-48 * time.Hour)
+
+	tests := []struct {
+		name              string
+		keys, current     []*models.RecordConfig
+		add, remove, keep []*models.RecordConfig
+		waitUntil         time.Time
+	}{
+		{
+			name:    "in sync",
+			keys:    []*models.RecordConfig{oldKSK, zsk},
+			current: []*models.RecordConfig{oldDS},
+		},
+		{
+			name:    "another digest type is enough",
+			keys:    []*models.RecordConfig{oldKSK},
+			current: []*models.RecordConfig{testDS(oldKSK, dnsv1.SHA384, time.Time{})},
+		},
+		{
+			name: "first DS",
+			keys: []*models.RecordConfig{oldKSK, zsk},
+			add:  []*models.RecordConfig{testDS(oldKSK, dnsv1.SHA256, time.Time{})},
+		},
+		{
+			name:    "the new key is published: add its DS",
+			keys:    []*models.RecordConfig{oldKSK, newKSK},
+			current: []*models.RecordConfig{oldDS},
+			add:     []*models.RecordConfig{newDS},
+		},
+		{
+			name:    "a DS is missing: add it before removing the old DS",
+			keys:    []*models.RecordConfig{newKSK, thirdKSK},
+			current: []*models.RecordConfig{oldDS, testDS(thirdKSK, dnsv1.SHA256, time.Time{})},
+			add:     []*models.RecordConfig{newDS},
+			keep:    []*models.RecordConfig{oldDS},
+		},
+		{
+			name:    "the keys changed at once: replace the DS records",
+			keys:    []*models.RecordConfig{newKSK},
+			current: []*models.RecordConfig{oldDS, testDS(zsk, dnsv1.SHA256, time.Time{})},
+			add:     []*models.RecordConfig{newDS},
+			remove:  []*models.RecordConfig{oldDS, testDS(zsk, dnsv1.SHA256, time.Time{})},
+		},
+		{
+			name:      "the old key is gone, the new DS was just added: wait",
+			keys:      []*models.RecordConfig{newKSK},
+			current:   []*models.RecordConfig{oldDS, testDS(newKSK, dnsv1.SHA256, hour)},
+			keep:      []*models.RecordConfig{oldDS},
+			waitUntil: hour.Add(DefaultWait),
+		},
+		{
+			name:    "the old key is gone, the new DS was added long ago: remove the old DS",
+			keys:    []*models.RecordConfig{newKSK},
+			current: []*models.RecordConfig{oldDS, testDS(newKSK, dnsv1.SHA256, twoDays)},
+			remove:  []*models.RecordConfig{oldDS},
+		},
+		{
+			name:    "the registrar doesn't know when the new DS was added",
+			keys:    []*models.RecordConfig{newKSK},
+			current: []*models.RecordConfig{oldDS, newDS},
+			remove:  []*models.RecordConfig{oldDS},
+		},
+		{
+			name:    "AUTODNSSEC_OFF",
+			current: []*models.RecordConfig{oldDS},
+			remove:  []*models.RecordConfig{oldDS},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			plan, err := MakePlan("example.com", tt.keys, tt.current, testNow, DefaultWait)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, c := range []struct {
+				what      string
+				got, want []*models.RecordConfig
+			}{{"Add", plan.Add, tt.add}, {"Remove", plan.Remove, tt.remove}, {"Keep", plan.Keep, tt.keep}} {
+				got, want := dsStrings(c.got), dsStrings(c.want)
+				if !slices.EqualFunc(got, want, strings.EqualFold) {
+					t.Errorf("%s = %v, want %v", c.what, got, want)
+				}
+			}
+			if !plan.WaitUntil.Equal(tt.waitUntil) {
+				t.Errorf("WaitUntil = %s, want %s", plan.WaitUntil, tt.waitUntil)
+			}
+		})
+	}
+}
+
+type fakeProvider struct {
+	models.DNSProvider
+	keys []*models.RecordConfig
+}
+
+func (p *fakeProvider) ListKeySigningKeys(dc *models.DomainConfig) ([]*models.RecordConfig, error) {
+	return p.keys, nil
+}
+
+type fakeRegistrar struct {
+	models.Registrar
+	ds []*models.RecordConfig
+}
+
+func (r *fakeRegistrar) GetRegistrarDS(dc *models.DomainConfig) ([]*models.RecordConfig, error) {
+	return r.ds, nil
+}
+
+func (r *fakeRegistrar) AddRegistrarDS(dc *models.DomainConfig, ds *models.RecordConfig) error {
+	r.ds = append(r.ds, ds)
+	return nil
+}
+
+func (r *fakeRegistrar) RemoveRegistrarDS(dc *models.DomainConfig, ds *models.RecordConfig) error {
+	r.ds = slices.DeleteFunc(r.ds, func(o *models.RecordConfig) bool { return o == ds })
+	return nil
+}
+
+func TestCorrections(t *testing.T) {
+	ksk := testKey(t, dnsv1.ZONE|dnsv1.SEP)
+	other := testDS(testKey(t, dnsv1.ZONE|dnsv1.SEP), dnsv1.SHA256, time.Time{})
+	reg := &fakeRegistrar{ds: []*models.RecordConfig{other}}
+	dc := models.MustNewDomainConfig("example.com")
+	dc.AutoDNSSEC = "on"
+	dsps := []*models.DNSProviderInstance{{Driver: &fakeProvider{keys: []*models.RecordConfig{ksk}}}}
+	dsps[0].Name = "dsp"
+
+	// The DS of the key is added; the other DS matches no key and no DS
+	// matches a key yet, so it is removed too.
+	corrections, count, err := Corrections(dc, dsps, reg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if count != 2 || len(corrections) != 2 {
+		t.Fatalf("got %d corrections (count %d), want 2", len(corrections), count)
+	}
+	for _, c := range corrections {
+		if err := c.F(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if want := dsString(testDS(ksk, dnsv1.SHA256, time.Time{})); len(reg.ds) != 1 || !strings.EqualFold(dsString(reg.ds[0]), want) {
+		t.Errorf("DS at the registrar = %v, want [%s]", dsStrings(reg.ds), want)
+	}
+
+	// Nothing left to do.
+	if corrections, count, _ = Corrections(dc, dsps, reg); count != 0 || len(corrections) != 0 {
+		t.Errorf("got %d corrections (count %d) after the push, want none", len(corrections), count)
+	}
+
+	// A provider without a key yet, which it publishes when its corrections
+	// run, before the registrar's: the DS of the key is added in the same
+	// push.
+	provider := &fakeProvider{}
+	dsps[0].Driver = provider
+	corrections, count, err = Corrections(dc, dsps, reg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if count != 1 || len(corrections) != 1 || corrections[0].F == nil {
+		t.Fatalf("provider without keys: got %d corrections (count %d), want 1", len(corrections), count)
+	}
+	newKSK := testKey(t, dnsv1.ZONE|dnsv1.SEP)
+	provider.keys = []*models.RecordConfig{newKSK}
+	if err := corrections[0].F(); err != nil {
+		t.Fatal(err)
+	}
+	if len(reg.ds) != 2 || !strings.EqualFold(dsString(reg.ds[1]), dsString(testDS(newKSK, dnsv1.SHA256, time.Time{}))) {
+		t.Errorf("DS at the registrar = %v, want the DS of the new key added", dsStrings(reg.ds))
+	}
+
+	// Without AUTODNSSEC, nothing is managed.
+	dc.AutoDNSSEC = ""
+	if corrections, _, _ = Corrections(dc, dsps, reg); len(corrections) != 0 {
+		t.Errorf("without AUTODNSSEC: got %d corrections, want none", len(corrections))
+	}
+}
+
+func TestCorrections_off(t *testing.T) {
+	dir, saved := t.TempDir(), StateDir
+	StateDir = func() (string, error) { return dir, nil }
+	t.Cleanup(func() { StateDir = saved })
+	ksk := testKey(t, dnsv1.ZONE|dnsv1.SEP)
+	reg := &fakeRegistrar{ds: []*models.RecordConfig{testDS(ksk, dnsv1.SHA256, time.Time{})}}
+	dc := models.MustNewDomainConfig("example.com")
+	dc.AutoDNSSEC = "off"
+	dc.Metadata[MetaWait] = "3600"
+	dsps := []*models.DNSProviderInstance{{Driver: &fakeProvider{keys: []*models.RecordConfig{ksk}}}}
+
+	// The DS is removed first; the provider keeps signing the zone.
+	now := time.Now()
+	if until, err := HoldUnsigning(dc, reg, now); err != nil || !until.Equal(now.Add(time.Hour)) {
+		t.Errorf("HoldUnsigning() = %s, %v, want an hour from now", until, err)
+	}
+	corrections, count, err := Corrections(dc, dsps, reg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if count != 1 || len(corrections) != 1 {
+		t.Fatalf("got %d corrections (count %d), want 1", len(corrections), count)
+	}
+	if err := corrections[0].F(); err != nil {
+		t.Fatal(err)
+	}
+	if len(reg.ds) != 0 {
+		t.Errorf("DS at the registrar = %v, want none", dsStrings(reg.ds))
+	}
+
+	// The DS is gone, but maybe not from the resolvers' caches yet.
+	until, err := HoldUnsigning(dc, reg, time.Now())
+	if err != nil || until.IsZero() {
+		t.Errorf("HoldUnsigning() = %s, %v, want to wait after the DS was removed", until, err)
+	}
+	if until, err = HoldUnsigning(dc, reg, until.Add(time.Second)); err != nil || !until.IsZero() {
+		t.Errorf("HoldUnsigning() = %s, %v after the wait, want zero", until, err)
+	}
+
+	// Without a DS registrar, the provider turns DNSSEC off right away.
+	if until, _ := HoldUnsigning(dc, nil, time.Now()); !until.IsZero() {
+		t.Errorf("HoldUnsigning() without a DS registrar = %s, want zero", until)
+	}
+}
+
+func TestWait(t *testing.T) {
+	dc := models.MustNewDomainConfig("example.com")
+	if w, err := Wait(dc); err != nil || w != DefaultWait {
+		t.Errorf("Wait() = %s, %v, want the default", w, err)
+	}
+	dc.Metadata[MetaWait] = "3600"
+	if w, err := Wait(dc); err != nil || w != time.Hour {
+		t.Errorf("Wait() = %s, %v, want 1h", w, err)
+	}
+	dc.Metadata[MetaWait] = "1d"
+	if _, err := Wait(dc); err == nil {
+		t.Errorf("Wait() accepted %q", dc.Metadata[MetaWait])
+	}
+}
@@ -0,0 +1,79 @@
+package emailcheck
+
+import (
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"strings"
+)
+
+// minRSABits is the size under which receivers ignore the signatures of an
+// RSA key (RFC 8301, section 3.2).
+const minRSABits = 1024
+
+// checkDKIM checks the DKIM keys of name, if it is a selector
+// (<selector>._domainkey.<domain>).
+func (z *zone) checkDKIM(name string) {
+	if strings.Index(name, "._domainkey.") <= 0 {
+		return
+	}
+	for _, txt := range z.txt[name] {
+		tags, _, err := parseTags(txt)
+		if err != nil {
+			z.add(Error, name, "the DKIM record is malformed: %v", err)
+			continue
+		}
+		if v, ok := tags["v"]; ok && v != "DKIM1" {
+			z.add(Error, name, "the DKIM record has the version v=%s, not DKIM1", v)
+			continue
+		}
+		p, ok := tags["p"]
+		if !ok {
+			z.add(Error, name, "the DKIM record has no key (p=)")
+			continue
+		}
+		p = strings.Join(strings.Fields(p), "")
+		if p == "" {
+			continue // A revoked key.
+		}
+		key, err := base64.StdEncoding.DecodeString(p)
+		if err != nil {
+			z.add(Error, name, "the DKIM key is not valid base64: %v", err)
+			continue
+		}
+
+		switch k := strings.ToLower(tags["k"]); k {
+		case "", "rsa":
+			bits := rsaBits(key)
+			switch {
+			case bits == 0:
+				z.add(Error, name, "the DKIM key is not an RSA public key")
+			case bits < minRSABits:
+				z.add(Error, name, "the DKIM key has %d bits: receivers ignore the signatures of RSA keys under %d bits (RFC 8301)", bits, minRSABits)
+			}
+		case "ed25519":
+			if len(key) != ed25519.PublicKeySize {
+				z.add(Error, name, "the DKIM key is not an Ed25519 public key")
+			}
+		default:
+			z.add(Warning, name, "unknown DKIM key type k=%s", k)
+		}
+	}
+}
+
+// rsaBits returns the size of the RSA public key der, in the
+// SubjectPublicKeyInfo format (RFC 6376, section 3.6.1) or, as some signers
+// publish it, as an RSAPublicKey. It returns 0 if der isn't an RSA key.
+func rsaBits(der []byte) int {
+	if pub, err := x509.ParsePKIXPublicKey(der); err == nil {
+		if rsaPub, ok := pub.(*rsa.PublicKey); ok {
+			return rsaPub.N.BitLen()
+		}
+		return 0
+	}
+	if pub, err := x509.ParsePKCS1PublicKey(der); err == nil {
+		return pub.N.BitLen()
+	}
+	return 0
+}
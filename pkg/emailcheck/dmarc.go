@@ -0,0 +1,201 @@
+package emailcheck
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// parseTags parses a tag list (RFC 6376, section 3.2), as used by DKIM and
+// DMARC records. The names of the tags are returned in lower case.
+func parseTags(txt string) (tags map[string]string, first string, err error) {
+	tags = map[string]string{}
+	for part := range strings.SplitSeq(txt, ";") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue // A trailing semicolon.
+		}
+		tag, value, ok := strings.Cut(part, "=")
+		tag, value = strings.ToLower(strings.TrimSpace(tag)), strings.TrimSpace(value)
+		if !ok || tag == "" {
+			return nil, "", fmt.Errorf("%q is not a tag=value pair", part)
+		}
+		if _, dup := tags[tag]; dup {
+			return nil, "", fmt.Errorf("the tag %s is repeated", tag)
+		}
+		if first == "" {
+			first = tag
+		}
+		tags[tag] = value
+	}
+	return tags, first, nil
+}
+
+var (
+	dmarcPolicies   = []string{"none", "quarantine", "reject"}
+	dmarcAlignments = []string{"r", "s"}
+	dmarcFailures   = []string{"0", "1", "d", "s"}
+	uriScheme       = regexp.MustCompile(`^[a-zA-Z][a-zA-Z0-9+.-]*:`)
+)
+
+// dmarcRecords returns the TXT records among txts that look like DMARC
+// records. Receivers ignore those that don't start with "v=DMARC1".
+func dmarcRecords(txts []string) []string {
+	var dmarcs []string
+	for _, txt := range txts {
+		if strings.HasPrefix(strings.ToLower(strings.TrimSpace(txt)), "v=dmarc") {
+			dmarcs = append(dmarcs, txt)
+		}
+	}
+	return dmarcs
+}
+
+// parseDMARC parses and validates a DMARC record (RFC 7489, section 6.3).
+func parseDMARC(txt string) (map[string]string, error) {
+	if !strings.HasPrefix(txt, "v=DMARC1") {
+		return nil, errors.New(`it must start with "v=DMARC1"`)
+	}
+	tags, first, err := parseTags(txt)
+	if err != nil {
+		return nil, err
+	}
+	if first != "v" || tags["v"] != "DMARC1" {
+		return nil, errors.New(`it must start with "v=DMARC1"`)
+	}
+
+	oneOf := func(tag string, values []string) error {
+		if v, ok := tags[tag]; ok && !slices.Contains(values, strings.ToLower(v)) {
+			return fmt.Errorf("%s=%s: must be one of %s", tag, v, strings.Join(values, ", "))
+		}
+		return nil
+	}
+	if _, ok := tags["p"]; !ok {
+		return nil, errors.New("the policy (p=) is missing")
+	}
+	for _, err := range []error{
+		oneOf("p", dmarcPolicies),
+		oneOf("sp", dmarcPolicies),
+		oneOf("adkim", dmarcAlignments),
+		oneOf("aspf", dmarcAlignments),
+	} {
+		if err != nil {
+			return nil, err
+		}
+	}
+	if v, ok := tags["pct"]; ok {
+		if n, err := strconv.Atoi(v); err != nil || n < 0 || n > 100 {
+			return nil, fmt.Errorf("pct=%s: must be a number between 0 and 100", v)
+		}
+	}
+	if v, ok := tags["ri"]; ok {
+		if _, err := strconv.ParseUint(v, 10, 32); err != nil {
+			return nil, fmt.Errorf("ri=%s: must be a number of seconds", v)
+		}
+	}
+	if v, ok := tags["fo"]; ok {
+		for o := range strings.SplitSeq(v, ":") {
+			if !slices.Contains(dmarcFailures, strings.ToLower(strings.TrimSpace(o))) {
+				return nil, fmt.Errorf("fo=%s: the options must be one of %s", v, strings.Join(dmarcFailures, ", "))
+			}
+		}
+	}
+	for _, tag := range []string{"rua", "ruf"} {
+		for _, uri := range reportURIs(tags[tag]) {
+			if !uriScheme.MatchString(uri) {
+				return nil, fmt.Errorf("%s: %q is not a URI (such as mailto:reports@example.com)", tag, uri)
+			}
+			if _, ok := reportDomain(uri); strings.HasPrefix(strings.ToLower(uri), "mailto:") && !ok {
+				return nil, fmt.Errorf("%s: %q is not an email address", tag, uri)
+			}
+		}
+	}
+	return tags, nil
+}
+
+// reportURIs splits the value of rua= or ruf=.
+func reportURIs(value string) []string {
+	var uris []string
+	for uri := range strings.SplitSeq(value, ",") {
+		if uri = strings.TrimSpace(uri); uri != "" {
+			uris = append(uris, uri)
+		}
+	}
+	return uris
+}
+
+// reportDomain returns the domain of the address of a mailto: report URI.
+func reportDomain(uri string) (string, bool) {
+	if !strings.HasPrefix(strings.ToLower(uri), "mailto:") {
+		return "", false
+	}
+	addr, _, _ := strings.Cut(uri[len("mailto:"):], "!") // The size limit.
+	at := strings.LastIndex(addr, "@")
+	if at <= 0 || at == len(addr)-1 {
+		return "", false
+	}
+	return strings.ToLower(strings.TrimSuffix(addr[at+1:], ".")), true
+}
+
+// checkDMARC checks the DMARC records of name, if it is a _dmarc name, and
+// that the external report addresses accept the reports.
+func (z *zone) checkDMARC(name string) {
+	domain, ok := strings.CutPrefix(name, "_dmarc.")
+	if !ok {
+		return
+	}
+	dmarcs := dmarcRecords(z.txt[name])
+	if len(dmarcs) > 1 {
+		z.add(Error, name, "%d DMARC records: receivers ignore them all", len(dmarcs))
+		return
+	}
+	if len(dmarcs) == 0 {
+		return
+	}
+	tags, err := parseDMARC(dmarcs[0])
+	if err != nil {
+		z.add(Error, name, "the DMARC record is malformed: %v", err)
+		return
+	}
+
+	// External destinations must authorize the reports (RFC 7489, section
+	// 7.1).
+	var checked []string
+	for _, uri := range append(reportURIs(tags["rua"]), reportURIs(tags["ruf"])...) {
+		dest, ok := reportDomain(uri)
+		if !ok || orgDomain(dest) == orgDomain(domain) || slices.Contains(checked, dest) {
+			continue
+		}
+		checked = append(checked, dest)
+		auth := domain + "._report._dmarc." + dest
+		txts, err := z.lookupTXT(auth)
+		switch {
+		case err != nil:
+			z.add(Warning, name, "could not check that %s accepts the DMARC reports: %v", dest, err)
+		case !slices.ContainsFunc(txts, func(txt string) bool { return strings.HasPrefix(txt, "v=DMARC1") }):
+			z.add(Warning, name, "%s doesn't accept the DMARC reports: %s has no \"v=DMARC1\" TXT record (RFC 7489, section 7.1)", dest, auth)
+		}
+	}
+}
+
+// checkDMARCPolicy checks that name has a DMARC policy: its own or that of
+// its organizational domain (RFC 7489, section 6.6.3).
+func (z *zone) checkDMARCPolicy(name string) {
+	candidates := []string{"_dmarc." + name}
+	if org := orgDomain(name); org != name {
+		candidates = append(candidates, "_dmarc."+org)
+	}
+	for _, c := range candidates {
+		txts, err := z.lookupTXT(c)
+		if err != nil {
+			z.add(Warning, name, "could not look up the DMARC record %s: %v", c, err)
+			return
+		}
+		if len(dmarcRecords(txts)) > 0 {
+			return // Reported by checkDMARC if it is malformed.
+		}
+	}
+	z.add(Warning, name, "no DMARC record (%s): receivers apply no policy to the mail that fails SPF and DKIM (see DMARC_BUILDER)", strings.Join(candidates, " or "))
+}
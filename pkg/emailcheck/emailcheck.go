@@ -0,0 +1,211 @@
+// Package emailcheck audits the email-authentication records of a zone:
+// SPF, DMARC and DKIM, and how they fit the MX records. The records that
+// receivers ignore, or that make them reject mail, are otherwise only
+// noticed in deliverability reports.
+package emailcheck
+
+import (
+	"cmp"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+
+	"github.com/DNSControl/dnscontrol/v4/models"
+	"github.com/DNSControl/dnscontrol/v4/pkg/spflib"
+	"golang.org/x/net/publicsuffix"
+)
+
+// Severity is how bad a Finding is.
+type Severity int
+
+// The severities, from least to most severe.
+const (
+	Info    Severity = iota // Not a problem.
+	Warning                 // Mail is delivered, but should be better protected.
+	Error                   // Receivers ignore the record, or reject mail because of it.
+)
+
+func (s Severity) String() string {
+	switch s {
+	case Info:
+		return "INFO"
+	case Warning:
+		return "WARNING"
+	default:
+		return "ERROR"
+	}
+}
+
+// Finding is the result of one check.
+type Finding struct {
+	Severity Severity
+	Msg      string
+}
+
+func (f Finding) String() string {
+	return f.Severity.String() + ": " + f.Msg
+}
+
+// Worst returns the most severe of the findings.
+func Worst(findings []Finding) Severity {
+	worst := Info
+	for _, f := range findings {
+		worst = max(worst, f.Severity)
+	}
+	return worst
+}
+
+// zone is the mail records of a zone.
+type zone struct {
+	name string
+	txt  map[string][]string // By FQDN, in lower case.
+	mx   map[string][]*models.RecordConfig
+	r    spflib.HostResolver // For the names outside of the zone.
+
+	findings []Finding
+}
+
+func (z *zone) add(severity Severity, name, format string, a ...any) {
+	z.findings = append(z.findings, Finding{severity, name + ": " + fmt.Sprintf(format, a...)})
+}
+
+// lookupTXT returns the TXT records of name, from the zone if name is in it.
+func (z *zone) lookupTXT(name string) ([]string, error) {
+	if name == z.name || strings.HasSuffix(name, "."+z.name) {
+		return z.txt[name], nil
+	}
+	return z.r.LookupTXT(name)
+}
+
+// Check returns the findings for the records of dc. r looks up the names
+// outside of dc that the checks lead to: the includes of the SPF records,
+// the DMARC record of the organizational domain and the authorizations of
+// the external DMARC report addresses.
+func Check(dc *models.DomainConfig, r spflib.HostResolver) []Finding {
+	z := &zone{
+		name: strings.ToLower(dc.Name),
+		txt:  map[string][]string{},
+		mx:   map[string][]*models.RecordConfig{},
+		r:    r,
+	}
+	for _, rc := range dc.Records {
+		name := strings.ToLower(rc.GetLabelFQDN())
+		switch rc.Type {
+		case "TXT":
+			z.txt[name] = append(z.txt[name], rc.GetTargetTXTJoined())
+		case "MX":
+			z.mx[name] = append(z.mx[name], rc)
+		}
+	}
+
+	// The apex first, then by the reversed labels.
+	names := slices.Collect(maps.Keys(z.txt))
+	for name := range z.mx {
+		if _, ok := z.txt[name]; !ok {
+			names = append(names, name)
+		}
+	}
+	slices.SortFunc(names, func(a, b string) int {
+		return cmp.Compare(reverseLabels(a), reverseLabels(b))
+	})
+
+	mailNames := 0
+	for _, name := range names {
+		spfs := spfRecords(z.txt[name])
+		z.checkSPF(name, spfs)
+		z.checkMX(name, spfs)
+		z.checkDMARC(name)
+		z.checkDKIM(name)
+
+		// The names that send or receive mail need a DMARC policy.
+		if (len(spfs) > 0 || len(z.mx[name]) > 0) && !strings.HasPrefix(name, "_") {
+			mailNames++
+			z.checkDMARCPolicy(name)
+		}
+	}
+
+	if mailNames == 0 && len(z.findings) == 0 {
+		z.add(Info, z.name, "no MX, SPF, DMARC or DKIM records")
+	}
+	return z.findings
+}
+
+// reverseLabels returns the labels of name in reverse order, to sort names
+// by hierarchy.
+func reverseLabels(name string) string {
+	labels := strings.Split(name, ".")
+	slices.Reverse(labels)
+	return strings.Join(labels, ".")
+}
+
+// orgDomain returns the organizational domain of name (RFC 7489, section
+// 3.2), using the public suffix list.
+func orgDomain(name string) string {
+	if org, err := publicsuffix.EffectiveTLDPlusOne(name); err == nil {
+		return org
+	}
+	return name
+}
+
+// spfRecords returns the SPF records among txts.
+func spfRecords(txts []string) []string {
+	var spfs []string
+	for _, txt := range txts {
+		if l := strings.ToLower(txt); l == "v=spf1" || strings.HasPrefix(l, "v=spf1 ") {
+			spfs = append(spfs, txt)
+		}
+	}
+	return spfs
+}
+
+// checkSPF checks the SPF records of name, and counts their DNS lookups.
+func (z *zone) checkSPF(name string, spfs []string) {
+	switch len(spfs) {
+	case 0:
+		return
+	case 1:
+	default:
+		z.add(Error, name, "%d SPF records: receivers treat this as a permerror", len(spfs))
+		return
+	}
+
+	n, err := spflib.CountLookups(z.r, name, spfs[0])
+	switch {
+	case err != nil && spflib.ErrorResult(err) == spflib.ResultTempError:
+		z.add(Warning, name, "could not count the DNS lookups of the SPF record: %v", err)
+	case err != nil:
+		z.add(Error, name, "the SPF record is invalid: %v", err)
+	case n > spflib.MaxLookups:
+		z.add(Error, name, "the SPF record needs %d DNS lookups, more than the limit of %d: receivers treat it as a permerror (see the optimize option of SPF_BUILDER)", n, spflib.MaxLookups)
+	default:
+		z.add(Info, name, "the SPF record needs %d DNS lookups (of %d)", n, spflib.MaxLookups)
+	}
+}
+
+// checkMX checks that the names with MX records have an SPF record, and
+// that a null MX (RFC 7505) comes with an SPF record that rejects all mail.
+func (z *zone) checkMX(name string, spfs []string) {
+	mxs := z.mx[name]
+	if len(mxs) == 0 {
+		return
+	}
+	if !slices.ContainsFunc(mxs, isNullMX) {
+		if len(spfs) == 0 {
+			z.add(Warning, name, "MX records, but no SPF record")
+		}
+		return
+	}
+
+	if len(mxs) > 1 {
+		z.add(Error, name, "a null MX must be the only MX record (RFC 7505)")
+	}
+	if len(spfs) != 1 || !slices.Equal(strings.Fields(strings.ToLower(spfs[0])), []string{"v=spf1", "-all"}) {
+		z.add(Warning, name, "a null MX, but not the SPF record \"v=spf1 -all\": the name doesn't send mail either")
+	}
+}
+
+// isNullMX returns true if rc is a null MX: "0 .".
+func isNullMX(rc *models.RecordConfig) bool {
+	return rc.MxPreference == 0 && rc.GetTargetField() == "."
+}
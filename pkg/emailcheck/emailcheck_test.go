@@ -0,0 +1,193 @@
+package emailcheck
+
+import (
+	"errors"
+	"net/netip"
+	"strings"
+	"testing"
+
+	"github.com/DNSControl/dnscontrol/v4/models"
+)
+
+// Public keys of 512 and 1024 bits.
+const (
+	key512  = "MFwwDQYJKoZIhvcNAQEBBQADSwAwSAJBAMizK/kEGfnnnwVQ6fayqOS2RaeCXfoAYb6I2hiVDBswZ5mVUN6uW8M+nexlXsuA/dnLkl1AUxPGRrHrVQeHaqkCAwEAAQ=="
+	key1024 = "MIGfMA0GCSqGSIb3DQEBAQUAA4GNADCBiQKBgQCrEw41A7HQkKi9pbwfyDUzzMVS5N7PuclGfLgOfIGnFMYWZbDl1uCykzREPpSGenDr0P0Q3OGipOXweNVEOWKrqdi3UihNc9Xk06tItg7BujKy2XI2rjxTq31bHg8iRBwGYYK9pmW8O+exjaSRdKqF5c+va9I+52aOQrwlInMxawIDAQAB"
+)
+
+// fakeResolver is the DNS outside of the zone. The names that aren't in it
+// time out.
+type fakeResolver map[string][]string
+
+func (r fakeResolver) LookupTXT(name string) ([]string, error) {
+	txts, ok := r[name]
+	if !ok {
+		return nil, errors.New("i/o timeout")
+	}
+	return txts, nil
+}
+func (r fakeResolver) LookupMX(name string) ([]string, error)  { return nil, nil }
+func (r fakeResolver) LookupAddr(netip.Addr) ([]string, error) { return nil, nil }
+func (r fakeResolver) LookupIP(network, name string) ([]netip.Addr, error) {
+	return nil, nil
+}
+
+func makeRec(label, rtype, content string) *models.RecordConfig {
+	r := &models.RecordConfig{TTL: 300}
+	r.SetLabel(label, "example.com")
+	if err := r.PopulateFromString(rtype, content, "example.com"); err != nil {
+		panic(err)
+	}
+	return r
+}
+
+func TestCheck(t *testing.T) {
+	var many []string
+	for range 11 {
+		many = append(many, "a")
+	}
+	live := fakeResolver{
+		"_spf.example.net": {"v=spf1 ip4:192.0.2.0/24 -all"},
+		"example.com._report._dmarc.reports.example.net":      {"v=DMARC1"},
+		"example.com._report._dmarc.unauthorized.example.org": {},
+		"nospf.example.com": {"google-site-verification=abc"},
+	}
+
+	tests := []struct {
+		desc    string
+		records models.Records
+		want    []string // The findings, with their severity.
+	}{
+		{
+			"no mail",
+			models.Records{makeRec("www", "A", "192.0.2.1")},
+			[]string{"INFO: example.com: no MX, SPF, DMARC or DKIM records"},
+		},
+		{
+			"good",
+			models.Records{
+				makeRec("@", "MX", "10 mx.example.com."),
+				makeRec("@", "TXT", "v=spf1 mx include:_spf.example.net -all"),
+				makeRec("_dmarc", "TXT", "v=DMARC1; p=reject; rua=mailto:dmarc@example.com,mailto:x@reports.example.net!10m"),
+				makeRec("sel._domainkey", "TXT", "v=DKIM1; k=rsa; p="+key1024),
+				makeRec("old._domainkey", "TXT", "v=DKIM1; p="), // Revoked.
+				makeRec("news", "TXT", "v=spf1 -all"),           // Covered by the DMARC policy of example.com.
+			},
+			[]string{
+				"INFO: example.com: the SPF record needs 2 DNS lookups (of 10)",
+				"INFO: news.example.com: the SPF record needs 0 DNS lookups (of 10)",
+			},
+		},
+		{
+			"MX without SPF or DMARC",
+			models.Records{makeRec("@", "MX", "10 mx.example.com.")},
+			[]string{
+				"WARNING: example.com: MX records, but no SPF record",
+				"WARNING: example.com: no DMARC record (_dmarc.example.com)",
+			},
+		},
+		{
+			"bad SPF",
+			models.Records{
+				makeRec("@", "TXT", "v=spf1 "+strings.Join(many, " ")+" -all"),
+				makeRec("two", "TXT", "v=spf1 -all"),
+				makeRec("two", "TXT", "v=spf1 +all"),
+				makeRec("bad", "TXT", "v=spf1 include:nospf.example.com -all"),
+				makeRec("down", "TXT", "v=spf1 include:down.example.net -all"),
+				makeRec("_dmarc", "TXT", "v=DMARC1; p=none"),
+			},
+			[]string{
+				"ERROR: example.com: the SPF record needs 11 DNS lookups, more than the limit of 10",
+				"ERROR: bad.example.com: the SPF record is invalid: include:nospf.example.com: nospf.example.com has no SPF record",
+				"WARNING: down.example.com: could not count",
+				"ERROR: two.example.com: 2 SPF records",
+			},
+		},
+		{
+			"null MX",
+			models.Records{
+				makeRec("@", "MX", "0 ."),
+				makeRec("@", "TXT", "v=spf1 -all"),
+				makeRec("_dmarc", "TXT", "v=DMARC1; p=reject"),
+				makeRec("parked", "MX", "0 ."),
+				makeRec("parked", "TXT", "v=spf1 ~all"),
+				makeRec("mixed", "MX", "0 ."),
+				makeRec("mixed", "MX", "10 mx.example.com."),
+			},
+			[]string{
+				"INFO: example.com: the SPF record needs 0 DNS lookups (of 10)",
+				"ERROR: mixed.example.com: a null MX must be the only MX record",
+				"WARNING: mixed.example.com: a null MX, but not the SPF record",
+				"INFO: parked.example.com: the SPF record needs 0 DNS lookups (of 10)",
+				"WARNING: parked.example.com: a null MX, but not the SPF record",
+			},
+		},
+		{
+			"bad DMARC",
+			models.Records{
+				makeRec("_dmarc", "TXT", "v=DMARC1; p=reject; rua=mailto:a@unauthorized.example.org; ruf=mailto:b@down.example.org"),
+				makeRec("_dmarc.a", "TXT", "v=DMARC1; p=block"),
+				makeRec("_dmarc.b", "TXT", "v=DMARC1; pct=50"),
+				makeRec("_dmarc.c", "TXT", "v=dmarc1; p=none"),
+				makeRec("_dmarc.d", "TXT", "v=DMARC1; p=none; rua=dmarc@example.com"),
+				makeRec("_dmarc.e", "TXT", "v=DMARC1; p=none"),
+				makeRec("_dmarc.e", "TXT", "v=DMARC1; p=reject"),
+			},
+			[]string{
+				"WARNING: _dmarc.example.com: unauthorized.example.org doesn't accept the DMARC reports: example.com._report._dmarc.unauthorized.example.org has no",
+				"WARNING: _dmarc.example.com: could not check that down.example.org accepts the DMARC reports",
+				"ERROR: _dmarc.a.example.com: the DMARC record is malformed: p=block: must be one of none, quarantine, reject",
+				"ERROR: _dmarc.b.example.com: the DMARC record is malformed: the policy (p=) is missing",
+				"ERROR: _dmarc.c.example.com: the DMARC record is malformed: it must start with \"v=DMARC1\"",
+				"ERROR: _dmarc.d.example.com: the DMARC record is malformed: rua: \"dmarc@example.com\" is not a URI",
+				"ERROR: _dmarc.e.example.com: 2 DMARC records",
+			},
+		},
+		{
+			"bad DKIM",
+			models.Records{
+				makeRec("small._domainkey", "TXT", "v=DKIM1; k=rsa; p="+key512),
+				makeRec("nokey._domainkey", "TXT", "v=DKIM1; k=rsa"),
+				makeRec("garbage._domainkey", "TXT", "v=DKIM1; p=bm90IGEga2V5"),
+				makeRec("ed._domainkey", "TXT", "v=DKIM1; k=ed25519; p=11qYAYKxCrfVS/7TyWQHOg7hcvPapiMlrwIaaPcHURo="),
+			},
+			[]string{
+				"ERROR: garbage._domainkey.example.com: the DKIM key is not an RSA public key",
+				"ERROR: nokey._domainkey.example.com: the DKIM record has no key (p=)",
+				"ERROR: small._domainkey.example.com: the DKIM key has 512 bits",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.desc, func(t *testing.T) {
+			dc := models.MustNewDomainConfig("example.com")
+			dc.Records = tt.records
+			got := Check(dc, live)
+			if len(got) != len(tt.want) {
+				t.Fatalf("Check() = %q, want %q", got, tt.want)
+			}
+			for i, f := range got {
+				if !strings.HasPrefix(f.String(), tt.want[i]) {
+					t.Errorf("finding %d = %q, want %q", i, f, tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestCheckOrgDomain(t *testing.T) {
+	live := fakeResolver{"_dmarc.example.co.uk": {"v=DMARC1; p=reject"}}
+	dc := models.MustNewDomainConfig("mail.example.co.uk")
+	rec := &models.RecordConfig{TTL: 300}
+	rec.SetLabel("@", "mail.example.co.uk")
+	if err := rec.PopulateFromString("MX", "10 mx.example.co.uk.", "mail.example.co.uk"); err != nil {
+		t.Fatal(err)
+	}
+	dc.Records = models.Records{rec}
+
+	// The DMARC policy of the organizational domain applies.
+	got := Check(dc, live)
+	if len(got) != 1 || !strings.Contains(got[0].Msg, "no SPF record") {
+		t.Errorf("Check() = %q, want only the missing SPF record", got)
+	}
+}
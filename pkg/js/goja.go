@@ -0,0 +1,299 @@
+package js
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/DNSControl/dnscontrol/v4/models"
+	"github.com/DNSControl/dnscontrol/v4/pkg/rfc4183"
+	"github.com/DNSControl/dnscontrol/v4/pkg/transform"
+	"github.com/dop251/goja"
+	"github.com/robertkrimen/otto/underscore"
+)
+
+// executeGoja runs the script with goja, which supports ES2020+ (let,
+// const, arrow functions, template literals, destructuring, ...). The
+// script sees the same helpers.js and functions as with otto.
+func executeGoja(script []byte, devMode bool, variables map[string]string) (*models.DNSConfig, error) {
+	vm := goja.New()
+
+	// add functions to goja
+	functions := map[string]func(goja.FunctionCall) goja.Value{
+		"require":   gojaRequire(vm),
+		"REV":       gojaReverse(vm),
+		"REVCOMPAT": gojaReverseCompat(vm),
+		"glob":      gojaListFiles(vm), // used for require_glob()
+		"PANIC":     gojaPanic(vm),
+		"HASH":      gojaHash(vm),
+	}
+	// only define fetch() when explicitly enabled
+	if EnableFetch {
+		functions["fetch"] = gojaFetch(vm)
+	}
+	for name, fn := range functions {
+		if err := vm.Set(name, fn); err != nil {
+			return nil, err
+		}
+	}
+
+	// add cli variables to goja
+	for key, value := range variables {
+		if err := vm.Set(key, value); err != nil {
+			return nil, err
+		}
+	}
+
+	// otto provides underscore.js, which helpers.js and many
+	// configurations use.
+	if _, err := vm.RunString(underscore.Source()); err != nil {
+		return nil, err
+	}
+
+	// run helper script to prime vm and initialize variables
+	if _, err := vm.RunString(GetHelpers(devMode)); err != nil {
+		return nil, err
+	}
+
+	// run user script. The promises (fetch()) are settled before RunString
+	// returns.
+	if _, err := vm.RunString(string(script)); err != nil {
+		return nil, err
+	}
+
+	// export conf as string and unmarshal
+	value, err := vm.RunString(`JSON.stringify(conf)`)
+	if err != nil {
+		return nil, err
+	}
+	return parseConfig(value.String())
+}
+
+// gojaThrow throws a JavaScript Error.
+func gojaThrow(vm *goja.Runtime, str string) {
+	e, err := vm.New(vm.Get("Error"), vm.ToValue(str))
+	if err != nil {
+		panic(err)
+	}
+	panic(e)
+}
+
+// gojaIsSet returns true if the argument was given, and isn't null.
+func gojaIsSet(v goja.Value) bool {
+	return v != nil && !goja.IsUndefined(v) && !goja.IsNull(v)
+}
+
+func gojaRequire(vm *goja.Runtime) func(goja.FunctionCall) goja.Value {
+	return func(call goja.FunctionCall) goja.Value {
+		if len(call.Arguments) != 1 {
+			gojaThrow(vm, "require takes exactly one argument")
+		}
+		file := call.Argument(0).String() // The filename as given by the user
+
+		relFile, data, currentDirectoryOld, err := requireFile(file)
+		if err != nil {
+			gojaThrow(vm, err.Error())
+		}
+
+		value := vm.ToValue(true)
+
+		// If its a json file return the json value, else default to true
+		if isJSONFile(relFile) {
+			value, err = vm.RunString(fmt.Sprintf(`JSON.parse(JSON.stringify(%s))`, string(data)))
+		} else {
+			_, err = vm.RunString(string(data))
+		}
+
+		if err != nil {
+			gojaThrow(vm, fmt.Sprintf("File %s: %s", filepath.Base(relFile), err.Error()))
+		}
+
+		// Pop back to the old directory.
+		currentDirectory = currentDirectoryOld
+
+		return value
+	}
+}
+
+func gojaListFiles(vm *goja.Runtime) func(goja.FunctionCall) goja.Value {
+	return func(call goja.FunctionCall) goja.Value {
+		// Check amount of arguments provided
+		if len(call.Arguments) < 1 || len(call.Arguments) > 3 {
+			gojaThrow(vm, "glob requires at least one argument: folder (string). "+
+				"Optional: recursive (bool) [true], fileExtension (string) [.js]")
+		}
+
+		dir, ok := call.Argument(0).Export().(string) // Path where to start listing
+		if !ok || dir == "" {
+			gojaThrow(vm, "glob: first argument needs to be a path, provided as string.")
+		}
+
+		recursive := true
+		if gojaIsSet(call.Argument(1)) {
+			if recursive, ok = call.Argument(1).Export().(bool); !ok {
+				gojaThrow(vm, "glob: second argument, if recursive, needs to be bool.")
+			}
+		}
+
+		fileExtension := ".js"
+		if gojaIsSet(call.Argument(2)) {
+			if fileExtension, ok = call.Argument(2).Export().(string); !ok {
+				gojaThrow(vm, "glob: third argument, file extension, needs to be a string. * for no filter.")
+			}
+			if !strings.HasPrefix(fileExtension, ".") {
+				// If it doesn't start with a dot, probably user forgot it and we do it instead.
+				fileExtension = "." + fileExtension
+			}
+		}
+
+		files, err := globFiles(dir, recursive, fileExtension)
+		if err != nil {
+			gojaThrow(vm, err.Error())
+		}
+
+		items := make([]any, len(files))
+		for i, f := range files {
+			items[i] = f
+		}
+		return vm.NewArray(items...)
+	}
+}
+
+func gojaPanic(vm *goja.Runtime) func(goja.FunctionCall) goja.Value {
+	return func(call goja.FunctionCall) goja.Value {
+		if len(call.Arguments) != 1 {
+			gojaThrow(vm, "PANIC takes exactly one argument")
+		}
+		fmt.Fprintln(os.Stderr, call.Argument(0).String())
+		os.Exit(1)
+		return goja.Undefined()
+	}
+}
+
+func gojaReverse(vm *goja.Runtime) func(goja.FunctionCall) goja.Value {
+	return func(call goja.FunctionCall) goja.Value {
+		if len(call.Arguments) != 1 {
+			gojaThrow(vm, "REV takes exactly one argument")
+		}
+		rev, err := transform.ReverseDomainName(call.Argument(0).String())
+		if err != nil {
+			gojaThrow(vm, err.Error())
+		}
+		return vm.ToValue(rev)
+	}
+}
+
+func gojaReverseCompat(vm *goja.Runtime) func(goja.FunctionCall) goja.Value {
+	return func(call goja.FunctionCall) goja.Value {
+		if len(call.Arguments) != 1 {
+			gojaThrow(vm, "REVCOMPAT takes exactly one argument")
+		}
+		if err := rfc4183.SetCompatibilityMode(call.Argument(0).String()); err != nil {
+			gojaThrow(vm, err.Error())
+		}
+		return goja.Null()
+	}
+}
+
+func gojaHash(vm *goja.Runtime) func(goja.FunctionCall) goja.Value {
+	return func(call goja.FunctionCall) goja.Value {
+		if len(call.Arguments) != 2 {
+			gojaThrow(vm, "HASH takes exactly two arguments")
+		}
+		hash, err := hashString(call.Argument(0).String(), call.Argument(1).String())
+		if err != nil {
+			gojaThrow(vm, err.Error())
+		}
+		return vm.ToValue(hash)
+	}
+}
+
+// gojaFetch returns fetch(url, {method, headers, body}). The request is made
+// when fetch() is called; the Response has ok, status, statusText, url,
+// headers.get(), text() and json().
+func gojaFetch(vm *goja.Runtime) func(goja.FunctionCall) goja.Value {
+	return func(call goja.FunctionCall) goja.Value {
+		promise, resolve, reject := vm.NewPromise()
+		if res, err := gojaDoFetch(vm, call.Argument(0).String(), call.Argument(1)); err != nil {
+			_ = reject(vm.NewGoError(err))
+		} else {
+			_ = resolve(res)
+		}
+		return vm.ToValue(promise)
+	}
+}
+
+func gojaDoFetch(vm *goja.Runtime, url string, options goja.Value) (*goja.Object, error) {
+	method := http.MethodGet
+	var body io.Reader
+	headers := map[string]string{}
+	if gojaIsSet(options) {
+		opts := options.ToObject(vm)
+		if v := opts.Get("method"); gojaIsSet(v) {
+			method = strings.ToUpper(v.String())
+		}
+		if v := opts.Get("body"); gojaIsSet(v) {
+			body = strings.NewReader(v.String())
+		}
+		if v := opts.Get("headers"); gojaIsSet(v) {
+			h := v.ToObject(vm)
+			for _, k := range h.Keys() {
+				headers[k] = h.Get(k).String()
+			}
+		}
+	}
+
+	req, err := http.NewRequest(method, url, body)
+	if err != nil {
+		return nil, err
+	}
+	for k, v := range headers {
+		req.Header.Set(k, v) // Set headers
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	h := vm.NewObject()
+	_ = h.Set("get", func(name string) goja.Value {
+		if vs := resp.Header.Values(name); len(vs) > 0 {
+			return vm.ToValue(strings.Join(vs, ", "))
+		}
+		return goja.Null()
+	})
+	_ = h.Set("has", func(name string) bool {
+		return len(resp.Header.Values(name)) > 0
+	})
+
+	res := vm.NewObject()
+	_ = res.Set("ok", resp.StatusCode >= 200 && resp.StatusCode < 300)
+	_ = res.Set("status", resp.StatusCode)
+	_ = res.Set("statusText", resp.Status)
+	_ = res.Set("url", url)
+	_ = res.Set("headers", h)
+	_ = res.Set("text", func() *goja.Promise {
+		p, resolve, _ := vm.NewPromise()
+		_ = resolve(string(data))
+		return p
+	})
+	_ = res.Set("json", func() *goja.Promise {
+		p, resolve, reject := vm.NewPromise()
+		parse, _ := goja.AssertFunction(vm.Get("JSON").ToObject(vm).Get("parse"))
+		if v, err := parse(goja.Undefined(), vm.ToValue(string(data))); err != nil {
+			_ = reject(vm.NewGoError(err))
+		} else {
+			_ = resolve(v)
+		}
+		return p
+	})
+	return res, nil
+}
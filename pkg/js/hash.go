@@ -17,26 +17,31 @@ func hashFunc(call otto.FunctionCall) otto.Value {
 	}
 	algorithm := call.Argument(0).String() // The algorithm to use for hashing
 	value := call.Argument(1).String()     // The value to hash
-	//lint:ignore SA4006 work around bug in staticcheck. This value is needed if the switch statement follows the default path.
-	result := otto.Value{} //nolint:staticcheck
-	// fmt.Printf("%s\n", value)
 
+	hash, err := hashString(algorithm, value)
+	if err != nil {
+		throw(call.Otto, err.Error())
+	}
+	result, _ := otto.ToValue(hash)
+	return result
+}
+
+// hashString returns the hex digest of value.
+func hashString(algorithm, value string) (string, error) {
 	switch algorithm {
 	case "SHA1", "sha1":
 		tmp := sha1.New()
 		tmp.Write([]byte(value))
-		// fmt.Printf("%s\n", hex.EncodeToString(tmp.Sum(nil)))
-		result, _ = otto.ToValue(hex.EncodeToString(tmp.Sum(nil)))
+		return hex.EncodeToString(tmp.Sum(nil)), nil
 	case "SHA256", "sha256":
 		tmp := sha256.New()
 		tmp.Write([]byte(value))
-		result, _ = otto.ToValue(hex.EncodeToString(tmp.Sum(nil)))
+		return hex.EncodeToString(tmp.Sum(nil)), nil
 	case "SHA512", "sha512":
 		tmp := sha512.New()
 		tmp.Write([]byte(value))
-		result, _ = otto.ToValue(hex.EncodeToString(tmp.Sum(nil)))
+		return hex.EncodeToString(tmp.Sum(nil)), nil
 	default:
-		throw(call.Otto, fmt.Sprintf("invalid algorithm %s given", algorithm))
+		return "", fmt.Errorf("invalid algorithm %s given", algorithm)
 	}
-	return result
 }
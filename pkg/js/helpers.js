@@ -277,7 +277,7 @@ function stringToDuration(v) {
     if (matches == null) {
         throw v + ' is not a valid duration string';
     }
-    unit = 's';
+    var unit = 's';
     if (matches[2]) {
         unit = matches[2];
     }
@@ -802,7 +802,7 @@ function getENotationInt(x) {
        1cm = 1e0 == 16 (1^4 + 0) or 0<<4 + 0
        0cm = 0e0 == 0
     */
-    size = x * 100; // get cm value
+    var size = x * 100; // get cm value
 
     // Convert the number to scientific notation
     var exp = Math.floor(Math.log10(size)); // Get the exponent (base 10)
@@ -829,7 +829,7 @@ function getENotationInt(x) {
         exp = 9; // Cap exponent at 9
     }
     // convert it to 4bit:4bit uint8
-    m_e = (mantissa << 4) | (exp & 0xf);
+    var m_e = (mantissa << 4) | (exp & 0xf);
     return m_e;
 }
 
@@ -842,11 +842,11 @@ function locStringBuilder(record, args) {
     // it is a good sanity check to compare with later on down the chain
     // when you're in the weeds with maths.
     // Tests depend on it being present. Changes here must reflect in tests.
-    nsstring = '';
-    ewstring = '';
-    precisionbuffer = '';
-    ns = args.ns.toUpperCase();
-    ew = args.ew.toUpperCase();
+    var nsstring = '';
+    var ewstring = '';
+    var precisionbuffer = '';
+    var ns = args.ns.toUpperCase();
+    var ew = args.ew.toUpperCase();
 
     // Handle N/S coords - can use also s1.toFixed(3)
     nsstring =
@@ -911,14 +911,16 @@ function locStringBuilder(record, args) {
 // Renders LOC type internal properties from D˚M'S" parameters.
 // Change anything here at your peril.
 function locDMSBuilder(record, args) {
-    LOCEquator = Math.pow(2, 31); // RFC 1876, Section 2.
-    LOCPrimeMeridian = Math.pow(2, 31); // RFC 1876, Section 2.
-    LOCHours = 60 * 1000;
-    LOCDegrees = 60 * LOCHours;
-    LOCAltitudeBase = 100000;
-
-    lat = args.d1 * LOCDegrees + args.m1 * LOCHours + args.s1 * 1000;
-    lon = args.d2 * LOCDegrees + args.m2 * LOCHours + args.s2 * 1000;
+    var LOCEquator = Math.pow(2, 31); // RFC 1876, Section 2.
+    var LOCPrimeMeridian = Math.pow(2, 31); // RFC 1876, Section 2.
+    var LOCHours = 60 * 1000;
+    var LOCDegrees = 60 * LOCHours;
+    var LOCAltitudeBase = 100000;
+
+    var lat = args.d1 * LOCDegrees + args.m1 * LOCHours + args.s1 * 1000;
+    var lon = args.d2 * LOCDegrees + args.m2 * LOCHours + args.s2 * 1000;
+    var ns = args.ns.toUpperCase();
+    var ew = args.ew.toUpperCase();
     if (ns == 'N') record.loclatitude = LOCEquator + lat;
     // S
     else record.loclatitude = LOCEquator - lat;
@@ -937,11 +939,9 @@ function locDMSBuilder(record, args) {
     // Size
     record.locsize = getENotationInt(args.siz);
     // Horizontal Precision
-    m_e = args.hp;
     record.lochorizpre = getENotationInt(args.hp);
 
     // Vertical Precision
-    m_e = args.vp;
     record.locvertpre = getENotationInt(args.vp);
 }
 
@@ -1122,6 +1122,34 @@ function DISABLE_IGNORE_SAFETY_CHECK(d) {
     d.unmanaged_disable_safety_check = true;
 }
 
+// CHANGE_LIMIT(maxDeletes, maxChangePercent)
+// Refuse to change the domain if a push would delete more than maxDeletes
+// records, or delete or modify more than maxChangePercent percent of the
+// existing records.  -1 (or omitting the argument) means no limit.
+// Usage:
+//   CHANGE_LIMIT(10)       // At most 10 deletes.
+//   CHANGE_LIMIT(-1, 25)   // At most 25% of the records deleted or modified.
+function CHANGE_LIMIT(maxDeletes, maxChangePercent) {
+    if (maxDeletes === undefined) {
+        maxDeletes = -1;
+    }
+    if (maxChangePercent === undefined) {
+        maxChangePercent = -1;
+    }
+    if (!_.isNumber(maxDeletes) || maxDeletes % 1 !== 0 || maxDeletes < -1) {
+        throw 'CHANGE_LIMIT: maxDeletes must be a whole number of records, or -1 for no limit (got ' + maxDeletes + ')';
+    }
+    if (!_.isNumber(maxChangePercent) || maxChangePercent % 1 !== 0 || maxChangePercent < -1) {
+        throw 'CHANGE_LIMIT: maxChangePercent must be a whole percentage, or -1 for no limit (got ' + maxChangePercent + ')';
+    }
+    return function (d) {
+        d.change_limit = {
+            max_deletes: maxDeletes,
+            max_change_percent: maxChangePercent,
+        };
+    };
+}
+
 // IGNORE(labelPattern, rtypePattern, targetPattern)
 function IGNORE(labelPattern, rtypePattern, targetPattern) {
     if (labelPattern === undefined) {
@@ -1212,6 +1240,30 @@ function IGNORE_EXTERNAL_DNS(prefix) {
     };
 }
 
+// OWNERSHIP_REGISTRY(owner, prefix)
+// Mark the records DNSControl manages with TXT records that name the owner,
+// and only modify or delete records that are marked with that owner.
+// Several dnsconfig.js files (with different owners) can then manage the
+// same zone.
+//
+// Usage:
+//   OWNERSHIP_REGISTRY("team-a")                  // Markers like "_dnscontrol-a.www"
+//   OWNERSHIP_REGISTRY("team-a", "_owner-")       // Markers like "_owner-a.www"
+function OWNERSHIP_REGISTRY(owner, prefix) {
+    if (!_.isString(owner) || !/^[A-Za-z0-9._-]+$/.test(owner)) {
+        throw 'OWNERSHIP_REGISTRY: owner must be a non-empty string of letters, digits, ".", "_" and "-"';
+    }
+    if (prefix !== undefined && !_.isString(prefix)) {
+        throw 'OWNERSHIP_REGISTRY: prefix must be a string';
+    }
+    return function (d) {
+        d.ownership_registry = { owner: owner };
+        if (prefix) {
+            d.ownership_registry.prefix = prefix;
+        }
+    };
+}
+
 // ENSURE_ABSENT_REC()
 // Usage: A("foo", "1.2.3.4", ENSURE_ABSENT_REC())
 function ENSURE_ABSENT_REC() {
@@ -1245,6 +1297,127 @@ function AUTODNSSEC(d) {
     );
 }
 
+// GENERATE(name, start, stop, [step,] type, args...)
+// Creates the records of BIND's $GENERATE: for i from start to stop,
+// type(name, args...) with each $ of name and of the string arguments
+// replaced by i. ${offset,width,base} adds offset to i and formats it with at
+// least width digits in base d (decimal), o (octal), x or X (hexadecimal), or
+// n and N (nibbles, for ip6.arpa). \$ and $$ are a literal $.
+function GENERATE(name, start, stop) {
+    var args = Array.prototype.slice.call(arguments, 3);
+    var step = 1;
+    if (_.isNumber(args[0])) {
+        step = args.shift();
+    }
+    var type = args.shift();
+
+    if (!_.isString(name)) {
+        throw 'GENERATE: the name must be a string';
+    }
+    if (
+        !_isNaturalNumber(start) ||
+        !_isNaturalNumber(stop) ||
+        !_isNaturalNumber(step) ||
+        start > stop ||
+        step < 1 ||
+        (stop - start) / step > 65535
+    ) {
+        throw (
+            'GENERATE: invalid range ' +
+            start +
+            '-' +
+            stop +
+            '/' +
+            step +
+            ' (0 <= start <= stop, step >= 1, at most 65536 records)'
+        );
+    }
+    if (!_.isFunction(type)) {
+        throw 'GENERATE: the type must be a record type, like A (without quotes)';
+    }
+
+    // The BIND provider can write the records as one $GENERATE line.
+    var meta = {};
+    if (_.isString(args[0])) {
+        meta.generate =
+            start + '-' + stop + '/' + step + ' ' + name + ' ' + args[0];
+    }
+
+    var records = [];
+    for (var i = start; i <= stop; i += step) {
+        var a = [_generateSubst(name, i)];
+        for (var j = 0; j < args.length; j++) {
+            a.push(_.isString(args[j]) ? _generateSubst(args[j], i) : args[j]);
+        }
+        a.push(meta);
+        records.push(type.apply(null, a));
+    }
+    return records;
+}
+
+function _isNaturalNumber(n) {
+    return _.isNumber(n) && n >= 0 && Math.floor(n) === n;
+}
+
+// _generateSubst returns the template of GENERATE() with $ replaced by i.
+function _generateSubst(tmpl, i) {
+    return tmpl.replace(
+        /\\\$|\$\$|\$(\{([+-]?\d+)(?:,(\d+)(?:,([doxXnN]))?)?\})?/g,
+        function (mod, braces, offset, width, base) {
+            if (mod === '\\$' || mod === '$$') {
+                return '$';
+            }
+            var v = i + (offset ? parseInt(offset, 10) : 0);
+            width = width ? parseInt(width, 10) : 0;
+            if (v < 0) {
+                throw 'GENERATE: ' + mod + ' is negative for ' + i;
+            }
+            var s;
+            switch (base) {
+                case 'o':
+                    s = v.toString(8);
+                    break;
+                case 'x':
+                    s = v.toString(16);
+                    break;
+                case 'X':
+                    s = v.toString(16).toUpperCase();
+                    break;
+                case 'n':
+                case 'N':
+                    return _generateNibbles(v, width, base === 'N');
+                default:
+                    s = v.toString(10);
+            }
+            while (s.length < width) {
+                s = '0' + s;
+            }
+            return s;
+        }
+    );
+}
+
+// _generateNibbles returns v as hex digits separated by dots, the least
+// significant first. Like BIND, width counts the dots.
+function _generateNibbles(v, width, upper) {
+    var digits = upper ? '0123456789ABCDEF' : '0123456789abcdef';
+    var s = '';
+    do {
+        s += digits.charAt(v & 0xf);
+        v = v >>> 4;
+        if (width > 0) {
+            width--;
+        }
+        if (width > 0 || v !== 0) {
+            s += '.';
+            if (width > 0) {
+                width--;
+            }
+        }
+    } while (v !== 0 || width > 0);
+    return s;
+}
+
 /**
  * @deprecated
  */
@@ -1630,7 +1803,7 @@ function LOC_BUILDER_DD(value) {
     var lati = ConvertDDToDMS(value.x, false);
     var long = ConvertDDToDMS(value.y, true);
 
-    dms = { lati: lati, long: long };
+    var dms = { lati: lati, long: long };
 
     return LOC_builder_push(value, dms);
 }
@@ -1697,8 +1870,8 @@ function LOC_BUILDER_STR(value) {
 }
 
 function LOC_builder_push(value, dms) {
-    r = []; // The list of records to return.
-    p = {}; // The metaparameters to set on the LOC record.
+    var r = []; // The list of records to return.
+    var p = {}; // The metaparameters to set on the LOC record.
     // rawloc = "";
 
     // Generate a LOC record with the metaparameters.
@@ -1794,6 +1967,8 @@ function LOC_builder_push(value, dms) {
 // ttl: The time for TTL, integer or string. (default: not defined, using DefaultTTL)
 // split: The template for additional records to be created (default: '_spf%d')
 // flatten: A list of domains to be flattened.
+// aggregate: Merge the ip4: and ip6: ranges into the fewest CIDRs. (default: false)
+// optimize: Flatten the includes needed to stay within 10 lookups, with the fewest records. (default: false)
 // overhead1: Amout of "buffer room" to reserve on the first item in the spf chain.
 // txtMaxSize: The maximum size for each TXT string. Values over 255 will result in multiple strings (default: '255')
 
@@ -1808,16 +1983,25 @@ function SPF_BUILDER(value) {
         value.raw = '_rawspf';
     }
 
-    r = []; // The list of records to return.
-    p = {}; // The metaparameters to set on the main TXT record.
-    rawspf = value.parts.join(' '); // The unaltered SPF settings.
+    var r = []; // The list of records to return.
+    var p = {}; // The metaparameters to set on the main TXT record.
+    var rawspf = value.parts.join(' '); // The unaltered SPF settings.
 
-    // If flattening is requested, generate a TXT record with the raw SPF settings.
     if (value.flatten && value.flatten.length > 0) {
         p.flatten = value.flatten.join(',');
+    }
+    if (value.aggregate) {
+        p.aggregate = 'true';
+    }
+    if (value.optimize) {
+        p.optimize = 'true';
+    }
+
+    // If flattening is requested, generate a TXT record with the raw SPF settings.
+    if (p.flatten || p.aggregate || p.optimize) {
         // Only add the raw spf record if it isn't an empty string
         if (value.raw !== '') {
-            rp = {};
+            var rp = {};
             if (value.ttl) {
                 r.push(TXT(value.raw, rawspf, rp, TTL(value.ttl)));
             } else {
@@ -1894,7 +2078,7 @@ function CAA_BUILDER(value) {
     if (value.ttl) {
         CAA_TTL = TTL(value.ttl);
     }
-    r = []; // The list of records to return.
+    var r = []; // The list of records to return.
 
     if (value.iodef) {
         if (value.iodef_critical) {
@@ -2314,6 +2498,177 @@ function DMARC_BUILDER(value) {
     return TXT(label, record.join('; '));
 }
 
+// MTASTS_BUILDER takes an object:
+// label: The DNS label for the MTA-STS record (_mta-sts prefix is added; default: '@')
+// mode: The policy mode, must be one of 'enforce', 'testing', 'none' (default: 'testing')
+// mx: Array of the MX hosts that the policy allows; '*.' matches one label (required unless mode is 'none')
+// maxAge: How long senders cache the policy, in seconds or a duration string (max_age, default: '1w')
+// id: The policy id (id=, default: derived from the hash of the policy)
+// policyFile: The file to write the policy to, to publish it at https://mta-sts.<domain>/.well-known/mta-sts.txt (optional)
+// ttl: Input for TTL method
+function MTASTS_BUILDER(value) {
+    value = _.defaults(value || {}, {
+        label: '@',
+        mode: 'testing',
+        mx: [],
+        maxAge: 604800,
+    });
+    if (_.isString(value.mx)) {
+        value.mx = [value.mx];
+    }
+    if (_.isString(value.maxAge)) {
+        value.maxAge = stringToDuration(value.maxAge);
+    }
+
+    if (!_.contains(['enforce', 'testing', 'none'], value.mode)) {
+        throw 'MTASTS_BUILDER mode must be one of: enforce, testing, none';
+    }
+    if (value.mode !== 'none' && value.mx.length === 0) {
+        throw 'MTASTS_BUILDER mx cannot be empty unless mode is none';
+    }
+    var mxPattern =
+        /^(\*\.)?([a-z0-9_]([a-z0-9_-]*[a-z0-9])?\.)+[a-z0-9]([a-z0-9-]*[a-z0-9])?$/i;
+    var mxs = [];
+    for (var i = 0; i < value.mx.length; i++) {
+        var mx = value.mx[i].replace(/\.$/, '');
+        if (!mxPattern.test(mx)) {
+            throw 'MTASTS_BUILDER mx ' + value.mx[i] + ' is not a valid host';
+        }
+        mxs.push(mx);
+    }
+    if (
+        !_.isNumber(value.maxAge) ||
+        value.maxAge % 1 !== 0 ||
+        value.maxAge < 0 ||
+        value.maxAge > 31557600
+    ) {
+        throw 'MTASTS_BUILDER maxAge must be between 0 and 31557600 seconds';
+    }
+
+    // The policy (RFC 8461, section 3.2), with CRLF line endings.
+    var lines = ['version: STSv1', 'mode: ' + value.mode];
+    for (var j = 0; j < mxs.length; j++) {
+        lines.push('mx: ' + mxs[j]);
+    }
+    lines.push('max_age: ' + value.maxAge);
+    var policy = lines.join('\r\n') + '\r\n';
+
+    // The id changes whenever the policy changes.
+    if (value.id === undefined) {
+        value.id = HASH('SHA256', policy).substring(0, 32);
+    }
+    if (!/^[a-zA-Z0-9]{1,32}$/.test(value.id)) {
+        throw 'MTASTS_BUILDER id must be 1 to 32 letters and digits';
+    }
+
+    var label = '_mta-sts';
+    if (value.label !== '@') {
+        label += '.' + value.label;
+    }
+
+    // The policy file is written by push.
+    var meta = {};
+    if (value.policyFile) {
+        meta.mtasts_policy_file = value.policyFile;
+        meta.mtasts_policy = policy;
+    }
+
+    var MTASTS_TTL = value.ttl ? TTL(value.ttl) : function () {};
+
+    return TXT(label, 'v=STSv1; id=' + value.id, meta, MTASTS_TTL);
+}
+
+// TLSRPT_BUILDER takes an object:
+// label: The DNS label for the TLS-RPT record (_smtp._tls prefix is added; default: '@')
+// rua: Array of report targets, mailto: or https: URIs (required)
+// ttl: Input for TTL method
+function TLSRPT_BUILDER(value) {
+    value = _.defaults(value || {}, {
+        label: '@',
+        rua: [],
+    });
+    if (_.isString(value.rua)) {
+        value.rua = [value.rua];
+    }
+
+    if (value.rua.length === 0) {
+        throw 'TLSRPT_BUILDER rua cannot be empty';
+    }
+    for (var i = 0; i < value.rua.length; i++) {
+        if (
+            !/^mailto:[^@\s,;!]+@[^@\s,;!]+$/.test(value.rua[i]) &&
+            !/^https:\/\/[^\s,;!]+$/.test(value.rua[i])
+        ) {
+            throw (
+                'TLSRPT_BUILDER rua ' +
+                value.rua[i] +
+                ' must be a mailto: or https:// URI (percent-encode commas, semicolons and exclamation points)'
+            );
+        }
+    }
+
+    var label = '_smtp._tls';
+    if (value.label !== '@') {
+        label += '.' + value.label;
+    }
+
+    var TLSRPT_TTL = value.ttl ? TTL(value.ttl) : function () {};
+
+    return TXT(label, 'v=TLSRPTv1; rua=' + value.rua.join(','), TLSRPT_TTL);
+}
+
+// BIMI_BUILDER takes an object:
+// label: The DNS label for the BIMI record ([selector]._bimi prefix is added; default: '@')
+// selector: The BIMI selector (default: 'default')
+// location: The https:// URL of the SVG logo (l=); empty to decline to publish a logo (required)
+// authority: The https:// URL of the PEM Verified Mark Certificate (a=, optional)
+// ttl: Input for TTL method
+function BIMI_BUILDER(value) {
+    value = _.defaults(value || {}, {
+        label: '@',
+        selector: 'default',
+        authority: '',
+    });
+
+    if (!/^[a-z0-9_]([a-z0-9_-]*[a-z0-9_])?$/i.test(value.selector)) {
+        throw (
+            'BIMI_BUILDER selector ' + value.selector + ' is not a valid label'
+        );
+    }
+    if (!_.isString(value.location)) {
+        throw 'BIMI_BUILDER location is required (empty to decline to publish a logo)';
+    }
+    if (
+        value.location !== '' &&
+        !/^https:\/\/[^\s;]+\.svg$/i.test(value.location)
+    ) {
+        throw 'BIMI_BUILDER location must be an https:// URL of an SVG file';
+    }
+    if (
+        value.authority !== '' &&
+        !/^https:\/\/[^\s;]+$/i.test(value.authority)
+    ) {
+        throw 'BIMI_BUILDER authority must be an https:// URL';
+    }
+    if (value.location === '' && value.authority !== '') {
+        throw 'BIMI_BUILDER authority requires a location';
+    }
+
+    var record = ['v=BIMI1', 'l=' + value.location];
+    if (value.authority !== '' || value.location === '') {
+        record.push('a=' + value.authority);
+    }
+
+    var label = value.selector + '._bimi';
+    if (value.label !== '@') {
+        label += '.' + value.label;
+    }
+
+    var BIMI_TTL = value.ttl ? TTL(value.ttl) : function () {};
+
+    return TXT(label, record.join('; '), BIMI_TTL);
+}
+
 // Documentation of the records: https://learn.microsoft.com/en-us/microsoft-365/enterprise/external-domain-name-system-records?view=o365-worldwide
 function M365_BUILDER(name, value) {
     // value is optional
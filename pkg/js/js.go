@@ -3,6 +3,7 @@ package js
 import (
 	_ "embed" // Used to embed helpers.js in the binary.
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
 	"os"
@@ -37,6 +38,15 @@ var currentDirectory string
 // EnableFetch sets whether to enable fetch() in JS execution environment.
 var EnableFetch bool = false
 
+// The JavaScript engines that can run dnsconfig.js.
+const (
+	EngineOtto = "otto" // ES5
+	EngineGoja = "goja" // ES2020+
+)
+
+// Engine is the JavaScript engine that runs dnsconfig.js.
+var Engine = EngineOtto
+
 // ExecuteJavaScript accepts a javascript file and runs it, returning the resulting dnsConfig.
 func ExecuteJavaScript(file string, devMode bool, variables map[string]string) (*models.DNSConfig, error) {
 	script, err := os.ReadFile(file)
@@ -52,6 +62,14 @@ func ExecuteJavaScript(file string, devMode bool, variables map[string]string) (
 
 // ExecuteJavascriptString accepts a string containing javascript and runs it, returning the resulting dnsConfig.
 func ExecuteJavascriptString(script []byte, devMode bool, variables map[string]string) (*models.DNSConfig, error) {
+	switch Engine {
+	case "", EngineOtto:
+	case EngineGoja:
+		return executeGoja(script, devMode, variables)
+	default:
+		return nil, fmt.Errorf("unknown JavaScript engine %q (valid: %s, %s)", Engine, EngineOtto, EngineGoja)
+	}
+
 	vm := otto.New()
 	l := loop.New(vm)
 
@@ -116,13 +134,17 @@ func ExecuteJavascriptString(script []byte, devMode bool, variables map[string]s
 	if err != nil {
 		return nil, err
 	}
+	return parseConfig(str)
+}
+
+// parseConfig returns the dnsConfig of conf, as exported by JSON.stringify().
+func parseConfig(str string) (*models.DNSConfig, error) {
 	conf := &models.DNSConfig{}
-	if err = json.Unmarshal([]byte(str), conf); err != nil {
+	if err := json.Unmarshal([]byte(str), conf); err != nil {
 		return nil, err
 	}
 
-	err = conf.PostProcess()
-	if err != nil {
+	if err := conf.PostProcess(); err != nil {
 		return nil, err
 	}
 	// No need to call FixLegacyDC here. These records were created from dnsconfig.js, not from a provider.
@@ -155,22 +177,7 @@ func require(call otto.FunctionCall) otto.Value {
 	}
 	file := call.Argument(0).String() // The filename as given by the user
 
-	// relFile is the file we're actually going to pass to ReadFile().
-	// It defaults to the user-provided name unless it is relative.
-	relFile := file
-	cleanFile := filepath.Clean(filepath.Join(currentDirectory, file))
-	if strings.HasPrefix(file, ".") {
-		relFile = cleanFile
-	}
-
-	// Record the old currentDirectory so that we can return there.
-	currentDirectoryOld := currentDirectory
-	// Record the directory path leading up to the file we're about to require.
-	currentDirectory = filepath.Dir(cleanFile)
-
-	printer.Debugf("requiring: %s (%s)\n", file, relFile)
-	// quick fix, by replacing to linux slashes, to make it work with windows paths too.
-	data, err := os.ReadFile(filepath.ToSlash(relFile))
+	relFile, data, currentDirectoryOld, err := requireFile(file)
 	if err != nil {
 		throw(call.Otto, err.Error())
 	}
@@ -178,8 +185,7 @@ func require(call otto.FunctionCall) otto.Value {
 	value := otto.TrueValue()
 
 	// If its a json file return the json value, else default to true
-	ext := strings.ToLower(filepath.Ext(relFile))
-	if strings.HasSuffix(ext, "json") || strings.HasSuffix(ext, "json5") {
+	if isJSONFile(relFile) {
 		cmd := fmt.Sprintf(`JSON.parse(JSON.stringify(%s))`, string(data))
 		value, err = call.Otto.Run(cmd)
 	} else {
@@ -196,6 +202,36 @@ func require(call otto.FunctionCall) otto.Value {
 	return value
 }
 
+// requireFile reads the file of require(file). It records the directory of
+// the file as the currentDirectory, and returns the old one, which the
+// caller restores once the file has run.
+func requireFile(file string) (relFile string, data []byte, currentDirectoryOld string, err error) {
+	// relFile is the file we're actually going to pass to ReadFile().
+	// It defaults to the user-provided name unless it is relative.
+	relFile = file
+	cleanFile := filepath.Clean(filepath.Join(currentDirectory, file))
+	if strings.HasPrefix(file, ".") {
+		relFile = cleanFile
+	}
+
+	// Record the old currentDirectory so that we can return there.
+	currentDirectoryOld = currentDirectory
+	// Record the directory path leading up to the file we're about to require.
+	currentDirectory = filepath.Dir(cleanFile)
+
+	printer.Debugf("requiring: %s (%s)\n", file, relFile)
+	// quick fix, by replacing to linux slashes, to make it work with windows paths too.
+	data, err = os.ReadFile(filepath.ToSlash(relFile))
+	return relFile, data, currentDirectoryOld, err
+}
+
+// isJSONFile returns true if require() returns the contents of the file
+// rather than running it.
+func isJSONFile(file string) bool {
+	ext := strings.ToLower(filepath.Ext(file))
+	return strings.HasSuffix(ext, "json") || strings.HasSuffix(ext, "json5")
+}
+
 func listFiles(call otto.FunctionCall) otto.Value {
 	// Check amount of arguments provided
 	if len(call.ArgumentList) < 1 || len(call.ArgumentList) > 3 {
@@ -210,15 +246,6 @@ func listFiles(call otto.FunctionCall) otto.Value {
 		throw(call.Otto, "glob: first argument needs to be a path, provided as string.")
 	}
 	dir := call.Argument(0).String() // Path where to start listing
-	printer.Debugf("listFiles: cd: %s, user: %s \n", currentDirectory, dir)
-	// now we always prepend the current directory we're working in, which is being set within
-	// the func ExecuteJavascript() above. So when require("domains/load_all.js") is being used,
-	// where glob("customer1/") is being used, we basically search for files in domains/customer1/.
-	dir = filepath.ToSlash(filepath.Join(currentDirectory, dir))
-
-	if _, err := os.Stat(dir); os.IsNotExist(err) {
-		throw(call.Otto, "glob: provided path does not exist.")
-	}
 
 	// Second: Recursive?
 	recursive := true
@@ -244,6 +271,32 @@ func listFiles(call otto.FunctionCall) otto.Value {
 		}
 	}
 
+	files, err := globFiles(dir, recursive, fileExtension)
+	if err != nil {
+		throw(call.Otto, err.Error())
+	}
+
+	// let's pass the data back to the JS engine.
+	value, err := call.Otto.ToValue(files)
+	if err != nil {
+		throw(call.Otto, fmt.Sprintf("converting value failed: %v", err.Error()))
+	}
+
+	return value
+}
+
+// globFiles returns the files of glob(dir, recursive, fileExtension).
+func globFiles(dir string, recursive bool, fileExtension string) ([]string, error) {
+	printer.Debugf("listFiles: cd: %s, user: %s \n", currentDirectory, dir)
+	// now we always prepend the current directory we're working in, which is being set within
+	// the func ExecuteJavascript() above. So when require("domains/load_all.js") is being used,
+	// where glob("customer1/") is being used, we basically search for files in domains/customer1/.
+	dir = filepath.ToSlash(filepath.Join(currentDirectory, dir))
+
+	if _, err := os.Stat(dir); os.IsNotExist(err) {
+		return nil, errors.New("glob: provided path does not exist")
+	}
+
 	// Now we're doing the actual work: Listing files.
 	// Folders are ending with a slash. Can be identified later on from the user with JavaScript.
 	// Additionally, when more smart logic required, user can use regex in JS.
@@ -272,16 +325,9 @@ func listFiles(call otto.FunctionCall) otto.Value {
 		return err
 	})
 	if err != nil {
-		throw(call.Otto, fmt.Sprintf("dirwalk failed: %v", err.Error()))
+		return nil, fmt.Errorf("dirwalk failed: %v", err.Error())
 	}
-
-	// let's pass the data back to the JS engine.
-	value, err := call.Otto.ToValue(files)
-	if err != nil {
-		throw(call.Otto, fmt.Sprintf("converting value failed: %v", err.Error()))
-	}
-
-	return value
+	return files, nil
 }
 
 func jsPanic(call otto.FunctionCall) otto.Value {
@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"regexp"
 	"strings"
 	"testing"
 	"unicode"
@@ -43,50 +44,7 @@ func TestParsedFiles(t *testing.T) {
 			continue
 		}
 		t.Run(name, func(t *testing.T) {
-			var err error
-
-			// Compile the .js file:
-			conf, err := ExecuteJavaScript(string(filepath.Join(testDir, name)), true, nil)
-			if err != nil {
-				t.Fatal(err)
-			}
-
-			errs := normalize.ValidateAndNormalizeConfig(conf)
-			if len(errs) != 0 {
-				t.Fatal(errs[0])
-			}
-
-			for _, dc := range conf.Domains {
-				// fmt.Printf("DEBUG: PrettySort: domain=%q #rec=%d\n", dc.Name, len(dc.Records))
-				// fmt.Printf("DEBUG: records = %d %v\n", len(dc.Records), dc.Records)
-				ps := prettyzone.PrettySort(dc.Records, dc.Name, 0, nil)
-				dc.Records = ps.Records
-				if len(dc.Records) == 0 {
-					dc.Records = models.Records{}
-				}
-			}
-
-			// Initialize any DNS providers mentioned.
-			for _, dProv := range conf.DNSProviders {
-				pcfg := map[string]string{}
-
-				if dProv.Type == "-" {
-					// Pretend any "look up provider type in creds.json" results
-					// in a provider type that actually exists.
-					dProv.Type = "CLOUDFLAREAPI"
-				}
-
-				// Fake out any provider's validation tests.
-				switch dProv.Type {
-				case "CLOUDFLAREAPI":
-					pcfg["apitoken"] = "fake"
-				default:
-				}
-				_, err := providers.CreateDNSProvider(dProv.Type, pcfg, nil)
-				if err != nil {
-					t.Fatal(err)
-				}
-			}
+			conf := compileParseTest(t, name)
 
 			// Test the JS compiled as expected (compare to the .json file)
 			actualJSON, err := json.MarshalIndent(conf, "", "  ")
@@ -150,6 +108,131 @@ func TestParsedFiles(t *testing.T) {
 	}
 }
 
+// filePosColumn matches the column of a filepos, which depends on the
+// JavaScript engine.
+var filePosColumn = regexp.MustCompile(`("filepos": "\[line:\d+):\d+\]"`)
+
+// TestParsedFilesGoja runs the parse tests with goja: the configurations
+// must compile as with otto.
+func TestParsedFilesGoja(t *testing.T) {
+	defer func(engine string) { Engine = engine }(Engine)
+	Engine = EngineGoja
+
+	files, err := os.ReadDir(testDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, f := range files {
+		name := f.Name()
+
+		// run all js files that start with a number. Skip others.
+		if filepath.Ext(name) != ".js" || !unicode.IsNumber(rune(name[0])) {
+			continue
+		}
+		t.Run(name, func(t *testing.T) {
+			conf := compileParseTest(t, name)
+
+			actualJSON, err := json.MarshalIndent(conf, "", "  ")
+			if err != nil {
+				t.Fatal(err)
+			}
+			expectedFile := filepath.Join(testDir, name[:len(name)-3]+".json")
+			expectedJSON, err := os.ReadFile(expectedFile)
+			if err != nil {
+				t.Fatal(err)
+			}
+			es := filePosColumn.ReplaceAllString(string(expectedJSON), "$1]\"")
+			as := filePosColumn.ReplaceAllString(string(actualJSON), "$1]\"")
+			testifyrequire.JSONEqf(t, es, as, "EXPECTING %q = \n```\n%s\n```", expectedFile, as)
+		})
+	}
+}
+
+func TestGojaModernSyntax(t *testing.T) {
+	defer func(engine string) { Engine = engine }(Engine)
+	Engine = EngineGoja
+
+	// let/const, arrow functions, template literals, destructuring, spread,
+	// classes and ??.
+	script := "const REG = NewRegistrar(\"none\");\n" +
+		"const hosts = { www: 1, mail: 2 };\n" +
+		"const records = Object.entries(hosts).map(([name, n]) => A(name, `10.0.0.${n}`));\n" +
+		"class Zone {\n" +
+		"  constructor(name, ...extra) { this.name = name; this.extra = extra; }\n" +
+		"  define() { D(this.name, REG, ...records, ...this.extra); }\n" +
+		"}\n" +
+		"const { ttl = 300 } = {};\n" +
+		"new Zone(\"example.com\", TXT(\"@\", `ttl=${ttl ?? 0}`)).define();\n"
+	conf, err := ExecuteJavascriptString([]byte(script), false, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(conf.Domains) != 1 {
+		t.Fatalf("got %d domains, want 1", len(conf.Domains))
+	}
+	var got []string
+	for _, rc := range conf.Domains[0].Records {
+		got = append(got, rc.Name+" "+rc.Type+" "+rc.GetTargetField())
+	}
+	want := []string{"www A 10.0.0.1", "mail A 10.0.0.2", "@ TXT ttl=300"}
+	testifyrequire.Equal(t, want, got)
+
+	Engine = EngineOtto
+	if _, err := ExecuteJavascriptString([]byte("const f = () => 1;"), false, nil); err == nil {
+		t.Error("otto accepted an arrow function")
+	}
+}
+
+// compileParseTest runs the parse test name, and returns its normalized
+// configuration.
+func compileParseTest(t *testing.T, name string) *models.DNSConfig {
+	t.Helper()
+
+	// Compile the .js file:
+	conf, err := ExecuteJavaScript(string(filepath.Join(testDir, name)), true, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	errs := normalize.ValidateAndNormalizeConfig(conf)
+	if len(errs) != 0 {
+		t.Fatal(errs[0])
+	}
+
+	for _, dc := range conf.Domains {
+		// fmt.Printf("DEBUG: PrettySort: domain=%q #rec=%d\n", dc.Name, len(dc.Records))
+		// fmt.Printf("DEBUG: records = %d %v\n", len(dc.Records), dc.Records)
+		ps := prettyzone.PrettySort(dc.Records, dc.Name, 0, nil)
+		dc.Records = ps.Records
+		if len(dc.Records) == 0 {
+			dc.Records = models.Records{}
+		}
+	}
+
+	// Initialize any DNS providers mentioned.
+	for _, dProv := range conf.DNSProviders {
+		pcfg := map[string]string{}
+
+		if dProv.Type == "-" {
+			// Pretend any "look up provider type in creds.json" results
+			// in a provider type that actually exists.
+			dProv.Type = "CLOUDFLAREAPI"
+		}
+
+		// Fake out any provider's validation tests.
+		switch dProv.Type {
+		case "CLOUDFLAREAPI":
+			pcfg["apitoken"] = "fake"
+		default:
+		}
+		_, err := providers.CreateDNSProvider(dProv.Type, pcfg, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	return conf
+}
+
 func TestErrors(t *testing.T) {
 	tests := []struct{ desc, text string }{
 		{"old dsp style", `D("foo.com","reg","dsp")`},
@@ -164,6 +247,14 @@ func TestErrors(t *testing.T) {
 		{"Dup domains", `D("example.org", "reg"); D("example.org", "reg")`},
 		{"Bad NAMESERVER", `D("example.com","reg", NAMESERVER("@","ns1.foo.com."))`},
 		{"Bad Hash function", `D(HASH("123", "abc"),"reg")`},
+		{"MTASTS_BUILDER bad mode", `D("foo.com","reg",MTASTS_BUILDER({mode: "strict", mx: ["mx.foo.com"]}))`},
+		{"MTASTS_BUILDER without mx", `D("foo.com","reg",MTASTS_BUILDER({mode: "enforce"}))`},
+		{"MTASTS_BUILDER bad mx", `D("foo.com","reg",MTASTS_BUILDER({mx: ["mx foo.com"]}))`},
+		{"TLSRPT_BUILDER bad rua", `D("foo.com","reg",TLSRPT_BUILDER({rua: ["tlsrpt@foo.com"]}))`},
+		{"CHANGE_LIMIT string", `D("foo.com","reg",CHANGE_LIMIT("10"))`},
+		{"CHANGE_LIMIT fraction", `D("foo.com","reg",CHANGE_LIMIT(0, 12.5))`},
+		{"CHANGE_LIMIT below -1", `D("foo.com","reg",CHANGE_LIMIT(-2))`},
+		{"BIMI_BUILDER not svg", `D("foo.com","reg",BIMI_BUILDER({location: "https://foo.com/logo.png"}))`},
 	}
 	for _, tst := range tests {
 		t.Run(tst.desc, func(t *testing.T) {
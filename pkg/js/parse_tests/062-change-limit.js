@@ -0,0 +1,9 @@
+// Test CHANGE_LIMIT domain modifier
+D("limit-deletes.com", "none", CHANGE_LIMIT(10));
+
+D("limit-percent.com", "none", CHANGE_LIMIT(-1, 25));
+
+D("limit-both.com", "none",
+    CHANGE_LIMIT(0, 12),
+    A("www", "1.2.3.4")
+);
@@ -0,0 +1,7 @@
+D("foo.com", "none",
+    OWNERSHIP_REGISTRY("team-a"),
+    A("www", "1.2.3.4")
+);
+D("bar.com", "none",
+    OWNERSHIP_REGISTRY("team-b", "_owner-")
+);
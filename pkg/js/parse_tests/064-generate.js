@@ -0,0 +1,7 @@
+D("foo.com", "none",
+    GENERATE("host-$", 1, 3, A, "10.0.0.$"),
+    GENERATE("h${10,3,x}", 0, 4, 2, CNAME, "host-$", TTL(60)),
+    GENERATE("${0,3,n}.v6", 26, 26, PTR, "v6-$.foo.com."),
+    GENERATE("mx$", 1, 1, MX, 10, "mail$"),
+    GENERATE("txt$", 1, 1, TXT, "price \\$$ or $$$")
+);
@@ -0,0 +1,24 @@
+D("foo.com", "none",
+    SPF_BUILDER({
+        label: "@",
+        overflow: "_spf%d",
+        parts: [
+            "v=spf1",
+            "ip4:198.51.100.0/25",
+            "ip4:198.51.100.128/25",
+            "~all"
+        ],
+        optimize: true
+    }),
+    SPF_BUILDER({
+        label: "aggregated",
+        raw: "",
+        parts: [
+            "v=spf1",
+            "ip4:203.0.113.0/24",
+            "ip4:203.0.113.7",
+            "-all"
+        ],
+        aggregate: true
+    })
+);
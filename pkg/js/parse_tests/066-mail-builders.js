@@ -0,0 +1,29 @@
+var REG = NewRegistrar("Third-Party");
+var CF = NewDnsProvider("Cloudflare", "CLOUDFLAREAPI");
+D("foo.com", REG, DnsProvider(CF),
+    MX("@", 10, "mx1.foo.com."),
+    MX("@", 20, "mx2.foo.com."),
+    MTASTS_BUILDER({
+        mode: "enforce",
+        mx: ["mx1.foo.com", "mx2.foo.com."],
+        maxAge: "1d",
+    }),
+    MTASTS_BUILDER({
+        label: "test",
+        mode: "none",
+        id: "20260101",
+        ttl: 300,
+    }),
+    TLSRPT_BUILDER({
+        rua: ["mailto:tlsrpt@foo.com", "https://reports.foo.com/tlsrpt"],
+    }),
+    BIMI_BUILDER({
+        location: "https://foo.com/logo.svg",
+        authority: "https://foo.com/vmc.pem",
+    }),
+    BIMI_BUILDER({
+        label: "marketing",
+        selector: "brand",
+        location: "",
+    })
+);
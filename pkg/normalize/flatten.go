@@ -8,6 +8,7 @@ import (
 	"strings"
 
 	"github.com/DNSControl/dnscontrol/v4/models"
+	"github.com/DNSControl/dnscontrol/v4/pkg/printer"
 	"github.com/DNSControl/dnscontrol/v4/pkg/spflib"
 )
 
@@ -33,7 +34,9 @@ func flattenSPFs(cfg *models.DNSConfig) []error {
 		for _, txt := range txtRecords {
 			var rec *spflib.SPFRecord
 			txtTarget := txt.GetTargetTXTJoined()
-			if txt.Metadata["flatten"] != "" || txt.Metadata["split"] != "" {
+			_, optimize := txt.Metadata["optimize"]
+			_, aggregate := txt.Metadata["aggregate"]
+			if txt.Metadata["flatten"] != "" || txt.Metadata["split"] != "" || optimize || aggregate {
 				if cache == nil {
 					cache, err = spflib.NewCache("spfcache.json")
 					if err != nil {
@@ -46,41 +49,64 @@ func flattenSPFs(cfg *models.DNSConfig) []error {
 					continue
 				}
 			}
-			if flatten, ok := txt.Metadata["flatten"]; ok && strings.HasPrefix(txtTarget, "v=spf1") {
-				rec = rec.Flatten(flatten)
-				err = txt.SetTargetTXT(rec.TXT())
+
+			overhead1 := 0
+			// overhead1: The first segment of the SPF record
+			// needs to be shorter than the others due to the overhead of
+			// other (non-SPF) txt records.  If there are (for example) 50
+			// bytes of txt records also on this domain record, setting
+			// overhead1=50 reduces the maxLen by 50. It only affects the
+			// first part of the split.
+			if oh, ok := txt.Metadata["overhead1"]; ok {
+				i, err := strconv.Atoi(oh)
 				if err != nil {
-					errs = append(errs, err)
-					continue
+					errs = append(errs, Warning{fmt.Errorf("split overhead1 %q is not an int", oh)})
 				}
+				overhead1 = i
 			}
-			// now split if needed
-			if split, ok := txt.Metadata["split"]; ok {
-				overhead1 := 0
-				// overhead1: The first segment of the SPF record
-				// needs to be shorter than the others due to the overhead of
-				// other (non-SPF) txt records.  If there are (for example) 50
-				// bytes of txt records also on this domain record, setting
-				// overhead1=50 reduces the maxLen by 50. It only affects the
-				// first part of the split.
-				if oh, ok := txt.Metadata["overhead1"]; ok {
-					i, err := strconv.Atoi(oh)
-					if err != nil {
-						errs = append(errs, Warning{fmt.Errorf("split overhead1 %q is not an int", oh)})
-					}
-					overhead1 = i
+
+			// Default txtMaxSize will not result in multiple TXT strings
+			txtMaxSize := 255
+			if oh, ok := txt.Metadata["txtMaxSize"]; ok {
+				i, err := strconv.Atoi(oh)
+				if err != nil {
+					errs = append(errs, Warning{fmt.Errorf("split txtMaxSize %q is not an int", oh)})
 				}
+				txtMaxSize = i
+			}
 
-				// Default txtMaxSize will not result in multiple TXT strings
-				txtMaxSize := 255
-				if oh, ok := txt.Metadata["txtMaxSize"]; ok {
-					i, err := strconv.Atoi(oh)
+			flatten, ok := txt.Metadata["flatten"]
+			if (ok || optimize || aggregate) && strings.HasPrefix(txtTarget, "v=spf1") {
+				if optimize {
+					// Flatten the includes needed to stay within the
+					// lookup limit, with the fewest records.
+					pattern := ""
+					if split := txt.Metadata["split"]; strings.Contains(split, "%d") {
+						pattern = split + "." + domain.Name
+					}
+					res, err := rec.Optimize(flatten, pattern, overhead1, txtMaxSize)
 					if err != nil {
-						errs = append(errs, Warning{fmt.Errorf("split txtMaxSize %q is not an int", oh)})
+						errs = append(errs, Warning{fmt.Errorf("SPF record %s: %w", txt.GetLabelFQDN(), err)})
+					}
+					printer.Printf("SPF record %s: %d lookups before optimization, %d after (%d TXT records, flattened: %q)\n",
+						txt.GetLabelFQDN(), res.LookupsBefore, res.LookupsAfter, res.Records, res.Flatten)
+					rec = res.Record
+				} else {
+					if ok {
+						rec = rec.Flatten(flatten)
+					}
+					if aggregate {
+						rec = rec.Aggregate()
 					}
-					txtMaxSize = i
 				}
-
+				err = txt.SetTargetTXT(rec.TXT())
+				if err != nil {
+					errs = append(errs, err)
+					continue
+				}
+			}
+			// now split if needed
+			if split, ok := txt.Metadata["split"]; ok {
 				if !strings.Contains(split, "%d") {
 					errs = append(errs, Warning{fmt.Errorf("split format `%s` in `%s` is not proper format (missing %%d)", split, txt.GetLabelFQDN())})
 					continue
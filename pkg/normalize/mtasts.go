@@ -0,0 +1,37 @@
+package normalize
+
+import (
+	"fmt"
+
+	"github.com/DNSControl/dnscontrol/v4/models"
+)
+
+// collectMTASTSPolicies moves the policy files of MTASTS_BUILDER() from the
+// metadata of the records to config.MTASTSPolicies, so that push can write
+// them.
+func collectMTASTSPolicies(cfg *models.DNSConfig) []error {
+	var errs []error
+	for _, domain := range cfg.Domains {
+		for _, rec := range domain.Records {
+			file, ok := rec.Metadata["mtasts_policy_file"]
+			if !ok {
+				continue
+			}
+			policy := rec.Metadata["mtasts_policy"]
+			delete(rec.Metadata, "mtasts_policy_file")
+			delete(rec.Metadata, "mtasts_policy")
+
+			if old, ok := cfg.MTASTSPolicies[file]; ok {
+				if old != policy {
+					errs = append(errs, fmt.Errorf("MTASTS_BUILDER of %s: %s is the policy file of another, different policy", domain.Name, file))
+				}
+				continue
+			}
+			if cfg.MTASTSPolicies == nil {
+				cfg.MTASTSPolicies = map[string]string{}
+			}
+			cfg.MTASTSPolicies[file] = policy
+		}
+	}
+	return errs
+}
@@ -0,0 +1,40 @@
+package normalize
+
+import (
+	"testing"
+
+	"github.com/DNSControl/dnscontrol/v4/models"
+)
+
+func TestCollectMTASTSPolicies(t *testing.T) {
+	file := "www/mta-sts.txt"
+	policy := "version: STSv1\r\nmode: testing\r\nmx: mx.example.com\r\nmax_age: 604800\r\n"
+	rec := func(policy string) *models.RecordConfig {
+		return &models.RecordConfig{Type: "TXT", Metadata: map[string]string{
+			"mtasts_policy_file": file,
+			"mtasts_policy":      policy,
+		}}
+	}
+	cfg := &models.DNSConfig{Domains: []*models.DomainConfig{
+		{Name: "example.com", Records: models.Records{rec(policy)}},
+		{Name: "example.net", Records: models.Records{rec(policy)}}, // Same file, same policy.
+	}}
+
+	if errs := collectMTASTSPolicies(cfg); len(errs) != 0 {
+		t.Fatal(errs)
+	}
+	if got := cfg.MTASTSPolicies[file]; len(cfg.MTASTSPolicies) != 1 || got != policy {
+		t.Errorf("the policies are %q, want %q: %q", cfg.MTASTSPolicies, file, policy)
+	}
+	if len(cfg.Domains[0].Records[0].Metadata) != 0 {
+		t.Errorf("the metadata %v was not removed", cfg.Domains[0].Records[0].Metadata)
+	}
+
+	cfg = &models.DNSConfig{Domains: []*models.DomainConfig{
+		{Name: "example.com", Records: models.Records{rec(policy)}},
+		{Name: "example.net", Records: models.Records{rec("version: STSv1\r\nmode: none\r\nmax_age: 86400\r\n")}},
+	}}
+	if errs := collectMTASTSPolicies(cfg); len(errs) != 1 {
+		t.Errorf("two policies in one file gave %v, want an error", errs)
+	}
+}
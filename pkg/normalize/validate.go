@@ -522,6 +522,11 @@ func ValidateAndNormalizeConfig(config *models.DNSConfig) (errs []error) {
 		errs = append(errs, ers...)
 	}
 
+	// MTA-STS policy files
+	if ers := collectMTASTSPolicies(config); len(ers) > 0 {
+		errs = append(errs, ers...)
+	}
+
 	// Process IMPORT_TRANSFORM
 	for _, domain := range config.Domains {
 		for _, rec := range domain.Records {
@@ -0,0 +1,207 @@
+// Package nsverify checks that authoritative nameservers serve the records
+// that were pushed to a DNS provider.
+//
+// A provider's API accepting a change does not mean the change is being
+// served. Some providers take a while to propagate changes to all their
+// nameservers, and a few silently drop records they do not like.  Wait
+// polls each nameserver until it serves the expected records or the time
+// runs out.
+package nsverify
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"net"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/DNSControl/dnscontrol/v4/models"
+	dnsv1 "github.com/miekg/dns"
+)
+
+// QueryTimeout is how long to wait for a nameserver to answer a query.
+var QueryTimeout = 3 * time.Second
+
+// RRset is a name/type and the records a nameserver should return for it.
+type RRset struct {
+	Name string         // FQDN, without the trailing dot.
+	Type string         // "A", "MX", etc.
+	Want models.Records // Empty if the name/type should not exist.
+}
+
+// Mismatch describes an RRset that a nameserver did not serve as expected.
+type Mismatch struct {
+	Nameserver string // As passed to Wait.
+	Address    string // The address that was queried.
+	Name       string
+	Type       string
+	Got        []string
+	Want       []string
+	Err        error // Set if the query failed.
+}
+
+func (m Mismatch) String() string {
+	if m.Address == "" {
+		return fmt.Sprintf("%s: %s", m.Nameserver, m.Err) // The nameserver's address could not be found.
+	}
+	if m.Err != nil {
+		return fmt.Sprintf("%s (%s): %s %s: %s", m.Nameserver, m.Address, m.Name, m.Type, m.Err)
+	}
+	return fmt.Sprintf("%s (%s): %s %s: got %s, want %s", m.Nameserver, m.Address, m.Name, m.Type, listOrNone(m.Got), listOrNone(m.Want))
+}
+
+func listOrNone(l []string) string {
+	if len(l) == 0 {
+		return "(none)"
+	}
+	return "[" + strings.Join(l, ", ") + "]"
+}
+
+// Verifiable returns true if records of type rtype can be verified. Pseudo
+// types (ALIAS, CF_REDIRECT, etc.) are served as something else and SOA
+// serial numbers are changed by the providers.
+func Verifiable(rtype string) bool {
+	_, ok := dnsv1.StringToType[rtype]
+	return ok && rtype != "SOA"
+}
+
+// Wait queries each nameserver for each RRset until they all match or ctx is
+// done. It waits interval between attempts. A nameserver is a hostname (all
+// of its addresses are queried) or an address with a port ("192.0.2.1:53").
+// The mismatches found by the last attempt are returned, or nil if everything
+// matched.
+func Wait(ctx context.Context, nameservers []string, rrsets []RRset, interval time.Duration) []Mismatch {
+	type target struct{ ns, addr string }
+
+	// Convert the wanted records once, since ToRR() is not safe for
+	// concurrent use.
+	wants := map[string][]string{}
+	for _, rs := range rrsets {
+		wants[rs.Name+" "+rs.Type] = wantRdata(rs.Want)
+	}
+
+	// The RRsets that each target has not yet served correctly.
+	pending := map[target][]RRset{}
+	var failed []Mismatch
+	for _, ns := range nameservers {
+		addrs, err := resolve(ctx, ns)
+		if err != nil {
+			failed = append(failed, Mismatch{Nameserver: ns, Err: err})
+			continue
+		}
+		for _, a := range addrs {
+			pending[target{ns, a}] = rrsets
+		}
+	}
+
+	for {
+		// Query all the targets concurrently.
+		var mu sync.Mutex
+		var wg sync.WaitGroup
+		var mismatches []Mismatch
+		next := map[target][]RRset{}
+		for t, sets := range pending {
+			wg.Go(func() {
+				for _, rs := range sets {
+					if mm, ok := check(ctx, t.ns, t.addr, rs, wants[rs.Name+" "+rs.Type]); !ok {
+						mu.Lock()
+						next[t] = append(next[t], rs)
+						mismatches = append(mismatches, mm)
+						mu.Unlock()
+					}
+				}
+			})
+		}
+		wg.Wait()
+
+		if len(next) == 0 {
+			return failed
+		}
+		select {
+		case <-ctx.Done():
+			slices.SortFunc(mismatches, func(a, b Mismatch) int {
+				return cmp.Or(
+					strings.Compare(a.Nameserver, b.Nameserver),
+					strings.Compare(a.Address, b.Address),
+					strings.Compare(a.Name, b.Name),
+					strings.Compare(a.Type, b.Type),
+				)
+			})
+			return append(failed, mismatches...)
+		case <-time.After(interval):
+		}
+		pending = next // Only retry what failed.
+	}
+}
+
+// resolve returns the addresses (with port) to query for a nameserver.
+func resolve(ctx context.Context, ns string) ([]string, error) {
+	if _, _, err := net.SplitHostPort(ns); err == nil {
+		return []string{ns}, nil
+	}
+	ips, err := net.DefaultResolver.LookupHost(ctx, strings.TrimSuffix(ns, "."))
+	if err != nil {
+		return nil, err
+	}
+	addrs := make([]string, len(ips))
+	for i, ip := range ips {
+		addrs[i] = net.JoinHostPort(ip, "53")
+	}
+	return addrs, nil
+}
+
+// wantRdata returns the sorted rdata of recs.
+func wantRdata(recs models.Records) []string {
+	var want []string
+	for _, rc := range recs {
+		want = append(want, rdata(rc.ToRR()))
+	}
+	slices.Sort(want)
+	return slices.Compact(want)
+}
+
+// check queries addr for rs and reports if the answer matches want.
+func check(ctx context.Context, ns, addr string, rs RRset, want []string) (Mismatch, bool) {
+	mm := Mismatch{Nameserver: ns, Address: addr, Name: rs.Name, Type: rs.Type, Want: want}
+
+	qtype := dnsv1.StringToType[rs.Type]
+	req := new(dnsv1.Msg)
+	req.SetQuestion(dnsv1.Fqdn(rs.Name), qtype)
+	req.RecursionDesired = false
+
+	c := &dnsv1.Client{Timeout: QueryTimeout}
+	resp, _, err := c.ExchangeContext(ctx, req, addr)
+	if err == nil && resp.Truncated {
+		c.Net = "tcp"
+		resp, _, err = c.ExchangeContext(ctx, req, addr)
+	}
+	if err != nil {
+		mm.Err = err
+		return mm, false
+	}
+	if resp.Rcode != dnsv1.RcodeSuccess && resp.Rcode != dnsv1.RcodeNameError {
+		mm.Err = fmt.Errorf("rcode %s", dnsv1.RcodeToString[resp.Rcode])
+		return mm, false
+	}
+
+	for _, rr := range resp.Answer {
+		if rr.Header().Rrtype == qtype && strings.EqualFold(rr.Header().Name, req.Question[0].Name) {
+			mm.Got = append(mm.Got, rdata(rr))
+		}
+	}
+	slices.Sort(mm.Got)
+	mm.Got = slices.Compact(mm.Got)
+	return mm, slices.Equal(mm.Got, mm.Want)
+}
+
+// rdata returns the data part of rr in a form that can be compared.  TXT
+// strings are joined since providers split long TXT records differently.
+func rdata(rr dnsv1.RR) string {
+	if txt, ok := rr.(*dnsv1.TXT); ok {
+		return fmt.Sprintf("%q", strings.Join(txt.Txt, ""))
+	}
+	return strings.ToLower(strings.TrimPrefix(rr.String(), rr.Header().String()))
+}
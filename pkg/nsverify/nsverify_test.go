@@ -0,0 +1,103 @@
+package nsverify
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/DNSControl/dnscontrol/v4/models"
+	"github.com/DNSControl/dnscontrol/v4/pkg/zoneserver"
+	dnsv1 "github.com/miekg/dns"
+)
+
+func makeRec(label, rtype, content string) *models.RecordConfig {
+	r := &models.RecordConfig{TTL: 300}
+	r.SetLabel(label, "example.com")
+	if err := r.PopulateFromString(rtype, content, "example.com"); err != nil {
+		panic(err)
+	}
+	return r
+}
+
+// startServer serves recs as example.com on a local UDP port and returns its address.
+func startServer(t *testing.T, recs ...*models.RecordConfig) string {
+	t.Helper()
+	dc := models.MustNewDomainConfig("example.com")
+	dc.Records = recs
+	handler, _ := zoneserver.New([]*models.DomainConfig{dc}, 1)
+
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	started := make(chan struct{})
+	srv := &dnsv1.Server{PacketConn: pc, Handler: handler, NotifyStartedFunc: func() { close(started) }}
+	go func() { _ = srv.ActivateAndServe() }()
+	<-started
+	t.Cleanup(func() { _ = srv.Shutdown() })
+	return pc.LocalAddr().String()
+}
+
+func TestWait(t *testing.T) {
+	addr := startServer(t,
+		makeRec("www", "A", "1.2.3.4"),
+		makeRec("www", "A", "1.2.3.5"),
+		makeRec("@", "TXT", "v=spf1 -all"),
+		makeRec("@", "MX", "10 mail.example.com."),
+	)
+
+	tests := []struct {
+		name   string
+		rrsets []RRset
+		want   int
+	}{
+		{
+			name: "match",
+			rrsets: []RRset{
+				{Name: "www.example.com", Type: "A", Want: models.Records{makeRec("www", "A", "1.2.3.5"), makeRec("www", "A", "1.2.3.4")}},
+				{Name: "example.com", Type: "TXT", Want: models.Records{makeRec("@", "TXT", "v=spf1 -all")}},
+				{Name: "example.com", Type: "MX", Want: models.Records{makeRec("@", "MX", "10 MAIL.example.com.")}},
+			},
+			want: 0,
+		},
+		{
+			name:   "deleted",
+			rrsets: []RRset{{Name: "old.example.com", Type: "A"}, {Name: "www.example.com", Type: "AAAA"}},
+			want:   0,
+		},
+		{
+			name:   "missing",
+			rrsets: []RRset{{Name: "new.example.com", Type: "A", Want: models.Records{makeRec("new", "A", "1.1.1.1")}}},
+			want:   1,
+		},
+		{
+			name:   "notDeleted",
+			rrsets: []RRset{{Name: "www.example.com", Type: "A"}},
+			want:   1,
+		},
+		{
+			name:   "partial",
+			rrsets: []RRset{{Name: "www.example.com", Type: "A", Want: models.Records{makeRec("www", "A", "1.2.3.4")}}},
+			want:   1,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, cancel := context.WithTimeout(context.Background(), 300*time.Millisecond)
+			defer cancel()
+			got := Wait(ctx, []string{addr}, tt.rrsets, 100*time.Millisecond)
+			if len(got) != tt.want {
+				t.Errorf("Wait() = %v, want %d mismatches", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestVerifiable(t *testing.T) {
+	for rtype, want := range map[string]bool{"A": true, "MX": true, "SOA": false, "ALIAS": false, "CF_REDIRECT": false} {
+		if got := Verifiable(rtype); got != want {
+			t.Errorf("Verifiable(%q) = %v, want %v", rtype, got, want)
+		}
+	}
+}
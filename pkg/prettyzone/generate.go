@@ -0,0 +1,225 @@
+package prettyzone
+
+// $GENERATE: the templates of BIND, and the collapsing of the records of a
+// GENERATE() back into one $GENERATE line.
+
+import (
+	"fmt"
+	"net/netip"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/DNSControl/dnscontrol/v4/models"
+)
+
+// MetaGenerate is the record metadata that GENERATE() sets on the records it
+// creates: "start-stop/step lhs rhs", the range and the templates of the
+// name and the target.
+const MetaGenerate = "generate"
+
+// generateTypes are the types whose records can be written as $GENERATE:
+// their target is a single field.
+var generateTypes = map[string]bool{"A": true, "AAAA": true, "CNAME": true, "DNAME": true, "NS": true, "PTR": true}
+
+var (
+	generateRange    = regexp.MustCompile(`^(\d+)-(\d+)(?:/(\d+))?$`)
+	generateModifier = regexp.MustCompile(`\\\$|\$\$|\$(\{([+-]?\d+)(?:,(\d+)(?:,([doxXnN]))?)?\})?`)
+)
+
+// ParseGenerateRange parses the range of $GENERATE: start-stop[/step].
+func ParseGenerateRange(s string) (start, stop, step int, err error) {
+	m := generateRange.FindStringSubmatch(s)
+	if m == nil {
+		return 0, 0, 0, fmt.Errorf("invalid $GENERATE range %q", s)
+	}
+	start, _ = strconv.Atoi(m[1])
+	stop, _ = strconv.Atoi(m[2])
+	step = 1
+	if m[3] != "" {
+		step, _ = strconv.Atoi(m[3])
+	}
+	if start > stop || step < 1 || (stop-start)/step > 65535 {
+		return 0, 0, 0, fmt.Errorf("invalid $GENERATE range %q", s)
+	}
+	return start, stop, step, nil
+}
+
+// GenerateSubst returns the template of $GENERATE with $ replaced by i, as
+// BIND does: ${offset,width,base} adds offset to i and formats it with at
+// least width digits in base d, o, x, X, or n and N (nibbles, for
+// ip6.arpa). \$ and $$ are a literal $.
+func GenerateSubst(tmpl string, i int) (string, error) {
+	var err error
+	s := generateModifier.ReplaceAllStringFunc(tmpl, func(mod string) string {
+		if mod == `\$` || mod == "$$" {
+			return "$"
+		}
+		sm := generateModifier.FindStringSubmatch(mod)
+		offset, _ := strconv.Atoi(sm[2])
+		width, _ := strconv.Atoi(sm[3])
+		v := i + offset
+		if v < 0 {
+			err = fmt.Errorf("%s: %d is negative", mod, v)
+			return ""
+		}
+		switch sm[4] {
+		case "o":
+			return fmt.Sprintf("%0*o", width, v)
+		case "x":
+			return fmt.Sprintf("%0*x", width, v)
+		case "X":
+			return fmt.Sprintf("%0*X", width, v)
+		case "n":
+			return nibbles(v, width, "0123456789abcdef")
+		case "N":
+			return nibbles(v, width, "0123456789ABCDEF")
+		default:
+			return fmt.Sprintf("%0*d", width, v)
+		}
+	})
+	return s, err
+}
+
+// nibbles returns v as hex digits separated by dots, the least significant
+// first. Like BIND, width counts the dots.
+func nibbles(v, width int, digits string) string {
+	var b strings.Builder
+	for {
+		b.WriteByte(digits[v&0xf])
+		v >>= 4
+		if width > 0 {
+			width--
+		}
+		if width > 0 || v != 0 {
+			b.WriteByte('.')
+			if width > 0 {
+				width--
+			}
+		}
+		if v == 0 && width == 0 {
+			return b.String()
+		}
+	}
+}
+
+// usesNibbles returns true if the template uses the base n or N.
+func usesNibbles(tmpl string) bool {
+	for _, sm := range generateModifier.FindAllStringSubmatch(tmpl, -1) {
+		if sm[4] == "n" || sm[4] == "N" {
+			return true
+		}
+	}
+	return false
+}
+
+// generateLines returns the $GENERATE lines that replace the records of
+// each GENERATE() (see MetaGenerate), keyed by the first of its records in
+// z.Records. The other records of a GENERATE() map to "". The records of a
+// GENERATE() are kept as is if they can't be written as $GENERATE (for
+// example, if some of them changed, or the type has several fields).
+func (z *ZoneGenData) generateLines() map[*models.RecordConfig]string {
+	type group struct {
+		spec    string
+		records []*models.RecordConfig
+	}
+	var groups []*group
+	byKey := map[string]*group{}
+	for _, rr := range z.Records {
+		spec, ok := rr.Metadata[MetaGenerate]
+		if !ok || !generateTypes[rr.Type] {
+			continue
+		}
+		key := rr.Type + " " + spec
+		g := byKey[key]
+		if g == nil {
+			g = &group{spec: spec}
+			byKey[key] = g
+			groups = append(groups, g)
+		}
+		g.records = append(g.records, rr)
+	}
+
+	lines := map[*models.RecordConfig]string{}
+	for _, g := range groups {
+		line, ok := z.generateLine(g.spec, g.records)
+		if !ok {
+			continue
+		}
+		for _, rr := range g.records {
+			lines[rr] = ""
+		}
+		lines[g.records[0]] = line
+	}
+	return lines
+}
+
+// generateLine returns the $GENERATE line of spec, if it generates exactly
+// records.
+func (z *ZoneGenData) generateLine(spec string, records []*models.RecordConfig) (string, bool) {
+	fields := strings.Fields(spec)
+	if len(fields) != 3 || strings.ContainsAny(fields[1]+fields[2], `;"()\`) || usesNibbles(fields[1]) || usesNibbles(fields[2]) {
+		// The zone file parser of the BIND provider doesn't support
+		// nibbles.
+		return "", false
+	}
+	start, stop, step, err := ParseGenerateRange(fields[0])
+	if err != nil || (stop-start)/step+1 != len(records) {
+		return "", false
+	}
+	rtype, ttl := records[0].Type, records[0].TTL
+
+	// Each name must be generated once.
+	want := map[string]bool{}
+	for i := start; i <= stop; i += step {
+		name, err := GenerateSubst(fields[1], i)
+		if err != nil {
+			return "", false
+		}
+		target, err := GenerateSubst(fields[2], i)
+		if err != nil {
+			return "", false
+		}
+		key := strings.ToLower(name) + " " + z.generateTarget(rtype, target)
+		if want[key] {
+			return "", false
+		}
+		want[key] = true
+	}
+	for _, rr := range records {
+		if rr.Type != rtype || rr.TTL != ttl {
+			return "", false
+		}
+		key := strings.ToLower(rr.Name) + " " + z.generateTarget(rtype, rr.GetTargetField())
+		if !want[key] {
+			return "", false
+		}
+		delete(want, key)
+	}
+
+	rng := fmt.Sprintf("%d-%d", start, stop)
+	if step != 1 {
+		rng += fmt.Sprintf("/%d", step)
+	}
+	// The TTL is always written: zone file parsers don't agree on the TTL
+	// of $GENERATE without one.
+	return fmt.Sprintf("$GENERATE %s %s %d IN %s %s", rng, fields[1], ttl, rtype, fields[2]), true
+}
+
+// generateTarget returns the target in a form that can be compared: IP
+// addresses are normalized, names are FQDNs in lower case.
+func (z *ZoneGenData) generateTarget(rtype, target string) string {
+	if rtype == "A" || rtype == "AAAA" {
+		if ip, err := netip.ParseAddr(target); err == nil {
+			return ip.String()
+		}
+		return target
+	}
+	switch {
+	case target == "@":
+		target = z.Origin
+	case !strings.HasSuffix(target, "."):
+		target += "." + z.Origin
+	}
+	return strings.ToLower(target)
+}
@@ -0,0 +1,117 @@
+package prettyzone
+
+import (
+	"bytes"
+	"fmt"
+	"net/netip"
+	"strings"
+	"testing"
+
+	"github.com/DNSControl/dnscontrol/v4/models"
+	dnsv1 "github.com/miekg/dns"
+)
+
+func TestParseGenerateRange(t *testing.T) {
+	for s, want := range map[string][3]int{"1-10": {1, 10, 1}, "0-254/2": {0, 254, 2}, "5-5": {5, 5, 1}} {
+		start, stop, step, err := ParseGenerateRange(s)
+		if err != nil || [3]int{start, stop, step} != want {
+			t.Errorf("ParseGenerateRange(%q) = %d, %d, %d, %v, want %v", s, start, stop, step, err, want)
+		}
+	}
+	for _, s := range []string{"", "10", "10-1", "1-10/0", "-1-10", "0-65536", "1-10/x"} {
+		if _, _, _, err := ParseGenerateRange(s); err == nil {
+			t.Errorf("ParseGenerateRange(%q) was accepted", s)
+		}
+	}
+}
+
+func TestGenerateSubst(t *testing.T) {
+	tests := []struct {
+		tmpl string
+		i    int
+		want string
+	}{
+		{"host-$", 7, "host-7"},
+		{"host-$$", 7, "host-$"},
+		{`price-\$-$`, 7, "price-$-7"},
+		{"host${1}", 7, "host8"},
+		{"host${-2,3}", 7, "host005"},
+		{"host${0,4,x}", 255, "host00ff"},
+		{"host${0,0,X}", 255, "hostFF"},
+		{"host${0,0,o}", 8, "host10"},
+		{"${0,0,n}.ip6", 0x1a, "a.1.ip6"},
+		{"${0,3,n}.ip6", 0x1a, "a.1.ip6"},
+		{"${0,7,N}.ip6", 0x1a, "A.1.0.0.ip6"},
+		{"${0,3,n}.ip6", 0, "0.0.ip6"},
+	}
+	for _, tt := range tests {
+		if got, err := GenerateSubst(tt.tmpl, tt.i); err != nil || got != tt.want {
+			t.Errorf("GenerateSubst(%q, %d) = %q, %v, want %q", tt.tmpl, tt.i, got, err, tt.want)
+		}
+	}
+	if _, err := GenerateSubst("host${-8}", 7); err == nil {
+		t.Error("GenerateSubst accepted a negative value")
+	}
+}
+
+// generated returns the records of GENERATE(lhs, start, stop, rtype, rhs).
+func generated(t *testing.T, lhs string, start, stop int, rtype, rhs string) models.Records {
+	t.Helper()
+	var recs models.Records
+	for i := start; i <= stop; i++ {
+		name, _ := GenerateSubst(lhs, i)
+		target, _ := GenerateSubst(rhs, i)
+		zp := dnsv1.NewZoneParser(strings.NewReader(fmt.Sprintf("%s 300 IN %s %s", name, rtype, target)), "bosun.org.", "")
+		rr, _ := zp.Next()
+		if err := zp.Err(); err != nil {
+			t.Fatal(err)
+		}
+		rcs, err := rrstoRCs([]dnsv1.RR{rr}, "bosun.org")
+		if err != nil {
+			t.Fatal(err)
+		}
+		rcs[0].Metadata = map[string]string{MetaGenerate: fmt.Sprintf("%d-%d/1 %s %s", start, stop, lhs, rhs)}
+		recs = append(recs, rcs[0])
+	}
+	return recs
+}
+
+func TestWriteZoneFileGenerate(t *testing.T) {
+	var recs models.Records
+	recs = append(recs, generated(t, "host-$", 1, 3, "A", "10.0.0.$")...)
+	recs = append(recs, generated(t, "alias-$", 1, 2, "CNAME", "host-$")...)
+	// The target of a record changed since: written as records.
+	changed := generated(t, "web-$", 1, 2, "A", "10.0.1.$")
+	changed[1].SetTargetIP(netip.MustParseAddr("10.0.1.99"))
+	recs = append(recs, changed...)
+	// Not a single field: written as records.
+	recs = append(recs, generated(t, "txt-$", 1, 1, "TXT", "v$")...)
+
+	buf := &bytes.Buffer{}
+	if err := WriteZoneFileGenerate(buf, recs, "bosun.org", 0, nil); err != nil {
+		t.Fatal(err)
+	}
+	expected := `$TTL 300
+$GENERATE 1-2 alias-$ 300 IN CNAME host-$
+$GENERATE 1-3 host-$ 300 IN A 10.0.0.$
+txt-1            IN TXT   "v1"
+web-1            IN A     10.0.1.1
+web-2            IN A     10.0.1.99
+`
+	if buf.String() != expected {
+		t.Fatalf("Zone file does not match: got=(\n%v\n)\nexpected=(\n%v\n)\n", buf.String(), expected)
+	}
+
+	// The zone file has the same records.
+	zp := dnsv1.NewZoneParser(bytes.NewReader(buf.Bytes()), "bosun.org", "bosun.org.zone")
+	n := 0
+	for _, ok := zp.Next(); ok; _, ok = zp.Next() {
+		n++
+	}
+	if err := zp.Err(); err != nil {
+		t.Fatal(err)
+	}
+	if n != len(recs) {
+		t.Errorf("the zone file has %d records, want %d", n, len(recs))
+	}
+}
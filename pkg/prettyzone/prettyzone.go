@@ -67,6 +67,14 @@ func WriteZoneFileRC(w io.Writer, records models.Records, origin string, default
 	return z.generateZoneFileHelper(w)
 }
 
+// WriteZoneFileGenerate is like WriteZoneFileRC, but writes the records of
+// each GENERATE() as one $GENERATE line when possible.
+func WriteZoneFileGenerate(w io.Writer, records models.Records, origin string, defaultTTL uint32, comments []string) error {
+	z := PrettySort(records, origin, defaultTTL, comments)
+	z.Generate = true
+	return z.generateZoneFileHelper(w)
+}
+
 // PrettySort sorts the records in a pretty order.
 func PrettySort(records models.Records, origin string, defaultTTL uint32, comments []string) *ZoneGenData {
 	if defaultTTL == 0 {
@@ -102,7 +110,20 @@ func (z *ZoneGenData) generateZoneFileHelper(w io.Writer) error {
 			}
 		}
 	}
+	var generated map[*models.RecordConfig]string
+	if z.Generate {
+		generated = z.generateLines()
+	}
 	for i, rr := range z.Records {
+		if line, ok := generated[rr]; ok {
+			if line != "" {
+				fmt.Fprintln(w, line)
+				// The next record must have a name.
+				nameShortPrevious = ""
+			}
+			continue
+		}
+
 		// Fake types are commented out.
 		prefix := ""
 		_, ok := dnsv1.StringToType[rr.Type]
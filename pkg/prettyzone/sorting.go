@@ -18,6 +18,7 @@ type ZoneGenData struct {
 	DefaultTTL uint32
 	Records    models.Records
 	Comments   []string
+	Generate   bool // Write the records of a GENERATE() as $GENERATE.
 }
 
 func (z *ZoneGenData) Len() int      { return len(z.Records) }
@@ -0,0 +1,293 @@
+package printer
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/DNSControl/dnscontrol/v4/models"
+)
+
+// JSONPrinter is a CLI that writes one JSON object (an event) per line,
+// for programs that parse the output of preview/push.
+//
+// JSONPrinter is also an io.Writer. Each line written to it becomes a
+// "message" event (or "warning"/"error" if it starts with "WARNING: " or
+// "ERROR: "), so that the output of a ConsolePrinter can be redirected to it.
+type JSONPrinter struct {
+	Writer  io.Writer
+	Verbose bool
+
+	mu        sync.Mutex
+	domain    string    // The current domain.
+	provider  string    // The current DNS provider.
+	registrar string    // The current registrar.
+	started   time.Time // When the current correction started.
+	partial   []byte    // Text written so far that does not end in a newline.
+}
+
+// JSONEvent is one line of the output of JSONPrinter.
+type JSONEvent struct {
+	Time        string  `json:"time"`
+	Event       string  `json:"event"`
+	Domain      string  `json:"domain,omitempty"`
+	Provider    string  `json:"provider,omitempty"`
+	Registrar   string  `json:"registrar,omitempty"`
+	Skip        bool    `json:"skip,omitempty"`
+	Corrections *int    `json:"corrections,omitempty"` // On "end_provider".
+	Number      int     `json:"n,omitempty"`           // On "correction" and "report".
+	Msg         string  `json:"msg,omitempty"`
+	Error       string  `json:"error,omitempty"`
+	Duration    float64 `json:"duration_seconds,omitempty"` // On "end_correction".
+}
+
+// NewJSONPrinter returns a JSONPrinter that writes to w.
+func NewJSONPrinter(w io.Writer, verbose bool) *JSONPrinter {
+	return &JSONPrinter{Writer: w, Verbose: verbose}
+}
+
+var ansiRe = regexp.MustCompile(`\x1b\[[0-9;]*[a-zA-Z]`)
+
+// emit writes an event. The caller must hold the lock. Events about a
+// correction or provider carry the current domain and provider (or registrar).
+func (j *JSONPrinter) emit(e JSONEvent, inContext bool) {
+	e.Time = time.Now().UTC().Format(time.RFC3339Nano)
+	if inContext {
+		e.Domain = j.domain
+		if e.Provider == "" && e.Registrar == "" {
+			e.Provider, e.Registrar = j.provider, j.registrar
+		}
+	}
+	e.Msg = ansiRe.ReplaceAllString(e.Msg, "")
+	var b bytes.Buffer
+	enc := json.NewEncoder(&b)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(e); err != nil {
+		fmt.Fprintf(&b, "{\"time\":%q,\"event\":\"error\",\"error\":%q}\n", e.Time, err.Error())
+	}
+	j.Writer.Write(b.Bytes())
+}
+
+// message emits a message event for each line of text. The events carry
+// the current domain and provider (or registrar), if any.
+func (j *JSONPrinter) message(event, text string) {
+	j.messageAbout(JSONEvent{Event: event, Domain: j.domain, Provider: j.provider, Registrar: j.registrar}, text)
+}
+
+// messageAbout emits a copy of e for each line of text.
+func (j *JSONPrinter) messageAbout(e JSONEvent, text string) {
+	text = strings.TrimRight(text, "\n")
+	if text == "" {
+		return
+	}
+	for line := range strings.SplitSeq(text, "\n") {
+		e.Msg = line
+		j.emit(e, false)
+	}
+}
+
+// StartDomain is called at the start of each domain.
+func (j *JSONPrinter) StartDomain(dc *models.DomainConfig) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.domain, j.provider, j.registrar = dc.GetUniqueName(), "", ""
+	j.emit(JSONEvent{Event: "start_domain"}, true)
+}
+
+// EndDomain is called at the end of each domain. Later messages carry no
+// domain.
+func (j *JSONPrinter) EndDomain() {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.domain, j.provider, j.registrar = "", "", ""
+}
+
+// ForZone returns a Printer whose messages carry domain and provider.
+func (j *JSONPrinter) ForZone(domain, provider string) Printer {
+	return jsonZonePrinter{j: j, domain: domain, provider: provider}
+}
+
+// StartDNSProvider is called at the start of each new provider.
+func (j *JSONPrinter) StartDNSProvider(name string, skip bool) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.provider, j.registrar = name, ""
+	j.emit(JSONEvent{Event: "start_provider", Skip: skip}, true)
+}
+
+// StartRegistrar is called at the start of each new registrar.
+func (j *JSONPrinter) StartRegistrar(name string, skip bool) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.provider, j.registrar = "", name
+	j.emit(JSONEvent{Event: "start_registrar", Skip: skip}, true)
+}
+
+// EndProvider is called at the end of each provider.
+func (j *JSONPrinter) EndProvider(name string, numCorrections int, err error) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	e := JSONEvent{Event: "end_provider", Corrections: &numCorrections}
+	if err != nil {
+		e.Error = err.Error()
+	}
+	j.emit(e, true)
+}
+
+// EndProvider2 is called at the end of each provider.
+func (j *JSONPrinter) EndProvider2(name string, numCorrections int) {
+	j.EndProvider(name, numCorrections, nil)
+}
+
+// PrintCorrection is called to print/format each correction.
+func (j *JSONPrinter) PrintCorrection(i int, correction *models.Correction) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.started = time.Now()
+	j.emit(JSONEvent{Event: "correction", Number: i + 1, Msg: correction.Msg}, true)
+}
+
+// PrintReport is called to print/format each non-mutating correction (diff2.REPORT).
+func (j *JSONPrinter) PrintReport(i int, correction *models.Correction) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.emit(JSONEvent{Event: "report", Number: i + 1, Msg: correction.Msg}, true)
+}
+
+// EndCorrection is called at the end of each correction.
+func (j *JSONPrinter) EndCorrection(err error) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	e := JSONEvent{Event: "end_correction", Duration: time.Since(j.started).Seconds()}
+	if err != nil {
+		e.Error = err.Error()
+	}
+	j.emit(e, true)
+}
+
+// PromptToRun always returns false. A JSONPrinter can not be used interactively.
+func (j *JSONPrinter) PromptToRun() bool {
+	return false
+}
+
+// Debugf is called to print/format debug information.
+func (j *JSONPrinter) Debugf(format string, args ...any) {
+	if !j.Verbose {
+		return
+	}
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.message("debug", fmt.Sprintf(format, args...))
+}
+
+// Printf is called to print/format information.
+func (j *JSONPrinter) Printf(format string, args ...any) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.message("message", fmt.Sprintf(format, args...))
+}
+
+// Println is called to print/format information.
+func (j *JSONPrinter) Println(lines ...string) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.message("message", strings.Join(lines, " "))
+}
+
+// Warnf is called to print/format a warning.
+func (j *JSONPrinter) Warnf(format string, args ...any) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.message("warning", fmt.Sprintf(format, args...))
+}
+
+// Errorf is called to print/format an error.
+func (j *JSONPrinter) Errorf(format string, args ...any) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.message("error", fmt.Sprintf(format, args...))
+}
+
+// PrintfIf is called to optionally print/format a message.
+func (j *JSONPrinter) PrintfIf(prnt bool, format string, args ...any) {
+	if prnt {
+		j.Printf(format, args...)
+	}
+}
+
+// Write turns each complete line of p into a message event.
+func (j *JSONPrinter) Write(p []byte) (int, error) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.partial = append(j.partial, p...)
+	for {
+		i := bytes.IndexByte(j.partial, '\n')
+		if i < 0 {
+			break
+		}
+		line := string(j.partial[:i])
+		j.partial = j.partial[i+1:]
+		switch {
+		case strings.HasPrefix(line, "WARNING: "):
+			j.message("warning", strings.TrimPrefix(line, "WARNING: "))
+		case strings.HasPrefix(line, "ERROR: "):
+			j.message("error", strings.TrimPrefix(line, "ERROR: "))
+		default:
+			j.message("message", line)
+		}
+	}
+	return len(p), nil
+}
+
+// jsonZonePrinter is a Printer for the messages about one zone that are
+// printed outside of StartDomain/EndDomain, for example while the zones are
+// gathered concurrently.
+type jsonZonePrinter struct {
+	j                *JSONPrinter
+	domain, provider string
+}
+
+func (z jsonZonePrinter) message(event, text string) {
+	z.j.mu.Lock()
+	defer z.j.mu.Unlock()
+	z.j.messageAbout(JSONEvent{Event: event, Domain: z.domain, Provider: z.provider}, text)
+}
+
+// Debugf is called to print/format debug information.
+func (z jsonZonePrinter) Debugf(format string, args ...any) {
+	if z.j.Verbose {
+		z.message("debug", fmt.Sprintf(format, args...))
+	}
+}
+
+// Printf is called to print/format information.
+func (z jsonZonePrinter) Printf(format string, args ...any) {
+	z.message("message", fmt.Sprintf(format, args...))
+}
+
+// Println is called to print/format information.
+func (z jsonZonePrinter) Println(lines ...string) {
+	z.message("message", strings.Join(lines, " "))
+}
+
+// Warnf is called to print/format a warning.
+func (z jsonZonePrinter) Warnf(format string, args ...any) {
+	z.message("warning", fmt.Sprintf(format, args...))
+}
+
+// Errorf is called to print/format an error.
+func (z jsonZonePrinter) Errorf(format string, args ...any) {
+	z.message("error", fmt.Sprintf(format, args...))
+}
+
+// PrintfIf is called to optionally print/format a message.
+func (z jsonZonePrinter) PrintfIf(prnt bool, format string, args ...any) {
+	if prnt {
+		z.Printf(format, args...)
+	}
+}
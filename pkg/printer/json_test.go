@@ -0,0 +1,86 @@
+package printer
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/DNSControl/dnscontrol/v4/models"
+	"github.com/stretchr/testify/assert"
+)
+
+// decodeEvents returns the events in out, without their times.
+func decodeEvents(t *testing.T, out *bytes.Buffer) []JSONEvent {
+	t.Helper()
+	var events []JSONEvent
+	dec := json.NewDecoder(out)
+	for dec.More() {
+		var e JSONEvent
+		if err := dec.Decode(&e); err != nil {
+			t.Fatal(err)
+		}
+		if e.Time == "" {
+			t.Errorf("event %q has no time", e.Event)
+		}
+		e.Time, e.Duration = "", 0
+		events = append(events, e)
+	}
+	return events
+}
+
+func TestJSONPrinter(t *testing.T) {
+	out := &bytes.Buffer{}
+	p := NewJSONPrinter(out, false)
+	zero, one := 0, 1
+
+	p.Printf("Gathering %d zone(s)\n", 1)
+	p.Debugf("not shown\n")
+	p.StartDomain(&models.DomainConfig{Name: "example.com", UniqueName: "example.com"})
+	p.StartDNSProvider("bind", false)
+	p.EndProvider2("bind", 1)
+	p.PrintCorrection(0, &models.Correction{Msg: "\x1b[32m+ CREATE www.example.com A 1.2.3.4\x1b[0m"})
+	fmt.Fprint(p, "WARNING: slow API\n")
+	p.EndCorrection(errors.New("boom"))
+	p.StartRegistrar("none", false)
+	p.EndProvider2("none", 0)
+	p.PrintReport(0, &models.Correction{Msg: "nothing to do"})
+	p.EndDomain()
+	p.ForZone("example.com", "bind").Errorf("verification failed\n")
+	p.Printf("Done.\n")
+
+	assert.Equal(t, []JSONEvent{
+		{Event: "message", Msg: "Gathering 1 zone(s)"},
+		{Event: "start_domain", Domain: "example.com"},
+		{Event: "start_provider", Domain: "example.com", Provider: "bind"},
+		{Event: "end_provider", Domain: "example.com", Provider: "bind", Corrections: &one},
+		{Event: "correction", Domain: "example.com", Provider: "bind", Number: 1, Msg: "+ CREATE www.example.com A 1.2.3.4"},
+		{Event: "warning", Domain: "example.com", Provider: "bind", Msg: "slow API"},
+		{Event: "end_correction", Domain: "example.com", Provider: "bind", Error: "boom"},
+		{Event: "start_registrar", Domain: "example.com", Registrar: "none"},
+		{Event: "end_provider", Domain: "example.com", Registrar: "none", Corrections: &zero},
+		{Event: "report", Domain: "example.com", Registrar: "none", Number: 1, Msg: "nothing to do"},
+		{Event: "error", Domain: "example.com", Provider: "bind", Msg: "verification failed"},
+		{Event: "message", Msg: "Done."},
+	}, decodeEvents(t, out))
+}
+
+func TestJSONPrinter_Write(t *testing.T) {
+	out := &bytes.Buffer{}
+	p := NewJSONPrinter(out, false)
+	c := ConsolePrinter{Writer: p}
+
+	c.Printf("one ")
+	c.Printf("line\ntwo lines\n")
+	c.Warnf("careful\n")
+	c.Errorf("broken\n")
+	fmt.Fprint(p, "unfinished")
+
+	assert.Equal(t, []JSONEvent{
+		{Event: "message", Msg: "one line"},
+		{Event: "message", Msg: "two lines"},
+		{Event: "warning", Msg: "careful"},
+		{Event: "error", Msg: "broken"},
+	}, decodeEvents(t, out))
+}
@@ -14,6 +14,8 @@ import (
 type CLI interface {
 	Printer
 	StartDomain(dc *models.DomainConfig)
+	EndDomain()
+	ForZone(domain, provider string) Printer // For messages about a zone outside of StartDomain/EndDomain.
 	StartDNSProvider(name string, skip bool)
 	EndProvider(name string, numCorrections int, err error)
 	EndProvider2(name string, numCorrections int)
@@ -93,6 +95,14 @@ func (c ConsolePrinter) StartDomain(dc *models.DomainConfig) {
 	fmt.Fprintf(c.Writer, "******************** Domain: %s\n", dc.DisplayName)
 }
 
+// EndDomain is called at the end of each domain.
+func (c ConsolePrinter) EndDomain() {}
+
+// ForZone returns c. The console output names the zone where it matters.
+func (c ConsolePrinter) ForZone(domain, provider string) Printer {
+	return c
+}
+
 // PrintCorrection is called to print/format each correction.
 func (c ConsolePrinter) PrintCorrection(i int, correction *models.Correction) {
 	fmt.Fprintf(c.Writer, "#%d: %s\n", i+1, correction.Msg)
@@ -117,6 +117,11 @@ const (
 
 	// CanUseAKAMAITLC indicates the provider supports the specific AKAMAITLC records that only the Akamai EdgeDns provider supports.
 	CanUseAKAMAITLC
+
+	// CanPublishDS indicates the registrar can publish the DS records of a
+	// domain at the registry (it implements DSRegistrar). The DS records are
+	// derived from the keys of the DNS providers of zones with AUTODNSSEC_ON.
+	CanPublishDS
 )
 
 var providerCapabilities = map[string]map[Capability]bool{}
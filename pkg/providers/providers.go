@@ -32,6 +32,40 @@ type ZoneLister interface {
 	ListZones() ([]string, error)
 }
 
+// ConfiguredZonesSetter should be implemented by providers that need to know
+// every zone in dnsconfig.js that uses them, including the zones that
+// --domains leaves out. SetConfiguredZones is called once, before any zone is
+// processed.
+type ConfiguredZonesSetter interface {
+	SetConfiguredZones(zones []*models.DomainConfig)
+}
+
+// CatalogZoneCorrector should be implemented by DNS providers that maintain a
+// catalog zone (RFC 9432) of the zones that use them. The catalog zone is not
+// in dnsconfig.js, so it is a zone of its own in the output of preview/push.
+// CatalogZoneCorrections is called once, after the other zones were gathered.
+// It returns a nil zone if the provider has no catalog zone.
+type CatalogZoneCorrector interface {
+	CatalogZoneCorrections() (*models.DomainConfig, []*models.Correction, int, error)
+}
+
+// KeySigningKeysLister should be implemented by DNS providers that sign
+// zones (AUTODNSSEC_ON) and can list the key-signing keys of a zone. The DS
+// records at the registrar are derived from them. ListKeySigningKeys returns
+// DNSKEY records, including keys that are published but not active yet.
+type KeySigningKeysLister interface {
+	ListKeySigningKeys(dc *models.DomainConfig) ([]*models.RecordConfig, error)
+}
+
+// DSRegistrar should be implemented by registrars that can manage the DS
+// records of a domain at the registry (see CanPublishDS). The records are DS
+// RecordConfigs; registrars may set Original to their own object.
+type DSRegistrar interface {
+	GetRegistrarDS(dc *models.DomainConfig) ([]*models.RecordConfig, error)
+	AddRegistrarDS(dc *models.DomainConfig, ds *models.RecordConfig) error
+	RemoveRegistrarDS(dc *models.DomainConfig, ds *models.RecordConfig) error
+}
+
 // RegistrarInitializer is a function to create a registrar. Function will be passed the unprocessed json payload from the configuration file for the given provider.
 type RegistrarInitializer func(map[string]string) (Registrar, error)
 
@@ -0,0 +1,109 @@
+// Package snapshot saves point-in-time copies of the records of a zone so
+// that a push can be undone later with `dnscontrol rollback`.
+//
+// A snapshot is a directory with one subdirectory per DNS provider. Each
+// subdirectory has one BIND zone file per zone:
+//
+//	SNAPSHOTDIR/PROVIDERNAME/UNIQUENAME.zone
+//
+// UNIQUENAME is the zone name, plus "!tag" if the zone is tagged for split
+// horizon DNS.
+package snapshot
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/DNSControl/dnscontrol/v4/models"
+	"github.com/DNSControl/dnscontrol/v4/pkg/domaintags"
+	"github.com/DNSControl/dnscontrol/v4/pkg/prettyzone"
+	"github.com/DNSControl/dnscontrol/v4/pkg/zonefile"
+)
+
+// fileSuffix is the filename extension of a zone file in a snapshot.
+const fileSuffix = ".zone"
+
+// NewDir returns the name of a new snapshot directory within base. The name
+// is derived from t so that snapshots sort chronologically.
+func NewDir(base string, t time.Time) string {
+	return filepath.Join(base, t.UTC().Format("20060102T150405Z"))
+}
+
+// Entry is a zone file in a snapshot.
+type Entry struct {
+	Provider   string // The provider name (the key in creds.json)
+	UniqueName string // The zone name, plus "!tag" if tagged.
+	Filename   string
+}
+
+// Write saves the records of a zone (as found at providerName) in dir.
+func Write(dir, providerName string, dc *models.DomainConfig, records models.Records) error {
+	pdir := filepath.Join(dir, providerName)
+	if err := os.MkdirAll(pdir, 0o750); err != nil {
+		return err
+	}
+
+	filename := filepath.Join(pdir, dc.UniqueName+fileSuffix)
+	f, err := os.OpenFile(filename, os.O_CREATE|os.O_WRONLY|os.O_EXCL, 0o640)
+	if err != nil {
+		return err
+	}
+
+	comments := []string{
+		fmt.Sprintf("Snapshot of %q at %q", dc.UniqueName, providerName),
+		"Restore with: dnscontrol rollback " + dir,
+	}
+	err = prettyzone.WriteZoneFileRC(f, records, dc.Name, 0, comments)
+	return errors.Join(err, f.Close())
+}
+
+// List returns the zone files in the snapshot dir, sorted by provider then zone.
+func List(dir string) ([]Entry, error) {
+	pdirs, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var entries []Entry
+	for _, pdir := range pdirs {
+		if !pdir.IsDir() {
+			continue
+		}
+		files, err := os.ReadDir(filepath.Join(dir, pdir.Name()))
+		if err != nil {
+			return nil, err
+		}
+		for _, file := range files {
+			if file.IsDir() || !strings.HasSuffix(file.Name(), fileSuffix) {
+				continue
+			}
+			entries = append(entries, Entry{
+				Provider:   pdir.Name(),
+				UniqueName: strings.TrimSuffix(file.Name(), fileSuffix),
+				Filename:   filepath.Join(dir, pdir.Name(), file.Name()),
+			})
+		}
+	}
+	if len(entries) == 0 {
+		return nil, fmt.Errorf("no zone files found in snapshot %q", dir)
+	}
+
+	slices.SortFunc(entries, func(a, b Entry) int {
+		return strings.Compare(a.Provider+"/"+a.UniqueName, b.Provider+"/"+b.UniqueName)
+	})
+	return entries, nil
+}
+
+// Read returns the records stored in a snapshot's zone file.
+func (e Entry) Read() (models.Records, error) {
+	content, err := os.ReadFile(e.Filename)
+	if err != nil {
+		return nil, err
+	}
+	return zonefile.Parse(string(content), domaintags.MakeDomainNameVarieties(e.UniqueName).NameASCII, e.Filename)
+}
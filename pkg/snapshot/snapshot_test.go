@@ -0,0 +1,81 @@
+package snapshot
+
+import (
+	"testing"
+	"time"
+
+	"github.com/DNSControl/dnscontrol/v4/models"
+	_ "github.com/DNSControl/dnscontrol/v4/pkg/rtype"
+)
+
+func makeRec(label, rtype, content string) *models.RecordConfig {
+	r := &models.RecordConfig{TTL: 300}
+	r.SetLabel(label, "example.com")
+	if err := r.PopulateFromString(rtype, content, "example.com"); err != nil {
+		panic(err)
+	}
+	return r
+}
+
+func TestRoundTrip(t *testing.T) {
+	dir := NewDir(t.TempDir(), time.Date(2024, 1, 2, 15, 4, 5, 0, time.UTC))
+
+	plain := &models.DomainConfig{Name: "example.com"}
+	plain.PostProcess()
+	tagged := &models.DomainConfig{Name: "example.com!inside"}
+	tagged.PostProcess()
+	recs := models.Records{
+		makeRec("@", "MX", "10 mail.example.com."),
+		makeRec("www", "A", "1.2.3.4"),
+		makeRec("www", "TXT", "hello world"),
+	}
+
+	if err := Write(dir, "bind", plain, recs); err != nil {
+		t.Fatal(err)
+	}
+	if err := Write(dir, "bind", tagged, recs[1:2]); err != nil {
+		t.Fatal(err)
+	}
+	// Snapshots are never overwritten.
+	if err := Write(dir, "bind", plain, recs); err == nil {
+		t.Errorf("Write() of an existing snapshot: expected an error")
+	}
+
+	entries, err := List(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 2 {
+		t.Fatalf("List() returned %d entries, want 2", len(entries))
+	}
+	for i, want := range []struct {
+		uniqueName string
+		count      int
+	}{
+		{"example.com", 3},
+		{"example.com!inside", 1},
+	} {
+		e := entries[i]
+		if e.Provider != "bind" || e.UniqueName != want.uniqueName {
+			t.Errorf("entries[%d] = %+v, want provider=bind uniquename=%s", i, e, want.uniqueName)
+		}
+		got, err := e.Read()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(got) != want.count {
+			t.Errorf("%s: Read() returned %d records, want %d", e.UniqueName, len(got), want.count)
+		}
+		for _, r := range got {
+			if r.GetLabelFQDN() != "example.com" && r.GetLabelFQDN() != "www.example.com" {
+				t.Errorf("%s: unexpected label %q", e.UniqueName, r.GetLabelFQDN())
+			}
+		}
+	}
+}
+
+func TestListEmpty(t *testing.T) {
+	if _, err := List(t.TempDir()); err == nil {
+		t.Errorf("List() of an empty directory: expected an error")
+	}
+}
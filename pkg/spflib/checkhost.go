@@ -0,0 +1,531 @@
+package spflib
+
+import (
+	"errors"
+	"fmt"
+	"net/netip"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// Result is the result of check_host() (RFC 7208, section 2.6).
+type Result string
+
+// The results of check_host().
+const (
+	ResultNone      Result = "none"
+	ResultNeutral   Result = "neutral"
+	ResultPass      Result = "pass"
+	ResultFail      Result = "fail"
+	ResultSoftFail  Result = "softfail"
+	ResultTempError Result = "temperror"
+	ResultPermError Result = "permerror"
+)
+
+// Limits of RFC 7208, section 4.6.4.
+const (
+	maxVoidLookups = 2  // Lookups that return no records.
+	maxMXNames     = 10 // Exchanges of an mx mechanism.
+	maxPTRNames    = 10 // Names of a ptr mechanism or %{p} macro.
+)
+
+// HostResolver looks up the records that CheckHost needs. A name that
+// doesn't exist, or has no records of the type, returns no records and no
+// error. An error is a temporary failure (a temperror).
+type HostResolver interface {
+	LookupTXT(name string) ([]string, error)
+	// LookupIP looks up the A (network "ip4") or AAAA ("ip6") records.
+	LookupIP(network, name string) ([]netip.Addr, error)
+	// LookupMX returns the exchanges of the MX records, by preference.
+	LookupMX(name string) ([]string, error)
+	// LookupAddr returns the names of the PTR records of addr.
+	LookupAddr(addr netip.Addr) ([]string, error)
+}
+
+// Checker evaluates SPF records as a receiving mail server does: it
+// implements check_host() of RFC 7208, with macros.
+type Checker struct {
+	Resolver HostResolver
+	// HELO is the domain of the HELO/EHLO command, for the %{h} macro. The
+	// domain of the sender if empty.
+	HELO string
+
+	lookups     int // The lookups of mechanisms and modifiers so far.
+	voidLookups int // Those that returned no records.
+}
+
+// CheckResult is the result of CheckHost.
+type CheckResult struct {
+	Result Result
+	// Domain is the domain of the SPF record that decided the result, and
+	// Mechanism the mechanism that matched (or failed). Mechanism is empty if
+	// no mechanism matched.
+	Domain    string
+	Mechanism string
+	// Lookups is the number of DNS lookups that counted against the limit
+	// of 10.
+	Lookups int
+}
+
+// checkError is the error of a temperror or permerror.
+type checkError struct {
+	result Result
+	err    error
+}
+
+func (e *checkError) Error() string { return e.err.Error() }
+func (e *checkError) Unwrap() error { return e.err }
+
+func permErrorf(format string, a ...any) error {
+	return &checkError{ResultPermError, fmt.Errorf(format, a...)}
+}
+
+func tempError(err error) error {
+	return &checkError{ResultTempError, err}
+}
+
+// ErrorResult returns the result of an error of CheckHost or CountLookups:
+// temperror or permerror.
+func ErrorResult(err error) Result {
+	var ce *checkError
+	if errors.As(err, &ce) {
+		return ce.result
+	}
+	return ResultTempError
+}
+
+// CheckHost returns whether ip may send mail for domain, the domain of the
+// MAIL FROM (or HELO) identity. sender is the MAIL FROM address; if it is
+// empty, or has no local part, "postmaster" is used. The error explains a
+// temperror or a permerror.
+func (c *Checker) CheckHost(ip netip.Addr, domain, sender string) (CheckResult, error) {
+	c.lookups, c.voidLookups = 0, 0
+	domain = strings.TrimSuffix(domain, ".")
+	switch {
+	case sender == "":
+		sender = "postmaster@" + domain
+	case !strings.Contains(sender, "@"):
+		sender = "postmaster@" + sender
+	case strings.HasPrefix(sender, "@"):
+		sender = "postmaster" + sender
+	}
+	res, err := c.checkHost(ip.Unmap(), domain, sender)
+	res.Lookups = c.lookups
+	return res, err
+}
+
+func (c *Checker) checkHost(ip netip.Addr, domain, sender string) (CheckResult, error) {
+	res := CheckResult{Domain: domain}
+	if !validDomain(domain) {
+		res.Result = ResultNone
+		return res, nil
+	}
+
+	spf, err := lookupSPF(c.Resolver, domain)
+	if err != nil {
+		res.Result = ErrorResult(err)
+		return res, err
+	}
+	if spf == "" {
+		res.Result = ResultNone
+		return res, nil
+	}
+
+	terms, redirect, err := parseTerms(spf)
+	if err != nil {
+		res.Result = ResultPermError
+		return res, fmt.Errorf("the SPF record of %s: %w", domain, err)
+	}
+	for _, t := range terms {
+		matched, err := c.match(ip, domain, sender, t)
+		if err != nil {
+			res.Result, res.Mechanism = ErrorResult(err), t.text
+			return res, err
+		}
+		if matched {
+			res.Result, res.Mechanism = qualifierResults[t.qualifier], t.text
+			return res, nil
+		}
+	}
+
+	if redirect == "" {
+		res.Result = ResultNeutral
+		return res, nil
+	}
+	if err := c.countLookup(); err != nil {
+		res.Result = ResultPermError
+		return res, err
+	}
+	target, err := c.expand(redirect, ip, domain, sender)
+	if err != nil {
+		res.Result = ResultPermError
+		return res, err
+	}
+	res, err = c.checkHost(ip, target, sender)
+	if res.Result == ResultNone {
+		res.Result = ResultPermError
+		return res, permErrorf("redirect=%s: %s has no SPF record", redirect, target)
+	}
+	return res, err
+}
+
+// lookupSPF returns the SPF record of domain, or "" if it has none.
+func lookupSPF(r HostResolver, domain string) (string, error) {
+	txts, err := r.LookupTXT(domain)
+	if err != nil {
+		return "", tempError(err)
+	}
+	var records []string
+	for _, txt := range txts {
+		if l := strings.ToLower(txt); l == "v=spf1" || strings.HasPrefix(l, "v=spf1 ") {
+			records = append(records, txt)
+		}
+	}
+	switch len(records) {
+	case 0:
+		return "", nil
+	case 1:
+		return records[0], nil
+	default:
+		return "", permErrorf("%s has multiple SPF records", domain)
+	}
+}
+
+var qualifierResults = map[byte]Result{
+	'+': ResultPass,
+	'-': ResultFail,
+	'~': ResultSoftFail,
+	'?': ResultNeutral,
+}
+
+// validDomain returns true if domain is a valid multi-label domain
+// (RFC 7208, section 4.3).
+func validDomain(domain string) bool {
+	labels := strings.Split(domain, ".")
+	if len(domain) > 253 || len(labels) < 2 {
+		return false
+	}
+	for _, l := range labels {
+		if l == "" || len(l) > 63 {
+			return false
+		}
+	}
+	return true
+}
+
+// countLookup counts a DNS lookup against the limit of 10.
+func (c *Checker) countLookup() error {
+	c.lookups++
+	if c.lookups > MaxLookups {
+		return permErrorf("more than %d DNS lookups", MaxLookups)
+	}
+	return nil
+}
+
+// countVoid counts a lookup that returned n records against the limit of
+// void lookups.
+func (c *Checker) countVoid(n int) error {
+	if n > 0 {
+		return nil
+	}
+	c.voidLookups++
+	if c.voidLookups > maxVoidLookups {
+		return permErrorf("more than %d DNS lookups returned no records", maxVoidLookups)
+	}
+	return nil
+}
+
+// term is a mechanism of an SPF record.
+type term struct {
+	text      string // As written.
+	qualifier byte   // '+', '-', '~' or '?'.
+	name      string // The mechanism, in lower case.
+	// domainSpec is the domain of a, mx, ptr, include and exists, with its
+	// macros. It is the current domain if empty.
+	domainSpec string
+	prefix     netip.Prefix // The range of ip4 and ip6.
+	cidr4      int          // The prefix lengths of a and mx.
+	cidr6      int
+}
+
+var (
+	modifierName = regexp.MustCompile(`^[A-Za-z][A-Za-z0-9._-]*=`)
+	dualCIDR     = regexp.MustCompile(`^(.*?)(?:/(0|[1-9][0-9]{0,2}))?(?://(0|[1-9][0-9]{0,2}))?$`)
+	macroExpand  = regexp.MustCompile(`%\{[^}]*\}`)
+)
+
+// parseTerms parses an SPF record. It returns its mechanisms and its
+// redirect= modifier.
+func parseTerms(text string) ([]*term, string, error) {
+	var terms []*term
+	var redirect string
+	var hasRedirect, hasExp bool
+	for _, field := range strings.Fields(text)[1:] {
+		if modifierName.MatchString(field) {
+			name, value, _ := strings.Cut(field, "=")
+			name = strings.ToLower(name)
+			letters := expLetters
+			if name == "redirect" {
+				letters = macroLetters
+			}
+			if err := checkMacroString(value, letters); err != nil {
+				return nil, "", err
+			}
+			switch name {
+			case "redirect":
+				if hasRedirect {
+					return nil, "", permErrorf("multiple redirect= modifiers")
+				}
+				hasRedirect, redirect = true, value
+			case "exp":
+				if hasExp {
+					return nil, "", permErrorf("multiple exp= modifiers")
+				}
+				hasExp = true
+			}
+			// Unknown modifiers are ignored.
+			continue
+		}
+		t, err := parseMechanism(field)
+		if err != nil {
+			return nil, "", err
+		}
+		terms = append(terms, t)
+	}
+	return terms, redirect, nil
+}
+
+// parseMechanism parses a mechanism, with its qualifier.
+func parseMechanism(text string) (*term, error) {
+	t := &term{text: text, qualifier: '+', cidr4: 32, cidr6: 128}
+	rest := text
+	if qualifiers[rest[0]] {
+		t.qualifier, rest = rest[0], rest[1:]
+	}
+	end := strings.IndexAny(rest, ":/")
+	if end < 0 {
+		end = len(rest)
+	}
+	t.name, rest = strings.ToLower(rest[:end]), rest[end:]
+
+	// The domain-spec after the colon.
+	domainSpec := func(required bool) (string, error) {
+		if !strings.HasPrefix(rest, ":") {
+			if required {
+				return "", permErrorf("%s: missing domain", text)
+			}
+			return "", nil
+		}
+		spec := rest[1:]
+		if spec == "" {
+			return "", permErrorf("%s: missing domain", text)
+		}
+		if strings.Contains(macroExpand.ReplaceAllString(spec, ""), "/") {
+			return "", permErrorf("%s: invalid domain", text)
+		}
+		return spec, checkMacroString(spec, macroLetters)
+	}
+
+	var err error
+	switch t.name {
+	case "all":
+		if rest != "" {
+			return nil, permErrorf("%s: all takes no argument", text)
+		}
+	case "include", "exists", "ptr":
+		t.domainSpec, err = domainSpec(t.name != "ptr")
+	case "a", "mx":
+		m := dualCIDR.FindStringSubmatch(rest)
+		if m[2] != "" {
+			if t.cidr4, _ = strconv.Atoi(m[2]); t.cidr4 > 32 {
+				return nil, permErrorf("%s: invalid IPv4 prefix length", text)
+			}
+		}
+		if m[3] != "" {
+			if t.cidr6, _ = strconv.Atoi(m[3]); t.cidr6 > 128 {
+				return nil, permErrorf("%s: invalid IPv6 prefix length", text)
+			}
+		}
+		rest = m[1]
+		if rest != "" && !strings.HasPrefix(rest, ":") {
+			return nil, permErrorf("%s: invalid mechanism", text)
+		}
+		t.domainSpec, err = domainSpec(false)
+	case "ip4", "ip6":
+		if !strings.HasPrefix(rest, ":") {
+			return nil, permErrorf("%s: missing address", text)
+		}
+		t.prefix, err = parseIPRange(t.name, rest[1:])
+		if err != nil {
+			return nil, permErrorf("%s: %w", text, err)
+		}
+	default:
+		return nil, permErrorf("%s: unknown mechanism", text)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+// parseIPRange parses the address and prefix length of an ip4 or ip6
+// mechanism.
+func parseIPRange(mech, text string) (netip.Prefix, error) {
+	addrText, bitsText, hasBits := strings.Cut(text, "/")
+	addr, err := netip.ParseAddr(addrText)
+	if err != nil || addr.Zone() != "" || addr.Is4() != (mech == "ip4") {
+		return netip.Prefix{}, fmt.Errorf("invalid address %q", addrText)
+	}
+	bits := addr.BitLen()
+	if hasBits {
+		if bits, err = strconv.Atoi(bitsText); err != nil || bits < 0 || bits > addr.BitLen() || (len(bitsText) > 1 && bitsText[0] == '0') {
+			return netip.Prefix{}, fmt.Errorf("invalid prefix length %q", bitsText)
+		}
+	}
+	return netip.PrefixFrom(addr, bits).Masked(), nil
+}
+
+// match returns true if ip matches the mechanism t of the SPF record of
+// domain.
+func (c *Checker) match(ip netip.Addr, domain, sender string, t *term) (bool, error) {
+	switch t.name {
+	case "all":
+		return true, nil
+	case "ip4", "ip6":
+		return t.prefix.Contains(ip), nil
+	}
+
+	// The other mechanisms require lookups.
+	if err := c.countLookup(); err != nil {
+		return false, err
+	}
+	target := domain
+	if t.domainSpec != "" {
+		var err error
+		if target, err = c.expand(t.domainSpec, ip, domain, sender); err != nil {
+			return false, err
+		}
+	}
+
+	switch t.name {
+	case "include":
+		res, err := c.checkHost(ip, target, sender)
+		switch res.Result {
+		case ResultPass:
+			return true, nil
+		case ResultFail, ResultSoftFail, ResultNeutral:
+			return false, nil
+		case ResultNone:
+			return false, permErrorf("include:%s: %s has no SPF record", t.domainSpec, target)
+		default:
+			return false, err
+		}
+
+	case "a":
+		addrs, err := c.lookupIP(ip, target)
+		if err != nil {
+			return false, err
+		}
+		if err := c.countVoid(len(addrs)); err != nil {
+			return false, err
+		}
+		return matchAddrs(ip, addrs, t), nil
+
+	case "mx":
+		hosts, err := c.Resolver.LookupMX(target)
+		if err != nil {
+			return false, tempError(err)
+		}
+		if err := c.countVoid(len(hosts)); err != nil {
+			return false, err
+		}
+		if len(hosts) > maxMXNames {
+			return false, permErrorf("%s has more than %d MX records", target, maxMXNames)
+		}
+		for _, host := range hosts {
+			if host == "." || host == "" {
+				continue // A null MX (RFC 7505).
+			}
+			addrs, err := c.lookupIP(ip, host)
+			if err != nil {
+				return false, err
+			}
+			if matchAddrs(ip, addrs, t) {
+				return true, nil
+			}
+		}
+		return false, nil
+
+	case "ptr":
+		for _, name := range c.validatedNames(ip) {
+			if strings.EqualFold(name, target) || strings.HasSuffix(strings.ToLower(name), "."+strings.ToLower(target)) {
+				return true, nil
+			}
+		}
+		return false, nil
+
+	case "exists":
+		// Always an A lookup, whatever the family of ip.
+		addrs, err := c.Resolver.LookupIP("ip4", target)
+		if err != nil {
+			return false, tempError(err)
+		}
+		if err := c.countVoid(len(addrs)); err != nil {
+			return false, err
+		}
+		return len(addrs) > 0, nil
+	}
+	return false, permErrorf("%s: unknown mechanism", t.text)
+}
+
+// lookupIP looks up the addresses of name of the family of ip.
+func (c *Checker) lookupIP(ip netip.Addr, name string) ([]netip.Addr, error) {
+	network := "ip6"
+	if ip.Is4() {
+		network = "ip4"
+	}
+	addrs, err := c.Resolver.LookupIP(network, name)
+	if err != nil {
+		return nil, tempError(err)
+	}
+	return addrs, nil
+}
+
+// matchAddrs returns true if ip is in the range of one of addrs, with the
+// prefix lengths of t.
+func matchAddrs(ip netip.Addr, addrs []netip.Addr, t *term) bool {
+	bits := t.cidr6
+	if ip.Is4() {
+		bits = t.cidr4
+	}
+	for _, addr := range addrs {
+		if pfx, err := addr.Unmap().Prefix(bits); err == nil && pfx.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// validatedNames returns the names of the PTR records of ip that have an
+// address record of ip (RFC 7208, section 5.5). Errors are ignored: the
+// names that can't be validated are skipped.
+func (c *Checker) validatedNames(ip netip.Addr) []string {
+	names, err := c.Resolver.LookupAddr(ip)
+	if err != nil {
+		return nil
+	}
+	if len(names) > maxPTRNames {
+		names = names[:maxPTRNames]
+	}
+	var validated []string
+	for _, name := range names {
+		name = strings.TrimSuffix(name, ".")
+		addrs, err := c.lookupIP(ip, name)
+		if err == nil && slices.Contains(addrs, ip) {
+			validated = append(validated, name)
+		}
+	}
+	return validated
+}
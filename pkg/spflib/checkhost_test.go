@@ -0,0 +1,232 @@
+package spflib
+
+import (
+	"fmt"
+	"net/netip"
+	"strings"
+	"testing"
+)
+
+// fakeHostResolver answers from maps keyed by name (or address for ptr).
+type fakeHostResolver struct {
+	txt  map[string][]string
+	ip   map[string][]string
+	mx   map[string][]string
+	ptr  map[string][]string
+	fail map[string]bool // Names whose lookups time out.
+}
+
+func (r fakeHostResolver) LookupTXT(name string) ([]string, error) {
+	if r.fail[name] {
+		return nil, fmt.Errorf("lookup %s: i/o timeout", name)
+	}
+	return r.txt[name], nil
+}
+
+func (r fakeHostResolver) LookupIP(network, name string) ([]netip.Addr, error) {
+	if r.fail[name] {
+		return nil, fmt.Errorf("lookup %s: i/o timeout", name)
+	}
+	var addrs []netip.Addr
+	for _, s := range r.ip[name] {
+		addr := netip.MustParseAddr(s)
+		if addr.Is4() == (network == "ip4") {
+			addrs = append(addrs, addr)
+		}
+	}
+	return addrs, nil
+}
+
+func (r fakeHostResolver) LookupMX(name string) ([]string, error) {
+	return r.mx[name], nil
+}
+
+func (r fakeHostResolver) LookupAddr(addr netip.Addr) ([]string, error) {
+	return r.ptr[addr.String()], nil
+}
+
+func TestCheckHost(t *testing.T) {
+	res := fakeHostResolver{
+		txt: map[string][]string{
+			"example.com":          {"google-site-verification=abc", "v=spf1 ip4:192.0.2.0/24 include:_spf.example.net a:mail.example.com mx/30 ~all"},
+			"_spf.example.net":     {"v=spf1 ip6:2001:db8::/32 -all"},
+			"redirect.example.com": {"v=spf1 redirect=example.com"},
+			"ptr.example.com":      {"v=spf1 ptr -all"},
+			"exists.example.com":   {"v=spf1 exists:%{ir}.%{l1r+-}._spf.%{d} -all"},
+			"double.example.com":   {"v=spf1 -all", "v=spf1 +all"},
+			"bad.example.com":      {"v=spf1 ip4:300.0.0.1 -all"},
+			"missing.example.com":  {"v=spf1 include:none.example.com -all"},
+			"void.example.com":     {"v=spf1 a:v1.example.com a:v2.example.com a:v3.example.com -all"},
+			"loop.example.com":     {"v=spf1 include:loop.example.com -all"},
+			"temp.example.com":     {"v=spf1 include:down.example.com -all"},
+			"a6.example.com":       {"v=spf1 a//64 -all"},
+			"neutral.example.com":  {"v=spf1 ip4:198.51.100.1"},
+		},
+		ip: map[string][]string{
+			"mail.example.com":                          {"203.0.113.10"},
+			"mx1.example.com":                           {"203.0.113.20"},
+			"host.ptr.example.com":                      {"198.51.100.7"},
+			"liar.example.org":                          {"198.51.100.9"},
+			"7.100.51.198.jane._spf.exists.example.com": {"127.0.0.2"},
+			"a6.example.com":                            {"2001:db8:1::1"},
+		},
+		mx: map[string][]string{
+			"example.com": {"mx1.example.com"},
+		},
+		ptr: map[string][]string{
+			"198.51.100.7": {"host.ptr.example.com."},
+			"198.51.100.8": {"host.ptr.example.com."}, // Not validated.
+		},
+		fail: map[string]bool{"down.example.com": true},
+	}
+
+	tests := []struct {
+		ip, domain, sender string
+		want               Result
+		mechanism          string
+	}{
+		{"192.0.2.55", "example.com", "", ResultPass, "ip4:192.0.2.0/24"},
+		{"2001:db8::25", "example.com", "", ResultPass, "include:_spf.example.net"},
+		{"203.0.113.10", "example.com", "", ResultPass, "a:mail.example.com"},
+		{"203.0.113.22", "example.com", "", ResultPass, "mx/30"},
+		{"203.0.113.30", "example.com", "", ResultSoftFail, "~all"},
+		{"::ffff:192.0.2.1", "example.com", "", ResultPass, "ip4:192.0.2.0/24"},
+		{"192.0.2.1", "redirect.example.com", "", ResultPass, "ip4:192.0.2.0/24"},
+		{"198.51.100.7", "ptr.example.com", "", ResultPass, "ptr"},
+		{"198.51.100.8", "ptr.example.com", "", ResultFail, "-all"},
+		{"198.51.100.7", "exists.example.com", "jane-john@example.org", ResultPass, "exists:%{ir}.%{l1r+-}._spf.%{d}"},
+		{"198.51.100.7", "exists.example.com", "john-jane@example.org", ResultFail, "-all"},
+		{"2001:db8:1::abcd", "a6.example.com", "", ResultPass, "a//64"},
+		{"192.0.2.1", "neutral.example.com", "", ResultNeutral, ""},
+		{"192.0.2.1", "nospf.example.com", "", ResultNone, ""},
+		{"192.0.2.1", "localhost", "", ResultNone, ""},
+		{"192.0.2.1", "double.example.com", "", ResultPermError, ""},
+		{"192.0.2.1", "bad.example.com", "", ResultPermError, ""},
+		{"192.0.2.1", "missing.example.com", "", ResultPermError, "include:none.example.com"},
+		{"192.0.2.1", "void.example.com", "", ResultPermError, "a:v3.example.com"},
+		{"192.0.2.1", "loop.example.com", "", ResultPermError, "include:loop.example.com"},
+		{"192.0.2.1", "temp.example.com", "", ResultTempError, "include:down.example.com"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.ip+"@"+tt.domain, func(t *testing.T) {
+			c := &Checker{Resolver: res}
+			got, err := c.CheckHost(netip.MustParseAddr(tt.ip), tt.domain, tt.sender)
+			if got.Result != tt.want || got.Mechanism != tt.mechanism {
+				t.Errorf("CheckHost() = %s (%q), %v, want %s (%q)", got.Result, got.Mechanism, err, tt.want, tt.mechanism)
+			}
+			if (err != nil) != (tt.want == ResultPermError || tt.want == ResultTempError) {
+				t.Errorf("CheckHost() error = %v", err)
+			}
+		})
+	}
+}
+
+func TestCheckHostLookupLimit(t *testing.T) {
+	res := fakeHostResolver{txt: map[string][]string{}, ip: map[string][]string{}}
+	var parts []string
+	for i := range 11 {
+		name := fmt.Sprintf("h%d.example.com", i)
+		parts = append(parts, "a:"+name)
+		res.ip[name] = []string{"203.0.113.1"}
+	}
+	res.txt["example.com"] = []string{"v=spf1 " + strings.Join(parts, " ") + " -all"}
+
+	c := &Checker{Resolver: res}
+	got, err := c.CheckHost(netip.MustParseAddr("192.0.2.1"), "example.com", "")
+	if got.Result != ResultPermError || got.Mechanism != "a:h10.example.com" || err == nil {
+		t.Errorf("CheckHost() = %+v, %v, want a permerror at the 11th lookup", got, err)
+	}
+	got, err = c.CheckHost(netip.MustParseAddr("203.0.113.1"), "example.com", "")
+	if got.Result != ResultPass || got.Lookups != 1 || err != nil {
+		t.Errorf("CheckHost() = %+v, %v, want a pass after 1 lookup", got, err)
+	}
+}
+
+func TestParseTermsErrors(t *testing.T) {
+	for _, spf := range []string{
+		"v=spf1 foo -all",
+		"v=spf1 all:example.com",
+		"v=spf1 include -all",
+		"v=spf1 include: -all",
+		"v=spf1 a/33 -all",
+		"v=spf1 mx//129 -all",
+		"v=spf1 a:example.com/024",
+		"v=spf1 ip4:192.0.2.0/33",
+		"v=spf1 ip6:192.0.2.1",
+		"v=spf1 ip4:2001:db8::1",
+		"v=spf1 redirect=a.example.com redirect=b.example.com",
+		"v=spf1 exp=a.example.com exp=b.example.com",
+		"v=spf1 exists:%{x}.example.com",
+		"v=spf1 exists:%{d0}.example.com",
+		"v=spf1 exists:%{d}%.example.com",
+		"v=spf1 exists:%{c}.example.com",
+	} {
+		if _, _, err := parseTerms(spf); err == nil {
+			t.Errorf("parseTerms(%q) was accepted", spf)
+		}
+	}
+	for _, spf := range []string{
+		"v=spf1",
+		"v=spf1 +a -mx ~ptr ?all",
+		"v=spf1 a:example.com/24//64 mx:%{d2}//48 ip4:192.0.2.1 ip6:2001:db8::/32",
+		"v=spf1 exists:%{l/}.example.com -all",
+		"v=spf1 -all exp=explain.%{d} moo=%{c}", // Unknown modifiers are ignored.
+		"v=spf1 -all exp=%{r}.%{t}.example.com",
+	} {
+		if _, _, err := parseTerms(spf); err != nil {
+			t.Errorf("parseTerms(%q) = %v", spf, err)
+		}
+	}
+}
+
+// TestExpand checks the examples of RFC 7208, section 7.4.
+func TestExpand(t *testing.T) {
+	c := &Checker{Resolver: fakeHostResolver{}}
+	ip4 := netip.MustParseAddr("192.0.2.3")
+	ip6 := netip.MustParseAddr("2001:db8::cb01")
+	tests := []struct {
+		ip   netip.Addr
+		spec string
+		want string
+	}{
+		{ip4, "%{s}", "strong-bad@email.example.com"},
+		{ip4, "%{o}", "email.example.com"},
+		{ip4, "%{d}", "email.example.com"},
+		{ip4, "%{d4}", "email.example.com"},
+		{ip4, "%{d3}", "email.example.com"},
+		{ip4, "%{d2}", "example.com"},
+		{ip4, "%{d1}", "com"},
+		{ip4, "%{dr}", "com.example.email"},
+		{ip4, "%{d2r}", "example.email"},
+		{ip4, "%{l}", "strong-bad"},
+		{ip4, "%{l-}", "strong.bad"},
+		{ip4, "%{lr}", "strong-bad"},
+		{ip4, "%{lr-}", "bad.strong"},
+		{ip4, "%{l1r-}", "strong"},
+		{ip4, "%{ir}.%{v}._spf.%{d2}", "3.2.0.192.in-addr._spf.example.com"},
+		{ip4, "%{lr-}.lp._spf.%{d2}", "bad.strong.lp._spf.example.com"},
+		{ip4, "%{lr-}.lp.%{ir}.%{v}._spf.%{d2}", "bad.strong.lp.3.2.0.192.in-addr._spf.example.com"},
+		{ip4, "%{ir}.%{v}.%{l1r-}.lp._spf.%{d2}", "3.2.0.192.in-addr.strong.lp._spf.example.com"},
+		{ip4, "%{d2}.trusted-domains.example.net", "example.com.trusted-domains.example.net"},
+		{ip6, "%{ir}.%{v}._spf.%{d2}", "1.0.b.c.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.8.b.d.0.1.0.0.2.ip6._spf.example.com"},
+		{ip4, "%{S}.%%.%_%-", "strong-bad%40email.example.com.%. %20"},
+		{ip4, "%{p}.example.net", "unknown.example.net"},
+	}
+	for _, tt := range tests {
+		got, err := c.expand(tt.spec, tt.ip, "email.example.com", "strong-bad@email.example.com")
+		if err != nil || got != tt.want {
+			t.Errorf("expand(%q) = %q, %v, want %q", tt.spec, got, err, tt.want)
+		}
+	}
+
+	// A domain longer than 253 characters loses its leftmost labels.
+	var labels []string
+	for _, l := range "abcde" {
+		labels = append(labels, strings.Repeat(string(l), 50))
+	}
+	want := strings.Join(labels[1:], ".")
+	got, err := c.expand("%{l}."+strings.Join(labels, "."), ip4, "example.com", "x@example.com")
+	if err != nil || got != want {
+		t.Errorf("expand() = %q, %v, want %q", got, err, want)
+	}
+}
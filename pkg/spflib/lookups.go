@@ -0,0 +1,74 @@
+package spflib
+
+import (
+	"slices"
+	"strings"
+)
+
+// CountLookups returns the number of DNS lookups that the SPF record spf of
+// domain counts against the limit of 10 (RFC 7208, section 4.6.4) in the
+// worst case, when every mechanism is evaluated: the a, mx, ptr, exists and
+// include mechanisms and the redirect= modifier, with those of the records
+// that include and redirect= lead to. Targets with macros depend on the
+// message, so they count as one lookup and aren't followed. The error
+// explains a permerror or a temperror (see ErrorResult).
+func CountLookups(r HostResolver, domain, spf string) (int, error) {
+	return countLookups(r, strings.ToLower(strings.TrimSuffix(domain, ".")), spf, nil)
+}
+
+// countLookups implements CountLookups. path is the chain of domains that
+// led to domain, to detect loops.
+func countLookups(r HostResolver, domain, spf string, path []string) (int, error) {
+	if slices.Contains(path, domain) {
+		return 0, permErrorf("%s includes itself", domain)
+	}
+	path = append(path, domain)
+
+	terms, redirect, err := parseTerms(spf)
+	if err != nil {
+		return 0, err
+	}
+
+	// follow counts the lookups of the SPF record of target.
+	follow := func(term, target string) (int, error) {
+		if strings.Contains(target, "%") {
+			return 0, nil
+		}
+		target = strings.ToLower(strings.TrimSuffix(target, "."))
+		spf, err := lookupSPF(r, target)
+		if err != nil {
+			return 0, err
+		}
+		if spf == "" {
+			return 0, permErrorf("%s: %s has no SPF record", term, target)
+		}
+		return countLookups(r, target, spf, path)
+	}
+
+	count := 0
+	for _, t := range terms {
+		switch t.name {
+		case "all":
+			// Neither the mechanisms after all nor redirect= are evaluated.
+			return count, nil
+		case "ip4", "ip6":
+			continue
+		}
+		count++
+		if t.name == "include" {
+			n, err := follow(t.text, t.domainSpec)
+			if err != nil {
+				return 0, err
+			}
+			count += n
+		}
+	}
+	if redirect != "" {
+		n, err := follow("redirect="+redirect, redirect)
+		if err != nil {
+			return 0, err
+		}
+		count += 1 + n
+	}
+	return count, nil
+}
@@ -0,0 +1,40 @@
+package spflib
+
+import "testing"
+
+func TestCountLookups(t *testing.T) {
+	res := fakeHostResolver{
+		txt: map[string][]string{
+			"_spf.example.net":  {"v=spf1 include:_spf1.example.net include:_spf2.example.net ~all"},
+			"_spf1.example.net": {"v=spf1 ip4:192.0.2.0/24 a mx -all"},
+			"_spf2.example.net": {"v=spf1 ip6:2001:db8::/32 -all"},
+			"base.example.com":  {"v=spf1 ptr exists:%{i}.example.com"},
+			"loop.example.com":  {"v=spf1 include:loop2.example.com -all"},
+			"loop2.example.com": {"v=spf1 redirect=loop.example.com"},
+		},
+		fail: map[string]bool{"down.example.com": true},
+	}
+	tests := []struct {
+		spf    string
+		want   int
+		result Result // Of the error, if any.
+	}{
+		{"v=spf1 -all", 0, ""},
+		{"v=spf1 ip4:192.0.2.1 ip6:2001:db8::1 -all", 0, ""},
+		{"v=spf1 a mx:example.com/24 -all", 2, ""},
+		{"v=spf1 include:_spf.example.net -all", 5, ""},
+		{"v=spf1 a include:_spf.example.net redirect=base.example.com", 9, ""},
+		{"v=spf1 a -all redirect=base.example.com", 1, ""}, // redirect= isn't evaluated.
+		{"v=spf1 include:%{d}.example.com -all", 1, ""},    // Macros aren't followed.
+		{"v=spf1 include:none.example.com -all", 0, ResultPermError},
+		{"v=spf1 include:loop.example.com -all", 0, ResultPermError},
+		{"v=spf1 include:down.example.com -all", 0, ResultTempError},
+		{"v=spf1 bogus -all", 0, ResultPermError},
+	}
+	for _, tt := range tests {
+		got, err := CountLookups(res, "example.com.", tt.spf)
+		if got != tt.want || (err != nil) != (tt.result != "") || (err != nil && ErrorResult(err) != tt.result) {
+			t.Errorf("CountLookups(%q) = %d, %v, want %d (%s)", tt.spf, got, err, tt.want, tt.result)
+		}
+	}
+}
@@ -0,0 +1,209 @@
+package spflib
+
+import (
+	"fmt"
+	"net/netip"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// The macros of RFC 7208, section 7.
+const (
+	macroLetters    = "slodiphv"
+	expLetters      = macroLetters + "crt" // Not allowed in domain-specs.
+	macroDelimiters = ".-+,/_="
+	maxDomainLength = 253
+)
+
+// expand expands the macros of the domain-spec spec in the SPF record of
+// domain. A result longer than 253 characters loses its leftmost labels.
+func (c *Checker) expand(spec string, ip netip.Addr, domain, sender string) (string, error) {
+	local, senderDomain, _ := strings.Cut(sender, "@")
+	out, err := expandMacros(spec, macroLetters, func(letter byte) string {
+		switch letter {
+		case 's':
+			return sender
+		case 'l':
+			return local
+		case 'o':
+			return senderDomain
+		case 'd':
+			return domain
+		case 'i':
+			return macroIP(ip)
+		case 'p':
+			return c.macroPTR(ip, domain)
+		case 'v':
+			if ip.Is4() {
+				return "in-addr"
+			}
+			return "ip6"
+		default: // 'h'
+			if c.HELO != "" {
+				return c.HELO
+			}
+			return senderDomain
+		}
+	})
+	if err != nil {
+		return "", err
+	}
+	out = strings.TrimSuffix(out, ".")
+	for len(out) > maxDomainLength {
+		_, after, ok := strings.Cut(out, ".")
+		if !ok {
+			break
+		}
+		out = after
+	}
+	return out, nil
+}
+
+// checkMacroString returns an error if s has invalid macros, or macro
+// letters that aren't in letters.
+func checkMacroString(s, letters string) error {
+	_, err := expandMacros(s, letters, func(byte) string { return "x" })
+	return err
+}
+
+// expandMacros expands the macros of s. value returns the value of a macro
+// letter, in lower case.
+func expandMacros(s, letters string, value func(letter byte) string) (string, error) {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		ch := s[i]
+		if ch != '%' {
+			if ch < 0x21 || ch > 0x7e {
+				return "", permErrorf("%q: invalid character", s)
+			}
+			b.WriteByte(ch)
+			continue
+		}
+		i++
+		if i >= len(s) {
+			return "", permErrorf("%q: incomplete macro", s)
+		}
+		switch s[i] {
+		case '%':
+			b.WriteByte('%')
+		case '_':
+			b.WriteByte(' ')
+		case '-':
+			b.WriteString("%20")
+		case '{':
+			end := strings.IndexByte(s[i:], '}')
+			if end < 0 {
+				return "", permErrorf("%q: unterminated macro", s)
+			}
+			expanded, err := expandMacro(s[i+1:i+end], letters, value)
+			if err != nil {
+				return "", permErrorf("%q: %w", s, err)
+			}
+			b.WriteString(expanded)
+			i += end
+		default:
+			return "", permErrorf("%q: invalid macro %%%c", s, s[i])
+		}
+	}
+	return b.String(), nil
+}
+
+// expandMacro expands the macro in %{...}: a letter, the number of
+// rightmost parts to keep, "r" to reverse the parts, and the delimiters
+// that split them.
+func expandMacro(spec, letters string, value func(letter byte) string) (string, error) {
+	if spec == "" {
+		return "", fmt.Errorf("empty macro")
+	}
+	letter := spec[0]
+	lower := letter | 0x20
+	if !strings.ContainsRune(letters, rune(lower)) {
+		return "", fmt.Errorf("invalid macro letter %q", letter)
+	}
+	rest := spec[1:]
+
+	digits := len(rest) - len(strings.TrimLeft(rest, "0123456789"))
+	keep := 0
+	if digits > 0 {
+		var err error
+		if keep, err = strconv.Atoi(rest[:digits]); err != nil || keep == 0 {
+			return "", fmt.Errorf("invalid number of parts %q", rest[:digits])
+		}
+	}
+	rest = rest[digits:]
+	reverse := false
+	if rest != "" && (rest[0] == 'r' || rest[0] == 'R') {
+		reverse, rest = true, rest[1:]
+	}
+	delimiters := rest
+	if strings.Trim(delimiters, macroDelimiters) != "" {
+		return "", fmt.Errorf("invalid delimiters %q", delimiters)
+	}
+	if delimiters == "" {
+		delimiters = "."
+	}
+
+	parts := strings.FieldsFunc(value(lower), func(r rune) bool {
+		return strings.ContainsRune(delimiters, r)
+	})
+	if reverse {
+		slices.Reverse(parts)
+	}
+	if keep > 0 && keep < len(parts) {
+		parts = parts[len(parts)-keep:]
+	}
+	out := strings.Join(parts, ".")
+	if letter != lower {
+		out = urlEscape(out)
+	}
+	return out, nil
+}
+
+// urlEscape escapes the characters that aren't unreserved (RFC 3986), for
+// the macros in upper case.
+func urlEscape(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		ch := s[i]
+		if ch >= 'a' && ch <= 'z' || ch >= 'A' && ch <= 'Z' || ch >= '0' && ch <= '9' || strings.IndexByte("-._~", ch) >= 0 {
+			b.WriteByte(ch)
+		} else {
+			fmt.Fprintf(&b, "%%%02X", ch)
+		}
+	}
+	return b.String()
+}
+
+// macroIP returns the %{i} macro: an IPv4 address, or the nibbles of an IPv6
+// address separated by dots.
+func macroIP(ip netip.Addr) string {
+	if ip.Is4() {
+		return ip.String()
+	}
+	var nibbles []string
+	for _, b := range ip.As16() {
+		nibbles = append(nibbles, strconv.FormatUint(uint64(b>>4), 16), strconv.FormatUint(uint64(b&0xf), 16))
+	}
+	return strings.Join(nibbles, ".")
+}
+
+// macroPTR returns the %{p} macro: a validated name of ip, domain or one of
+// its subdomains if possible, "unknown" if there is none.
+func (c *Checker) macroPTR(ip netip.Addr, domain string) string {
+	names := c.validatedNames(ip)
+	if len(names) == 0 {
+		return "unknown"
+	}
+	for _, name := range names {
+		if strings.EqualFold(name, domain) {
+			return name
+		}
+	}
+	for _, name := range names {
+		if strings.HasSuffix(strings.ToLower(name), "."+strings.ToLower(domain)) {
+			return name
+		}
+	}
+	return names[0]
+}
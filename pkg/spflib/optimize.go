@@ -0,0 +1,272 @@
+package spflib
+
+import (
+	"cmp"
+	"fmt"
+	"net/netip"
+	"slices"
+	"strings"
+)
+
+// MaxLookups is the maximum number of DNS lookups of an SPF record (RFC 7208,
+// section 4.6.4).
+const MaxLookups = 10
+
+// maxOptimizeIncludes is the maximum number of includes whose combinations
+// Optimize tries. With more, it flattens all of them.
+const maxOptimizeIncludes = 12
+
+// Aggregate returns s with its ip4: and ip6: mechanisms merged: duplicates
+// are removed, and overlapping and adjacent ranges are replaced by the
+// fewest CIDRs that cover them. Only the mechanisms that pass (no qualifier,
+// or +) are merged, and never across a mechanism with another qualifier, as
+// the first mechanism that matches decides the result.
+func (s *SPFRecord) Aggregate() *SPFRecord {
+	newRec := &SPFRecord{}
+	var prefixes []netip.Prefix
+	at := -1 // Where the merged ranges go in newRec.Parts.
+	flush := func() {
+		if at < 0 {
+			return
+		}
+		var parts []*SPFPart
+		for _, pfx := range aggregatePrefixes(prefixes) {
+			parts = append(parts, &SPFPart{Text: prefixText(pfx)})
+		}
+		newRec.Parts = slices.Insert(newRec.Parts, at, parts...)
+		prefixes, at = nil, -1
+	}
+	for _, p := range s.Parts {
+		if pfx, ok := passPrefix(p.Text); ok {
+			if at < 0 {
+				at = len(newRec.Parts)
+			}
+			prefixes = append(prefixes, pfx)
+			continue
+		}
+		if p.Text != "" && qualifiers[p.Text[0]] && p.Text[0] != '+' {
+			flush()
+		}
+		newRec.Parts = append(newRec.Parts, p)
+	}
+	flush()
+	return newRec
+}
+
+// passPrefix returns the range of an ip4: or ip6: mechanism that passes.
+func passPrefix(text string) (netip.Prefix, bool) {
+	text = strings.ToLower(strings.TrimPrefix(text, "+"))
+	var want4 bool
+	switch {
+	case strings.HasPrefix(text, "ip4:"):
+		want4 = true
+	case strings.HasPrefix(text, "ip6:"):
+	default:
+		return netip.Prefix{}, false
+	}
+	text = text[len("ip4:"):]
+
+	var pfx netip.Prefix
+	if strings.Contains(text, "/") {
+		var err error
+		if pfx, err = netip.ParsePrefix(text); err != nil {
+			return netip.Prefix{}, false
+		}
+	} else {
+		addr, err := netip.ParseAddr(text)
+		if err != nil {
+			return netip.Prefix{}, false
+		}
+		pfx = netip.PrefixFrom(addr, addr.BitLen())
+	}
+	if pfx.Addr().Is4() != want4 || pfx.Addr().Is4In6() || pfx.Addr().Zone() != "" {
+		return netip.Prefix{}, false
+	}
+	return pfx.Masked(), true
+}
+
+// aggregatePrefixes returns the fewest prefixes that cover prefixes, IPv4
+// first, in order.
+func aggregatePrefixes(prefixes []netip.Prefix) []netip.Prefix {
+	prefixes = slices.Clone(prefixes)
+	slices.SortFunc(prefixes, func(a, b netip.Prefix) int {
+		if c := a.Addr().Compare(b.Addr()); c != 0 {
+			return c
+		}
+		return cmp.Compare(a.Bits(), b.Bits())
+	})
+
+	var out []netip.Prefix
+	for _, pfx := range prefixes {
+		// The ranges are sorted: only the last one can contain pfx.
+		if n := len(out); n > 0 && out[n-1].Bits() <= pfx.Bits() && out[n-1].Contains(pfx.Addr()) {
+			continue
+		}
+		out = append(out, pfx)
+		// Merge the halves of a range.
+		for n := len(out); n > 1; n = len(out) {
+			a, b := out[n-2], out[n-1]
+			if a.Bits() != b.Bits() || a.Bits() == 0 || a.Addr().BitLen() != b.Addr().BitLen() {
+				break
+			}
+			parent := netip.PrefixFrom(a.Addr(), a.Bits()-1).Masked()
+			if parent != netip.PrefixFrom(b.Addr(), b.Bits()-1).Masked() {
+				break
+			}
+			out = append(out[:n-2], parent)
+		}
+	}
+	return out
+}
+
+// prefixText returns the ip4: or ip6: mechanism of pfx.
+func prefixText(pfx netip.Prefix) string {
+	mech := "ip6:"
+	if pfx.Addr().Is4() {
+		mech = "ip4:"
+	}
+	if pfx.IsSingleIP() {
+		return mech + pfx.Addr().String()
+	}
+	return mech + pfx.String()
+}
+
+// OptimizeResult is the result of Optimize.
+type OptimizeResult struct {
+	Record *SPFRecord
+	// Flatten is the flatten spec that was used: the includes that were
+	// flattened.
+	Flatten string
+	// LookupsBefore and LookupsAfter are the number of lookups of the record
+	// before and after. LookupsAfter includes the includes that chain the
+	// records of the split.
+	LookupsBefore int
+	LookupsAfter  int
+	// Records is the number of TXT records once split.
+	Records int
+
+	flattened int // The number of includes picked by Optimize.
+	length    int // The length of the record before the split.
+}
+
+// betterThan returns true if r is a better optimization than o: within the
+// lookup limit (or the fewest lookups), with the fewest records, flattening
+// the fewest includes, the shortest.
+func (r *OptimizeResult) betterThan(o *OptimizeResult) bool {
+	switch {
+	case o == nil:
+		return true
+	case (r.LookupsAfter <= MaxLookups) != (o.LookupsAfter <= MaxLookups):
+		return r.LookupsAfter <= MaxLookups
+	case r.LookupsAfter > MaxLookups:
+		return r.LookupsAfter < o.LookupsAfter
+	case r.Records != o.Records:
+		return r.Records < o.Records
+	case r.flattened != o.flattened:
+		return r.flattened < o.flattened
+	default:
+		return r.length < o.length
+	}
+}
+
+// Optimize flattens s and merges its ranges (see Aggregate) so that it
+// requires at most MaxLookups lookups with the fewest TXT records, once
+// split by TXTSplit(pattern, overhead, txtMaxSize). pattern is empty if the
+// record isn't split. The includes of spec are always flattened; Optimize
+// picks which of the others to flatten (as few as possible between the
+// combinations with the fewest records). It returns an error if the record
+// requires too many lookups even once fully flattened.
+func (s *SPFRecord) Optimize(spec, pattern string, overhead, txtMaxSize int) (*OptimizeResult, error) {
+	var always []string
+	if spec != "" {
+		always = strings.Split(spec, ",")
+	}
+	var candidates []string
+	if spec != "*" {
+		for _, p := range s.Parts {
+			if p.IncludeRecord != nil && !slices.Contains(always, p.IncludeDomain) && !slices.Contains(candidates, p.IncludeDomain) {
+				candidates = append(candidates, p.IncludeDomain)
+			}
+		}
+	}
+
+	var best *OptimizeResult
+	try := func(flatten []string) {
+		if res := s.optimized(always, flatten, pattern, overhead, txtMaxSize); res.betterThan(best) {
+			best = res
+		}
+	}
+	if len(candidates) > maxOptimizeIncludes {
+		try(candidates)
+	} else {
+		for mask := 0; mask < 1<<len(candidates); mask++ {
+			var flatten []string
+			for i, c := range candidates {
+				if mask&(1<<i) != 0 {
+					flatten = append(flatten, c)
+				}
+			}
+			try(flatten)
+		}
+	}
+
+	best.LookupsBefore = s.Lookups()
+	if best.LookupsAfter > MaxLookups {
+		return best, fmt.Errorf("the SPF record requires %d lookups once flattened, more than %d", best.LookupsAfter, MaxLookups)
+	}
+	return best, nil
+}
+
+// optimized returns s with the includes of always and flatten flattened
+// (with the includes they include), and its ranges merged.
+func (s *SPFRecord) optimized(always, flatten []string, pattern string, overhead, txtMaxSize int) *OptimizeResult {
+	domains := slices.Clone(always)
+	for _, p := range s.Parts {
+		if p.IncludeRecord != nil && slices.Contains(flatten, p.IncludeDomain) {
+			domains = append(domains, p.IncludeDomain)
+			domains = append(domains, p.IncludeRecord.includeDomains()...)
+		}
+	}
+	spec := strings.Join(uniqueStrings(domains), ",")
+	if slices.Contains(always, "*") {
+		spec = "*"
+	}
+
+	rec := s.Flatten(spec).Aggregate()
+	records := 1
+	if pattern != "" {
+		records = len(rec.TXTSplit(pattern, overhead, txtMaxSize))
+	}
+	return &OptimizeResult{
+		Record:  rec,
+		Flatten: spec,
+		// Each record of the split includes the next one.
+		LookupsAfter: rec.Lookups() + records - 1,
+		Records:      records,
+		flattened:    len(flatten),
+		length:       len(rec.TXT()),
+	}
+}
+
+// includeDomains returns the domains that s includes, at any depth.
+func (s *SPFRecord) includeDomains() []string {
+	var domains []string
+	for _, p := range s.Parts {
+		if p.IncludeRecord != nil {
+			domains = append(domains, p.IncludeDomain)
+			domains = append(domains, p.IncludeRecord.includeDomains()...)
+		}
+	}
+	return domains
+}
+
+// uniqueStrings returns s without duplicates, in order.
+func uniqueStrings(s []string) []string {
+	var out []string
+	for _, v := range s {
+		if !slices.Contains(out, v) {
+			out = append(out, v)
+		}
+	}
+	return out
+}
@@ -0,0 +1,181 @@
+package spflib
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestAggregate(t *testing.T) {
+	tests := []struct {
+		description string
+		input       string
+		want        string
+	}{
+		{
+			description: "duplicates",
+			input:       "v=spf1 ip4:192.0.2.1 ip4:192.0.2.1 ip4:192.0.2.1/32 -all",
+			want:        "v=spf1 ip4:192.0.2.1 -all",
+		},
+		{
+			description: "adjacent ranges",
+			input:       "v=spf1 ip4:192.0.2.0/25 ip4:192.0.2.128/25 ip4:198.51.100.4 ip4:198.51.100.5 -all",
+			want:        "v=spf1 ip4:192.0.2.0/24 ip4:198.51.100.4/31 -all",
+		},
+		{
+			description: "merges cascade",
+			input:       "v=spf1 ip4:10.0.0.0/24 ip4:10.0.3.0/24 ip4:10.0.1.0/24 ip4:10.0.2.0/24 ~all",
+			want:        "v=spf1 ip4:10.0.0.0/22 ~all",
+		},
+		{
+			description: "overlapping ranges and host bits",
+			input:       "v=spf1 ip4:10.1.2.3/16 ip4:10.1.200.0/24 ip4:10.1.7.7 -all",
+			want:        "v=spf1 ip4:10.1.0.0/16 -all",
+		},
+		{
+			description: "not adjacent",
+			input:       "v=spf1 ip4:10.0.1.0/24 ip4:10.0.2.0/24 -all",
+			want:        "v=spf1 ip4:10.0.1.0/24 ip4:10.0.2.0/24 -all",
+		},
+		{
+			description: "ip6, sorted after ip4",
+			input:       "v=spf1 ip6:2001:db8::/33 ip4:192.0.2.1 ip6:2001:db8:8000::/33 ip6:2001:DB8::1 -all",
+			want:        "v=spf1 ip4:192.0.2.1 ip6:2001:db8::/32 -all",
+		},
+		{
+			description: "other mechanisms are kept in place",
+			input:       "v=spf1 mx ip4:192.0.2.0/25 include:example.net +ip4:192.0.2.128/25 a -all",
+			want:        "v=spf1 mx ip4:192.0.2.0/24 include:example.net a -all",
+		},
+		{
+			description: "not merged across another qualifier",
+			input:       "v=spf1 ip4:192.0.2.0/25 -ip4:192.0.2.200 ip4:192.0.2.128/25 ?ip4:192.0.2.1 ~all",
+			want:        "v=spf1 ip4:192.0.2.0/25 -ip4:192.0.2.200 ip4:192.0.2.128/25 ?ip4:192.0.2.1 ~all",
+		},
+		{
+			description: "invalid ranges are kept",
+			input:       "v=spf1 ip4:192.0.2.300 ip4:2001:db8::1 ip4:192.0.2.1 -all",
+			want:        "v=spf1 ip4:192.0.2.300 ip4:2001:db8::1 ip4:192.0.2.1 -all",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.description, func(t *testing.T) {
+			rec, err := Parse(test.input, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := rec.Aggregate().TXT(); got != test.want {
+				t.Errorf("got  %s\nwant %s", got, test.want)
+			}
+		})
+	}
+}
+
+func TestOptimize(t *testing.T) {
+	var ranges []string
+	for i := 0; i < 30; i++ {
+		ranges = append(ranges, fmt.Sprintf("ip4:203.0.113.%d", 2*i+64))
+	}
+	bigRanges := strings.Join(ranges, " ")
+
+	dnsres := fakeResolver{
+		// 3 lookups each.
+		"one.example.net":   "v=spf1 include:one-a.example.net include:one-b.example.net ~all",
+		"one-a.example.net": "v=spf1 ip4:192.0.2.0/25 ~all",
+		"one-b.example.net": "v=spf1 ip4:192.0.2.128/25 ~all",
+		"two.example.net":   "v=spf1 include:two-a.example.net include:two-b.example.net ~all",
+		"two-a.example.net": "v=spf1 ip4:198.51.100.0/24 ~all",
+		"two-b.example.net": "v=spf1 ip4:198.51.100.0/24 ip6:2001:db8::/32 ~all",
+		// 2 lookups, 1 once flattened.
+		"mx.example.net": "v=spf1 mx ip4:203.0.113.1 ~all",
+		// 3 records once flattened.
+		"big.example.net": "v=spf1 " + bigRanges + " ~all",
+	}
+
+	tests := []struct {
+		description string
+		input       string
+		spec        string
+		pattern     string
+		want        string
+		flatten     string
+		before      int
+		after       int
+		records     int
+	}{
+		{
+			description: "within the limit",
+			input:       "v=spf1 include:one.example.net mx -all",
+			want:        "v=spf1 include:one.example.net mx -all",
+			before:      4,
+			after:       4,
+			records:     1,
+		},
+		{
+			description: "flattens as few includes as possible",
+			input:       "v=spf1 include:one.example.net include:two.example.net include:mx.example.net a a a mx ip4:192.0.2.1 -all",
+			want:        "v=spf1 ip4:192.0.2.0/24 include:two.example.net include:mx.example.net a a a mx -all",
+			flatten:     "one.example.net,one-a.example.net,one-b.example.net",
+			before:      12,
+			after:       9,
+			records:     1,
+		},
+		{
+			description: "the spec is always flattened",
+			input:       "v=spf1 include:one.example.net include:two.example.net -all",
+			spec:        "one.example.net",
+			want:        "v=spf1 include:one-a.example.net include:one-b.example.net include:two.example.net -all",
+			flatten:     "one.example.net",
+			before:      6,
+			after:       5,
+			records:     1,
+		},
+		{
+			description: "fewest records",
+			input:       "v=spf1 include:big.example.net include:two.example.net include:mx.example.net a a a a a -all",
+			pattern:     "_spf%d.example.com",
+			want:        "v=spf1 include:big.example.net include:two.example.net mx ip4:203.0.113.1 a a a a a -all",
+			flatten:     "mx.example.net",
+			before:      11,
+			after:       10,
+			records:     1,
+		},
+		{
+			description: "the records of the split are lookups",
+			input:       "v=spf1 include:big.example.net include:one.example.net a a a a a a a -all",
+			pattern:     "_spf%d.example.com",
+			want:        "v=spf1 include:big.example.net ip4:192.0.2.0/24 a a a a a a a -all",
+			flatten:     "one.example.net,one-a.example.net,one-b.example.net",
+			before:      11,
+			after:       8,
+			records:     1,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.description, func(t *testing.T) {
+			rec, err := Parse(test.input, dnsres)
+			if err != nil {
+				t.Fatal(err)
+			}
+			res, err := rec.Optimize(test.spec, test.pattern, 0, 255)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := res.Record.TXT(); got != test.want {
+				t.Errorf("got  %s\nwant %s", got, test.want)
+			}
+			if res.Flatten != test.flatten || res.LookupsBefore != test.before || res.LookupsAfter != test.after || res.Records != test.records {
+				t.Errorf("got flatten=%q before=%d after=%d records=%d, want flatten=%q before=%d after=%d records=%d",
+					res.Flatten, res.LookupsBefore, res.LookupsAfter, res.Records, test.flatten, test.before, test.after, test.records)
+			}
+		})
+	}
+
+	rec, err := Parse("v=spf1 a a a a a a a a a a mx -all", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := rec.Optimize("", "", 0, 255); err == nil {
+		t.Error("a record with 11 lookups was accepted")
+	}
+}
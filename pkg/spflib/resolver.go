@@ -1,11 +1,18 @@
 package spflib
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net" // Not used for IP addresses.
+	"net/netip"
 	"os"
+	"slices"
 	"strings"
+	"time"
+
+	dnsv1 "github.com/miekg/dns"
 )
 
 // Resolver looks up spf txt records associated with a FQDN.
@@ -13,6 +20,11 @@ type Resolver interface {
 	GetSPF(string) (string, error)
 }
 
+// TTLResolver is a Resolver that also returns the TTL of the records.
+type TTLResolver interface {
+	GetSPFWithTTL(string) (string, uint32, error)
+}
+
 // LiveResolver simply queries DNS to resolve SPF records.
 type LiveResolver struct{}
 
@@ -22,6 +34,50 @@ func (l LiveResolver) GetSPF(name string) (string, error) {
 	if err != nil {
 		return "", err
 	}
+	return pickSPF(name, vals)
+}
+
+// GetSPFWithTTL looks up the SPF record named "name", and its TTL. The TTL
+// is 0 if /etc/resolv.conf has no nameserver to query.
+func (l LiveResolver) GetSPFWithTTL(name string) (string, uint32, error) {
+	conf, err := dnsv1.ClientConfigFromFile("/etc/resolv.conf")
+	if err != nil || len(conf.Servers) == 0 {
+		spf, err := l.GetSPF(name)
+		return spf, 0, err
+	}
+	m := new(dnsv1.Msg)
+	m.SetQuestion(dnsv1.Fqdn(name), dnsv1.TypeTXT)
+	m.SetEdns0(4096, false)
+	client := &dnsv1.Client{}
+	server := net.JoinHostPort(conf.Servers[0], conf.Port)
+	r, _, err := client.Exchange(m, server)
+	if err == nil && r.Truncated {
+		client.Net = "tcp"
+		r, _, err = client.Exchange(m, server)
+	}
+	if err != nil {
+		return "", 0, fmt.Errorf("lookup %s: %w", name, err)
+	}
+	if r.Rcode != dnsv1.RcodeSuccess {
+		return "", 0, fmt.Errorf("lookup %s: %s", name, dnsv1.RcodeToString[r.Rcode])
+	}
+	var vals []string
+	var ttl uint32
+	for _, rr := range r.Answer {
+		// The TTL of the CNAMEs counts too.
+		if ttl == 0 || rr.Header().Ttl < ttl {
+			ttl = rr.Header().Ttl
+		}
+		if txt, ok := rr.(*dnsv1.TXT); ok {
+			vals = append(vals, strings.Join(txt.Txt, ""))
+		}
+	}
+	spf, err := pickSPF(name, vals)
+	return spf, ttl, err
+}
+
+// pickSPF returns the SPF record of the TXT records of name.
+func pickSPF(name string, vals []string) (string, error) {
 	spf := ""
 	for _, v := range vals {
 		if strings.HasPrefix(v, "v=spf1") {
@@ -37,9 +93,70 @@ func (l LiveResolver) GetSPF(name string) (string, error) {
 	return spf, nil
 }
 
+// LookupTXT looks up the TXT records named "name".
+func (l LiveResolver) LookupTXT(name string) ([]string, error) {
+	txts, err := net.LookupTXT(name)
+	return txts, liveError(err)
+}
+
+// LookupIP looks up the A (network "ip4") or AAAA ("ip6") records named
+// "name".
+func (l LiveResolver) LookupIP(network, name string) ([]netip.Addr, error) {
+	addrs, err := net.DefaultResolver.LookupNetIP(context.Background(), network, name)
+	return addrs, liveError(err)
+}
+
+// LookupMX looks up the exchanges of the MX records named "name".
+func (l LiveResolver) LookupMX(name string) ([]string, error) {
+	mxs, err := net.LookupMX(name)
+	if err = liveError(err); err != nil {
+		return nil, err
+	}
+	hosts := make([]string, len(mxs))
+	for i, mx := range mxs {
+		hosts[i] = strings.TrimSuffix(mx.Host, ".")
+	}
+	return hosts, nil
+}
+
+// LookupAddr looks up the names of the PTR records of addr.
+func (l LiveResolver) LookupAddr(addr netip.Addr) ([]string, error) {
+	names, err := net.LookupAddr(addr.String())
+	return names, liveError(err)
+}
+
+// liveError returns nil if err is a name that doesn't exist or has no
+// records of the type.
+func liveError(err error) error {
+	var dnsErr *net.DNSError
+	if errors.As(err, &dnsErr) && dnsErr.IsNotFound {
+		return nil
+	}
+	return err
+}
+
+// Refresh policies of the cache: when the cached records are looked up
+// again.
+const (
+	RefreshNever   = "never"   // Only the records that aren't cached are looked up.
+	RefreshExpired = "expired" // The records whose TTL expired are looked up again.
+	RefreshAlways  = "always"  // All the records are looked up again.
+)
+
+// Refresh is the refresh policy of the caches created by NewCache.
+var Refresh = RefreshAlways
+
+// Offline disables the lookups of the caches created by NewCache: a record
+// that isn't cached is an error.
+var Offline bool
+
+// now is time.Now, except in tests.
+var now = time.Now
+
 // CachingResolver wraps a live resolver and adds caching to it.
 // GetSPF will always return the cached value, if present.
-// It will also query the inner resolver and compare results.
+// Depending on the refresh policy, it will also query the inner resolver
+// and compare results.
 // If a given lookup has inconsistencies between cache and live,
 // GetSPF will return the cached result.
 // All records queries will be stored for the lifetime of the resolver,
@@ -55,28 +172,55 @@ type CachingResolver interface {
 
 type cacheEntry struct {
 	SPF string
+	// Fetched is when SPF was looked up, and TTL its TTL in seconds.
+	Fetched time.Time `json:",omitzero"`
+	TTL     uint32    `json:",omitempty"`
 
 	// value we have looked up this run
 	resolvedSPF  string
+	resolvedTTL  uint32
+	resolvedAt   time.Time
 	resolveError error
+	looked       bool // Looked up this run.
+	used         bool // Requested this run.
+}
+
+// expired returns true if the TTL of the entry expired. An entry without a
+// fetch time (written by older versions) is expired.
+func (e *cacheEntry) expired() bool {
+	return e.Fetched.IsZero() || !now().Before(e.Fetched.Add(time.Duration(e.TTL)*time.Second))
 }
 
 type cache struct {
 	records map[string]*cacheEntry
 
 	inner          Resolver
-	cachePreserved bool // Set to true if cache preservation mode is enabled.
+	cachePreserved bool   // Set to true if cache preservation mode is enabled.
+	refresh        string // The refresh policy.
+	offline        bool   // Set to true to never query inner.
 }
 
-// NewCache creates a new cache file named filename.
+// NewCache creates a new cache file named filename. Its refresh policy is
+// Refresh, and it does no lookups if Offline is set.
 func NewCache(filename string) (CachingResolver, error) {
+	return newCache(filename, LiveResolver{}, Refresh, Offline)
+}
+
+func newCache(filename string, inner Resolver, refresh string, offline bool) (*cache, error) {
+	switch refresh {
+	case RefreshNever, RefreshExpired, RefreshAlways:
+	default:
+		return nil, fmt.Errorf("unknown SPF cache refresh policy %q (valid: %s, %s, %s)", refresh, RefreshNever, RefreshExpired, RefreshAlways)
+	}
 	dat, err := os.ReadFile(filename)
 	if err != nil {
 		if os.IsNotExist(err) {
 			return &cache{
 				records:        map[string]*cacheEntry{},
-				inner:          LiveResolver{},
+				inner:          inner,
 				cachePreserved: false, // Disable cache preservation mode.
+				refresh:        refresh,
+				offline:        offline,
 			}, nil
 		}
 		return nil, err // Otherwise, return the error.
@@ -91,8 +235,10 @@ func NewCache(filename string) (CachingResolver, error) {
 	}
 	return &cache{
 		records:        recs,
-		inner:          LiveResolver{},
+		inner:          inner,
 		cachePreserved: true, // Enable cache preservation mode.
+		refresh:        refresh,
+		offline:        offline,
 	}, nil
 }
 
@@ -103,14 +249,39 @@ func (c *cache) IsCachePreserved() bool {
 	return c.cachePreserved
 }
 
+// needsLookup returns true if the entry must be looked up, according to
+// the refresh policy.
+func (c *cache) needsLookup(entry *cacheEntry) bool {
+	switch {
+	case c.offline:
+		return false
+	case entry.SPF == "" || c.refresh == RefreshAlways:
+		return true
+	case c.refresh == RefreshExpired:
+		return entry.expired()
+	default:
+		return false
+	}
+}
+
 func (c *cache) GetSPF(name string) (string, error) {
 	entry, ok := c.records[name]
 	if !ok {
 		entry = &cacheEntry{}
 		c.records[name] = entry
 	}
-	if entry.resolvedSPF == "" && entry.resolveError == nil {
-		entry.resolvedSPF, entry.resolveError = c.inner.GetSPF(name)
+	entry.used = true
+	if c.offline && entry.SPF == "" {
+		return "", fmt.Errorf("%s is not in the SPF cache (offline mode)", name)
+	}
+	if !entry.looked && c.needsLookup(entry) {
+		entry.looked = true
+		entry.resolvedAt = now()
+		if ttlres, ok := c.inner.(TTLResolver); ok {
+			entry.resolvedSPF, entry.resolvedTTL, entry.resolveError = ttlres.GetSPFWithTTL(name)
+		} else {
+			entry.resolvedSPF, entry.resolveError = c.inner.GetSPF(name)
+		}
 	}
 	// return cached value
 	if entry.SPF != "" {
@@ -120,13 +291,22 @@ func (c *cache) GetSPF(name string) (string, error) {
 	return entry.resolvedSPF, entry.resolveError
 }
 
+// ChangedRecords returns the names of the records that were looked up and
+// differ from the cache, or were refreshed because their TTL expired (with
+// the "expired" policy), and of the cached records that weren't used.
 func (c *cache) ChangedRecords() []string {
 	names := []string{}
 	for name, entry := range c.records {
-		if entry.resolvedSPF != entry.SPF {
-			names = append(names, name)
+		switch {
+		case entry.looked && entry.resolvedSPF != entry.SPF:
+		case entry.looked && c.refresh == RefreshExpired && entry.expired():
+		case !entry.used && !c.offline:
+		default:
+			continue
 		}
+		names = append(names, name)
 	}
+	slices.Sort(names)
 	return names
 }
 
@@ -142,10 +322,15 @@ func (c *cache) ResolveErrors() (errs []error) {
 func (c *cache) Save(filename string) error {
 	outRecs := make(map[string]*cacheEntry, len(c.records))
 	for k, entry := range c.records {
-		// move resolved data into cached field
-		// only take those we actually resolved
-		if entry.resolvedSPF != "" {
+		switch {
+		case entry.looked && entry.resolvedSPF != "":
+			// move resolved data into cached field
 			entry.SPF = entry.resolvedSPF
+			entry.Fetched = entry.resolvedAt.UTC().Truncate(time.Second)
+			entry.TTL = entry.resolvedTTL
+			outRecs[k] = entry
+		case entry.used && entry.SPF != "":
+			// keep the cached records that were not looked up again
 			outRecs[k] = entry
 		}
 	}
@@ -0,0 +1,128 @@
+package spflib
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// ttlResolver is a fakeResolver whose records have a TTL of an hour, and
+// that counts its lookups.
+type ttlResolver struct {
+	fakeResolver
+	lookups int
+}
+
+func (r *ttlResolver) GetSPFWithTTL(name string) (string, uint32, error) {
+	r.lookups++
+	spf, err := r.GetSPF(name)
+	return spf, 3600, err
+}
+
+func TestCacheRefresh(t *testing.T) {
+	fetched := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	defer func(f func() time.Time) { now = f }(now)
+	now = func() time.Time { return fetched.Add(30 * time.Minute) }
+
+	file := filepath.Join(t.TempDir(), "spfcache.json")
+	writeCache := func(t *testing.T) {
+		t.Helper()
+		dat, _ := json.Marshal(map[string]*cacheEntry{
+			"fresh.example.com": {SPF: "v=spf1 ip4:192.0.2.1 -all", Fetched: fetched, TTL: 3600},
+			"stale.example.com": {SPF: "v=spf1 ip4:192.0.2.2 -all", Fetched: fetched, TTL: 60},
+			"old.example.com":   {SPF: "v=spf1 ip4:192.0.2.3 -all"}, // No fetch time.
+		})
+		if err := os.WriteFile(file, dat, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	names := []string{"fresh.example.com", "stale.example.com", "old.example.com", "new.example.com"}
+	live := fakeResolver{
+		"fresh.example.com": "v=spf1 ip4:192.0.2.1 -all",
+		"stale.example.com": "v=spf1 ip4:192.0.2.2 -all",
+		"old.example.com":   "v=spf1 ip4:192.0.2.99 -all", // Changed.
+		"new.example.com":   "v=spf1 ip4:192.0.2.4 -all",
+	}
+
+	tests := []struct {
+		refresh string
+		lookups int
+		changed []string
+	}{
+		{RefreshNever, 1, []string{"new.example.com"}},
+		{RefreshExpired, 3, []string{"new.example.com", "old.example.com", "stale.example.com"}},
+		{RefreshAlways, 4, []string{"new.example.com", "old.example.com"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.refresh, func(t *testing.T) {
+			writeCache(t)
+			inner := &ttlResolver{fakeResolver: live}
+			c, err := newCache(file, inner, tt.refresh, false)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, name := range names {
+				if _, err := c.GetSPF(name); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if inner.lookups != tt.lookups {
+				t.Errorf("%d lookups, want %d", inner.lookups, tt.lookups)
+			}
+			if got := c.ChangedRecords(); !reflect.DeepEqual(got, tt.changed) {
+				t.Errorf("ChangedRecords() = %v, want %v", got, tt.changed)
+			}
+
+			// The refreshed entries get the new fetch time and TTL.
+			updated := filepath.Join(t.TempDir(), "spfcache.updated.json")
+			if err := c.Save(updated); err != nil {
+				t.Fatal(err)
+			}
+			dat, _ := os.ReadFile(updated)
+			saved := map[string]*cacheEntry{}
+			if err := json.Unmarshal(dat, &saved); err != nil {
+				t.Fatal(err)
+			}
+			if len(saved) != len(names) {
+				t.Errorf("saved %d entries, want %d", len(saved), len(names))
+			}
+			if e := saved["new.example.com"]; e == nil || !e.Fetched.Equal(now()) || e.TTL != 3600 {
+				t.Errorf("new.example.com was saved as %+v", e)
+			}
+			if e := saved["fresh.example.com"]; e == nil || e.Fetched.Equal(fetched) != (tt.refresh != RefreshAlways) {
+				t.Errorf("fresh.example.com was saved as %+v", e)
+			}
+		})
+	}
+}
+
+func TestCacheOffline(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "spfcache.json")
+	if err := os.WriteFile(file, []byte(`{"cached.example.com": {"SPF": "v=spf1 -all"}}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	inner := &ttlResolver{fakeResolver: fakeResolver{"missing.example.com": "v=spf1 -all"}}
+	c, err := newCache(file, inner, RefreshAlways, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if spf, err := c.GetSPF("cached.example.com"); spf != "v=spf1 -all" || err != nil {
+		t.Errorf("GetSPF() = %q, %v", spf, err)
+	}
+	if _, err := c.GetSPF("missing.example.com"); err == nil {
+		t.Error("GetSPF() of an entry that isn't cached succeeded in offline mode")
+	}
+	if inner.lookups != 0 {
+		t.Errorf("%d lookups in offline mode", inner.lookups)
+	}
+	if changed := c.ChangedRecords(); len(changed) != 0 {
+		t.Errorf("ChangedRecords() = %v", changed)
+	}
+
+	if _, err := newCache(file, inner, "sometimes", false); err == nil {
+		t.Error("an unknown refresh policy was accepted")
+	}
+}
@@ -0,0 +1,69 @@
+// Package zonefile reads the records of BIND zone files.
+package zonefile
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/DNSControl/dnscontrol/v4/models"
+	"github.com/DNSControl/dnscontrol/v4/pkg/dnsrr"
+	"github.com/DNSControl/dnscontrol/v4/pkg/domaintags"
+	"github.com/DNSControl/dnscontrol/v4/pkg/rtypecontrol"
+	"github.com/DNSControl/dnscontrol/v4/pkg/rtypeinfo"
+	dnsv1 "github.com/miekg/dns"
+)
+
+// Parse parses a string as a BIND zone and returns the records.
+// zonefileName is only used in error messages.
+func Parse(content string, zoneName string, zonefileName string) (models.Records, error) {
+	return parse(content, zoneName, zonefileName, false)
+}
+
+// ParseUnsigned is like Parse, but skips the records that are generated when
+// the zone is signed (RRSIG, NSEC, NSEC3 and NSEC3PARAM).
+func ParseUnsigned(content string, zoneName string, zonefileName string) (models.Records, error) {
+	return parse(content, zoneName, zonefileName, true)
+}
+
+func parse(content string, zoneName string, zonefileName string, skipSignatures bool) (models.Records, error) {
+	zp := dnsv1.NewZoneParser(strings.NewReader(content), zoneName, zonefileName)
+
+	foundRecords := models.Records{}
+	for rr, ok := zp.Next(); ok; rr, ok = zp.Next() {
+		var rec models.RecordConfig
+		var prec *models.RecordConfig
+		var err error
+
+		rtype := rr.Header().Rrtype
+		if skipSignatures {
+			switch rtype {
+			case dnsv1.TypeRRSIG, dnsv1.TypeNSEC, dnsv1.TypeNSEC3, dnsv1.TypeNSEC3PARAM:
+				continue
+			}
+		}
+		rtypeStr := dnsv1.TypeToString[rtype]
+		if rtypeinfo.IsModernType(rtypeStr) {
+			// Modern types:
+			name := rr.Header().Name
+			prec, err = rtypecontrol.NewRecordConfigFromStruct(name, rr.Header().Ttl, rtypeStr, rr, domaintags.MakeDomainNameVarieties(zoneName))
+			if err != nil {
+				return nil, err
+			}
+			rec = *prec
+			rec.TTL = rr.Header().Ttl
+		} else {
+			// Legacy types:
+			rec, err = dnsrr.RRtoRCTxtBug(rr, zoneName)
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		foundRecords = append(foundRecords, &rec)
+	}
+
+	if err := zp.Err(); err != nil {
+		return nil, fmt.Errorf("error while parsing '%v': %w", zonefileName, err)
+	}
+	return foundRecords, nil
+}
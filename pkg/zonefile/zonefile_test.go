@@ -0,0 +1,37 @@
+package zonefile
+
+import (
+	"testing"
+
+	_ "github.com/DNSControl/dnscontrol/v4/pkg/rtype"
+)
+
+const signedZone = `$ORIGIN example.com.
+@ 300 IN SOA ns1.example.com. hostmaster.example.com. 1 7200 3600 864000 300
+@ 300 IN NS ns1.example.com.
+www 300 IN A 192.0.2.1
+www 300 IN RRSIG A 13 3 300 20300101000000 20200101000000 12345 example.com. dGVzdA==
+www 300 IN NSEC example.com. A RRSIG NSEC
+`
+
+func TestParse(t *testing.T) {
+	recs, err := ParseUnsigned(signedZone, "example.com", "example.com.zone")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(recs) != 3 {
+		t.Fatalf("ParseUnsigned() returned %d records, want 3: %v", len(recs), recs)
+	}
+	if r := recs[2]; r.Type != "A" || r.GetLabel() != "www" || r.GetTargetField() != "192.0.2.1" || r.TTL != 300 {
+		t.Errorf("ParseUnsigned()[2] = %s %s %s %d", r.GetLabel(), r.Type, r.GetTargetField(), r.TTL)
+	}
+
+	// The signatures are not kept silently.
+	if _, err := Parse(signedZone, "example.com", "example.com.zone"); err == nil {
+		t.Error("Parse() of a signed zone returned no error")
+	}
+
+	if _, err := Parse("www 300 IN A not-an-address\n", "example.com", "bad.zone"); err == nil {
+		t.Error("Parse() of an invalid zone returned no error")
+	}
+}
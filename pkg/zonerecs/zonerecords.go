@@ -1,17 +1,37 @@
 package zonerecs
 
 import (
+	"slices"
+
 	"github.com/DNSControl/dnscontrol/v4/models"
+	"github.com/DNSControl/dnscontrol/v4/pkg/diff2"
 	"github.com/DNSControl/dnscontrol/v4/pkg/rtypecontrol"
 )
 
+// ZoneResult is everything learned about a zone while generating its
+// corrections.
+type ZoneResult struct {
+	Existing          models.Records       // Records downloaded from the provider (downcased, canonicalized).
+	Changes           diff2.ChangeList     // The instructions diff2 generated for the provider.
+	Reports           []*models.Correction // Informational messages (.F == nil).
+	Corrections       []*models.Correction // Actions to be performed (.F != nil).
+	ActualChangeCount int                  // Number of actual changes, not including REPORTs.
+}
+
 // CorrectZoneRecords calls both GetZoneRecords, does any
 // post-processing, and then calls GetZoneRecordsCorrections.  The
 // name sucks because all the good names were taken.
 func CorrectZoneRecords(driver models.DNSProvider, dc *models.DomainConfig) ([]*models.Correction, []*models.Correction, int, error) {
+	zr, err := GetZoneResult(driver, dc)
+	return zr.Reports, zr.Corrections, zr.ActualChangeCount, err
+}
+
+// GetZoneResult is like CorrectZoneRecords but also returns the existing
+// records and the diff2 instructions that the corrections were generated from.
+func GetZoneResult(driver models.DNSProvider, dc *models.DomainConfig) (ZoneResult, error) {
 	existingRecords, err := driver.GetZoneRecords(dc)
 	if err != nil {
-		return nil, nil, 0, err
+		return ZoneResult{}, err
 	}
 	rtypecontrol.FixLegacyRecords(&existingRecords) // Call this after GetZoneRecords() to fix providers that haven't been updated for RecordConfigV2.
 
@@ -27,19 +47,34 @@ func CorrectZoneRecords(driver models.DNSProvider, dc *models.DomainConfig) ([]*
 	// dc.Records.
 	dc, err = dc.Copy()
 	if err != nil {
-		return nil, nil, 0, err
+		return ZoneResult{Existing: existingRecords}, err
 	}
 
 	// punycode
 	if err := dc.Punycode(); err != nil {
-		return nil, nil, 0, err
+		return ZoneResult{Existing: existingRecords}, err
 	}
 	// FIXME(tlim) It is a waste to PunyCode every iteration.
 	// This should be moved to where the JavaScript is processed.
 
+	diff2.StartRecording(dc)
 	everything, actualChangeCount, err := driver.GetZoneRecordsCorrections(dc, existingRecords)
+	changes := diff2.StopRecording(dc)
 	reports, corrections := splitReportsAndCorrections(everything)
-	return reports, corrections, actualChangeCount, err
+	return ZoneResult{
+		Existing:          existingRecords,
+		Changes:           changes,
+		Reports:           reports,
+		Corrections:       corrections,
+		ActualChangeCount: actualChangeCount,
+	}, err
+}
+
+// ChangesRecords returns true if any of the corrections change the zone's
+// records. Corrections that only update something else, such as a server
+// config file, are marked models.Correction.NonRecord.
+func (zr ZoneResult) ChangesRecords() bool {
+	return slices.ContainsFunc(zr.Corrections, func(c *models.Correction) bool { return !c.NonRecord })
 }
 
 func splitReportsAndCorrections(everything []*models.Correction) (reports, corrections []*models.Correction) {
@@ -0,0 +1,292 @@
+// Package zoneserver answers DNS queries from the records of a list of zones.
+//
+// It is used by `dnscontrol serve` to test resolvers, mail setups and the
+// like against the desired state (dnsconfig.js) before it is pushed.  It is
+// a simple authoritative server: it does not recurse, sign, or transfer
+// zones.
+package zoneserver
+
+import (
+	"cmp"
+	"fmt"
+	"net"
+	"slices"
+	"strings"
+
+	"github.com/DNSControl/dnscontrol/v4/models"
+	dnsv1 "github.com/miekg/dns"
+)
+
+// maxChase is the most CNAMEs that are followed when answering a query.
+const maxChase = 8
+
+// Server is a dnsv1.Handler that answers queries for the zones it was
+// created with.
+type Server struct {
+	zones map[string]*zone // Key is the FQDN of the apex (lowercase, trailing dot).
+}
+
+// zone is the records of one zone, indexed for lookups.
+type zone struct {
+	origin string
+	soa    dnsv1.RR
+	nodes  map[string][]dnsv1.RR // The records at each name.
+	names  map[string]bool       // All names, including empty non-terminals.
+}
+
+// New creates a Server for the zones. If a zone does not have an SOA record,
+// one is created using serial. It also returns a message for each record
+// that can not be served (for example, provider-specific pseudo records
+// such as ALIAS or CF_REDIRECT).
+func New(dcs []*models.DomainConfig, serial uint32) (*Server, []string) {
+	s := &Server{zones: map[string]*zone{}}
+	var skipped []string
+	for _, dc := range dcs {
+		z := &zone{
+			origin: canonical(dc.Name),
+			nodes:  map[string][]dnsv1.RR{},
+			names:  map[string]bool{},
+		}
+		for _, rc := range dc.Records {
+			if _, ok := dnsv1.StringToType[rc.Type]; !ok {
+				skipped = append(skipped, fmt.Sprintf("%s: %s %s is not a standard record type and will not be served", dc.UniqueName, rc.GetLabelFQDN(), rc.Type))
+				continue
+			}
+			z.add(rc.ToRR())
+		}
+		for _, ns := range dc.Nameservers { // NAMESERVER()
+			z.add(&dnsv1.NS{
+				Hdr: dnsv1.RR_Header{Name: z.origin, Rrtype: dnsv1.TypeNS, Class: dnsv1.ClassINET, Ttl: models.DefaultTTL},
+				Ns:  canonical(ns.Name),
+			})
+		}
+		z.soa = z.findSOA(serial)
+		s.zones[z.origin] = z
+	}
+	return s, skipped
+}
+
+// canonical returns name as a lowercase FQDN with a trailing dot.
+func canonical(name string) string {
+	return dnsv1.CanonicalName(name)
+}
+
+// add adds rr to the zone. Duplicates are ignored.
+func (z *zone) add(rr dnsv1.RR) {
+	name := canonical(rr.Header().Name)
+	rr.Header().Name = name
+	for _, x := range z.nodes[name] {
+		if dnsv1.IsDuplicate(x, rr) {
+			return
+		}
+	}
+	z.nodes[name] = append(z.nodes[name], rr)
+
+	// Record the name and all its parents (up to the origin) so that empty
+	// non-terminals return NODATA rather than NXDOMAIN.
+	for n := name; dnsv1.IsSubDomain(z.origin, n); {
+		z.names[n] = true
+		i, end := dnsv1.NextLabel(n, 0)
+		if end {
+			break
+		}
+		n = n[i:]
+	}
+}
+
+// findSOA returns the zone's SOA record, or creates one.
+func (z *zone) findSOA(serial uint32) dnsv1.RR {
+	var mname string
+	for _, rr := range z.nodes[z.origin] {
+		switch v := rr.(type) {
+		case *dnsv1.SOA:
+			return v
+		case *dnsv1.NS:
+			if mname == "" {
+				mname = v.Ns
+			}
+		}
+	}
+	soa := &dnsv1.SOA{
+		Hdr:     dnsv1.RR_Header{Name: z.origin, Rrtype: dnsv1.TypeSOA, Class: dnsv1.ClassINET, Ttl: models.DefaultTTL},
+		Ns:      cmp.Or(mname, "ns."+z.origin),
+		Mbox:    "hostmaster." + z.origin,
+		Serial:  serial,
+		Refresh: 3600,
+		Retry:   600,
+		Expire:  604800,
+		Minttl:  models.DefaultTTL,
+	}
+	z.add(soa)
+	return soa
+}
+
+// findZone returns the zone that name is in, or nil.  If zones are nested
+// (example.com and sub.example.com) the most specific zone is returned.
+func (s *Server) findZone(name string) *zone {
+	for n := name; ; {
+		if z, ok := s.zones[n]; ok {
+			return z
+		}
+		i, end := dnsv1.NextLabel(n, 0)
+		if end {
+			return nil
+		}
+		n = n[i:]
+	}
+}
+
+// ServeDNS implements dnsv1.Handler.
+func (s *Server) ServeDNS(w dnsv1.ResponseWriter, req *dnsv1.Msg) {
+	m := s.Answer(req)
+
+	// Truncate UDP replies that are too big. The client will retry with TCP.
+	if _, ok := w.RemoteAddr().(*net.UDPAddr); ok {
+		size := dnsv1.MinMsgSize
+		if opt := req.IsEdns0(); opt != nil {
+			size = max(size, int(opt.UDPSize()))
+		}
+		m.Truncate(size)
+	}
+	_ = w.WriteMsg(m)
+}
+
+// Answer returns the reply to req.
+func (s *Server) Answer(req *dnsv1.Msg) *dnsv1.Msg {
+	m := new(dnsv1.Msg)
+	m.SetReply(req)
+	if opt := req.IsEdns0(); opt != nil {
+		m.SetEdns0(dnsv1.DefaultMsgSize, false)
+	}
+
+	if req.Opcode != dnsv1.OpcodeQuery {
+		m.Rcode = dnsv1.RcodeNotImplemented
+		return m
+	}
+	if len(req.Question) != 1 {
+		m.Rcode = dnsv1.RcodeFormatError
+		return m
+	}
+	q := req.Question[0]
+	qname := canonical(q.Name)
+
+	z := s.findZone(qname)
+	if z == nil {
+		m.Rcode = dnsv1.RcodeRefused
+		return m
+	}
+	m.Authoritative = true
+
+	for range maxChase {
+		// A delegation below the apex is answered with a referral.
+		if ns, glue := z.referral(qname, q.Qtype); ns != nil {
+			m.Authoritative = len(m.Answer) != 0 // Only the CNAMEs, if any, are ours.
+			m.Ns, m.Extra = ns, append(m.Extra, glue...)
+			return m
+		}
+
+		rrs, ok := z.lookup(qname)
+		if !ok {
+			m.Rcode = dnsv1.RcodeNameError // RFC 6604: The rcode is for the last name in the chain.
+			m.Ns = []dnsv1.RR{z.soa}
+			return m
+		}
+
+		answer := filter(rrs, q.Qtype)
+		if len(answer) != 0 {
+			m.Answer = append(m.Answer, answer...)
+			return m
+		}
+
+		// Follow a CNAME to its target (if we know it).
+		cname := filter(rrs, dnsv1.TypeCNAME)
+		if len(cname) == 0 {
+			m.Ns = []dnsv1.RR{z.soa} // NODATA
+			return m
+		}
+		m.Answer = append(m.Answer, cname[0])
+		qname = canonical(cname[0].(*dnsv1.CNAME).Target)
+		if z = s.findZone(qname); z == nil {
+			return m // Not ours. The resolver will continue from here.
+		}
+	}
+	m.Rcode = dnsv1.RcodeServerFailure // CNAME loop.
+	return m
+}
+
+// lookup returns the records at name, expanding wildcards (RFC 4592). It
+// returns false if the name does not exist.
+func (z *zone) lookup(name string) ([]dnsv1.RR, bool) {
+	if rrs, ok := z.nodes[name]; ok {
+		return rrs, true
+	}
+	if z.names[name] {
+		return nil, true // Empty non-terminal.
+	}
+
+	// Find the closest encloser, then the wildcard below it.
+	for n := name; n != z.origin; {
+		i, _ := dnsv1.NextLabel(n, 0)
+		n = n[i:]
+		if !z.names[n] {
+			continue
+		}
+		wild, ok := z.nodes["*."+n]
+		if !ok {
+			return nil, false
+		}
+		rrs := make([]dnsv1.RR, len(wild))
+		for j, rr := range wild {
+			rrs[j] = dnsv1.Copy(rr)
+			rrs[j].Header().Name = name
+		}
+		return rrs, true
+	}
+	return nil, false
+}
+
+// referral returns the NS records and glue if name is at or below a zone cut.
+// DS records are served by the parent, so a DS query at the cut itself is not
+// referred.
+func (z *zone) referral(name string, qtype uint16) (ns []dnsv1.RR, glue []dnsv1.RR) {
+	// Walk from the apex down, so the highest cut wins.
+	labels := dnsv1.SplitDomainName(strings.TrimSuffix(name, z.origin))
+	for i := len(labels) - 1; i >= 0; i-- {
+		cut := strings.Join(labels[i:], ".") + "." + z.origin
+		ns = filter(z.nodes[cut], dnsv1.TypeNS)
+		if len(ns) == 0 || (cut == name && qtype == dnsv1.TypeDS) {
+			continue
+		}
+		for _, rr := range ns {
+			target := rr.(*dnsv1.NS).Ns
+			glue = append(glue, filter(z.nodes[target], dnsv1.TypeA)...)
+			glue = append(glue, filter(z.nodes[target], dnsv1.TypeAAAA)...)
+		}
+		return ns, glue
+	}
+	return nil, nil
+}
+
+// filter returns the records of type qtype.
+func filter(rrs []dnsv1.RR, qtype uint16) []dnsv1.RR {
+	if qtype == dnsv1.TypeANY {
+		return slices.Clone(rrs)
+	}
+	var out []dnsv1.RR
+	for _, rr := range rrs {
+		if rr.Header().Rrtype == qtype {
+			out = append(out, rr)
+		}
+	}
+	return out
+}
+
+// Names returns the names of the zones, sorted.
+func (s *Server) Names() []string {
+	var names []string
+	for _, z := range s.zones {
+		names = append(names, z.origin)
+	}
+	slices.Sort(names)
+	return names
+}
@@ -0,0 +1,122 @@
+package zoneserver
+
+import (
+	"testing"
+
+	"github.com/DNSControl/dnscontrol/v4/models"
+	dnsv1 "github.com/miekg/dns"
+)
+
+func makeRec(origin, label, rtype, content string) *models.RecordConfig {
+	r := &models.RecordConfig{TTL: 300}
+	r.SetLabel(label, origin)
+	if err := r.PopulateFromString(rtype, content, origin); err != nil {
+		panic(err)
+	}
+	return r
+}
+
+func makeServer(t *testing.T) *Server {
+	t.Helper()
+	dc := models.MustNewDomainConfig("example.com")
+	dc.Records = models.Records{
+		makeRec("example.com", "@", "A", "1.2.3.4"),
+		makeRec("example.com", "@", "MX", "10 mail.example.com."),
+		makeRec("example.com", "mail", "A", "1.2.3.5"),
+		makeRec("example.com", "www", "CNAME", "example.com."),
+		makeRec("example.com", "ext", "CNAME", "www.example.net."),
+		makeRec("example.com", "loop1", "CNAME", "loop2.example.com."),
+		makeRec("example.com", "loop2", "CNAME", "loop1.example.com."),
+		makeRec("example.com", "a.b.c", "TXT", "deep"),
+		makeRec("example.com", "*.wild", "A", "9.9.9.9"),
+		makeRec("example.com", "sub", "NS", "ns1.sub.example.com."),
+		makeRec("example.com", "ns1.sub", "A", "10.0.0.1"),
+	}
+	alias := &models.RecordConfig{Type: "ALIAS", TTL: 300}
+	alias.SetLabel("alias", "example.com")
+	_ = alias.SetTarget("example.net.")
+	dc.Records = append(dc.Records, alias) // Pseudo records are skipped.
+	dc.Nameservers = []*models.Nameserver{{Name: "ns1.example.net"}}
+
+	s, skipped := New([]*models.DomainConfig{dc}, 2024010101)
+	if len(skipped) != 1 {
+		t.Errorf("New() skipped %q, want 1 record", skipped)
+	}
+	return s
+}
+
+func TestAnswer(t *testing.T) {
+	s := makeServer(t)
+
+	tests := []struct {
+		name      string
+		qname     string
+		qtype     uint16
+		wantRcode int
+		wantAA    bool
+		wantAns   int
+		wantNs    int
+		wantExtra int
+	}{
+		{"apexA", "example.com.", dnsv1.TypeA, dnsv1.RcodeSuccess, true, 1, 0, 0},
+		{"caseInsensitive", "MAIL.Example.COM.", dnsv1.TypeA, dnsv1.RcodeSuccess, true, 1, 0, 0},
+		{"apexNS", "example.com.", dnsv1.TypeNS, dnsv1.RcodeSuccess, true, 1, 0, 0},
+		{"synthesizedSOA", "example.com.", dnsv1.TypeSOA, dnsv1.RcodeSuccess, true, 1, 0, 0},
+		{"any", "example.com.", dnsv1.TypeANY, dnsv1.RcodeSuccess, true, 4, 0, 0}, // A MX NS SOA
+		{"nodata", "mail.example.com.", dnsv1.TypeAAAA, dnsv1.RcodeSuccess, true, 0, 1, 0},
+		{"nxdomain", "nope.example.com.", dnsv1.TypeA, dnsv1.RcodeNameError, true, 0, 1, 0},
+		{"emptyNonTerminal", "b.c.example.com.", dnsv1.TypeA, dnsv1.RcodeSuccess, true, 0, 1, 0},
+		{"cnameChased", "www.example.com.", dnsv1.TypeA, dnsv1.RcodeSuccess, true, 2, 0, 0},
+		{"cnameItself", "www.example.com.", dnsv1.TypeCNAME, dnsv1.RcodeSuccess, true, 1, 0, 0},
+		{"cnameExternal", "ext.example.com.", dnsv1.TypeA, dnsv1.RcodeSuccess, true, 1, 0, 0},
+		{"cnameLoop", "loop1.example.com.", dnsv1.TypeA, dnsv1.RcodeServerFailure, true, maxChase, 0, 0},
+		{"wildcard", "foo.wild.example.com.", dnsv1.TypeA, dnsv1.RcodeSuccess, true, 1, 0, 0},
+		{"wildcardNotDeeper", "foo.www.example.com.", dnsv1.TypeA, dnsv1.RcodeNameError, true, 0, 1, 0},
+		{"referral", "host.sub.example.com.", dnsv1.TypeA, dnsv1.RcodeSuccess, false, 0, 1, 1},
+		{"referralAtCut", "sub.example.com.", dnsv1.TypeNS, dnsv1.RcodeSuccess, false, 0, 1, 1},
+		{"dsAtCut", "sub.example.com.", dnsv1.TypeDS, dnsv1.RcodeSuccess, true, 0, 1, 0}, // Answered by the parent (NODATA), not referred.
+		{"notOurs", "example.org.", dnsv1.TypeA, dnsv1.RcodeRefused, false, 0, 0, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := new(dnsv1.Msg)
+			req.SetQuestion(tt.qname, tt.qtype)
+			m := s.Answer(req)
+			if m.Rcode != tt.wantRcode {
+				t.Errorf("Rcode = %s, want %s", dnsv1.RcodeToString[m.Rcode], dnsv1.RcodeToString[tt.wantRcode])
+			}
+			if m.Authoritative != tt.wantAA {
+				t.Errorf("Authoritative = %v, want %v", m.Authoritative, tt.wantAA)
+			}
+			if len(m.Answer) != tt.wantAns || len(m.Ns) != tt.wantNs || len(m.Extra) != tt.wantExtra {
+				t.Errorf("got %d/%d/%d answer/authority/additional records, want %d/%d/%d:\n%s",
+					len(m.Answer), len(m.Ns), len(m.Extra), tt.wantAns, tt.wantNs, tt.wantExtra, m)
+			}
+		})
+	}
+}
+
+func TestAnswerWildcardOwner(t *testing.T) {
+	s := makeServer(t)
+	req := new(dnsv1.Msg)
+	req.SetQuestion("foo.wild.example.com.", dnsv1.TypeA)
+	m := s.Answer(req)
+	if len(m.Answer) != 1 || m.Answer[0].Header().Name != "foo.wild.example.com." {
+		t.Fatalf("wildcard answer = %v, want owner foo.wild.example.com.", m.Answer)
+	}
+}
+
+func TestNestedZones(t *testing.T) {
+	parent := models.MustNewDomainConfig("example.com")
+	parent.Records = models.Records{makeRec("example.com", "www.sub", "A", "1.1.1.1")}
+	child := models.MustNewDomainConfig("sub.example.com")
+	child.Records = models.Records{makeRec("sub.example.com", "www", "A", "2.2.2.2")}
+	s, _ := New([]*models.DomainConfig{parent, child}, 1)
+
+	req := new(dnsv1.Msg)
+	req.SetQuestion("www.sub.example.com.", dnsv1.TypeA)
+	m := s.Answer(req)
+	if len(m.Answer) != 1 || m.Answer[0].(*dnsv1.A).A.String() != "2.2.2.2" {
+		t.Errorf("answer = %v, want the record from the most specific zone", m.Answer)
+	}
+}
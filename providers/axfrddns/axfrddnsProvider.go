@@ -7,7 +7,8 @@ axfrddns -
   push Dynamic DNS updates (RFC2136) to the same server.
 
   Both the AXFR request and the updates might be authentificated with
-  a TSIG.
+  a TSIG, either HMAC or GSS-TSIG (RFC3645). The updates might instead be
+  signed with SIG(0) (RFC2931).
 
 */
 
@@ -58,9 +59,8 @@ var features = providers.DocumentationNotes{
 	providers.CanUseTLSA:             providers.Can(),
 	providers.DocDualHost:            providers.Cannot(),
 	providers.DocOfficiallySupported: providers.Cannot(),
-	// Possible to support via catalog zones (RFC 9432), but those are not
-	// directly supported by DNSControl right now (although nothing is stopping
-	// you from manually updating a catalog zone using DNSControl if you wish).
+	// Possible to support via catalog zones (RFC 9432). DNSControl maintains
+	// one with the "catalog" option, but does not list the zones from it.
 	providers.CanGetZones:      providers.Cannot(),
 	providers.DocCreateDomains: providers.Cannot(),
 	// Not a valid RR type, so impossible to encode in an RFC-compliant DNS
@@ -81,9 +81,13 @@ type axfrddnsProvider struct {
 	nameservers    []*models.Nameserver
 	transferKey    *Key
 	updateKey      *Key
+	keys           map[string]*Key // The "key-NAME" entries of creds.json, by NAME.
+	cacheDirectory string          // Where the zones are cached between runs, if set.
+	catalog        string          // The catalog zone of the zones, if set.
 
-	mu               sync.Mutex // protects hasDnssecRecords during concurrent collection.
+	mu               sync.Mutex // protects hasDnssecRecords and configuredZones during concurrent collection.
 	hasDnssecRecords map[string]bool
+	configuredZones  []*models.DomainConfig // Every zone that uses the provider.
 }
 
 func initAxfrDdns(config map[string]string, providermeta json.RawMessage) (providers.DNSServiceProvider, error) {
@@ -91,6 +95,7 @@ func initAxfrDdns(config map[string]string, providermeta json.RawMessage) (provi
 	// providermeta -- the json blob from NewReq('name', 'TYPE', providermeta)
 	var err error
 	api := &axfrddnsProvider{
+		keys:             map[string]*Key{},
 		hasDnssecRecords: map[string]bool{},
 	}
 	param := &Param{}
@@ -159,6 +164,19 @@ func initAxfrDdns(config map[string]string, providermeta json.RawMessage) (provi
 	if err != nil {
 		return nil, err
 	}
+	if api.transferKey != nil && api.transferKey.sig0 != nil {
+		return nil, errors.New("SIG(0) keys can only sign updates, not transfers (transfer-key)")
+	}
+	for key, value := range config {
+		if name, ok := strings.CutPrefix(key, "key-"); ok {
+			api.keys[name], err = readKey(value, key)
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+	api.cacheDirectory = config["cache-directory"]
+	api.catalog = strings.TrimSuffix(config["catalog"], ".")
 	switch strings.ToLower(strings.TrimSpace(config["buggy-cname"])) {
 	case "yes", "true":
 		printer.Warnf("'buggy-cname' is deprecated as it is no longer necessary.\n")
@@ -173,10 +191,15 @@ func initAxfrDdns(config map[string]string, providermeta json.RawMessage) (provi
 			"update-mode",
 			"transfer-mode",
 			"buggy-cname",
+			"cache-directory",
+			"catalog",
 			"domain",
 			"TYPE":
 			continue
 		default:
+			if strings.HasPrefix(key, "key-") {
+				continue
+			}
 			printer.Printf("[Warning] AXFRDDNS: unknown key in `creds.json` (%s)\n", key)
 		}
 	}
@@ -199,17 +222,34 @@ type Param struct {
 	DefaultNS []string `json:"default_ns"`
 }
 
-// Key stores the individual parts of a TSIG key.
+// Key stores the individual parts of a TSIG key, or a SIG(0) or GSS-TSIG key.
 type Key struct {
 	algo   string
 	id     string
 	secret string
+	sig0   *sig0Key // Set for SIG(0) keys, which leave the other fields empty.
+	gss    *gssKey  // Set for GSS-TSIG keys, which leave the other fields empty.
 }
 
 func readKey(raw string, kind string) (*Key, error) {
 	if raw == "" {
 		return nil, nil
 	}
+	if path, ok := strings.CutPrefix(raw, "sig0:"); ok {
+		k, err := readSig0Key(path, kind)
+		if err != nil {
+			return nil, err
+		}
+		return &Key{sig0: k}, nil
+	}
+	switch algo, spec, _ := strings.Cut(raw, ":"); algo {
+	case "gss-tsig", "gss":
+		k, err := readGSSKey(spec, kind)
+		if err != nil {
+			return nil, err
+		}
+		return &Key{gss: k}, nil
+	}
 	arr := strings.Split(raw, ":")
 	if len(arr) != 3 {
 		return nil, fmt.Errorf("invalid key format (%s) in AXFRDDNS.TSIG", kind)
@@ -239,6 +279,30 @@ func readKey(raw string, kind string) (*Key, error) {
 	return &Key{algo: algo, id: id, secret: arr[2]}, nil
 }
 
+// Domain metadata that selects the keys of a zone by the NAME of a
+// "key-NAME" entry in creds.json.
+const (
+	metaUpdateKey   = "axfrddns_update_key"
+	metaTransferKey = "axfrddns_transfer_key"
+)
+
+// zoneKey returns the key named by the domain metadata meta, or def if the
+// metadata isn't set. The zones of get-zones have no metadata.
+func (c *axfrddnsProvider) zoneKey(dc *models.DomainConfig, meta string, def *Key) (*Key, error) {
+	name := dc.Metadata[meta]
+	if name == "" {
+		return def, nil
+	}
+	k, ok := c.keys[name]
+	if !ok {
+		return nil, fmt.Errorf("%s: %s %q is not defined: add \"key-%s\" to the provider in creds.json", dc.Name, meta, name, name)
+	}
+	if meta == metaTransferKey && k.sig0 != nil {
+		return nil, fmt.Errorf("%s: SIG(0) keys can only sign updates, not transfers (%s %q)", dc.Name, meta, name)
+	}
+	return k, nil
+}
+
 // GetNameservers returns the nameservers for a domain.
 func (c *axfrddnsProvider) GetNameservers(domain string) ([]*models.Nameserver, error) {
 	return c.nameservers, nil
@@ -266,6 +330,21 @@ func (c *axfrddnsProvider) getAxfrConnection() (*dnsv1.Transfer, error) {
 
 // FetchZoneRecords gets the records of a zone and returns them in dns.RR format.
 func (c *axfrddnsProvider) FetchZoneRecords(domain string) ([]dnsv1.RR, error) {
+	return c.fetchZoneRecords(domain, c.transferKey)
+}
+
+// fetchZoneRecords is FetchZoneRecords with the transfer key of the zone.
+func (c *axfrddnsProvider) fetchZoneRecords(domain string, transferKey *Key) ([]dnsv1.RR, error) {
+	if c.cacheDirectory != "" {
+		return c.fetchCachedZoneRecords(domain, transferKey)
+	}
+	request := new(dnsv1.Msg)
+	request.SetAxfr(domain + ".")
+	return c.transfer(domain, request, transferKey)
+}
+
+// transfer sends the AXFR or IXFR request and returns the records of the answer.
+func (c *axfrddnsProvider) transfer(domain string, request *dnsv1.Msg, transferKey *Key) ([]dnsv1.RR, error) {
 	transfer, err := c.getAxfrConnection()
 	if err != nil {
 		return nil, err
@@ -273,14 +352,20 @@ func (c *axfrddnsProvider) FetchZoneRecords(domain string) ([]dnsv1.RR, error) {
 	transfer.DialTimeout = dnsTimeout
 	transfer.ReadTimeout = dnsTimeout
 
-	request := new(dnsv1.Msg)
-	request.SetAxfr(domain + ".")
-
-	if c.transferKey != nil {
-		transfer.TsigSecret = map[string]string{c.transferKey.id: c.transferKey.secret}
-		request.SetTsig(c.transferKey.id, c.transferKey.algo, 300, time.Now().Unix())
-		if c.transferKey.algo == dnsv1.HmacMD5 {
-			transfer.TsigProvider = md5Provider(c.transferKey.secret)
+	switch {
+	case transferKey == nil:
+	case transferKey.gss != nil:
+		ctx, err := transferKey.gss.context(c.transferServer)
+		if err != nil {
+			return nil, err
+		}
+		transfer.TsigProvider = ctx
+		request.SetTsig(ctx.name, gssAlgorithm, 300, time.Now().Unix())
+	default:
+		transfer.TsigSecret = map[string]string{transferKey.id: transferKey.secret}
+		request.SetTsig(transferKey.id, transferKey.algo, 300, time.Now().Unix())
+		if transferKey.algo == dnsv1.HmacMD5 {
+			transfer.TsigProvider = md5Provider(transferKey.secret)
 		}
 	}
 
@@ -308,7 +393,11 @@ func (c *axfrddnsProvider) FetchZoneRecords(domain string) ([]dnsv1.RR, error) {
 func (c *axfrddnsProvider) GetZoneRecords(dc *models.DomainConfig) (models.Records, error) {
 	domain := dc.Name
 
-	rawRecords, err := c.FetchZoneRecords(domain)
+	transferKey, err := c.zoneKey(dc, metaTransferKey, c.transferKey)
+	if err != nil {
+		return nil, err
+	}
+	rawRecords, err := c.fetchZoneRecords(domain, transferKey)
 	if err != nil {
 		return nil, err
 	}
@@ -387,20 +476,37 @@ func (c *axfrddnsProvider) BuildCorrection(dc *models.DomainConfig, msgs []strin
 	return &models.Correction{
 		Msg: fmt.Sprintf("DDNS UPDATES to '%s' (primary master: '%s'). Changes:\n%s", dc.Name, c.master, strings.Join(msgs, "\n")),
 		F: func() error {
+			updateKey, err := c.zoneKey(dc, metaUpdateKey, c.updateKey)
+			if err != nil {
+				return err
+			}
 			for _, update := range updates {
 				update.Compress = true
 				client := new(dnsv1.Client)
 				client.Net = c.updateMode
 				client.Timeout = dnsTimeout
-				if c.updateKey != nil {
-					client.TsigSecret = map[string]string{c.updateKey.id: c.updateKey.secret}
-					update.SetTsig(c.updateKey.id, c.updateKey.algo, 300, time.Now().Unix())
-					if c.updateKey.algo == dnsv1.HmacMD5 {
-						client.TsigProvider = md5Provider(c.updateKey.secret)
+				var msg *dnsv1.Msg
+				switch {
+				case updateKey == nil:
+					msg, _, err = client.Exchange(update, c.master)
+				case updateKey.sig0 != nil:
+					msg, err = exchangeSig0(client, updateKey.sig0, update, c.master)
+				case updateKey.gss != nil:
+					var ctx *gssContext
+					if ctx, err = updateKey.gss.context(c.master); err != nil {
+						return err
 					}
+					client.TsigProvider = ctx
+					update.SetTsig(ctx.name, gssAlgorithm, 300, time.Now().Unix())
+					msg, _, err = client.Exchange(update, c.master)
+				default:
+					client.TsigSecret = map[string]string{updateKey.id: updateKey.secret}
+					update.SetTsig(updateKey.id, updateKey.algo, 300, time.Now().Unix())
+					if updateKey.algo == dnsv1.HmacMD5 {
+						client.TsigProvider = md5Provider(updateKey.secret)
+					}
+					msg, _, err = client.Exchange(update, c.master)
 				}
-
-				msg, _, err := client.Exchange(update, c.master)
 				if err != nil {
 					return err
 				}
@@ -452,6 +558,11 @@ func (c *axfrddnsProvider) GetZoneRecordsCorrections(dc *models.DomainConfig, fo
 	}
 	c.mu.Unlock()
 
+	// Report a missing update key now rather than when pushing.
+	if _, err := c.zoneKey(dc, metaUpdateKey, c.updateKey); err != nil {
+		return nil, 0, err
+	}
+
 	// An RFC2136-compliant server must silently ignore an
 	// update that inserts a non-CNAME RRset when a CNAME RR
 	// with the same name is present in the zone (and
@@ -0,0 +1,41 @@
+package axfrddns
+
+import (
+	"github.com/DNSControl/dnscontrol/v4/models"
+	"github.com/DNSControl/dnscontrol/v4/pkg/catalogzone"
+)
+
+// The catalog zone (creds.json "catalog") lists every zone of the provider
+// (RFC 9432). It must already exist on the server, and is updated with
+// DDNS like the other zones, so that secondaries that use it provision the
+// zones of dnsconfig.js.
+
+// SetConfiguredZones records the zones that use the provider, which are
+// the members of the catalog zone.
+func (c *axfrddnsProvider) SetConfiguredZones(zones []*models.DomainConfig) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.configuredZones = zones
+}
+
+// CatalogZoneCorrections returns the catalog zone and the corrections that
+// update it, if it is out of date.
+func (c *axfrddnsProvider) CatalogZoneCorrections() (*models.DomainConfig, []*models.Correction, int, error) {
+	c.mu.Lock()
+	members := c.configuredZones
+	c.mu.Unlock()
+	if c.catalog == "" || members == nil {
+		return nil, nil, 0, nil
+	}
+
+	dc, err := catalogzone.Zone(c.catalog, members)
+	if err != nil {
+		return nil, nil, 0, err
+	}
+	found, err := c.GetZoneRecords(dc)
+	if err != nil {
+		return nil, nil, 0, err
+	}
+	corrections, count, err := c.GetZoneRecordsCorrections(dc, found)
+	return dc, corrections, count, err
+}
@@ -0,0 +1,61 @@
+package axfrddns
+
+import (
+	"net"
+	"strings"
+	"testing"
+
+	"github.com/DNSControl/dnscontrol/v4/models"
+	dnsv1 "github.com/miekg/dns"
+)
+
+func TestCatalogCorrections(t *testing.T) {
+	// A server with a catalog zone that lists old.example.
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	catalog := mustRRs(t,
+		"catalog.example. 300 IN SOA ns.example.com. hostmaster.example.com. 1 3600 600 604800 300",
+		"catalog.example. 300 IN NS invalid.",
+		`version.catalog.example. 300 IN TXT "2"`,
+		"0e8f3c6a7f2d1b0c9a8e7d6c5b4a39281706f5e4.zones.catalog.example. 300 IN PTR old.example.",
+	)
+	srv := &dnsv1.Server{Listener: ln, Handler: dnsv1.HandlerFunc(func(w dnsv1.ResponseWriter, r *dnsv1.Msg) {
+		reply := new(dnsv1.Msg)
+		reply.SetReply(r)
+		reply.Answer = append(catalog, catalog[0])
+		_ = w.WriteMsg(reply)
+	})}
+	go func() { _ = srv.ActivateAndServe() }()
+	defer func() { _ = srv.Shutdown() }()
+
+	c := &axfrddnsProvider{
+		master:           ln.Addr().String(),
+		transferServer:   ln.Addr().String(),
+		transferMode:     "tcp",
+		catalog:          "catalog.example",
+		hasDnssecRecords: map[string]bool{},
+	}
+	c.SetConfiguredZones([]*models.DomainConfig{models.MustNewDomainConfig("example.com")})
+
+	dc, corrections, count, err := c.CatalogZoneCorrections()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if dc.Name != "catalog.example" {
+		t.Errorf("zone = %q, want catalog.example", dc.Name)
+	}
+	if len(corrections) != 1 || count != 2 {
+		t.Fatalf("got %d corrections and %d changes, want 1 and 2", len(corrections), count)
+	}
+	for _, want := range []string{
+		"DDNS UPDATES to 'catalog.example'",
+		"+ CREATE c5e4b4da1e5a620ddaa3635e55c3732a5b49c7f4.zones.catalog.example PTR example.com.",
+		"- DELETE 0e8f3c6a7f2d1b0c9a8e7d6c5b4a39281706f5e4.zones.catalog.example PTR old.example.",
+	} {
+		if !strings.Contains(corrections[0].Msg, want) {
+			t.Errorf("Msg does not contain %q:\n%s", want, corrections[0].Msg)
+		}
+	}
+}
@@ -0,0 +1,297 @@
+package axfrddns
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jcmturner/gokrb5/v8/client"
+	"github.com/jcmturner/gokrb5/v8/config"
+	"github.com/jcmturner/gokrb5/v8/credentials"
+	"github.com/jcmturner/gokrb5/v8/crypto"
+	"github.com/jcmturner/gokrb5/v8/gssapi"
+	"github.com/jcmturner/gokrb5/v8/iana/flags"
+	"github.com/jcmturner/gokrb5/v8/iana/keyusage"
+	"github.com/jcmturner/gokrb5/v8/keytab"
+	"github.com/jcmturner/gokrb5/v8/messages"
+	"github.com/jcmturner/gokrb5/v8/spnego"
+	"github.com/jcmturner/gokrb5/v8/types"
+	dnsv1 "github.com/miekg/dns"
+)
+
+// GSS-TSIG (RFC3645) signs messages like an HMAC TSIG, but with a security
+// context that is negotiated with the server using Kerberos, as Active
+// Directory and BIND's tkey-gssapi-keytab expect. The context is set up with
+// a TKEY query (RFC2930) and reused until it expires.
+
+const (
+	gssAlgorithm       = "gss-tsig."
+	gssTKEYNegotiation = 3 // TKEY mode: GSS-API negotiation (RFC2930 section 2.5)
+	gssContextLifetime = time.Hour
+)
+
+// gssKey is a Kerberos principal that signs messages with GSS-TSIG.
+type gssKey struct {
+	client *client.Client
+
+	mu       sync.Mutex
+	contexts map[string]*gssContext // By server address.
+}
+
+// gssContext is a security context established with a server. It signs and
+// verifies the TSIG of the messages exchanged with the server.
+type gssContext struct {
+	name           string // The key name of the TKEY query and of the TSIGs.
+	key            types.EncryptionKey
+	acceptorSubkey bool // key was chosen by the server.
+	expires        time.Time
+
+	mu  sync.Mutex
+	seq uint64 // The sequence number of the next MIC token.
+}
+
+// readGSSKey reads the Kerberos credentials of a GSS-TSIG key. spec is
+// either "PRINCIPAL:KEYTAB" or empty, to use the credential cache of kinit.
+// The Kerberos configuration is read from $KRB5_CONFIG or /etc/krb5.conf.
+func readGSSKey(spec string, kind string) (*gssKey, error) {
+	var user, realm, ktPath string
+	if spec != "" {
+		var principal string
+		var ok, hasRealm bool
+		principal, ktPath, ok = strings.Cut(spec, ":")
+		user, realm, hasRealm = strings.Cut(principal, "@")
+		if !ok || !hasRealm || user == "" || realm == "" || ktPath == "" {
+			return nil, fmt.Errorf("invalid GSS-TSIG key format (%s): expected gss-tsig:USER@REALM:KEYTAB", kind)
+		}
+	}
+
+	confPath := os.Getenv("KRB5_CONFIG")
+	if confPath == "" {
+		confPath = "/etc/krb5.conf"
+	}
+	conf, err := config.Load(confPath)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read Kerberos configuration for GSS-TSIG (%s): %w", kind, err)
+	}
+
+	var cl *client.Client
+	if spec == "" {
+		ccPath := strings.TrimPrefix(os.Getenv("KRB5CCNAME"), "FILE:")
+		if ccPath == "" {
+			ccPath = fmt.Sprintf("/tmp/krb5cc_%d", os.Getuid())
+		}
+		cc, err := credentials.LoadCCache(ccPath)
+		if err != nil {
+			return nil, fmt.Errorf("cannot read Kerberos credential cache for GSS-TSIG (%s): %w", kind, err)
+		}
+		cl, err = client.NewFromCCache(cc, conf, client.DisablePAFXFAST(true))
+		if err != nil {
+			return nil, fmt.Errorf("invalid Kerberos credential cache for GSS-TSIG (%s): %w", kind, err)
+		}
+	} else {
+		kt, err := keytab.Load(ktPath)
+		if err != nil {
+			return nil, fmt.Errorf("cannot read keytab for GSS-TSIG (%s): %w", kind, err)
+		}
+		cl = client.NewWithKeytab(user, realm, kt, conf, client.DisablePAFXFAST(true))
+	}
+	return &gssKey{client: cl, contexts: map[string]*gssContext{}}, nil
+}
+
+// context returns the security context with server, negotiating a new one
+// if there is none yet or it expired.
+func (k *gssKey) context(server string) (*gssContext, error) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	if ctx := k.contexts[server]; ctx != nil && time.Now().Before(ctx.expires) {
+		return ctx, nil
+	}
+	ctx, err := k.negotiate(server, time.Now())
+	if err != nil {
+		return nil, fmt.Errorf("[Error] AXFRDDNS: GSS-TSIG negotiation with %s failed: %w", server, err)
+	}
+	k.contexts[server] = ctx
+	return ctx, nil
+}
+
+// negotiate sets up a security context with server. The server's service
+// principal is DNS/HOST, where HOST is the name of server.
+func (k *gssKey) negotiate(server string, now time.Time) (*gssContext, error) {
+	host, _, err := net.SplitHostPort(server)
+	if err != nil {
+		return nil, errors.New("GSS-TSIG requires the host name of the server (the update-mode and transfer-mode can't be \"unix\")")
+	}
+	if err := k.client.AffirmLogin(); err != nil {
+		return nil, err
+	}
+	tkt, sessionKey, err := k.client.GetServiceTicket("DNS/" + host)
+	if err != nil {
+		return nil, err
+	}
+	token, err := spnego.NewKRB5TokenAPREQ(k.client, tkt, sessionKey,
+		[]int{gssapi.ContextFlagMutual, gssapi.ContextFlagReplay, gssapi.ContextFlagInteg},
+		[]int{flags.APOptionMutualRequired})
+	if err != nil {
+		return nil, err
+	}
+	// The MIC tokens are numbered from the sequence number of the authenticator.
+	if err := token.APReq.DecryptAuthenticator(sessionKey); err != nil {
+		return nil, err
+	}
+	seq := uint64(token.APReq.Authenticator.SeqNumber)
+	b, err := token.Marshal()
+	if err != nil {
+		return nil, err
+	}
+
+	var r [4]byte
+	if _, err := rand.Read(r[:]); err != nil {
+		return nil, err
+	}
+	name := dnsv1.Fqdn(fmt.Sprintf("%d.sig-%s", binary.BigEndian.Uint32(r[:]), host))
+	query := new(dnsv1.Msg)
+	query.SetQuestion(name, dnsv1.TypeTKEY)
+	query.Question[0].Qclass = dnsv1.ClassANY
+	query.Extra = append(query.Extra, &dnsv1.TKEY{
+		Hdr:        dnsv1.RR_Header{Name: name, Rrtype: dnsv1.TypeTKEY, Class: dnsv1.ClassANY},
+		Algorithm:  gssAlgorithm,
+		Mode:       gssTKEYNegotiation,
+		Inception:  uint32(now.Unix()),
+		Expiration: uint32(now.Add(gssContextLifetime).Unix()),
+		KeySize:    uint16(len(b)),
+		Key:        hex.EncodeToString(b),
+	})
+
+	// The reply is signed with the new context, so it is read raw and
+	// verified once the context is set up.
+	conn, err := dnsv1.DialTimeout("tcp", server, dnsTimeout)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	if err := conn.SetDeadline(time.Now().Add(dnsTimeout)); err != nil {
+		return nil, err
+	}
+	if err := conn.WriteMsg(query); err != nil {
+		return nil, err
+	}
+	raw, err := conn.ReadMsgHeader(nil)
+	if err != nil {
+		return nil, err
+	}
+	reply := new(dnsv1.Msg)
+	if err := reply.Unpack(raw); err != nil {
+		return nil, err
+	}
+	if reply.Id != query.Id {
+		return nil, dnsv1.ErrId
+	}
+	if reply.Rcode != dnsv1.RcodeSuccess {
+		return nil, fmt.Errorf("TKEY query refused: %s", dnsv1.RcodeToString[reply.Rcode])
+	}
+	var tkey *dnsv1.TKEY
+	for _, rr := range reply.Answer {
+		if t, ok := rr.(*dnsv1.TKEY); ok {
+			tkey = t
+		}
+	}
+	if tkey == nil {
+		return nil, errors.New("no TKEY record in the reply")
+	}
+	if tkey.Error != 0 {
+		return nil, fmt.Errorf("TKEY error %s", dnsv1.RcodeToString[int(tkey.Error)])
+	}
+
+	ctx, err := newGSSContext(name, tkey, sessionKey, seq)
+	if err != nil {
+		return nil, err
+	}
+	if reply.IsTsig() != nil {
+		if err := dnsv1.TsigVerifyWithProvider(raw, ctx, "", false); err != nil {
+			return nil, fmt.Errorf("the signature of the TKEY reply is invalid: %w", err)
+		}
+	}
+	return ctx, nil
+}
+
+// newGSSContext completes the security context with the server's token
+// (an AP-REP) from the TKEY reply.
+func newGSSContext(name string, tkey *dnsv1.TKEY, sessionKey types.EncryptionKey, seq uint64) (*gssContext, error) {
+	b, err := hex.DecodeString(tkey.Key)
+	if err != nil {
+		return nil, err
+	}
+	var token spnego.KRB5Token
+	if err := token.Unmarshal(b); err != nil {
+		return nil, err
+	}
+	if token.IsKRBError() {
+		return nil, token.KRBError
+	}
+	if !token.IsAPRep() {
+		return nil, errors.New("the server did not authenticate itself (no AP-REP)")
+	}
+	// Only the server can encrypt the AP-REP with the session key.
+	plain, err := crypto.DecryptEncPart(token.APRep.EncPart, sessionKey, keyusage.AP_REP_ENCPART)
+	if err != nil {
+		return nil, err
+	}
+	var part messages.EncAPRepPart
+	if err := part.Unmarshal(plain); err != nil {
+		return nil, err
+	}
+
+	ctx := &gssContext{
+		name:    name,
+		key:     sessionKey,
+		expires: time.Unix(int64(tkey.Expiration), 0).Add(-time.Minute),
+		seq:     seq,
+	}
+	if len(part.Subkey.KeyValue) != 0 {
+		ctx.key = part.Subkey
+		ctx.acceptorSubkey = true
+	}
+	return ctx, nil
+}
+
+// Generate implements dnsv1.TsigProvider. The MAC of the TSIG is a MIC
+// token (RFC4121 section 4.2.6.1) of msg.
+func (ctx *gssContext) Generate(msg []byte, _ *dnsv1.TSIG) ([]byte, error) {
+	ctx.mu.Lock()
+	seq := ctx.seq
+	ctx.seq++
+	ctx.mu.Unlock()
+
+	token := gssapi.MICToken{SndSeqNum: seq, Payload: msg}
+	if ctx.acceptorSubkey {
+		token.Flags = gssapi.MICTokenFlagAcceptorSubkey
+	}
+	if err := token.SetChecksum(ctx.key, keyusage.GSSAPI_INITIATOR_SIGN); err != nil {
+		return nil, err
+	}
+	return token.Marshal()
+}
+
+// Verify implements dnsv1.TsigProvider. It checks the server's MIC token.
+func (ctx *gssContext) Verify(msg []byte, t *dnsv1.TSIG) error {
+	mac, err := hex.DecodeString(t.MAC)
+	if err != nil {
+		return err
+	}
+	var token gssapi.MICToken
+	if err := token.Unmarshal(mac, true); err != nil {
+		return fmt.Errorf("%w: %w", dnsv1.ErrSig, err)
+	}
+	token.Payload = msg
+	if ok, err := token.Verify(ctx.key, keyusage.GSSAPI_ACCEPTOR_SIGN); !ok {
+		return fmt.Errorf("%w: %w", dnsv1.ErrSig, err)
+	}
+	return nil
+}
@@ -0,0 +1,150 @@
+package axfrddns
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/jcmturner/gofork/encoding/asn1"
+	"github.com/jcmturner/gokrb5/v8/asn1tools"
+	"github.com/jcmturner/gokrb5/v8/crypto"
+	"github.com/jcmturner/gokrb5/v8/gssapi"
+	"github.com/jcmturner/gokrb5/v8/iana/asnAppTag"
+	"github.com/jcmturner/gokrb5/v8/iana/etypeID"
+	"github.com/jcmturner/gokrb5/v8/iana/keyusage"
+	"github.com/jcmturner/gokrb5/v8/iana/msgtype"
+	"github.com/jcmturner/gokrb5/v8/keytab"
+	"github.com/jcmturner/gokrb5/v8/messages"
+	"github.com/jcmturner/gokrb5/v8/types"
+	dnsv1 "github.com/miekg/dns"
+)
+
+func TestReadKey_gss(t *testing.T) {
+	dir := t.TempDir()
+	conf := filepath.Join(dir, "krb5.conf")
+	if err := os.WriteFile(conf, []byte("[libdefaults]\n  default_realm = EXAMPLE.COM\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("KRB5_CONFIG", conf)
+	kt := keytab.New()
+	if err := kt.AddEntry("dnscontrol", "EXAMPLE.COM", "secret", time.Now(), 1, etypeID.AES256_CTS_HMAC_SHA1_96); err != nil {
+		t.Fatal(err)
+	}
+	b, err := kt.Marshal()
+	if err != nil {
+		t.Fatal(err)
+	}
+	ktPath := filepath.Join(dir, "dnscontrol.keytab")
+	if err := os.WriteFile(ktPath, b, 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	k, err := readKey("gss-tsig:dnscontrol@EXAMPLE.COM:"+ktPath, "update-key")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if k.gss == nil || k.gss.client.Credentials.UserName() != "dnscontrol" {
+		t.Errorf("readKey() = %+v", k)
+	}
+
+	if _, err := readKey("gss-tsig:dnscontrol@EXAMPLE.COM:"+ktPath+"-missing", "update-key"); err == nil {
+		t.Error("readKey() of a missing keytab succeeded")
+	}
+}
+
+// apRep returns the TKEY record of a reply whose AP-REP asserts subkey.
+func apRep(t *testing.T, sessionKey, subkey types.EncryptionKey) *dnsv1.TKEY {
+	t.Helper()
+	part, err := asn1.Marshal(messages.EncAPRepPart{CTime: time.Now().UTC().Truncate(time.Second), Subkey: subkey})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ed, err := crypto.GetEncryptedData(asn1tools.AddASNAppTag(part, asnAppTag.EncAPRepPart), sessionKey, keyusage.AP_REP_ENCPART, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rep, err := asn1.Marshal(messages.APRep{PVNO: 5, MsgType: msgtype.KRB_AP_REP, EncPart: ed})
+	if err != nil {
+		t.Fatal(err)
+	}
+	token, err := asn1.Marshal(gssapi.OIDKRB5.OID())
+	if err != nil {
+		t.Fatal(err)
+	}
+	token = append(token, 0x02, 0x00)
+	token = append(token, asn1tools.AddASNAppTag(rep, asnAppTag.APREP)...)
+	b := asn1tools.AddASNAppTag(token, 0)
+	return &dnsv1.TKEY{
+		Algorithm:  gssAlgorithm,
+		Mode:       gssTKEYNegotiation,
+		Expiration: uint32(time.Now().Add(time.Hour).Unix()),
+		KeySize:    uint16(len(b)),
+		Key:        hex.EncodeToString(b),
+	}
+}
+
+func newTestKey(t *testing.T) types.EncryptionKey {
+	t.Helper()
+	k := types.EncryptionKey{KeyType: etypeID.AES256_CTS_HMAC_SHA1_96, KeyValue: make([]byte, 32)}
+	if _, err := rand.Read(k.KeyValue); err != nil {
+		t.Fatal(err)
+	}
+	return k
+}
+
+func TestGSSContext(t *testing.T) {
+	sessionKey, subkey := newTestKey(t), newTestKey(t)
+	ctx, err := newGSSContext("1.sig-ns1.example.com.", apRep(t, sessionKey, subkey), sessionKey, 42)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !ctx.acceptorSubkey || !bytes.Equal(ctx.key.KeyValue, subkey.KeyValue) {
+		t.Fatalf("the context does not use the server's subkey: %+v", ctx)
+	}
+
+	// The AP-REP can only be decrypted with the session key.
+	if _, err := newGSSContext("1.sig-ns1.example.com.", apRep(t, newTestKey(t), subkey), sessionKey, 42); err == nil {
+		t.Error("newGSSContext() accepted an AP-REP of another session")
+	}
+
+	// The server verifies our MIC tokens.
+	payload := []byte("message and TSIG variables")
+	for want := uint64(42); want < 44; want++ {
+		mac, err := ctx.Generate(payload, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var token gssapi.MICToken
+		if err := token.Unmarshal(mac, false); err != nil {
+			t.Fatal(err)
+		}
+		token.Payload = payload
+		if ok, err := token.Verify(subkey, keyusage.GSSAPI_INITIATOR_SIGN); !ok {
+			t.Errorf("the server can't verify the MIC: %v", err)
+		}
+		if token.SndSeqNum != want || token.Flags != gssapi.MICTokenFlagAcceptorSubkey {
+			t.Errorf("MIC token seq=%d flags=%#x, want seq=%d flags=%#x", token.SndSeqNum, token.Flags, want, gssapi.MICTokenFlagAcceptorSubkey)
+		}
+	}
+
+	// We verify the server's MIC tokens.
+	token := gssapi.MICToken{Flags: gssapi.MICTokenFlagSentByAcceptor | gssapi.MICTokenFlagAcceptorSubkey, SndSeqNum: 7, Payload: payload}
+	if err := token.SetChecksum(subkey, keyusage.GSSAPI_ACCEPTOR_SIGN); err != nil {
+		t.Fatal(err)
+	}
+	mac, err := token.Marshal()
+	if err != nil {
+		t.Fatal(err)
+	}
+	tsig := &dnsv1.TSIG{MAC: hex.EncodeToString(mac)}
+	if err := ctx.Verify(payload, tsig); err != nil {
+		t.Errorf("Verify() = %v", err)
+	}
+	if err := ctx.Verify([]byte("tampered"), tsig); err == nil {
+		t.Error("Verify() of a tampered message succeeded")
+	}
+}
@@ -0,0 +1,227 @@
+package axfrddns
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/DNSControl/dnscontrol/v4/pkg/printer"
+	dnsv1 "github.com/miekg/dns"
+)
+
+// With a cache-directory, the last transferred copy of each zone is kept
+// on disk. The next run sends an IXFR (RFC1995) with the serial of the
+// copy: the server answers with just its SOA when the zone is unchanged,
+// or with the differences since that serial. Servers that don't keep the
+// differences answer with the whole zone, like an AXFR.
+
+// cacheHeader is the first line of a cache file. It is checked when the
+// file is read, so that a copy from another server is not used.
+func cacheHeader(domain, server string) string {
+	return fmt.Sprintf("; AXFRDDNS cache of %s from %s. Generated by dnscontrol. Do not edit.", domain, server)
+}
+
+// cacheFile is the name of the cache file of domain. Each transfer server
+// has its own subdirectory, so that providers that share the cache-directory
+// but transfer the zone from different servers don't overwrite each other's
+// copy.
+func (c *axfrddnsProvider) cacheFile(domain string) string {
+	server := strings.Map(func(r rune) rune {
+		if r == '.' || r == '-' || ('0' <= r && r <= '9') || ('a' <= r && r <= 'z') || ('A' <= r && r <= 'Z') {
+			return r
+		}
+		return '_'
+	}, c.transferServer)
+	return filepath.Join(c.cacheDirectory, server, domain+".zone")
+}
+
+// fetchCachedZoneRecords is fetchZoneRecords, but updates the cached copy
+// of the zone with an IXFR instead of transferring all of it.
+func (c *axfrddnsProvider) fetchCachedZoneRecords(domain string, transferKey *Key) ([]dnsv1.RR, error) {
+	fname := c.cacheFile(domain)
+	cached, err := readZoneCache(fname, domain, cacheHeader(domain, c.transferServer))
+	if err != nil {
+		printer.Warnf("AXFRDDNS: ignoring the cache of %s: %s\n", domain, err)
+	}
+
+	var zone []dnsv1.RR
+	if cached != nil {
+		soa := cached[0].(*dnsv1.SOA)
+		request := new(dnsv1.Msg)
+		request.SetIxfr(domain+".", soa.Serial, soa.Ns, soa.Mbox)
+		answer, err := c.transfer(domain, request, transferKey)
+		if err != nil {
+			return nil, err
+		}
+		var ok bool
+		zone, ok = applyIxfr(cached, answer)
+		switch {
+		case !ok:
+			printer.Debugf("AXFRDDNS: can't apply the IXFR of %s to the cache, transferring the zone\n", domain)
+		case len(answer) == 1:
+			printer.Debugf("AXFRDDNS: %s is unchanged (serial %d)\n", domain, soa.Serial)
+			return append(zone, zone[0]), nil
+		default:
+			printer.Debugf("AXFRDDNS: %s updated from serial %d to %d (IXFR of %d records)\n", domain, soa.Serial, zone[0].(*dnsv1.SOA).Serial, len(answer))
+		}
+	}
+
+	if zone == nil {
+		request := new(dnsv1.Msg)
+		request.SetAxfr(domain + ".")
+		answer, err := c.transfer(domain, request, transferKey)
+		if err != nil {
+			return nil, err
+		}
+		if len(answer) < 2 {
+			return answer, nil
+		}
+		zone = answer[:len(answer)-1]
+	}
+
+	if err := writeZoneCache(fname, cacheHeader(domain, c.transferServer), zone); err != nil {
+		return nil, fmt.Errorf("AXFRDDNS: can't write the cache of %s: %w", domain, err)
+	}
+	// The records as the AXFR sends them: with the SOA first and last.
+	return append(zone, zone[0]), nil
+}
+
+// rrKey identifies rr when it is deleted: the deletion may have a
+// different TTL, and names are not case sensitive.
+func rrKey(rr dnsv1.RR) string {
+	rr = dnsv1.Copy(rr)
+	h := rr.Header()
+	h.Name = strings.ToLower(h.Name)
+	h.Ttl = 0
+	return rr.String()
+}
+
+// applyIxfr applies the answer to an IXFR request to the cached zone,
+// which starts with its SOA. The zone is returned in the same form. If
+// the answer doesn't apply to the cache, ok is false and the zone must be
+// transferred again.
+func applyIxfr(cached, answer []dnsv1.RR) (zone []dnsv1.RR, ok bool) {
+	if len(answer) == 0 {
+		return nil, false
+	}
+	soa, isSOA := answer[0].(*dnsv1.SOA)
+	if !isSOA {
+		return nil, false
+	}
+	serial := cached[0].(*dnsv1.SOA).Serial
+
+	switch {
+	case len(answer) == 1:
+		// Only the SOA: the zone is unchanged.
+		return cached, soa.Serial == serial
+
+	case answer[1].Header().Rrtype != dnsv1.TypeSOA:
+		// The whole zone, as in an AXFR.
+		last, isSOA := answer[len(answer)-1].(*dnsv1.SOA)
+		if !isSOA || last.Serial != soa.Serial {
+			return nil, false
+		}
+		return answer[:len(answer)-1], true
+	}
+
+	// The differences: sequences of the old SOA, the deleted records,
+	// the new SOA and the added records, ending with the current SOA.
+	if answer[1].(*dnsv1.SOA).Serial != serial {
+		return nil, false
+	}
+	last, isSOA := answer[len(answer)-1].(*dnsv1.SOA)
+	if !isSOA || last.Serial != soa.Serial {
+		return nil, false
+	}
+	records := slices.Clone(cached[1:])
+	index := make(map[string]int, len(records))
+	for i, rr := range records {
+		index[rrKey(rr)] = i
+	}
+	deleting := false
+	for _, rr := range answer[1 : len(answer)-1] {
+		if rr.Header().Rrtype == dnsv1.TypeSOA {
+			deleting = !deleting
+			continue
+		}
+		key := rrKey(rr)
+		if deleting {
+			i, found := index[key]
+			if !found {
+				return nil, false
+			}
+			records[i] = nil
+			delete(index, key)
+		} else if _, found := index[key]; !found {
+			index[key] = len(records)
+			records = append(records, rr)
+		}
+	}
+
+	zone = make([]dnsv1.RR, 0, len(index)+1)
+	zone = append(zone, soa)
+	for _, rr := range records {
+		if rr != nil {
+			zone = append(zone, rr)
+		}
+	}
+	return zone, true
+}
+
+// readZoneCache returns the cached zone in fname, or nil if there is none.
+func readZoneCache(fname, domain, header string) ([]dnsv1.RR, error) {
+	f, err := os.Open(fname)
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	r := bufio.NewReader(f)
+	first, err := r.ReadString('\n')
+	if err != nil || strings.TrimSuffix(first, "\n") != header {
+		return nil, fmt.Errorf("%s was not written for this provider", fname)
+	}
+	var zone []dnsv1.RR
+	zp := dnsv1.NewZoneParser(r, domain+".", fname)
+	for rr, ok := zp.Next(); ok; rr, ok = zp.Next() {
+		zone = append(zone, rr)
+	}
+	if err := zp.Err(); err != nil {
+		return nil, err
+	}
+	if len(zone) == 0 || zone[0].Header().Rrtype != dnsv1.TypeSOA {
+		return nil, fmt.Errorf("%s does not start with a SOA", fname)
+	}
+	return zone, nil
+}
+
+// writeZoneCache replaces fname with the zone. The file is replaced
+// atomically so that an interrupted run doesn't leave half a zone.
+func writeZoneCache(fname, header string, zone []dnsv1.RR) error {
+	if err := os.MkdirAll(filepath.Dir(fname), 0o750); err != nil {
+		return err
+	}
+	f, err := os.CreateTemp(filepath.Dir(fname), filepath.Base(fname)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+	w := bufio.NewWriter(f)
+	fmt.Fprintln(w, header)
+	for _, rr := range zone {
+		fmt.Fprintln(w, rr.String())
+	}
+	if err := w.Flush(); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(f.Name(), fname)
+}
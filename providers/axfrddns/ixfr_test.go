@@ -0,0 +1,226 @@
+package axfrddns
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"strings"
+	"testing"
+
+	dnsv1 "github.com/miekg/dns"
+)
+
+func mustRRs(t *testing.T, lines ...string) []dnsv1.RR {
+	t.Helper()
+	var rrs []dnsv1.RR
+	for _, line := range lines {
+		rr, err := dnsv1.NewRR(line)
+		if err != nil {
+			t.Fatal(err)
+		}
+		rrs = append(rrs, rr)
+	}
+	return rrs
+}
+
+func rrStrings(rrs []dnsv1.RR) string {
+	var s []string
+	for _, rr := range rrs {
+		s = append(s, rr.String())
+	}
+	return strings.Join(s, "\n")
+}
+
+const (
+	soa1 = "example.com. 300 IN SOA ns.example.com. hostmaster.example.com. 1 3600 600 604800 300"
+	soa2 = "example.com. 300 IN SOA ns.example.com. hostmaster.example.com. 2 3600 600 604800 300"
+	soa3 = "example.com. 300 IN SOA ns.example.com. hostmaster.example.com. 3 3600 600 604800 300"
+)
+
+func TestApplyIxfr(t *testing.T) {
+	cached := mustRRs(t, soa1,
+		"example.com. 300 IN NS ns.example.com.",
+		"www.example.com. 300 IN A 192.0.2.1",
+		"mail.example.com. 300 IN A 192.0.2.2",
+	)
+	tests := []struct {
+		name   string
+		answer []dnsv1.RR
+		want   []dnsv1.RR
+		ok     bool
+	}{
+		{
+			name:   "unchanged",
+			answer: mustRRs(t, soa1),
+			want:   cached,
+			ok:     true,
+		},
+		{
+			name:   "server serial went back",
+			answer: mustRRs(t, soa2),
+			ok:     false,
+		},
+		{
+			name: "whole zone",
+			answer: mustRRs(t, soa2,
+				"example.com. 300 IN NS ns.example.com.",
+				soa2,
+			),
+			want: mustRRs(t, soa2, "example.com. 300 IN NS ns.example.com."),
+			ok:   true,
+		},
+		{
+			name: "differences",
+			answer: mustRRs(t, soa3,
+				soa1,
+				"WWW.example.com. 3600 IN A 192.0.2.1",
+				soa2,
+				"www.example.com. 300 IN A 192.0.2.10",
+				soa2,
+				"mail.example.com. 300 IN A 192.0.2.2",
+				soa3,
+				"mail.example.com. 300 IN A 192.0.2.20",
+				"new.example.com. 300 IN TXT \"new\"",
+				soa3,
+			),
+			want: mustRRs(t, soa3,
+				"example.com. 300 IN NS ns.example.com.",
+				"www.example.com. 300 IN A 192.0.2.10",
+				"mail.example.com. 300 IN A 192.0.2.20",
+				"new.example.com. 300 IN TXT \"new\"",
+			),
+			ok: true,
+		},
+		{
+			name:   "differences from another serial",
+			answer: mustRRs(t, soa3, soa2, soa3, "new.example.com. 300 IN TXT \"new\"", soa3),
+			ok:     false,
+		},
+		{
+			name:   "deletes a missing record",
+			answer: mustRRs(t, soa2, soa1, "missing.example.com. 300 IN A 192.0.2.3", soa2, soa2),
+			ok:     false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			before := rrStrings(cached)
+			got, ok := applyIxfr(cached, tt.answer)
+			if ok != tt.ok {
+				t.Fatalf("ok = %v, want %v", ok, tt.ok)
+			}
+			if ok && rrStrings(got) != rrStrings(tt.want) {
+				t.Errorf("zone =\n%s\nwant\n%s", rrStrings(got), rrStrings(tt.want))
+			}
+			if rrStrings(cached) != before {
+				t.Errorf("the cached zone was modified")
+			}
+		})
+	}
+}
+
+// ixfrServer serves example.com at serial 1, or at serial 2 once serial
+// is changed. It only keeps the differences from serial 1.
+type ixfrServer struct {
+	serial    uint32
+	transfers []string // The requests: "AXFR" or "IXFR <serial>".
+}
+
+func (s *ixfrServer) ServeDNS(w dnsv1.ResponseWriter, r *dnsv1.Msg) {
+	zone1 := []string{soa1, "example.com. 300 IN NS ns.example.com.", "www.example.com. 300 IN A 192.0.2.1"}
+	zone2 := []string{soa2, "example.com. 300 IN NS ns.example.com.", "www.example.com. 300 IN A 192.0.2.10"}
+	reply := new(dnsv1.Msg)
+	reply.SetReply(r)
+	var answer []string
+	switch r.Question[0].Qtype {
+	case dnsv1.TypeAXFR:
+		s.transfers = append(s.transfers, "AXFR")
+		answer = append(zone1, soa1)
+		if s.serial == 2 {
+			answer = append(zone2, soa2)
+		}
+	case dnsv1.TypeIXFR:
+		serial := r.Ns[0].(*dnsv1.SOA).Serial
+		s.transfers = append(s.transfers, fmt.Sprintf("IXFR %d", serial))
+		switch {
+		case serial == s.serial:
+			answer = []string{soa1}
+			if s.serial == 2 {
+				answer = []string{soa2}
+			}
+		case serial == 1:
+			answer = []string{soa2, soa1, zone1[2], soa2, zone2[2], soa2}
+		default:
+			answer = append(zone2, soa2)
+		}
+	}
+	for _, line := range answer {
+		rr, _ := dnsv1.NewRR(line)
+		reply.Answer = append(reply.Answer, rr)
+	}
+	_ = w.WriteMsg(reply)
+}
+
+func TestFetchCachedZoneRecords(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := &ixfrServer{serial: 1}
+	srv := &dnsv1.Server{Listener: ln, Handler: server}
+	go func() { _ = srv.ActivateAndServe() }()
+	defer func() { _ = srv.Shutdown() }()
+
+	c := &axfrddnsProvider{
+		transferServer: ln.Addr().String(),
+		transferMode:   "tcp",
+		cacheDirectory: t.TempDir(),
+	}
+	fetch := func(want ...string) {
+		t.Helper()
+		rrs, err := c.fetchZoneRecords("example.com", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		// The SOA first and last, like an AXFR.
+		want = append(want, want[0])
+		if got := rrStrings(rrs); got != rrStrings(mustRRs(t, want...)) {
+			t.Errorf("records =\n%s\nwant\n%s", got, rrStrings(mustRRs(t, want...)))
+		}
+	}
+
+	// Nothing is cached yet.
+	fetch(soa1, "example.com. 300 IN NS ns.example.com.", "www.example.com. 300 IN A 192.0.2.1")
+	// The serial didn't change.
+	fetch(soa1, "example.com. 300 IN NS ns.example.com.", "www.example.com. 300 IN A 192.0.2.1")
+	// The differences.
+	server.serial = 2
+	fetch(soa2, "example.com. 300 IN NS ns.example.com.", "www.example.com. 300 IN A 192.0.2.10")
+	fetch(soa2, "example.com. 300 IN NS ns.example.com.", "www.example.com. 300 IN A 192.0.2.10")
+
+	if got, want := strings.Join(server.transfers, ", "), "AXFR, IXFR 1, IXFR 1, IXFR 2"; got != want {
+		t.Errorf("transfers = %s, want %s", got, want)
+	}
+
+	// A cache from another server is not used, nor overwritten.
+	first := c.cacheFile("example.com")
+	_, port, _ := net.SplitHostPort(ln.Addr().String())
+	c.transferServer = net.JoinHostPort("localhost", port)
+	if c.cacheFile("example.com") == first {
+		t.Fatalf("the servers share the cache file %s", first)
+	}
+	fetch(soa2, "example.com. 300 IN NS ns.example.com.", "www.example.com. 300 IN A 192.0.2.10")
+	if got := server.transfers[len(server.transfers)-1]; got != "AXFR" {
+		t.Errorf("last transfer = %s, want AXFR", got)
+	}
+	b, err := os.ReadFile(c.cacheFile("example.com"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(string(b), cacheHeader("example.com", c.transferServer)+"\n") {
+		t.Errorf("cache file starts with %q", strings.SplitN(string(b), "\n", 2)[0])
+	}
+	if _, err := os.Stat(first); err != nil {
+		t.Errorf("the cache of the first server is gone: %v", err)
+	}
+}
@@ -0,0 +1,109 @@
+package axfrddns
+
+import (
+	"crypto"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	dnsv1 "github.com/miekg/dns"
+)
+
+// sig0Fudge is how long before and after the current time a SIG(0)
+// signature is valid (RFC2931 section 3.1 suggests a few minutes).
+const sig0Fudge = 5 * time.Minute
+
+// sig0Key is a key pair that signs updates with SIG(0) (RFC2931).
+type sig0Key struct {
+	name      string // The owner name of the KEY record.
+	algorithm uint8
+	keyTag    uint16
+	priv      crypto.Signer
+}
+
+// readSig0Key reads a key pair in the format written by
+// `dnssec-keygen -T KEY`: Kname.+alg+tag.key and Kname.+alg+tag.private.
+// path may name either file, or leave out the extension.
+func readSig0Key(path string, kind string) (*sig0Key, error) {
+	base := strings.TrimSuffix(strings.TrimSuffix(path, ".key"), ".private")
+
+	f, err := os.Open(base + ".key")
+	if err != nil {
+		return nil, fmt.Errorf("cannot read SIG(0) key (%s): %w", kind, err)
+	}
+	defer f.Close()
+	rr, err := dnsv1.ReadRR(f, base+".key")
+	if err != nil {
+		return nil, fmt.Errorf("cannot parse SIG(0) key (%s): %w", kind, err)
+	}
+	var pub *dnsv1.DNSKEY
+	switch k := rr.(type) {
+	case *dnsv1.KEY:
+		pub = &k.DNSKEY
+	case *dnsv1.DNSKEY:
+		pub = k
+	default:
+		return nil, fmt.Errorf("SIG(0) key (%s): %s.key does not contain a KEY record", kind, base)
+	}
+
+	p, err := os.Open(base + ".private")
+	if err != nil {
+		return nil, fmt.Errorf("cannot read SIG(0) private key (%s): %w", kind, err)
+	}
+	defer p.Close()
+	priv, err := pub.ReadPrivateKey(p, base+".private")
+	if err != nil {
+		return nil, fmt.Errorf("cannot parse SIG(0) private key (%s): %w", kind, err)
+	}
+	signer, ok := priv.(crypto.Signer)
+	if !ok {
+		return nil, fmt.Errorf("SIG(0) key (%s): unsupported algorithm %s", kind, dnsv1.AlgorithmToString[pub.Algorithm])
+	}
+
+	return &sig0Key{
+		name:      dnsv1.CanonicalName(pub.Hdr.Name),
+		algorithm: pub.Algorithm,
+		keyTag:    pub.KeyTag(),
+		priv:      signer,
+	}, nil
+}
+
+// sign returns the wire format of m with a SIG(0) record appended.
+func (k *sig0Key) sign(m *dnsv1.Msg, now time.Time) ([]byte, error) {
+	sig := &dnsv1.SIG{}
+	sig.Algorithm = k.algorithm
+	sig.KeyTag = k.keyTag
+	sig.SignerName = k.name
+	sig.Inception = uint32(now.Add(-sig0Fudge).Unix())
+	sig.Expiration = uint32(now.Add(sig0Fudge).Unix())
+	return sig.Sign(k.priv, m)
+}
+
+// exchangeSig0 sends the update m signed with k and returns the reply.
+// dnsv1.Client.Exchange can't be used as it packs the message itself.
+func exchangeSig0(client *dnsv1.Client, k *sig0Key, m *dnsv1.Msg, address string) (*dnsv1.Msg, error) {
+	buf, err := k.sign(m, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	conn, err := client.Dial(address)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	if err := conn.SetDeadline(time.Now().Add(dnsTimeout)); err != nil {
+		return nil, err
+	}
+	if _, err := conn.Write(buf); err != nil {
+		return nil, err
+	}
+	r, err := conn.ReadMsg()
+	if err != nil {
+		return nil, err
+	}
+	if r.Id != m.Id {
+		return nil, dnsv1.ErrId
+	}
+	return r, nil
+}
@@ -0,0 +1,184 @@
+package axfrddns
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DNSControl/dnscontrol/v4/models"
+	dnsv1 "github.com/miekg/dns"
+)
+
+// writeSig0Key writes a new key pair like `dnssec-keygen -T KEY` and
+// returns the file name without extension and the public key.
+func writeSig0Key(t *testing.T, dir, name string) (string, *dnsv1.KEY) {
+	t.Helper()
+	pub := &dnsv1.KEY{DNSKEY: dnsv1.DNSKEY{
+		Hdr:       dnsv1.RR_Header{Name: name, Rrtype: dnsv1.TypeKEY, Class: dnsv1.ClassINET, Ttl: 3600},
+		Flags:     512,
+		Protocol:  3,
+		Algorithm: dnsv1.ED25519,
+	}}
+	priv, err := pub.Generate(256)
+	if err != nil {
+		t.Fatal(err)
+	}
+	base := filepath.Join(dir, fmt.Sprintf("K%s+%03d+%05d", name, pub.Algorithm, pub.KeyTag()))
+	if err := os.WriteFile(base+".key", []byte(pub.String()+"\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(base+".private", []byte(pub.PrivateKeyString(priv)), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return base, pub
+}
+
+func TestReadKey_sig0(t *testing.T) {
+	base, pub := writeSig0Key(t, t.TempDir(), "update.example.com.")
+
+	for _, path := range []string{base, base + ".key", base + ".private"} {
+		k, err := readKey("sig0:"+path, "update-key")
+		if err != nil {
+			t.Fatalf("readKey(%q): %v", path, err)
+		}
+		if k.sig0 == nil || k.sig0.name != "update.example.com." || k.sig0.keyTag != pub.KeyTag() {
+			t.Errorf("readKey(%q) = %+v", path, k.sig0)
+		}
+	}
+
+	if _, err := readKey("sig0:"+base+"-missing", "update-key"); err == nil {
+		t.Error("readKey() of a missing file succeeded")
+	}
+}
+
+func TestSig0Key_sign(t *testing.T) {
+	base, pub := writeSig0Key(t, t.TempDir(), "update.example.com.")
+	k, err := readSig0Key(base, "update-key")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	update := new(dnsv1.Msg)
+	update.SetUpdate("example.com.")
+	rr, _ := dnsv1.NewRR("www.example.com. 300 IN A 1.2.3.4")
+	update.Insert([]dnsv1.RR{rr})
+	buf, err := k.sign(update, time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	signed := new(dnsv1.Msg)
+	if err := signed.Unpack(buf); err != nil {
+		t.Fatal(err)
+	}
+	if len(signed.Extra) != 1 {
+		t.Fatalf("signed update has %d additional records, want 1", len(signed.Extra))
+	}
+	sig, ok := signed.Extra[0].(*dnsv1.SIG)
+	if !ok {
+		t.Fatalf("additional record is %T, want *dns.SIG", signed.Extra[0])
+	}
+	if err := sig.Verify(pub, buf); err != nil {
+		t.Errorf("signature does not verify: %v", err)
+	}
+}
+
+func TestReadKey_errors(t *testing.T) {
+	tests := []struct {
+		raw, want string
+	}{
+		{"gss-tsig:host/ns1.example.com", "invalid GSS-TSIG key format"},
+		{"hmac-sha256:id", "invalid key format"},
+		{"hmac-sha3:id:c2VjcmV0", "unknown algorithm"},
+		{"hmac-sha256:id:not base64", "Base64"},
+	}
+	for _, tt := range tests {
+		_, err := readKey(tt.raw, "update-key")
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("readKey(%q) error = %v, want %q", tt.raw, err, tt.want)
+		}
+	}
+}
+
+func TestZoneKey(t *testing.T) {
+	base, _ := writeSig0Key(t, t.TempDir(), "example.net.")
+	api, err := initAxfrDdns(map[string]string{
+		"master":          "127.0.0.1",
+		"update-key":      "hmac-sha256:default:c2VjcmV0",
+		"key-example.org": "hmac-sha512:example.org:b3RoZXJzZWNyZXQ=",
+		"key-example.net": "sig0:" + base,
+	}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := api.(*axfrddnsProvider)
+	zone := func(metadata map[string]string) *models.DomainConfig {
+		return &models.DomainConfig{Name: "example.com", Metadata: metadata}
+	}
+
+	k, err := c.zoneKey(zone(nil), metaUpdateKey, c.updateKey)
+	if err != nil || k.id != "default." {
+		t.Errorf("default update key = %+v, %v", k, err)
+	}
+	k, err = c.zoneKey(zone(map[string]string{metaUpdateKey: "example.org"}), metaUpdateKey, c.updateKey)
+	if err != nil || k.id != "example.org." || k.algo != dnsv1.HmacSHA512 {
+		t.Errorf("example.org update key = %+v, %v", k, err)
+	}
+	k, err = c.zoneKey(zone(map[string]string{metaUpdateKey: "example.net"}), metaUpdateKey, c.updateKey)
+	if err != nil || k.sig0 == nil {
+		t.Errorf("example.net update key = %+v, %v", k, err)
+	}
+	if k, err := c.zoneKey(zone(nil), metaTransferKey, c.transferKey); err != nil || k != nil {
+		t.Errorf("default transfer key = %+v, %v", k, err)
+	}
+
+	if _, err := c.zoneKey(zone(map[string]string{metaUpdateKey: "nope"}), metaUpdateKey, c.updateKey); err == nil || !strings.Contains(err.Error(), `"key-nope"`) {
+		t.Errorf("undefined key error = %v", err)
+	}
+	if _, err := c.zoneKey(zone(map[string]string{metaTransferKey: "example.net"}), metaTransferKey, c.transferKey); err == nil {
+		t.Error("a SIG(0) transfer key was accepted")
+	}
+}
+
+func TestExchangeSig0(t *testing.T) {
+	base, pub := writeSig0Key(t, t.TempDir(), "update.example.com.")
+	k, err := readSig0Key(base, "update-key")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// A server that accepts updates that are signed with pub.
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := &dnsv1.Server{Listener: ln, MsgAcceptFunc: func(dnsv1.Header) dnsv1.MsgAcceptAction { return dnsv1.MsgAccept }}
+	srv.Handler = dnsv1.HandlerFunc(func(w dnsv1.ResponseWriter, r *dnsv1.Msg) {
+		reply := new(dnsv1.Msg)
+		reply.SetRcode(r, dnsv1.RcodeRefused)
+		if sig, ok := r.Extra[len(r.Extra)-1].(*dnsv1.SIG); ok {
+			buf, _ := r.Pack()
+			if sig.Verify(pub, buf) == nil {
+				reply.SetRcode(r, dnsv1.RcodeSuccess)
+			}
+		}
+		_ = w.WriteMsg(reply)
+	})
+	go func() { _ = srv.ActivateAndServe() }()
+	defer func() { _ = srv.Shutdown() }()
+
+	update := new(dnsv1.Msg)
+	update.SetUpdate("example.com.")
+	client := &dnsv1.Client{Net: "tcp", Timeout: dnsTimeout}
+	r, err := exchangeSig0(client, k, update, ln.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.Rcode != dnsv1.RcodeSuccess {
+		t.Errorf("Rcode = %s, want NOERROR", dnsv1.RcodeToString[r.Rcode])
+	}
+}
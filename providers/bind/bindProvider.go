@@ -14,24 +14,24 @@ bind -
 */
 
 import (
+	"bytes"
 	"encoding/json"
 	"fmt"
 	"os"
 	"path/filepath"
+	"slices"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/DNSControl/dnscontrol/v4/models"
 	"github.com/DNSControl/dnscontrol/v4/pkg/bindserial"
 	"github.com/DNSControl/dnscontrol/v4/pkg/diff2"
-	"github.com/DNSControl/dnscontrol/v4/pkg/dnsrr"
 	"github.com/DNSControl/dnscontrol/v4/pkg/domaintags"
 	"github.com/DNSControl/dnscontrol/v4/pkg/prettyzone"
 	"github.com/DNSControl/dnscontrol/v4/pkg/printer"
 	"github.com/DNSControl/dnscontrol/v4/pkg/providers"
-	"github.com/DNSControl/dnscontrol/v4/pkg/rtypecontrol"
-	"github.com/DNSControl/dnscontrol/v4/pkg/rtypeinfo"
-	dnsv1 "github.com/miekg/dns"
+	"github.com/DNSControl/dnscontrol/v4/pkg/zonefile"
 )
 
 // defaultZonesDir is used when the BIND credentials do not specify a
@@ -41,7 +41,7 @@ const defaultZonesDir = "zones"
 var features = providers.DocumentationNotes{
 	// The default for unlisted capabilities is 'Cannot'.
 	// See providers/capabilities.go for the entire list of capabilities.
-	providers.CanAutoDNSSEC:          providers.Can("Just writes out a comment indicating DNSSEC was requested"),
+	providers.CanAutoDNSSEC:          providers.Can("Signs the zone files with local keys"),
 	providers.CanConcur:              providers.Can(),
 	providers.CanGetZones:            providers.Can(),
 	providers.CanUseCAA:              providers.Can(),
@@ -72,6 +72,14 @@ func initBind(config map[string]string, providermeta json.RawMessage) (providers
 	api := &bindProvider{
 		directory:      config["directory"],
 		filenameformat: config["filenameformat"],
+		configfile:     config["configfile"],
+		configformat:   config["configformat"],
+		configzonedir:  config["configzonedir"],
+		catalog:        strings.TrimSuffix(config["catalog"], "."),
+		keydirectory:   config["keydirectory"],
+		algorithm:      config["dnssecalgorithm"],
+		nsec3:          config["nsec3"] == "true",
+		generate:       config["generate"] == "true",
 	}
 	if api.directory == "" {
 		api.directory = "zones"
@@ -79,6 +87,21 @@ func initBind(config map[string]string, providermeta json.RawMessage) (providers
 	if api.filenameformat == "" {
 		api.filenameformat = "%c.zone"
 	}
+	if api.configformat == "" {
+		api.configformat = "bind"
+	}
+	if _, ok := serverConfigFormats[api.configformat]; !ok {
+		return nil, fmt.Errorf("configformat %q is not one of: bind, nsd, knot", api.configformat)
+	}
+	if api.keydirectory == "" {
+		api.keydirectory = defaultKeyDirectory
+	}
+	if api.algorithm == "" {
+		api.algorithm = defaultDNSSECAlgorithm
+	}
+	if _, ok := dnssecAlgorithms[api.algorithm]; !ok {
+		return nil, fmt.Errorf("dnssecalgorithm %q is not one of: RSASHA256, ECDSAP256SHA256, ECDSAP384SHA384, ED25519", api.algorithm)
+	}
 	if len(providermeta) != 0 {
 		err := json.Unmarshal(providermeta, api)
 		if err != nil {
@@ -164,6 +187,26 @@ type bindProvider struct {
 	nameservers    []*models.Nameserver
 	directory      string
 	filenameformat string
+
+	// The server config fragment: (see serverconf.go)
+	configfile    string
+	configformat  string
+	configzonedir string
+
+	// The catalog zone: (see catalog.go)
+	catalog string
+
+	// DNSSEC signing: (see dnssec.go)
+	keydirectory string
+	algorithm    string
+	nsec3        bool
+
+	// Write the records of GENERATE() as $GENERATE:
+	generate bool
+
+	sync.Mutex
+	configuredZones []*models.DomainConfig // Every zone that uses the provider.
+	configClaimed   bool                   // A zone's corrections update the config fragment.
 }
 
 // GetNameservers returns the nameservers for a domain.
@@ -236,45 +279,20 @@ func (c *bindProvider) GetZoneRecords(dc *models.DomainConfig) (models.Records,
 		return nil, fmt.Errorf("can't open %s: %w", zonefile, err)
 	}
 
-	return ParseZoneContents(string(content), domain, zonefile)
+	// The records generated when the zone is signed are not managed by
+	// dnsconfig.js. (See dnssec.go)
+	return parseUnsignedZone(string(content), domain, zonefile)
 }
 
 // ParseZoneContents parses a string as a BIND zone and returns the records.
 func ParseZoneContents(content string, zoneName string, zonefileName string) (models.Records, error) {
-	zp := dnsv1.NewZoneParser(strings.NewReader(content), zoneName, zonefileName)
-
-	foundRecords := models.Records{}
-	for rr, ok := zp.Next(); ok; rr, ok = zp.Next() {
-		var rec models.RecordConfig
-		var prec *models.RecordConfig
-		var err error
-
-		rtype := rr.Header().Rrtype
-		rtypeStr := dnsv1.TypeToString[rtype]
-		if rtypeinfo.IsModernType(rtypeStr) {
-			// Modern types:
-			name := rr.Header().Name
-			prec, err = rtypecontrol.NewRecordConfigFromStruct(name, rr.Header().Ttl, rtypeStr, rr, domaintags.MakeDomainNameVarieties(zoneName))
-			if err != nil {
-				return nil, err
-			}
-			rec = *prec
-			rec.TTL = rr.Header().Ttl
-		} else {
-			// Legacy types:
-			rec, err = dnsrr.RRtoRCTxtBug(rr, zoneName)
-			if err != nil {
-				return nil, err
-			}
-		}
-
-		foundRecords = append(foundRecords, &rec)
-	}
+	return zonefile.Parse(content, zoneName, zonefileName)
+}
 
-	if err := zp.Err(); err != nil {
-		return nil, fmt.Errorf("error while parsing '%v': %w", zonefileName, err)
-	}
-	return foundRecords, nil
+// parseUnsignedZone is zonefile.ParseUnsigned, for the functions with a
+// local variable named zonefile.
+func parseUnsignedZone(content string, zoneName string, zonefileName string) (models.Records, error) {
+	return zonefile.ParseUnsigned(content, zoneName, zonefileName)
 }
 
 func (c *bindProvider) EnsureZoneExists(_ *models.DomainConfig) error {
@@ -289,6 +307,42 @@ func (c *bindProvider) GetZoneRecordsCorrections(dc *models.DomainConfig, foundR
 	changes := false
 	var msg string
 
+	zonefile = filepath.Join(c.directory,
+		makeFileName(
+			c.filenameformat,
+			domaintags.DomainNameVarieties{
+				Tag:         dc.Tag,
+				NameRaw:     dc.NameRaw,
+				NameASCII:   dc.Name,
+				NameUnicode: dc.NameUnicode,
+				UniqueName:  dc.UniqueName,
+			},
+		),
+	)
+
+	// If the zone is signed, its keys are not part of dnsconfig.js.
+	// Without AUTODNSSEC_ON/OFF, a signed zone stays signed.
+	var signer *zoneSigner
+	var resign string // Why the zone must be signed again.
+	if dc.AutoDNSSEC != "off" {
+		var err error
+		signer, err = newZoneSigner(c.keydirectory, dc.Name, c.algorithm, c.nsec3)
+		if err != nil {
+			return nil, 0, err
+		}
+		if dc.AutoDNSSEC == "" && !slices.ContainsFunc(foundRecords, signer.isOwnKey) {
+			signer = nil
+		}
+	}
+	if signer != nil {
+		foundRecords = slices.DeleteFunc(slices.Clone(foundRecords), signer.isOwnKey)
+		content, err := os.ReadFile(zonefile)
+		if err != nil && !os.IsNotExist(err) {
+			return nil, 0, fmt.Errorf("can't open %s: %w", zonefile, err)
+		}
+		resign = signer.resignReason(string(content), time.Now())
+	}
+
 	// Find the SOA records; use them to make or update the desired SOA.
 	var foundSoa *models.RecordConfig
 	for _, r := range foundRecords {
@@ -319,36 +373,33 @@ func (c *bindProvider) GetZoneRecordsCorrections(dc *models.DomainConfig, foundR
 		return nil, 0, err
 	}
 	msgs, changes, actualChangeCount = result.Msgs, result.HasChanges, result.ActualChangeCount
-	if !changes {
-		return nil, 0, nil
+
+	// The server config fragment is updated along with the zone files.
+	configCorrections, err := c.serverConfigCorrections()
+	if err != nil {
+		return nil, 0, err
+	}
+	if !changes && resign == "" {
+		return configCorrections, len(configCorrections), nil
+	}
+	if resign != "" {
+		msgs = append(msgs, fmt.Sprintf("± SIGN %s (%s)", dc.Name, resign))
+		if !changes {
+			actualChangeCount = 1 // The signatures are the only change.
+		}
 	}
+	resignOnly := !changes
 	msg = strings.Join(msgs, "\n")
 
 	comments := make([]string, 0, 5)
 	comments = append(comments,
 		"generated with dnscontrol "+time.Now().Format(time.RFC3339),
 	)
-	if dc.AutoDNSSEC == "on" {
-		// This does nothing but reminds the user to add the correct
-		// auto-dnssecc zone statement to named.conf.
-		// While it is a no-op, it is useful for situations where a zone
-		// has multiple providers.
-		comments = append(comments, "Automatic DNSSEC signing requested")
+	if signer != nil {
+		// The server must load the zone as is. It must not sign it again.
+		comments = append(comments, "DNSSEC signed by dnscontrol with the keys in "+c.keydirectory)
 	}
 
-	zonefile = filepath.Join(c.directory,
-		makeFileName(
-			c.filenameformat,
-			domaintags.DomainNameVarieties{
-				Tag:         dc.Tag,
-				NameRaw:     dc.NameRaw,
-				NameASCII:   dc.Name,
-				NameUnicode: dc.NameUnicode,
-				UniqueName:  dc.UniqueName,
-			},
-		),
-	)
-
 	// We only change the serial number if there is a change.
 	desiredSoa.SoaSerial = nextSerial
 
@@ -359,33 +410,44 @@ func (c *bindProvider) GetZoneRecordsCorrections(dc *models.DomainConfig, foundR
 
 	corrections = append(corrections,
 		&models.Correction{
-			Msg: msg,
+			Msg:       msg,
+			NonRecord: resignOnly, // The records are unchanged. Only the signatures are new.
 			F: func() error {
 				printer.Printf("WRITING ZONEFILE: %v\n", zonefile)
-				fname, err := preprocessFilename(zonefile)
-				if err != nil {
-					return fmt.Errorf("could not create zonefile: %w", err)
-				}
-				zf, err := os.Create(fname)
-				if err != nil {
-					return fmt.Errorf("could not create zonefile: %w", err)
-				}
 				// Beware that if there are any fake types, then they will
 				// be commented out on write, but we don't reverse that when
 				// reading, so there will be a diff on every invocation.
-				err = prettyzone.WriteZoneFileRC(zf, result.DesiredPlus, dc.Name, 0, comments)
+				var buf bytes.Buffer
+				write := prettyzone.WriteZoneFileRC
+				if c.generate {
+					write = prettyzone.WriteZoneFileGenerate
+				}
+				err := write(&buf, result.DesiredPlus, dc.Name, 0, comments)
 				if err != nil {
 					return fmt.Errorf("failed WriteZoneFile: %w", err)
 				}
-				err = zf.Close()
+				if signer != nil {
+					sigs, err := signer.sign(buf.Bytes(), time.Now())
+					if err != nil {
+						return fmt.Errorf("could not sign %s: %w", dc.Name, err)
+					}
+					buf.WriteString("\n; DNSSEC records. Generated by dnscontrol. Do not edit.\n")
+					for _, rr := range sigs {
+						buf.WriteString(rr.String() + "\n")
+					}
+				}
+				fname, err := preprocessFilename(zonefile)
 				if err != nil {
-					return fmt.Errorf("closing: %w", err)
+					return fmt.Errorf("could not create zonefile: %w", err)
+				}
+				if err := os.WriteFile(fname, buf.Bytes(), 0o666); err != nil {
+					return fmt.Errorf("could not create zonefile: %w", err)
 				}
 				return nil
 			},
 		})
 
-	return corrections, actualChangeCount, nil
+	return append(corrections, configCorrections...), actualChangeCount + len(configCorrections), nil
 }
 
 // preprocessFilename pre-processes a filename we're about to os.Create()
@@ -0,0 +1,32 @@
+package bind
+
+import (
+	"github.com/DNSControl/dnscontrol/v4/models"
+	"github.com/DNSControl/dnscontrol/v4/pkg/catalogzone"
+)
+
+// The catalog zone (creds.json "catalog") lists every zone of the provider
+// (RFC 9432). It is written like the other zone files, so that secondaries
+// that use it provision the zones of dnsconfig.js.
+
+// CatalogZoneCorrections returns the catalog zone and the corrections that
+// update it, if it is out of date.
+func (c *bindProvider) CatalogZoneCorrections() (*models.DomainConfig, []*models.Correction, int, error) {
+	c.Lock()
+	members := c.configuredZones
+	c.Unlock()
+	if c.catalog == "" || members == nil {
+		return nil, nil, 0, nil
+	}
+
+	dc, err := catalogzone.Zone(c.catalog, members)
+	if err != nil {
+		return nil, nil, 0, err
+	}
+	found, err := c.GetZoneRecords(dc)
+	if err != nil {
+		return nil, nil, 0, err
+	}
+	corrections, count, err := c.GetZoneRecordsCorrections(dc, found)
+	return dc, corrections, count, err
+}
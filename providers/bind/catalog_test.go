@@ -0,0 +1,73 @@
+package bind
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/DNSControl/dnscontrol/v4/models"
+)
+
+func TestCatalogCorrections(t *testing.T) {
+	dir := t.TempDir()
+	newProvider := func(zones ...string) *bindProvider {
+		c := &bindProvider{directory: dir, filenameformat: "%c.zone", catalog: "catalog.example", keydirectory: defaultKeyDirectory, algorithm: defaultDNSSECAlgorithm}
+		c.SetConfiguredZones(testZones(zones...))
+		return c
+	}
+
+	dc, corrections, count, err := newProvider("example.com", "example.org").CatalogZoneCorrections()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if dc.Name != "catalog.example" {
+		t.Errorf("zone = %q, want catalog.example", dc.Name)
+	}
+	if len(corrections) != 1 || count == 0 {
+		t.Fatalf("got %d corrections and %d changes, want 1", len(corrections), count)
+	}
+	if err := corrections[0].F(); err != nil {
+		t.Fatal(err)
+	}
+	b, err := os.ReadFile(filepath.Join(dir, "catalog.example.zone"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		"IN NS    invalid.",
+		`version          IN TXT   "2"`,
+		"c5e4b4da1e5a620ddaa3635e55c3732a5b49c7f4.zones IN PTR example.com.",
+		"47ac1a4d93b61fffdb4762c18c9e7d1a6b046d33.zones IN PTR example.org.",
+	} {
+		if !strings.Contains(string(b), want) {
+			t.Errorf("catalog zone does not contain %q:\n%s", want, b)
+		}
+	}
+
+	// Nothing changed.
+	if _, corrections, _, _ := newProvider("example.org", "example.com").CatalogZoneCorrections(); len(corrections) != 0 {
+		t.Errorf("unchanged catalog returned %d corrections, want 0", len(corrections))
+	}
+}
+
+// The catalog zone is not updated by the corrections of its members.
+func TestCatalogNotInMemberCorrections(t *testing.T) {
+	dir := t.TempDir()
+	c := &bindProvider{directory: dir, filenameformat: "%c.zone", catalog: "catalog.example", keydirectory: defaultKeyDirectory, algorithm: defaultDNSSECAlgorithm}
+	zones := testZones("example.com")
+	c.SetConfiguredZones(zones)
+
+	corrections, _, err := c.GetZoneRecordsCorrections(zones[0], models.Records{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, corr := range corrections {
+		if err := corr.F(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(dir, "catalog.example.zone")); !os.IsNotExist(err) {
+		t.Errorf("the member's corrections wrote the catalog zone (%v)", err)
+	}
+}
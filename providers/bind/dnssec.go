@@ -0,0 +1,473 @@
+package bind
+
+// Inline DNSSEC signing: with AUTODNSSEC_ON(), the zone file is signed with
+// the zone's keys in the key directory. A KSK and a ZSK are generated if the
+// zone has none. The DNSKEY, RRSIG and NSEC (or NSEC3) records are appended
+// to the zone file. They are not part of dnsconfig.js, so they are removed
+// from the existing records before they are compared.
+//
+// The zone is signed again whenever it changes, when a signature is about to
+// expire, and when the set of keys changes.
+
+import (
+	"bytes"
+	"cmp"
+	"crypto"
+	"fmt"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/DNSControl/dnscontrol/v4/models"
+	dnsv1 "github.com/miekg/dns"
+)
+
+const (
+	defaultKeyDirectory    = "keys"
+	defaultDNSSECAlgorithm = "ECDSAP256SHA256"
+
+	sigValidity = 30 * 24 * time.Hour // How long signatures are valid.
+	sigRefresh  = 10 * 24 * time.Hour // Sign again when a signature expires sooner than this.
+	sigBackdate = time.Hour           // Signatures are valid from a little earlier, in case of clock skew.
+	dnskeyTTL   = 3600
+)
+
+// dnssecAlgorithms are the algorithms that keys can be generated for, and
+// their key sizes.
+var dnssecAlgorithms = map[string]struct {
+	alg  uint8
+	bits int
+}{
+	"RSASHA256":       {dnsv1.RSASHA256, 2048},
+	"ECDSAP256SHA256": {dnsv1.ECDSAP256SHA256, 256},
+	"ECDSAP384SHA384": {dnsv1.ECDSAP384SHA384, 384},
+	"ED25519":         {dnsv1.ED25519, 256},
+}
+
+// signingKey is a DNSKEY and its private key.
+type signingKey struct {
+	dnskey *dnsv1.DNSKEY
+	priv   crypto.Signer
+}
+
+func (k *signingKey) isKSK() bool {
+	return k.dnskey.Flags&dnsv1.SEP != 0
+}
+
+// keyFileBase returns the name of the key's files without the extension, as
+// BIND's dnssec-keygen names them.
+func (k *signingKey) keyFileBase() string {
+	return fmt.Sprintf("K%s+%03d+%05d", k.dnskey.Hdr.Name, k.dnskey.Algorithm, k.dnskey.KeyTag())
+}
+
+// zoneSigner signs a zone.
+type zoneSigner struct {
+	origin    string // The zone's FQDN, in lower case.
+	keydir    string
+	algorithm uint8 // For new keys.
+	bits      int
+	nsec3     bool
+	keys      []*signingKey
+	needKSK   bool // A KSK must be generated.
+	needZSK   bool // A ZSK must be generated.
+}
+
+// newZoneSigner returns a signer that uses the zone's keys in keydir.
+func newZoneSigner(keydir, zone, algorithm string, nsec3 bool) (*zoneSigner, error) {
+	a, ok := dnssecAlgorithms[algorithm]
+	if !ok {
+		return nil, fmt.Errorf("dnssecalgorithm %q is not one of: RSASHA256, ECDSAP256SHA256, ECDSAP384SHA384, ED25519", algorithm)
+	}
+	s := &zoneSigner{
+		origin:    dnsv1.CanonicalName(zone),
+		keydir:    keydir,
+		algorithm: a.alg,
+		bits:      a.bits,
+		nsec3:     nsec3,
+	}
+	var err error
+	if s.keys, err = readSigningKeys(keydir, s.origin); err != nil {
+		return nil, err
+	}
+	if len(s.keys) != 0 {
+		// New keys must use the algorithm that the zone is signed with.
+		s.algorithm = s.keys[0].dnskey.Algorithm
+		for _, a := range dnssecAlgorithms {
+			if a.alg == s.algorithm {
+				s.bits = a.bits
+			}
+		}
+	}
+	s.needKSK = !slices.ContainsFunc(s.keys, (*signingKey).isKSK)
+	s.needZSK = !slices.ContainsFunc(s.keys, func(k *signingKey) bool { return !k.isKSK() })
+	return s, nil
+}
+
+// readSigningKeys reads the keys of origin in keydir. Revoked keys are ignored.
+func readSigningKeys(keydir, origin string) ([]*signingKey, error) {
+	files, err := filepath.Glob(filepath.Join(keydir, "K"+origin+"+*.key"))
+	if err != nil {
+		return nil, err
+	}
+	slices.Sort(files)
+	var keys []*signingKey
+	for _, file := range files {
+		f, err := os.Open(file)
+		if err != nil {
+			return nil, err
+		}
+		rr, err := dnsv1.ReadRR(f, file)
+		f.Close()
+		if err != nil {
+			return nil, fmt.Errorf("can't read key %s: %w", file, err)
+		}
+		dnskey, ok := rr.(*dnsv1.DNSKEY)
+		if !ok || dnsv1.CanonicalName(dnskey.Hdr.Name) != origin || dnskey.Flags&dnsv1.REVOKE != 0 {
+			continue
+		}
+		privfile := strings.TrimSuffix(file, ".key") + ".private"
+		f, err = os.Open(privfile)
+		if err != nil {
+			return nil, err
+		}
+		priv, err := dnskey.ReadPrivateKey(f, privfile)
+		f.Close()
+		if err != nil {
+			return nil, fmt.Errorf("can't read key %s: %w", privfile, err)
+		}
+		signer, ok := priv.(crypto.Signer)
+		if !ok {
+			return nil, fmt.Errorf("key %s can not sign", privfile)
+		}
+		dnskey.Hdr.Name = origin
+		keys = append(keys, &signingKey{dnskey: dnskey, priv: signer})
+	}
+	return keys, nil
+}
+
+// ensureKeys generates and saves the KSK and ZSK if the zone does not have them.
+func (s *zoneSigner) ensureKeys() error {
+	for _, flags := range []struct {
+		need  *bool
+		flags uint16
+	}{{&s.needKSK, dnsv1.ZONE | dnsv1.SEP}, {&s.needZSK, dnsv1.ZONE}} {
+		if !*flags.need {
+			continue
+		}
+		dnskey := &dnsv1.DNSKEY{
+			Hdr:       dnsv1.RR_Header{Name: s.origin, Rrtype: dnsv1.TypeDNSKEY, Class: dnsv1.ClassINET, Ttl: dnskeyTTL},
+			Flags:     flags.flags,
+			Protocol:  3,
+			Algorithm: s.algorithm,
+		}
+		priv, err := dnskey.Generate(s.bits)
+		if err != nil {
+			return fmt.Errorf("could not generate key: %w", err)
+		}
+		k := &signingKey{dnskey: dnskey, priv: priv.(crypto.Signer)}
+		if err := saveSigningKey(s.keydir, k); err != nil {
+			return err
+		}
+		s.keys = append(s.keys, k)
+		*flags.need = false
+	}
+	return nil
+}
+
+// saveSigningKey writes a key to keydir in the format of BIND's dnssec-keygen.
+func saveSigningKey(keydir string, k *signingKey) error {
+	if err := os.MkdirAll(keydir, 0o700); err != nil {
+		return err
+	}
+	base := filepath.Join(keydir, k.keyFileBase())
+	kind := "zone-signing"
+	if k.isKSK() {
+		kind = "key-signing"
+	}
+	public := fmt.Sprintf("; This is a %s key, keyid %d, for %s\n%s\n", kind, k.dnskey.KeyTag(), k.dnskey.Hdr.Name, k.dnskey.String())
+	if err := writeNewFile(base+".private", []byte(k.dnskey.PrivateKeyString(k.priv)), 0o600); err != nil {
+		return err
+	}
+	return writeNewFile(base+".key", []byte(public), 0o644)
+}
+
+// writeNewFile is like os.WriteFile but fails if the file exists.
+func writeNewFile(name string, data []byte, perm os.FileMode) error {
+	f, err := os.OpenFile(name, os.O_WRONLY|os.O_CREATE|os.O_EXCL, perm)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// isOwnKey returns true if rec is the DNSKEY of one of the zone's keys.
+func (s *zoneSigner) isOwnKey(rec *models.RecordConfig) bool {
+	return rec.Type == "DNSKEY" && slices.ContainsFunc(s.keys, func(k *signingKey) bool {
+		return rec.DnskeyFlags == k.dnskey.Flags && rec.DnskeyAlgorithm == k.dnskey.Algorithm && rec.DnskeyPublicKey == k.dnskey.PublicKey
+	})
+}
+
+// resignReason returns why the zone file (content) must be signed again, or
+// "" if it need not be.
+func (s *zoneSigner) resignReason(content string, now time.Time) string {
+	if s.needKSK || s.needZSK {
+		return "new keys"
+	}
+
+	var earliest time.Time
+	signers := map[uint16]bool{}
+	zp := dnsv1.NewZoneParser(strings.NewReader(content), s.origin, "")
+	for rr, ok := zp.Next(); ok; rr, ok = zp.Next() {
+		if sig, ok := rr.(*dnsv1.RRSIG); ok {
+			exp := time.Unix(int64(sig.Expiration), 0)
+			if earliest.IsZero() || exp.Before(earliest) {
+				earliest = exp
+			}
+			signers[sig.KeyTag] = true
+		}
+	}
+	switch {
+	case earliest.IsZero():
+		return "not signed"
+	case earliest.Before(now.Add(sigRefresh)):
+		return fmt.Sprintf("signatures expire %s", earliest.UTC().Format(time.DateOnly))
+	}
+	for _, k := range s.keys {
+		if !signers[k.dnskey.KeyTag()] {
+			return fmt.Sprintf("new key %d", k.dnskey.KeyTag())
+		}
+	}
+	if len(signers) != len(s.keys) {
+		return "removed key"
+	}
+	return ""
+}
+
+// sign returns the records that sign the zone (zonefile is the text of the
+// unsigned zone): the DNSKEYs, RRSIGs and NSEC (or NSEC3 and NSEC3PARAM)
+// records.
+func (s *zoneSigner) sign(zonefile []byte, now time.Time) ([]dnsv1.RR, error) {
+	if err := s.ensureKeys(); err != nil {
+		return nil, err
+	}
+
+	// The zone's RRsets, by name and type:
+	rrsets := map[string]map[uint16][]dnsv1.RR{}
+	add := func(rr dnsv1.RR) {
+		name := dnsv1.CanonicalName(rr.Header().Name)
+		rr.Header().Name = name
+		if rrsets[name] == nil {
+			rrsets[name] = map[uint16][]dnsv1.RR{}
+		}
+		rrsets[name][rr.Header().Rrtype] = append(rrsets[name][rr.Header().Rrtype], rr)
+	}
+	zp := dnsv1.NewZoneParser(bytes.NewReader(zonefile), s.origin, "")
+	for rr, ok := zp.Next(); ok; rr, ok = zp.Next() {
+		switch rr.Header().Rrtype {
+		case dnsv1.TypeRRSIG, dnsv1.TypeNSEC, dnsv1.TypeNSEC3, dnsv1.TypeNSEC3PARAM:
+			continue
+		}
+		add(rr)
+	}
+	if err := zp.Err(); err != nil {
+		return nil, err
+	}
+	soas := rrsets[s.origin][dnsv1.TypeSOA]
+	if len(soas) == 0 {
+		return nil, fmt.Errorf("zone %s has no SOA", s.origin)
+	}
+	soa := soas[0].(*dnsv1.SOA)
+	negativeTTL := min(soa.Minttl, soa.Hdr.Ttl)
+
+	var added []dnsv1.RR // What sign returns.
+
+	// Add the keys to the DNSKEY RRset (which may have other DNSKEYs too):
+	ttl := uint32(dnskeyTTL)
+	if others := rrsets[s.origin][dnsv1.TypeDNSKEY]; len(others) != 0 {
+		ttl = others[0].Header().Ttl
+	}
+	for _, k := range s.keys {
+		dnskey := dnsv1.Copy(k.dnskey).(*dnsv1.DNSKEY)
+		dnskey.Hdr.Ttl = ttl
+		if !slices.ContainsFunc(rrsets[s.origin][dnsv1.TypeDNSKEY], func(rr dnsv1.RR) bool { return dnsv1.IsDuplicate(rr, dnskey) }) {
+			add(dnskey)
+			added = append(added, dnskey)
+		}
+	}
+	if s.nsec3 {
+		param := &dnsv1.NSEC3PARAM{Hdr: dnsv1.RR_Header{Name: s.origin, Rrtype: dnsv1.TypeNSEC3PARAM, Class: dnsv1.ClassINET, Ttl: 0}, Hash: dnsv1.SHA1}
+		add(param)
+		added = append(added, param)
+	}
+
+	// The names in the zone, in canonical order. Names below a delegation
+	// (glue) are not authoritative. They are not signed or listed.
+	var names []string
+	delegations := map[string]bool{}
+	for name, types := range rrsets {
+		if _, ok := types[dnsv1.TypeNS]; ok && name != s.origin {
+			delegations[name] = true
+		}
+	}
+	for name := range rrsets {
+		if !dnsv1.IsSubDomain(s.origin, name) || belowDelegation(name, s.origin, delegations) {
+			continue
+		}
+		names = append(names, name)
+	}
+	slices.SortFunc(names, canonicalCompare)
+
+	// Which RRsets are signed, and the types listed by NSEC(3):
+	signed := func(name string, rrtype uint16) bool {
+		return !delegations[name] || rrtype == dnsv1.TypeDS || rrtype == dnsv1.TypeNSEC
+	}
+	typesAt := func(name string) []uint16 {
+		var types []uint16
+		for rrtype := range rrsets[name] {
+			if delegations[name] && rrtype != dnsv1.TypeNS && rrtype != dnsv1.TypeDS {
+				continue // Glue at the delegation point.
+			}
+			types = append(types, rrtype)
+		}
+		return types
+	}
+
+	// Authenticated denial of existence:
+	var denial []dnsv1.RR
+	if s.nsec3 {
+		denial = s.nsec3Chain(names, typesAt, signed, negativeTTL)
+	} else {
+		for i, name := range names {
+			types := append(typesAt(name), dnsv1.TypeRRSIG, dnsv1.TypeNSEC)
+			slices.Sort(types)
+			denial = append(denial, &dnsv1.NSEC{
+				Hdr:        dnsv1.RR_Header{Name: name, Rrtype: dnsv1.TypeNSEC, Class: dnsv1.ClassINET, Ttl: negativeTTL},
+				NextDomain: names[(i+1)%len(names)],
+				TypeBitMap: types,
+			})
+		}
+	}
+	for _, rr := range denial {
+		add(rr)
+	}
+	added = append(added, denial...)
+
+	// Sign every authoritative RRset:
+	inception, expiration := uint32(now.Add(-sigBackdate).Unix()), uint32(now.Add(sigValidity).Unix())
+	owners := names
+	for _, rr := range denial {
+		if rr.Header().Rrtype == dnsv1.TypeNSEC3 {
+			owners = append(owners, rr.Header().Name)
+		}
+	}
+	for _, name := range owners {
+		for _, rrtype := range slices.Sorted(maps.Keys(rrsets[name])) {
+			if !signed(name, rrtype) {
+				continue
+			}
+			rrset := rrsets[name][rrtype]
+			for _, k := range s.keys {
+				if k.isKSK() != (rrtype == dnsv1.TypeDNSKEY) {
+					continue // The KSKs sign the DNSKEY RRset. The ZSKs sign everything else.
+				}
+				sig := &dnsv1.RRSIG{
+					Hdr:        dnsv1.RR_Header{Ttl: rrset[0].Header().Ttl},
+					Algorithm:  k.dnskey.Algorithm,
+					KeyTag:     k.dnskey.KeyTag(),
+					SignerName: s.origin,
+					Inception:  inception,
+					Expiration: expiration,
+				}
+				if err := sig.Sign(k.priv, rrset); err != nil {
+					return nil, fmt.Errorf("could not sign %s %s: %w", name, dnsv1.TypeToString[rrtype], err)
+				}
+				added = append(added, sig)
+			}
+		}
+	}
+
+	slices.SortStableFunc(added, func(a, b dnsv1.RR) int {
+		return canonicalCompare(a.Header().Name, b.Header().Name)
+	})
+	return added, nil
+}
+
+// nsec3Chain returns the NSEC3 records of the zone (RFC 5155), without salt
+// or additional iterations (RFC 9276). Unlike NSEC, the empty non-terminals
+// are included.
+func (s *zoneSigner) nsec3Chain(names []string, typesAt func(string) []uint16, signed func(string, uint16) bool, ttl uint32) []dnsv1.RR {
+	type entry struct {
+		hash  string
+		types []uint16
+	}
+	entries := map[string]*entry{}
+	for _, name := range names {
+		types := typesAt(name)
+		if slices.ContainsFunc(types, func(rrtype uint16) bool { return signed(name, rrtype) }) {
+			types = append(types, dnsv1.TypeRRSIG)
+		}
+		slices.Sort(types)
+		entries[name] = &entry{types: types}
+		// Empty non-terminals:
+		for parent := name; parent != s.origin; {
+			i, _ := dnsv1.NextLabel(parent, 0)
+			parent = parent[i:]
+			if _, ok := entries[parent]; !ok {
+				entries[parent] = &entry{}
+			}
+		}
+	}
+	var hashed []*entry
+	for name, e := range entries {
+		e.hash = strings.ToLower(dnsv1.HashName(name, dnsv1.SHA1, 0, ""))
+		hashed = append(hashed, e)
+	}
+	slices.SortFunc(hashed, func(a, b *entry) int { return strings.Compare(a.hash, b.hash) })
+
+	var chain []dnsv1.RR
+	for i, e := range hashed {
+		chain = append(chain, &dnsv1.NSEC3{
+			Hdr:        dnsv1.RR_Header{Name: e.hash + "." + s.origin, Rrtype: dnsv1.TypeNSEC3, Class: dnsv1.ClassINET, Ttl: ttl},
+			Hash:       dnsv1.SHA1,
+			HashLength: 20,
+			NextDomain: strings.ToUpper(hashed[(i+1)%len(hashed)].hash),
+			TypeBitMap: e.types,
+		})
+	}
+	return chain
+}
+
+// belowDelegation returns true if name is below (not at) one of the
+// delegations of the zone origin.
+func belowDelegation(name, origin string, delegations map[string]bool) bool {
+	for name != origin {
+		i, end := dnsv1.NextLabel(name, 0)
+		if end {
+			return false
+		}
+		name = name[i:]
+		if delegations[name] {
+			return true
+		}
+	}
+	return false
+}
+
+// canonicalCompare compares two domain names in the canonical order of RFC
+// 4034 section 6.1: label by label, starting with the rightmost label.
+func canonicalCompare(a, b string) int {
+	la, lb := dnsv1.SplitDomainName(a), dnsv1.SplitDomainName(b)
+	for i := 1; i <= min(len(la), len(lb)); i++ {
+		if c := strings.Compare(strings.ToLower(la[len(la)-i]), strings.ToLower(lb[len(lb)-i])); c != 0 {
+			return c
+		}
+	}
+	return cmp.Compare(len(la), len(lb))
+}
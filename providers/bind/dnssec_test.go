@@ -0,0 +1,290 @@
+package bind
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DNSControl/dnscontrol/v4/models"
+	_ "github.com/DNSControl/dnscontrol/v4/pkg/rtype"
+	"github.com/DNSControl/dnscontrol/v4/pkg/zonerecs"
+	dnsv1 "github.com/miekg/dns"
+)
+
+const unsignedZone = `$TTL 300
+@        IN SOA   ns1.example.com. hostmaster.example.com. 2026101700 3600 600 604800 1440
+@        IN NS    ns1.example.com.
+@        IN A     1.2.3.4
+ns1      IN A     1.2.3.5
+www      IN A     1.2.3.6
+www      IN TXT   "hello"
+a.b.c    IN A     1.2.3.7
+sub      IN NS    ns.sub.example.com.
+sub      IN DS    12345 13 2 0123456789ABCDEF0123456789ABCDEF0123456789ABCDEF0123456789ABCDEF
+ns.sub   IN A     1.2.3.8
+`
+
+func TestCanonicalCompare(t *testing.T) {
+	// The example in RFC 4034 section 6.1:
+	want := []string{
+		"example.",
+		"a.example.",
+		"yljkjljk.a.example.",
+		"Z.a.example.",
+		"zABC.a.EXAMPLE.",
+		"z.example.",
+		"\\001.z.example.",
+		"*.z.example.",
+		"\\200.z.example.",
+	}
+	got := slices.Clone(want)
+	slices.Reverse(got)
+	slices.SortStableFunc(got, canonicalCompare)
+	// Escaped labels are compared as text, so only check the rest.
+	got = slices.DeleteFunc(got, func(s string) bool { return strings.HasPrefix(s, "\\") })
+	want = slices.DeleteFunc(want, func(s string) bool { return strings.HasPrefix(s, "\\") })
+	if !slices.Equal(got, want) {
+		t.Errorf("sorted = %v, want %v", got, want)
+	}
+}
+
+// signTestZone signs unsignedZone and returns the signer and the signed zone's RRsets.
+func signTestZone(t *testing.T, nsec3 bool, now time.Time) (*zoneSigner, map[string][]dnsv1.RR, []dnsv1.RR) {
+	t.Helper()
+	keydir := t.TempDir()
+	s, err := newZoneSigner(keydir, "example.com", "ECDSAP256SHA256", nsec3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !s.needKSK || !s.needZSK {
+		t.Fatalf("new zone has keys: %+v", s)
+	}
+	added, err := s.sign([]byte(unsignedZone), now)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The keys were saved and can be read back.
+	again, err := newZoneSigner(keydir, "example.com.", "ED25519", nsec3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if again.needKSK || again.needZSK || len(again.keys) != 2 || again.algorithm != dnsv1.ECDSAP256SHA256 {
+		t.Errorf("saved keys were not read back: %+v", again)
+	}
+
+	rrsets := map[string][]dnsv1.RR{}
+	zp := dnsv1.NewZoneParser(strings.NewReader(unsignedZone), "example.com.", "")
+	var all []dnsv1.RR
+	for rr, ok := zp.Next(); ok; rr, ok = zp.Next() {
+		all = append(all, rr)
+	}
+	all = append(all, added...)
+	for _, rr := range all {
+		if sig, ok := rr.(*dnsv1.RRSIG); ok {
+			k := strings.ToLower(sig.Hdr.Name) + " " + dnsv1.TypeToString[sig.TypeCovered] + " RRSIG"
+			rrsets[k] = append(rrsets[k], rr)
+			continue
+		}
+		k := strings.ToLower(rr.Header().Name) + " " + dnsv1.TypeToString[rr.Header().Rrtype]
+		rrsets[k] = append(rrsets[k], rr)
+	}
+	return s, rrsets, added
+}
+
+// verifySignatures checks that every signature in rrsets is valid, and
+// that exactly the RRsets in wantSigned are signed.
+func verifySignatures(t *testing.T, s *zoneSigner, rrsets map[string][]dnsv1.RR, wantSigned []string) {
+	t.Helper()
+	var signed []string
+	for k, sigs := range rrsets {
+		covered, ok := strings.CutSuffix(k, " RRSIG")
+		if !ok {
+			continue
+		}
+		signed = append(signed, covered)
+		for _, rr := range sigs {
+			sig := rr.(*dnsv1.RRSIG)
+			i := slices.IndexFunc(s.keys, func(k *signingKey) bool { return k.dnskey.KeyTag() == sig.KeyTag })
+			if i < 0 {
+				t.Errorf("%s: signed by unknown key %d", k, sig.KeyTag)
+				continue
+			}
+			if err := sig.Verify(s.keys[i].dnskey, rrsets[covered]); err != nil {
+				t.Errorf("%s: signature by key %d does not verify: %v", covered, sig.KeyTag, err)
+			}
+		}
+	}
+	slices.Sort(signed)
+	slices.Sort(wantSigned)
+	if !slices.Equal(signed, wantSigned) {
+		t.Errorf("signed RRsets =\n%s\nwant\n%s", strings.Join(signed, "\n"), strings.Join(wantSigned, "\n"))
+	}
+}
+
+func TestZoneSigner_NSEC(t *testing.T) {
+	s, rrsets, _ := signTestZone(t, false, time.Now())
+
+	verifySignatures(t, s, rrsets, []string{
+		"example.com. SOA", "example.com. NS", "example.com. A", "example.com. DNSKEY", "example.com. NSEC",
+		"ns1.example.com. A", "ns1.example.com. NSEC",
+		"www.example.com. A", "www.example.com. TXT", "www.example.com. NSEC",
+		"a.b.c.example.com. A", "a.b.c.example.com. NSEC",
+		"sub.example.com. DS", "sub.example.com. NSEC",
+	})
+
+	// The chain, in canonical order, without the glue:
+	chain := map[string]string{}
+	bitmaps := map[string][]uint16{}
+	for k, rrs := range rrsets {
+		if strings.HasSuffix(k, " NSEC") {
+			nsec := rrs[0].(*dnsv1.NSEC)
+			chain[nsec.Hdr.Name] = nsec.NextDomain
+			bitmaps[nsec.Hdr.Name] = nsec.TypeBitMap
+			if nsec.Hdr.Ttl != 300 {
+				t.Errorf("NSEC TTL = %d, want the negative TTL (300)", nsec.Hdr.Ttl)
+			}
+		}
+	}
+	want := map[string]string{
+		"example.com.":       "a.b.c.example.com.",
+		"a.b.c.example.com.": "ns1.example.com.",
+		"ns1.example.com.":   "sub.example.com.",
+		"sub.example.com.":   "www.example.com.",
+		"www.example.com.":   "example.com.",
+	}
+	if len(chain) != len(want) {
+		t.Errorf("NSEC chain = %v, want %v", chain, want)
+	}
+	for name, next := range want {
+		if chain[name] != next {
+			t.Errorf("NSEC %s -> %s, want %s", name, chain[name], next)
+		}
+	}
+	if got, want := bitmaps["sub.example.com."], []uint16{dnsv1.TypeNS, dnsv1.TypeDS, dnsv1.TypeRRSIG, dnsv1.TypeNSEC}; !slices.Equal(got, want) {
+		t.Errorf("delegation NSEC types = %v, want %v", got, want)
+	}
+}
+
+func TestZoneSigner_NSEC3(t *testing.T) {
+	s, rrsets, _ := signTestZone(t, true, time.Now())
+
+	var nsec3s []*dnsv1.NSEC3
+	var wantSigned []string
+	for k, rrs := range rrsets {
+		if strings.HasSuffix(k, " NSEC3") {
+			nsec3s = append(nsec3s, rrs[0].(*dnsv1.NSEC3))
+			wantSigned = append(wantSigned, k)
+		}
+	}
+	wantSigned = append(wantSigned,
+		"example.com. SOA", "example.com. NS", "example.com. A", "example.com. DNSKEY", "example.com. NSEC3PARAM",
+		"ns1.example.com. A",
+		"www.example.com. A", "www.example.com. TXT",
+		"a.b.c.example.com. A",
+		"sub.example.com. DS",
+	)
+	verifySignatures(t, s, rrsets, wantSigned)
+
+	// The authoritative names and the empty non-terminals (b.c, c), but not the glue:
+	names := []string{"example.com.", "ns1.example.com.", "www.example.com.", "a.b.c.example.com.", "b.c.example.com.", "c.example.com.", "sub.example.com."}
+	if len(nsec3s) != len(names) {
+		t.Fatalf("got %d NSEC3 records, want %d", len(nsec3s), len(names))
+	}
+	byHash := map[string]*dnsv1.NSEC3{}
+	for _, n := range nsec3s {
+		byHash[strings.ToUpper(strings.TrimSuffix(n.Hdr.Name, ".example.com."))] = n
+	}
+	for _, name := range names {
+		n, ok := byHash[dnsv1.HashName(name, dnsv1.SHA1, 0, "")]
+		if !ok {
+			t.Errorf("no NSEC3 for %s", name)
+			continue
+		}
+		if !n.Match(name) {
+			t.Errorf("NSEC3 %s does not match %s", n.Hdr.Name, name)
+		}
+	}
+	// The chain is a single loop.
+	seen := map[string]bool{}
+	hash := strings.ToUpper(strings.TrimSuffix(nsec3s[0].Hdr.Name, ".example.com."))
+	for range nsec3s {
+		seen[hash] = true
+		hash = byHash[hash].NextDomain
+	}
+	if len(seen) != len(nsec3s) {
+		t.Errorf("the NSEC3 chain visits %d of %d records", len(seen), len(nsec3s))
+	}
+}
+
+func TestZoneSigner_resignReason(t *testing.T) {
+	now := time.Date(2026, 10, 17, 0, 0, 0, 0, time.UTC)
+	s, _, added := signTestZone(t, false, now)
+	var signedZone strings.Builder
+	signedZone.WriteString(unsignedZone)
+	for _, rr := range added {
+		signedZone.WriteString(rr.String() + "\n")
+	}
+
+	tests := []struct {
+		name    string
+		content string
+		now     time.Time
+		want    string
+	}{
+		{"unsigned", unsignedZone, now, "not signed"},
+		{"fresh", signedZone.String(), now, ""},
+		{"expiring", signedZone.String(), now.Add(25 * 24 * time.Hour), "signatures expire 2026-11-16"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := s.resignReason(tt.content, tt.now); got != tt.want {
+				t.Errorf("resignReason() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+
+	s.keys = s.keys[:1] // A key was removed.
+	if got := s.resignReason(signedZone.String(), now); got != "removed key" {
+		t.Errorf("resignReason() = %q, want %q", got, "removed key")
+	}
+}
+
+func TestGetZoneRecordsCorrections_resign(t *testing.T) {
+	// The signatures expire soon.
+	s, _, added := signTestZone(t, false, time.Now().Add(-25*24*time.Hour))
+	var signedZone strings.Builder
+	signedZone.WriteString(unsignedZone)
+	for _, rr := range added {
+		signedZone.WriteString(rr.String() + "\n")
+	}
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "example.com.zone"), []byte(signedZone.String()), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	// Only the provider ignores the signatures. Other readers of zone
+	// files must not lose them silently.
+	if _, err := ParseZoneContents(signedZone.String(), "example.com", "example.com.zone"); err == nil {
+		t.Error("ParseZoneContents() of a signed zone returned no error")
+	}
+
+	c := &bindProvider{directory: dir, filenameformat: "%c.zone", keydirectory: s.keydir, algorithm: defaultDNSSECAlgorithm}
+	dc := models.MustNewDomainConfig("example.com")
+	dc.PostProcess()
+	dc.AutoDNSSEC = "on"
+	dc.Records, _ = ParseZoneContents(unsignedZone, "example.com", "")
+	zr, err := zonerecs.GetZoneResult(c, dc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(zr.Corrections) != 1 || !strings.Contains(zr.Corrections[0].Msg, "± SIGN example.com (signatures expire") {
+		t.Fatalf("corrections = %+v, want the zone to be signed again", zr.Corrections)
+	}
+	if zr.ChangesRecords() {
+		t.Error("signing the zone again is reported as a change to its records")
+	}
+}
@@ -0,0 +1,158 @@
+package bind
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/DNSControl/dnscontrol/v4/models"
+	"github.com/DNSControl/dnscontrol/v4/pkg/domaintags"
+	"github.com/DNSControl/dnscontrol/v4/pkg/printer"
+)
+
+// The server config fragment (creds.json "configfile") lists every zone of
+// the provider with the name of its zone file, in the format that the DNS
+// server includes in its configuration. It is rewritten whenever the list
+// changes, which removes the zones that are no longer in dnsconfig.js.
+
+// serverConfigFormat describes how to write (and read back) a config fragment.
+type serverConfigFormat struct {
+	stanza func(zone, file string) string // The config of one zone.
+	zoneRe *regexp.Regexp                 // Finds the zone names in a fragment.
+}
+
+var serverConfigFormats = map[string]serverConfigFormat{
+	"bind": {
+		stanza: func(zone, file string) string {
+			return fmt.Sprintf("zone %q {\n\ttype primary;\n\tfile %q;\n};\n", zone, file)
+		},
+		zoneRe: regexp.MustCompile(`(?m)^zone "([^"]+)"`),
+	},
+	"nsd": {
+		stanza: func(zone, file string) string {
+			return fmt.Sprintf("zone:\n\tname: %q\n\tzonefile: %q\n", zone, file)
+		},
+		zoneRe: regexp.MustCompile(`(?m)^\s*name: "([^"]+)"`),
+	},
+	"knot": {
+		stanza: func(zone, file string) string {
+			return fmt.Sprintf("zone:\n  - domain: %q\n    file: %q\n", zone, file)
+		},
+		zoneRe: regexp.MustCompile(`(?m)^\s*- domain: "([^"]+)"`),
+	},
+}
+
+const serverConfigHeader = "# Generated by dnscontrol. Do not edit. Changes will be overwritten.\n"
+
+// SetConfiguredZones records the zones that use the provider, which is the
+// list of zones in the server config fragment.
+func (c *bindProvider) SetConfiguredZones(zones []*models.DomainConfig) {
+	c.Lock()
+	defer c.Unlock()
+	c.configuredZones = zones
+}
+
+// makeServerConfig returns the config fragment that lists zones in format.
+// The file names are in zonedir.
+func makeServerConfig(format, zonedir, filenameformat string, zones []*models.DomainConfig) (string, error) {
+	f := serverConfigFormats[format]
+	sorted := slices.Clone(zones)
+	slices.SortFunc(sorted, func(a, b *models.DomainConfig) int { return strings.Compare(a.Name, b.Name) })
+
+	var b strings.Builder
+	b.WriteString(serverConfigHeader)
+	for i, dc := range sorted {
+		if i > 0 && sorted[i-1].Name == dc.Name {
+			return "", fmt.Errorf("zone %q is listed twice (%q and %q). Use a separate BIND provider for each view", dc.Name, sorted[i-1].UniqueName, dc.UniqueName)
+		}
+		file := filepath.Join(zonedir, makeFileName(filenameformat, domaintags.DomainNameVarieties{
+			Tag:         dc.Tag,
+			NameRaw:     dc.NameRaw,
+			NameASCII:   dc.Name,
+			NameUnicode: dc.NameUnicode,
+			UniqueName:  dc.UniqueName,
+		}))
+		b.WriteString("\n")
+		b.WriteString(f.stanza(dc.Name, file))
+	}
+	return b.String(), nil
+}
+
+// serverConfigCorrections returns the correction that updates the server
+// config fragment, if it is out of date. Only the first zone to ask gets it.
+func (c *bindProvider) serverConfigCorrections() ([]*models.Correction, error) {
+	c.Lock()
+	defer c.Unlock()
+	if c.configfile == "" || c.configuredZones == nil || c.configClaimed {
+		return nil, nil
+	}
+	c.configClaimed = true
+
+	zonedir := c.configzonedir
+	if zonedir == "" {
+		var err error
+		if zonedir, err = filepath.Abs(c.directory); err != nil {
+			return nil, err
+		}
+	}
+	zones := c.configuredZones
+	if c.catalog != "" {
+		dc, err := models.NewDomainConfig(c.catalog)
+		if err != nil {
+			return nil, err
+		}
+		zones = append(slices.Clone(zones), dc)
+	}
+	content, err := makeServerConfig(c.configformat, zonedir, c.filenameformat, zones)
+	if err != nil {
+		return nil, fmt.Errorf("configfile %q: %w", c.configfile, err)
+	}
+
+	old, err := os.ReadFile(c.configfile)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, fmt.Errorf("can't read %s: %w", c.configfile, err)
+	}
+	if bytes.Equal(old, []byte(content)) {
+		return nil, nil
+	}
+
+	// Describe the change in terms of zones:
+	re := serverConfigFormats[c.configformat].zoneRe
+	oldZones, newZones := map[string]bool{}, map[string]bool{}
+	for _, m := range re.FindAllStringSubmatch(string(old), -1) {
+		oldZones[m[1]] = true
+	}
+	for _, m := range re.FindAllStringSubmatch(content, -1) {
+		newZones[m[1]] = true
+	}
+	msgs := []string{fmt.Sprintf("WRITE %s config %s (%d zones)", c.configformat, c.configfile, len(newZones))}
+	for _, dc := range zones {
+		if !oldZones[dc.Name] {
+			msgs = append(msgs, fmt.Sprintf("+ ADD ZONE %s", dc.Name))
+		}
+	}
+	for zone := range oldZones {
+		if !newZones[zone] {
+			msgs = append(msgs, fmt.Sprintf("- REMOVE ZONE %s", zone))
+		}
+	}
+	slices.Sort(msgs[1:])
+
+	configfile := c.configfile
+	return []*models.Correction{{
+		Msg:       strings.Join(msgs, "\n"),
+		NonRecord: true,
+		F: func() error {
+			printer.Printf("WRITING CONFIG: %v\n", configfile)
+			fname, err := preprocessFilename(configfile)
+			if err != nil {
+				return fmt.Errorf("could not create config: %w", err)
+			}
+			return os.WriteFile(fname, []byte(content), 0o644)
+		},
+	}}, nil
+}
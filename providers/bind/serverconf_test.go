@@ -0,0 +1,131 @@
+package bind
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/DNSControl/dnscontrol/v4/models"
+)
+
+func testZones(names ...string) []*models.DomainConfig {
+	var zones []*models.DomainConfig
+	for _, name := range names {
+		dc := &models.DomainConfig{}
+		dc.PopulateNamesFromRaw(name)
+		zones = append(zones, dc)
+	}
+	return zones
+}
+
+func TestMakeServerConfig(t *testing.T) {
+	zones := testZones("example.org", "example.com!inside")
+	tests := []struct {
+		format string
+		want   string
+	}{
+		{"bind", `zone "example.com" {
+	type primary;
+	file "/var/zones/example.com!inside.zone";
+};
+
+zone "example.org" {
+	type primary;
+	file "/var/zones/example.org.zone";
+};
+`},
+		{"nsd", `zone:
+	name: "example.com"
+	zonefile: "/var/zones/example.com!inside.zone"
+
+zone:
+	name: "example.org"
+	zonefile: "/var/zones/example.org.zone"
+`},
+		{"knot", `zone:
+  - domain: "example.com"
+    file: "/var/zones/example.com!inside.zone"
+
+zone:
+  - domain: "example.org"
+    file: "/var/zones/example.org.zone"
+`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.format, func(t *testing.T) {
+			got, err := makeServerConfig(tt.format, "/var/zones", "%c.zone", zones)
+			if err != nil {
+				t.Fatal(err)
+			}
+			want := serverConfigHeader + "\n" + tt.want
+			if got != want {
+				t.Errorf("makeServerConfig() =\n%s\nwant\n%s", got, want)
+			}
+		})
+	}
+}
+
+func TestMakeServerConfig_duplicate(t *testing.T) {
+	_, err := makeServerConfig("bind", "/var/zones", "%c.zone", testZones("example.com!inside", "example.com!outside"))
+	if err == nil || !strings.Contains(err.Error(), "listed twice") {
+		t.Errorf("makeServerConfig() error = %v, want a duplicate error", err)
+	}
+}
+
+func TestServerConfigCorrections(t *testing.T) {
+	dir := t.TempDir()
+	configfile := filepath.Join(dir, "named.conf.zones")
+	newProvider := func(zones ...string) *bindProvider {
+		c := &bindProvider{directory: dir, filenameformat: "%c.zone", configfile: configfile, configformat: "bind", configzonedir: "/var/zones"}
+		c.SetConfiguredZones(testZones(zones...))
+		return c
+	}
+
+	// The first run adds every zone.
+	c := newProvider("example.com", "old.com")
+	corrections, err := c.serverConfigCorrections()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(corrections) != 1 {
+		t.Fatalf("got %d corrections, want 1", len(corrections))
+	}
+	if want := "WRITE bind config " + configfile + " (2 zones)\n+ ADD ZONE example.com\n+ ADD ZONE old.com"; corrections[0].Msg != want {
+		t.Errorf("Msg = %q, want %q", corrections[0].Msg, want)
+	}
+	if err := corrections[0].F(); err != nil {
+		t.Fatal(err)
+	}
+	// Only one zone's corrections update the config.
+	if again, _ := c.serverConfigCorrections(); len(again) != 0 {
+		t.Errorf("second call returned %d corrections, want 0", len(again))
+	}
+
+	// Nothing changed.
+	if corrections, _ := newProvider("old.com", "example.com").serverConfigCorrections(); len(corrections) != 0 {
+		t.Errorf("unchanged config returned %d corrections, want 0", len(corrections))
+	}
+
+	// A zone is removed from dnsconfig.js and another added.
+	corrections, err = newProvider("example.com", "new.com").serverConfigCorrections()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(corrections) != 1 {
+		t.Fatalf("got %d corrections, want 1", len(corrections))
+	}
+	if want := "WRITE bind config " + configfile + " (2 zones)\n+ ADD ZONE new.com\n- REMOVE ZONE old.com"; corrections[0].Msg != want {
+		t.Errorf("Msg = %q, want %q", corrections[0].Msg, want)
+	}
+	if err := corrections[0].F(); err != nil {
+		t.Fatal(err)
+	}
+	b, err := os.ReadFile(configfile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(b), "old.com") || !strings.Contains(string(b), `file "/var/zones/new.com.zone";`) {
+		t.Errorf("config file is wrong:\n%s", b)
+	}
+}
@@ -262,3 +262,24 @@ func (c *desecProvider) GetZoneRecordsCorrections(dc *models.DomainConfig, exist
 func (c *desecProvider) ListZones() ([]string, error) {
 	return c.listDomainIndex()
 }
+
+// ListKeySigningKeys returns the keys that sign the DNSKEY RRset of the
+// zone. deSEC signs every zone.
+func (c *desecProvider) ListKeySigningKeys(dc *models.DomainConfig) ([]*models.RecordConfig, error) {
+	dm, err := c.getDomain(dc.Name)
+	if err != nil {
+		return nil, err
+	}
+	var keys []*models.RecordConfig
+	for _, key := range dm.Keys {
+		rc := &models.RecordConfig{Type: "DNSKEY", Metadata: map[string]string{}}
+		rc.SetLabel("@", dc.Name)
+		if err := rc.SetTargetDNSKEYString(key.Dnskey); err != nil {
+			return nil, err
+		}
+		if rc.DnskeyFlags&1 != 0 { // SEP
+			keys = append(keys, rc)
+		}
+	}
+	return keys, nil
+}
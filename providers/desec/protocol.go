@@ -423,3 +423,16 @@ retry:
 	// time.Sleep(334 * time.Millisecond)
 	return bodyString, nil
 }
+
+// getDomain returns the domain, with its DNSSEC keys.
+func (c *desecProvider) getDomain(domain string) (*domainObject, error) {
+	bodyString, _, err := c.get(fmt.Sprintf("/domains/%s/", domain), "GET")
+	if err != nil {
+		return nil, fmt.Errorf("failed fetching domain %s (deSEC): %w", domain, err)
+	}
+	dm := &domainObject{}
+	if err := json.Unmarshal(bodyString, dm); err != nil {
+		return nil, err
+	}
+	return dm, nil
+}
@@ -0,0 +1,87 @@
+package dnsimple
+
+import (
+	"context"
+	"errors"
+	"strconv"
+
+	"github.com/DNSControl/dnscontrol/v4/models"
+	"github.com/DNSControl/dnscontrol/v4/pkg/dssync"
+	dnsimpleapi "github.com/dnsimple/dnsimple-go/v8/dnsimple"
+)
+
+// GetRegistrarDS returns the DS records of the domain at the registry.
+func (c *dnsimpleProvider) GetRegistrarDS(dc *models.DomainConfig) ([]*models.RecordConfig, error) {
+	client := c.getClient()
+
+	accountID, err := c.getAccountID()
+	if err != nil {
+		return nil, wrapError(err)
+	}
+
+	var dsRecords []*models.RecordConfig
+	opts := &dnsimpleapi.ListOptions{}
+	page := 1
+	for {
+		opts.Page = &page
+		dsResponse, err := client.Domains.ListDelegationSignerRecords(context.Background(), accountID, dc.Name, opts)
+		if err != nil {
+			return nil, wrapError(err)
+		}
+		for _, r := range dsResponse.Data {
+			rc := &models.RecordConfig{
+				Type:     "DS",
+				Metadata: map[string]string{dssync.MetaCreated: r.CreatedAt},
+				Original: &r,
+			}
+			rc.SetLabel("@", dc.Name)
+			if err := rc.SetTargetDSStrings(r.Keytag, r.Algorithm, r.DigestType, r.Digest); err != nil {
+				return nil, err
+			}
+			dsRecords = append(dsRecords, rc)
+		}
+		pg := dsResponse.Pagination
+		if pg == nil || pg.CurrentPage >= pg.TotalPages {
+			break
+		}
+		page++
+	}
+	return dsRecords, nil
+}
+
+// AddRegistrarDS adds a DS record of the domain at the registry.
+func (c *dnsimpleProvider) AddRegistrarDS(dc *models.DomainConfig, ds *models.RecordConfig) error {
+	client := c.getClient()
+
+	accountID, err := c.getAccountID()
+	if err != nil {
+		return wrapError(err)
+	}
+
+	_, err = client.Domains.CreateDelegationSignerRecord(context.Background(), accountID, dc.Name, dnsimpleapi.DelegationSignerRecord{
+		Algorithm:  strconv.Itoa(int(ds.DsAlgorithm)),
+		Digest:     ds.DsDigest,
+		DigestType: strconv.Itoa(int(ds.DsDigestType)),
+		Keytag:     strconv.Itoa(int(ds.DsKeyTag)),
+	})
+	return wrapError(err)
+}
+
+// RemoveRegistrarDS removes a DS record (returned by GetRegistrarDS) of the
+// domain at the registry.
+func (c *dnsimpleProvider) RemoveRegistrarDS(dc *models.DomainConfig, ds *models.RecordConfig) error {
+	r, ok := ds.Original.(*dnsimpleapi.DelegationSignerRecord)
+	if !ok {
+		return errors.New("not a DS record from DNSimple")
+	}
+
+	client := c.getClient()
+
+	accountID, err := c.getAccountID()
+	if err != nil {
+		return wrapError(err)
+	}
+
+	_, err = client.Domains.DeleteDelegationSignerRecord(context.Background(), accountID, dc.Name, r.ID)
+	return wrapError(err)
+}
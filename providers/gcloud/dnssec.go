@@ -1,12 +1,14 @@
 package gcloud
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"strings"
 	"time"
 
 	"github.com/DNSControl/dnscontrol/v4/models"
+	dnsv1 "github.com/miekg/dns"
 	gdns "google.golang.org/api/dns/v1"
 )
 
@@ -219,3 +221,35 @@ func (g *gcloudProvider) updateDnssecFromMetadata(domain string, desired *gdns.M
 	}
 	return nil
 }
+
+// ListKeySigningKeys returns the key-signing keys of the zone, including
+// the keys that are not active.
+func (g *gcloudProvider) ListKeySigningKeys(dc *models.DomainConfig) ([]*models.RecordConfig, error) {
+	zone, err := g.getZone(dc.Name)
+	if err != nil {
+		return nil, err
+	}
+	if zone == nil || zone.DnssecConfig == nil || zone.DnssecConfig.State == "off" {
+		return nil, nil
+	}
+	var keys []*models.RecordConfig
+	err = g.client.DnsKeys.List(g.project, zone.Name).Pages(context.Background(), func(resp *gdns.DnsKeysListResponse) error {
+		for _, k := range resp.DnsKeys {
+			if k.Type != "keySigning" {
+				continue
+			}
+			algorithm, ok := dnsv1.StringToAlgorithm[strings.ToUpper(k.Algorithm)]
+			if !ok {
+				return fmt.Errorf("key %d: unknown algorithm %q", k.KeyTag, k.Algorithm)
+			}
+			rc := &models.RecordConfig{Type: "DNSKEY", Metadata: map[string]string{}}
+			rc.SetLabel("@", dc.Name)
+			if err := rc.SetTargetDNSKEY(dnsv1.ZONE|dnsv1.SEP, 3, algorithm, k.PublicKey); err != nil {
+				return err
+			}
+			keys = append(keys, rc)
+		}
+		return nil
+	})
+	return keys, err
+}
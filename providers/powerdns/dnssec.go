@@ -2,6 +2,7 @@ package powerdns
 
 import (
 	"context"
+	"fmt"
 
 	"github.com/DNSControl/dnscontrol/v4/models"
 	"github.com/mittwald/go-powerdns/apis/cryptokeys"
@@ -70,3 +71,32 @@ func (dsp *powerdnsProvider) getDNSSECCorrections(dc *models.DomainConfig) ([]*m
 
 	return nil, nil
 }
+
+// ListKeySigningKeys returns the keys that sign the DNSKEY RRset of the zone
+// (KSKs and CSKs), including keys that are published but not active yet.
+func (dsp *powerdnsProvider) ListKeySigningKeys(dc *models.DomainConfig) ([]*models.RecordConfig, error) {
+	zoneCryptokeys, err := dsp.client.Cryptokeys().ListCryptokeys(context.Background(), dsp.ServerName, dsp.zoneName(dc.Name, dc.Tag))
+	if err != nil {
+		if pdnshttp.IsNotFound(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	var keys []*models.RecordConfig
+	for _, cryptoKey := range zoneCryptokeys {
+		if !cryptoKey.Active && !cryptoKey.Published {
+			continue
+		}
+		if cryptoKey.KeyType != "ksk" && cryptoKey.KeyType != "csk" {
+			continue
+		}
+		rc := &models.RecordConfig{Type: "DNSKEY", Metadata: map[string]string{}}
+		rc.SetLabel("@", dc.Name)
+		if err := rc.SetTargetDNSKEYString(cryptoKey.DNSKey); err != nil {
+			return nil, fmt.Errorf("cryptokey %d: %w", cryptoKey.ID, err)
+		}
+		keys = append(keys, rc)
+	}
+	return keys, nil
+}